@@ -2,7 +2,12 @@ package main
 
 import (
 	"context"
+	"errors"
+	"net/http"
 	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
 	"github.com/rkapps/fin-tracker-backend-go/cmd/common"
 	logger "github.com/rkapps/fin-tracker-backend-go/cmd/common/logger"
@@ -16,6 +21,9 @@ import (
 	"github.com/rkapps/storage-backend-go/migrations"
 )
 
+// shutdownTimeout bounds how long in-flight requests get to finish after SIGTERM.
+const shutdownTimeout = 10 * time.Second
+
 func main() {
 
 	//Set logger
@@ -56,10 +64,20 @@ func main() {
 		mlog.Error("GetApiApp", "error", err)
 		os.Exit(1)
 	}
-	err = migrations.RunMigrations(apiApp.Database)
-	if err != nil {
-		mlog.Error("RunMigrations", "error", err)
-		os.Exit(1)
+	// STORAGE=memory has no database to migrate
+	if apiApp.Database != nil {
+		err = migrations.RunMigrations(apiApp.Database)
+		if err != nil {
+			mlog.Error("RunMigrations", "error", err)
+			os.Exit(1)
+		}
+	}
+
+	// deletions of a process that was killed are left running — fail them so they can be requested again
+	if failed, err := apiApp.DeletionService.FailInterrupted(context.Background()); err != nil {
+		mlog.Error("FailInterrupted", "error", err)
+	} else if failed > 0 {
+		mlog.Warn("FailInterrupted", "Receipts", failed)
 	}
 
 	router := gin.New()
@@ -69,9 +87,11 @@ func main() {
 			"https://fin-tracker-rkapps.web.app",
 		},
 		AllowMethods:     []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"},
-		AllowHeaders:     []string{"Origin", "Content-Type", "Authorization", "Accept"},
+		AllowHeaders:     []string{"Origin", "Content-Type", "Authorization", "Accept", handlers.RequestIDHeader},
+		ExposeHeaders:    []string{"X-Next-Cursor", handlers.RequestIDHeader},
 		AllowCredentials: true,
 	}))
+	router.Use(handlers.RequestID())
 	router.SetTrustedProxies(nil)
 
 	//Portfolio handler
@@ -79,20 +99,48 @@ func main() {
 	portfolioHanlder.RegisterRoutes(router, fbAuthClient)
 
 	// accounts handler
-	accountsHandler := handlers.NewAccountsHandler(router, apiApp.AccountsService)
+	accountsHandler := handlers.NewAccountsHandler(router, apiApp.AccountsService, apiApp.DeletionService)
 	accountsHandler.RegisterRoutes(router, fbAuthClient)
 
+	// account credentials handler
+	credentialsHandler := handlers.NewCredentialsHandler(router, apiApp.CredentialsService)
+	credentialsHandler.RegisterRoutes(router, fbAuthClient)
+
 	transactionsHandler := handlers.NewTransactionsHandler(router, apiApp.TransactionsService)
 	transactionsHandler.RegisterRoutes(router, fbAuthClient)
 
-	userHandler := handlers.NewUserHandler(router, apiApp.UserService)
+	userHandler := handlers.NewUserHandler(router, apiApp.UserService, apiApp.ArchiveService, apiApp.DeletionService)
 	userHandler.RegisterRoutes(router, fbAuthClient)
 
+	// admin handler — restricted to ADMIN_UIDS
+	adminHandler := handlers.NewAdminHandler(router, apiApp.PipelineRunsService)
+	adminHandler.RegisterRoutes(router, fbAuthClient)
+
 	port := os.Getenv("PORT")
 	if port == "" {
 		port = "8080" // fallback for local dev
 	}
-	mlog.Info("Server", "Listening on port", port)
-	router.Run(":" + port)
-
+	// Cancels on SIGTERM — Cloud Run sends this before killing the container
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	server := &http.Server{Addr: ":" + port, Handler: router}
+	go func() {
+		mlog.Info("Server", "Listening on port", port)
+		if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			mlog.Error("ListenAndServe", "error", err)
+			os.Exit(1)
+		}
+	}()
+	<-ctx.Done()
+
+	// stop taking requests, let the in-flight ones finish, then wait for the deletions they started
+	mlog.Info("Server", "Status", "shutting down")
+	sctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	if err := server.Shutdown(sctx); err != nil {
+		mlog.Error("Shutdown", "error", err)
+	}
+	apiApp.DeletionService.Wait()
+	mlog.Info("Server", "Status", "stopped")
 }
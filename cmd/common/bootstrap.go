@@ -1,12 +1,19 @@
 package common
 
 import (
+	"context"
+	"database/sql"
+	"errors"
 	"log/slog"
 	"os"
 
 	"github.com/rkapps/fin-tracker-backend-go/cmd/common/logger"
+	"github.com/rkapps/fin-tracker-backend-go/internal/secrets"
 	"github.com/rkapps/fin-tracker-backend-go/internal/services"
+	"github.com/rkapps/fin-tracker-backend-go/internal/storage"
+	"github.com/rkapps/fin-tracker-backend-go/internal/storage/memory"
 	"github.com/rkapps/fin-tracker-backend-go/internal/storage/mongo"
+	"github.com/rkapps/fin-tracker-backend-go/internal/storage/postgres"
 	"github.com/rkapps/storage-backend-go/mongodb"
 )
 
@@ -14,67 +21,122 @@ type ApiApp struct {
 	Database            *mongodb.MongoDatabase
 	UserService         services.UserService
 	AccountsService     services.AccountsService
+	CredentialsService  services.CredentialsService
 	TransactionsService services.TransactionsService
 	PortfolioService    services.PortfolioService
+	PipelineRunsService services.PipelineRunsService
+	ArchiveService      services.ArchiveService
+	DeletionService     services.DeletionService
 }
 
 type PipelineApp struct {
-	Database         *mongodb.MongoDatabase
-	UserService      services.UserService
-	PortfolioService services.PortfolioService
+	Database            *mongodb.MongoDatabase
+	UserService         services.UserService
+	CredentialsService  services.CredentialsService
+	PortfolioService    services.PortfolioService
+	PipelineRunsService services.PipelineRunsService
+	ArchiveService      services.ArchiveService
 }
 
 func GetApiApp(trackerDbName string, financeDbName string, logConfig *logger.Config) (ApiApp, error) {
 
-	uri := os.Getenv("FINTRACKER_MONGO_URI")
-	database, err := getMongoDb(uri, trackerDbName)
+	database, storage, tstorage, err := getStorage(trackerDbName, financeDbName, logConfig)
 	if err != nil {
 		return ApiApp{}, err
 	}
-	// Create storeage
-	storage := mongo.NewFinTrackerMongoStorage(database)
-	accountsService := services.NewAccountsService(storage)
-	userService := services.NewUserService(storage)
-	transactionsService := services.NewTransactionsService(storage)
-
-	uri = os.Getenv("FINANCE_MONGO_URI")
-	database, err = getMongoDb(uri, financeDbName)
+	cipher, err := getCipher(logConfig)
 	if err != nil {
 		return ApiApp{}, err
 	}
-	// create ticker storage
-	tstorage := mongo.NewTickerMongoStorage(database)
+	accountsService := services.NewAccountsService(logConfig, storage)
+	credentialsService := services.NewCredentialsService(logConfig, storage, cipher)
+	userService := services.NewUserService(logConfig, storage)
+	transactionsService := services.NewTransactionsService(logConfig, storage)
+	pipelineRunsService := services.NewPipelineRunsService(logConfig, storage)
 	tickersService := services.NewStocksService(tstorage)
-	portfolioService := services.NewPortfolioService(logConfig, tickersService, storage)
+	portfolioService := services.NewPortfolioService(logConfig, tickersService, storage, cipher)
+	archiveService := services.NewArchiveService(logConfig, storage)
+	deletionService := services.NewDeletionService(logConfig, storage)
 
 	return ApiApp{Database: database, UserService: userService,
-		AccountsService:     accountsService,
+		AccountsService: accountsService, CredentialsService: credentialsService,
 		TransactionsService: transactionsService, PortfolioService: portfolioService,
+		PipelineRunsService: pipelineRunsService, ArchiveService: archiveService,
+		DeletionService: deletionService,
 	}, nil
 }
 
 func GetPipelineApp(trackerDbName string, financeDbName string, logConfig *logger.Config) (PipelineApp, error) {
 
-	uri := os.Getenv("FINTRACKER_MONGO_URI")
-	database, err := getMongoDb(uri, trackerDbName)
+	database, storage, tstorage, err := getStorage(trackerDbName, financeDbName, logConfig)
 	if err != nil {
 		return PipelineApp{}, err
 	}
-	// Create storeage
-	storage := mongo.NewFinTrackerMongoStorage(database)
-	userService := services.NewUserService(storage)
-
-	uri = os.Getenv("FINANCE_MONGO_URI")
-	database, err = getMongoDb(uri, financeDbName)
+	cipher, err := getCipher(logConfig)
 	if err != nil {
 		return PipelineApp{}, err
 	}
+	userService := services.NewUserService(logConfig, storage)
+	credentialsService := services.NewCredentialsService(logConfig, storage, cipher)
+	pipelineRunsService := services.NewPipelineRunsService(logConfig, storage)
+	tickersService := services.NewStocksService(tstorage)
+	portfolioService := services.NewPortfolioService(logConfig, tickersService, storage, cipher)
+	archiveService := services.NewArchiveService(logConfig, storage)
+
+	return PipelineApp{Database: database, UserService: userService, CredentialsService: credentialsService,
+		PortfolioService: portfolioService, PipelineRunsService: pipelineRunsService, ArchiveService: archiveService}, nil
+}
+
+// getStorage connects to the tracker and finance databases. With STORAGE=memory
+// nothing is connected — the data lives in process and the database is nil.
+// With STORAGE=postgres the tracker data is kept in FINTRACKER_POSTGRES_DSN, migrated
+// on connect, and the tickers are still read from the finance Mongo database.
+func getStorage(trackerDbName string, financeDbName string, logConfig *logger.Config) (*mongodb.MongoDatabase, storage.FinTrackerStorageService, storage.TickerStorageService, error) {
+
+	var fstorage storage.FinTrackerStorageService
+	switch os.Getenv("STORAGE") {
+	case "memory":
+		logConfig.For("bootstrap").Warn("getStorage", "Storage", "memory", "Persisted", false)
+		return nil, memory.NewFinTrackerMemoryStorage(), memory.NewTickerMemoryStorage(), nil
+	case "postgres":
+		db, err := getPostgresDb(os.Getenv("FINTRACKER_POSTGRES_DSN"))
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		fstorage = postgres.NewFinTrackerPostgresStorage(db)
+	default:
+		uri := os.Getenv("FINTRACKER_MONGO_URI")
+		database, err := getMongoDb(uri, trackerDbName)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		db, err := mongo.NewDriverDatabase(uri, trackerDbName)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		// Create storeage
+		fstorage = mongo.NewFinTrackerMongoStorage(database, db)
+	}
+
+	uri := os.Getenv("FINANCE_MONGO_URI")
+	database, err := getMongoDb(uri, financeDbName)
+	if err != nil {
+		return nil, nil, nil, err
+	}
 	// create ticker storage
 	tstorage := mongo.NewTickerMongoStorage(database)
-	tickersService := services.NewStocksService(tstorage)
-	portfolioService := services.NewPortfolioService(logConfig, tickersService, storage)
+	return database, fstorage, tstorage, nil
+}
 
-	return PipelineApp{Database: database, UserService: userService, PortfolioService: portfolioService}, nil
+// getCipher loads the credential master key. Without one the app runs but
+// credentials cannot be saved or used.
+func getCipher(logConfig *logger.Config) (*secrets.Cipher, error) {
+	cipher, err := secrets.NewCipherFromEnv()
+	if errors.Is(err, secrets.ErrNoMasterKey) {
+		logConfig.For("bootstrap").Warn("getCipher", "Error", err)
+		return nil, nil
+	}
+	return cipher, err
 }
 
 func getMongoDb(uri string, dbname string) (*mongodb.MongoDatabase, error) {
@@ -82,3 +144,18 @@ func getMongoDb(uri string, dbname string) (*mongodb.MongoDatabase, error) {
 	reg := mongodb.GetBsonRegistryForDecimal()
 	return mongodb.NewMongoDatabaseWithRegistry(uri, dbname, reg)
 }
+
+// getPostgresDb connects and applies the schema migrations — instances starting
+// together wait on the migration lock.
+func getPostgresDb(dsn string) (*sql.DB, error) {
+	ctx := context.Background()
+	db, err := postgres.Open(ctx, dsn)
+	if err != nil {
+		return nil, err
+	}
+	if err := postgres.Migrate(ctx, db); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return db, nil
+}
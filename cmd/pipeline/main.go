@@ -2,15 +2,20 @@ package main
 
 import (
 	"context"
-	"flag"
+	"fmt"
 	"log"
 	"os"
 	"os/signal"
+	"strconv"
 	"syscall"
+	"time"
 
 	"github.com/rkapps/fin-tracker-backend-go/cmd/common"
 	logger "github.com/rkapps/fin-tracker-backend-go/cmd/common/logger"
+	"github.com/rkapps/fin-tracker-backend-go/internal/domain"
 	"github.com/rkapps/fin-tracker-backend-go/internal/pipeline"
+	"github.com/rkapps/fin-tracker-backend-go/internal/portfolio"
+	"github.com/rkapps/fin-tracker-backend-go/internal/services"
 )
 
 func main() {
@@ -19,10 +24,19 @@ func main() {
 	plog := logConfig.For("pipeline")
 	plog.Logger.Info("pipeline", "LogLevel", logConfig)
 
-	flag.Parse()
-
 	if len(os.Args) < 2 {
-		log.Fatal("Usage: pipeline <command> [args]")
+		log.Fatal("Usage: pipeline <command> [flags] [args]")
+	}
+	command := os.Args[1]
+	switch command {
+	case "sync-all", "refresh-all", "sync-user", "refresh-user", "serve", "runs", "reencrypt-credentials",
+		"export-user", "import-user", "backfill-user":
+	default:
+		log.Fatalf("Unknown command: %s", command)
+	}
+	opts, positional, err := parseOptions(command, os.Args[2:])
+	if err != nil {
+		log.Fatal(err)
 	}
 
 	finTrackerDbName := os.Getenv("FINTRACKER_DB_NAME")
@@ -42,86 +56,427 @@ func main() {
 		log.Fatal(err)
 	}
 
-	plog.Info("Pipeline run", "Args", os.Args[1])
+	plog.Info("Pipeline run", "Command", command, "Workers", opts.workers, "Users", len(opts.users),
+		"Categories", opts.categories, "Since", opts.since, "DryRun", opts.dryRun)
 
 	// Cancels on SIGTERM — Cloud Run sends this before killing the container
 	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
 	defer stop()
 
-	// TODO: read worker count from env (e.g. os.Getenv("WORKER_COUNT"))
-	workerCount := 10
+	// exit code policy — by default any failed job fails the run
+	policy, err := failurePolicy()
+	if err != nil {
+		log.Fatal(err)
+	}
+	retry, err := retryPolicy()
+	if err != nil {
+		log.Fatal(err)
+	}
+	// in-flight jobs get this long to finish after SIGTERM
+	drain, err := envDuration("PIPELINE_DRAIN_TIMEOUT", 10*time.Second)
+	if err != nil {
+		log.Fatal(err)
+	}
 
-	syncPipeline := pipeline.NewPipeline(
-		workerCount,
-		func(ctx context.Context, job pipeline.SyncAccountsJob) error {
-			return pipelineApp.PortfolioService.SyncUserAccounts(ctx, job.UserID)
-		},
-		func(ctx context.Context) ([]pipeline.SyncAccountsJob, error) {
-			users := pipelineApp.UserService.GetUsers()
-			// map users to jobs — one job per user
-			jobs := make([]pipeline.SyncAccountsJob, len(users))
-			for i, user := range users {
-				jobs[i] = pipeline.SyncAccountsJob{UserID: user.ID}
+	// job counters for both pipelines — scraped in serve mode, written at the end of a single run
+	metrics := pipeline.NewMetrics()
+
+	// one sync job per selected user
+	fetchSyncJobs := func(ctx context.Context) ([]pipeline.SyncAccountsJob, error) {
+		allUsers, err := pipelineApp.UserService.GetUsers(ctx)
+		if err != nil {
+			return nil, err
+		}
+		users, unknown := selectUsers(allUsers, opts.users)
+		if len(unknown) > 0 {
+			plog.Warn("sync-all", "Unknown users", unknown)
+		}
+		jobs := make([]pipeline.SyncAccountsJob, len(users))
+		for i, user := range users {
+			jobs[i] = pipeline.SyncAccountsJob{UserID: user.ID, Categories: opts.categories, Since: opts.since}
+		}
+		return jobs, nil
+	}
+
+	// only users flagged by a sync or import unless --all is passed
+	fetchRefreshJobs := func(ctx context.Context) ([]pipeline.RefreshPortfolioJob, error) {
+		allUsers, err := pipelineApp.UserService.GetUsers(ctx)
+		if err != nil {
+			return nil, err
+		}
+		users, unknown := selectUsers(allUsers, opts.users)
+		if len(unknown) > 0 {
+			plog.Warn("refresh-all", "Unknown users", unknown)
+		}
+		jobs := []pipeline.RefreshPortfolioJob{}
+		for _, user := range users {
+			if len(opts.categories) > 0 {
+				hasAccounts, err := pipelineApp.PortfolioService.HasAccounts(ctx, user.ID, opts.categories)
+				if err != nil {
+					return nil, err
+				}
+				if !hasAccounts {
+					continue
+				}
 			}
+			if !opts.all {
+				needsRefresh, err := pipelineApp.PortfolioService.NeedsRefresh(ctx, user.ID)
+				if err != nil {
+					return nil, err
+				}
+				if !needsRefresh {
+					continue
+				}
+			}
+			jobs = append(jobs, pipeline.RefreshPortfolioJob{UserID: user.ID, Simulate: opts.simulate})
+		}
+		plog.Info("refresh-all", "Users", len(allUsers), "Jobs", len(jobs))
 
-			return jobs, nil
+		return jobs, nil
+	}
+
+	syncPipeline := pipeline.NewPipeline(
+		"sync",
+		opts.workers,
+		func(ctx context.Context, job pipeline.SyncAccountsJob) error {
+			return pipelineApp.PortfolioService.SyncUserAccounts(ctx, job.UserID,
+				portfolio.SyncOptions{Categories: job.Categories, Since: job.Since})
 		},
-	)
+		fetchSyncJobs,
+		logConfig,
+	).WithRetry(retry).WithDrain(drain).WithMetrics(metrics)
 
 	refreshPipeline := pipeline.NewPipeline(
-		workerCount,
+		"refresh",
+		opts.workers,
 		func(ctx context.Context, job pipeline.RefreshPortfolioJob) error {
 			return pipelineApp.PortfolioService.RefreshUserAccounts(ctx, job.UserID, job.Simulate)
 		},
-		func(ctx context.Context) ([]pipeline.RefreshPortfolioJob, error) {
-			// TODO: fetch all users, map to []RefreshPortfolioJob
-			users := pipelineApp.UserService.GetUsers()
-			jobs := make([]pipeline.RefreshPortfolioJob, len(users))
-			for i, user := range users {
-				jobs[i] = pipeline.RefreshPortfolioJob{UserID: user.ID}
-			}
+		fetchRefreshJobs,
+		logConfig,
+	).WithRetry(retry).WithDrain(drain).WithMetrics(metrics)
 
-			return jobs, nil
-		},
-	)
+	runs := pipelineApp.PipelineRunsService
+	args := os.Args[2:]
 
-	switch os.Args[1] {
+	switch command {
 	case "sync-all":
-		if err := syncPipeline.Run(ctx); err != nil {
-			plog.Error("sync-all", "error", err)
-			os.Exit(1)
+		if opts.dryRun {
+			exitOnError(plog, command, printJobs(fetchSyncJobs(ctx)))
+			return
 		}
+		summary, err := syncAll(ctx, runs, syncPipeline, args, opts.workers)
+		writeMetrics(plog, metrics)
+		exitOnFailure(plog, command, summary, err, policy)
 
 	case "refresh-all":
-		if err := refreshPipeline.Run(ctx); err != nil {
-			plog.Error("refresh-all", "error", err)
-			os.Exit(1)
+		if opts.dryRun {
+			exitOnError(plog, command, printJobs(fetchRefreshJobs(ctx)))
+			return
 		}
+		summary, err := refreshAll(ctx, runs, refreshPipeline, args, opts.workers)
+		writeMetrics(plog, metrics)
+		exitOnFailure(plog, command, summary, err, policy)
+
+	case "serve":
+		err := serve(ctx, logConfig, metrics, map[string]pipeline.Task{
+			"sync-all": func(ctx context.Context) error {
+				_, err := syncAll(ctx, runs, syncPipeline, nil, opts.workers)
+				return err
+			},
+			"refresh-all": func(ctx context.Context) error {
+				_, err := refreshAll(ctx, runs, refreshPipeline, nil, opts.workers)
+				return err
+			},
+		})
+		exitOnError(plog, command, err)
 
 	case "sync-user":
-		if len(os.Args) < 3 {
-			log.Fatal("Usage: pipeline sync-user <uid>")
-			plog.Error("sync-user Usage: pipeline sync-user <uid>", "error", err)
+		if len(positional) < 1 {
+			plog.Error("sync-user Usage: pipeline sync-user <uid> [--category c] [--since YYYY-MM-DD] [--dry-run]")
 			os.Exit(1)
-
 		}
-		if err := syncPipeline.RunForOne(ctx, pipeline.SyncAccountsJob{UserID: os.Args[2]}); err != nil {
-			log.Fatal(err)
+		job := pipeline.SyncAccountsJob{UserID: positional[0], Categories: opts.categories, Since: opts.since}
+		if opts.dryRun {
+			exitOnError(plog, command, printJobs([]pipeline.SyncAccountsJob{job}, nil))
+			return
 		}
+		run := runs.StartRun(ctx, command, args, opts.workers)
+		summary, err := syncPipeline.RunForOne(ctx, job)
+		recordRun(ctx, runs, run, summary, err)
+		writeMetrics(plog, metrics)
+		exitOnFailure(plog, command, summary, err, policy)
 
 	case "refresh-user":
-		if len(os.Args) < 3 {
-			plog.Error("refresh-user Usage: pipeline refresh-user <uid>", "error", err)
+		if len(positional) < 1 {
+			plog.Error("refresh-user Usage: pipeline refresh-user <uid> [--simulate] [--dry-run]")
 			os.Exit(1)
 		}
+		job := pipeline.RefreshPortfolioJob{UserID: positional[0], Simulate: opts.simulate}
+		if opts.dryRun {
+			exitOnError(plog, command, printJobs([]pipeline.RefreshPortfolioJob{job}, nil))
+			return
+		}
+		run := runs.StartRun(ctx, command, args, opts.workers)
+		summary, err := refreshPipeline.RunForOne(ctx, job)
+		recordRun(ctx, runs, run, summary, err)
+		writeMetrics(plog, metrics)
+		exitOnFailure(plog, command, summary, err, policy)
+
+	case "runs":
+		// pipeline runs [command|id] — recent runs or the detail of one run
+		filter := ""
+		if len(positional) > 0 {
+			filter = positional[0]
+		}
+		exitOnError(plog, command, printRuns(ctx, runs, filter))
 
-		simulate := len(os.Args) > 3 && os.Args[3] == "--simulate"
+	case "reencrypt-credentials":
+		count, err := pipelineApp.CredentialsService.ReencryptCredentials(ctx)
+		exitOnError(plog, command, err)
+		plog.Info("reencrypt-credentials", "Updated", count)
 
-		if err := refreshPipeline.RunForOne(ctx, pipeline.RefreshPortfolioJob{UserID: os.Args[2], Simulate: simulate}); err != nil {
-			log.Fatal(err)
+	case "export-user":
+		if len(positional) < 1 {
+			plog.Error("export-user Usage: pipeline export-user <uid> [--out file]")
+			os.Exit(1)
 		}
+		exitOnError(plog, command, exportUser(ctx, pipelineApp.ArchiveService, positional[0], opts.out))
 
-	default:
-		log.Fatalf("Unknown command: %s", os.Args[1])
+	case "import-user":
+		if len(positional) < 1 {
+			plog.Error("import-user Usage: pipeline import-user <file> [--as uid]")
+			os.Exit(1)
+		}
+		exitOnError(plog, command, importUser(ctx, pipelineApp.ArchiveService, positional[0], opts.as))
+
+	case "backfill-user":
+		if len(positional) < 1 {
+			plog.Error("backfill-user Usage: pipeline backfill-user <uid>")
+			os.Exit(1)
+		}
+		count, err := pipelineApp.PortfolioService.BackfillValues(ctx, positional[0])
+		exitOnError(plog, command, err)
+		plog.Info("backfill-user", "UID", positional[0], "Values", count)
+	}
+}
+
+// exportUser writes the user's archive to path, <uid>-<date>.zip when empty.
+func exportUser(ctx context.Context, archive services.ArchiveService, uid string, path string) error {
+	if len(path) == 0 {
+		path = fmt.Sprintf("%s-%s.zip", uid, time.Now().UTC().Format(time.DateOnly))
+	}
+	// write then rename so a failed export leaves no partial archive
+	tmp := path + ".tmp"
+	file, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	manifest, err := archive.Export(ctx, uid, file)
+	if cerr := file.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(tmp, path)
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	fmt.Printf("%s %v\n", path, manifest.Counts)
+	return nil
+}
+
+// importUser restores the archive at path, into the user as when set.
+func importUser(ctx context.Context, archive services.ArchiveService, path string, as string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	info, err := file.Stat()
+	if err != nil {
+		return err
+	}
+	manifest, err := archive.Import(ctx, file, info.Size(), services.ImportOptions{UID: as})
+	if err != nil {
+		return err
+	}
+	fmt.Printf("%s %v\n", manifest.UID, manifest.Counts)
+	return nil
+}
+
+// printJobs lists the jobs a dry run would dispatch.
+func printJobs[J any](jobs []J, err error) error {
+	if err != nil {
+		return err
+	}
+	for _, job := range jobs {
+		fmt.Printf("%v\n", job)
+	}
+	fmt.Printf("%d jobs\n", len(jobs))
+	return nil
+}
+
+// writeMetrics writes the metrics snapshot to PIPELINE_METRICS_FILE (e.g for the
+// node exporter textfile collector). Without a file nothing is written — serve
+// exposes the metrics on /metrics.
+func writeMetrics(plog *logger.Logger, metrics *pipeline.Metrics) {
+	path := os.Getenv("PIPELINE_METRICS_FILE")
+	if len(path) == 0 {
+		plog.Debug("writeMetrics", "Skipped", "PIPELINE_METRICS_FILE not set")
+		return
+	}
+	// write then rename so a scrape never reads a partial file
+	tmp := path + ".tmp"
+	file, err := os.Create(tmp)
+	if err == nil {
+		_, err = metrics.WriteTo(file)
+		if cerr := file.Close(); err == nil {
+			err = cerr
+		}
+	}
+	if err == nil {
+		err = os.Rename(tmp, path)
+	}
+	if err != nil {
+		plog.Warn("writeMetrics", "Path", path, "error", err)
+	}
+}
+
+// exitOnError logs the error and exits 1.
+func exitOnError(plog *logger.Logger, command string, err error) {
+	if err != nil {
+		plog.Error(command, "error", err)
+		os.Exit(1)
+	}
+}
+
+// failurePolicy reads PIPELINE_MAX_FAILURES and PIPELINE_MAX_FAILURE_RATE (0..1).
+// Unset values allow no failures.
+func failurePolicy() (pipeline.FailurePolicy, error) {
+	policy := pipeline.FailurePolicy{}
+	if value := os.Getenv("PIPELINE_MAX_FAILURES"); len(value) > 0 {
+		maxFailures, err := strconv.Atoi(value)
+		if err != nil {
+			return policy, fmt.Errorf("invalid PIPELINE_MAX_FAILURES: %v", err)
+		}
+		policy.MaxFailures = maxFailures
+		// a count limit alone should not be undercut by the default rate
+		policy.MaxFailureRate = -1
+	}
+	if value := os.Getenv("PIPELINE_MAX_FAILURE_RATE"); len(value) > 0 {
+		rate, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return policy, fmt.Errorf("invalid PIPELINE_MAX_FAILURE_RATE: %v", err)
+		}
+		policy.MaxFailureRate = rate
+		if len(os.Getenv("PIPELINE_MAX_FAILURES")) == 0 {
+			policy.MaxFailures = -1
+		}
+	}
+	return policy, nil
+}
+
+// retryPolicy reads PIPELINE_MAX_ATTEMPTS and PIPELINE_JOB_TIMEOUT (e.g 10m) over the default policy.
+func retryPolicy() (pipeline.RetryPolicy, error) {
+	retry := pipeline.DefaultRetryPolicy()
+	if value := os.Getenv("PIPELINE_MAX_ATTEMPTS"); len(value) > 0 {
+		attempts, err := strconv.Atoi(value)
+		if err != nil {
+			return retry, fmt.Errorf("invalid PIPELINE_MAX_ATTEMPTS: %v", err)
+		}
+		retry.MaxAttempts = attempts
+	}
+	timeout, err := envDuration("PIPELINE_JOB_TIMEOUT", retry.Timeout)
+	if err != nil {
+		return retry, err
+	}
+	retry.Timeout = timeout
+	return retry, nil
+}
+
+// envDuration reads a duration e.g 30s from the environment.
+func envDuration(name string, value time.Duration) (time.Duration, error) {
+	if svalue := os.Getenv(name); len(svalue) > 0 {
+		duration, err := time.ParseDuration(svalue)
+		if err != nil {
+			return value, fmt.Errorf("invalid %s: %v", name, err)
+		}
+		return duration, nil
+	}
+	return value, nil
+}
+
+// syncAll syncs every user and records the run.
+func syncAll(ctx context.Context, runs services.PipelineRunsService, p *pipeline.Pipeline[pipeline.SyncAccountsJob], args []string, workers int) (*pipeline.Summary[pipeline.SyncAccountsJob], error) {
+	run := runs.StartRun(ctx, "sync-all", args, workers)
+	summary, err := p.Run(ctx)
+	recordRun(ctx, runs, run, summary, err)
+	return summary, err
+}
+
+// refreshAll refreshes the flagged users (all with --all) and records the run.
+func refreshAll(ctx context.Context, runs services.PipelineRunsService, p *pipeline.Pipeline[pipeline.RefreshPortfolioJob], args []string, workers int) (*pipeline.Summary[pipeline.RefreshPortfolioJob], error) {
+	run := runs.StartRun(ctx, "refresh-all", args, workers)
+	summary, err := p.Run(ctx)
+	recordRun(ctx, runs, run, summary, err)
+	return summary, err
+}
+
+// recordRun finishes the run history from the summary — a fetch error leaves no summary.
+func recordRun[J any](ctx context.Context, runs services.PipelineRunsService, run *domain.PipelineRun, summary *pipeline.Summary[J], err error) {
+	if summary != nil {
+		summary.Record(run, ctx.Err() != nil)
+	}
+	runs.FinishRun(ctx, run, err)
+}
+
+// printRuns lists the recent runs, for one command if filter names one,
+// or prints the per-user outcomes when filter is a run id.
+func printRuns(ctx context.Context, runs services.PipelineRunsService, filter string) error {
+
+	commands := map[string]bool{"sync-all": true, "refresh-all": true, "sync-user": true, "refresh-user": true}
+	if len(filter) > 0 && !commands[filter] {
+		run, err := runs.GetRun(ctx, filter)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("%s %s %v\n", run.ID, run.Command, run.Args)
+		fmt.Printf("  started %s, %s in %s, %d workers\n", run.Started.Format(time.RFC3339), run.Status, run.Duration().Round(time.Millisecond), run.Workers)
+		fmt.Printf("  %d jobs, %d succeeded, %d failed, %d skipped, %d retries\n", run.Jobs, run.Succeeded, run.Failed, run.Skipped, run.Retries)
+		if len(run.Error) > 0 {
+			fmt.Printf("  error: %s\n", run.Error)
+		}
+		for _, outcome := range run.Outcomes {
+			fmt.Printf("  %-30s %-7s %2d %8dms  %s\n", outcome.UserID, outcome.Status, outcome.Attempts, outcome.DurationMs, outcome.Error)
+		}
+		return nil
+	}
+
+	list, err := runs.GetRuns(ctx, filter, 0)
+	if err != nil {
+		return err
+	}
+	for _, run := range list {
+		fmt.Printf("%s  %-12s %-9s %s %10s  %d/%d ok\n", run.ID, run.Command, run.Status,
+			run.Started.Format(time.RFC3339), run.Duration().Round(time.Millisecond), run.Succeeded, run.Jobs)
+	}
+	return nil
+}
+
+// exitOnFailure prints the run summary and exits 1 when the run breaches the policy.
+func exitOnFailure[J any](plog *logger.Logger, command string, summary *pipeline.Summary[J], err error, policy pipeline.FailurePolicy) {
+	if summary == nil {
+		plog.Error(command, "error", err)
+		os.Exit(1)
+	}
+	fmt.Print(summary.String())
+	if perr := policy.Exceeded(summary.Failed, summary.FailureRate()); perr != nil {
+		plog.Error(command, "error", perr)
+		os.Exit(1)
+	}
+	if err != nil {
+		plog.Warn(command, "Failed", summary.Failed, "error", "within failure policy")
 	}
 }
@@ -0,0 +1,182 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rkapps/fin-tracker-backend-go/internal/domain"
+)
+
+const defaultWorkerCount = 10
+
+// options are the command line flags of a pipeline command.
+type options struct {
+	workers    int
+	users      []string // empty means every user
+	categories []domain.AccountCategory
+	since      *time.Time
+	simulate   bool
+	all        bool   // refresh users that are not flagged too
+	dryRun     bool   // list the jobs without running them
+	out        string // archive written by export-user
+	as         string // user restored into by import-user
+}
+
+// parseOptions parses the flags for the command from args (os.Args[2:]).
+// Positional arguments e.g the uid of sync-user may come before or after the flags.
+func parseOptions(command string, args []string) (options, []string, error) {
+
+	opts := options{}
+	fs := flag.NewFlagSet(command, flag.ContinueOnError)
+	fs.SetOutput(os.Stderr)
+
+	var users, usersFile, categories, since string
+	pipelineCommand := true
+	switch command {
+	case "sync-all", "refresh-all", "sync-user", "refresh-user", "serve":
+		workers := defaultWorkerCount
+		if value := os.Getenv("PIPELINE_WORKERS"); len(value) > 0 {
+			var err error
+			if workers, err = strconv.Atoi(value); err != nil {
+				return opts, nil, fmt.Errorf("invalid PIPELINE_WORKERS: %v", err)
+			}
+		}
+		fs.IntVar(&opts.workers, "workers", workers, "worker count, defaults to PIPELINE_WORKERS or 10")
+	default:
+		pipelineCommand = false
+	}
+	switch command {
+	case "sync-all", "refresh-all":
+		fs.StringVar(&users, "users", "", "comma separated user ids")
+		fs.StringVar(&usersFile, "users-file", "", "file with one user id per line")
+		fs.StringVar(&categories, "category", "", "comma separated account categories e.g crypto")
+	case "sync-user":
+		fs.StringVar(&categories, "category", "", "comma separated account categories e.g crypto")
+	}
+	switch command {
+	case "sync-all", "sync-user":
+		fs.StringVar(&since, "since", "", "pull the provider history after this date (YYYY-MM-DD) instead of after the last sync")
+	case "refresh-all", "refresh-user":
+		fs.BoolVar(&opts.simulate, "simulate", false, "run gain/loss without saving")
+	}
+	switch command {
+	case "export-user":
+		fs.StringVar(&opts.out, "out", "", "archive file, defaults to <uid>-<date>.zip")
+	case "import-user":
+		fs.StringVar(&opts.as, "as", "", "restore into this user with new record ids e.g a test user")
+	}
+	if command == "refresh-all" {
+		fs.BoolVar(&opts.all, "all", false, "refresh every user, not only those flagged by a sync or import")
+	}
+	if pipelineCommand && command != "serve" {
+		fs.BoolVar(&opts.dryRun, "dry-run", false, "list the jobs without running them")
+	}
+
+	positional := []string{}
+	rest := args
+	for {
+		if err := fs.Parse(rest); err != nil {
+			return opts, nil, fmt.Errorf("%s: %v", command, err)
+		}
+		if fs.NArg() == 0 {
+			break
+		}
+		positional = append(positional, fs.Arg(0))
+		rest = fs.Args()[1:]
+	}
+
+	if pipelineCommand && opts.workers < 1 {
+		return opts, nil, fmt.Errorf("%s: workers must be at least 1", command)
+	}
+
+	opts.users = splitList(users)
+	if len(usersFile) > 0 {
+		fileUsers, err := readUsersFile(usersFile)
+		if err != nil {
+			return opts, nil, err
+		}
+		opts.users = append(opts.users, fileUsers...)
+	}
+
+	for _, name := range splitList(categories) {
+		category, err := domain.ParseAccountCategory(name)
+		if err != nil {
+			return opts, nil, fmt.Errorf("%s: %v", command, err)
+		}
+		opts.categories = append(opts.categories, category)
+	}
+
+	if len(since) > 0 {
+		date, err := time.Parse(time.DateOnly, since)
+		if err != nil {
+			return opts, nil, fmt.Errorf("%s: invalid since date %q, want YYYY-MM-DD", command, since)
+		}
+		opts.since = &date
+	}
+	return opts, positional, nil
+}
+
+func splitList(value string) []string {
+	items := []string{}
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); len(item) > 0 {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// readUsersFile reads one user id per line — blank lines and # comments are skipped.
+func readUsersFile(path string) ([]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("users file: %v", err)
+	}
+	defer file.Close()
+
+	users := []string{}
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if len(line) == 0 || strings.HasPrefix(line, "#") {
+			continue
+		}
+		users = append(users, line)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("users file: %v", err)
+	}
+	return users, nil
+}
+
+// selectUsers keeps the users named on the command line, all when none are.
+// Unknown ids are returned so the caller can report them.
+func selectUsers(users []*domain.User, ids []string) ([]*domain.User, []string) {
+	if len(ids) == 0 {
+		return users, nil
+	}
+	usersm := make(map[string]*domain.User)
+	for _, user := range users {
+		usersm[user.ID] = user
+	}
+	selected := []*domain.User{}
+	unknown := []string{}
+	seen := make(map[string]bool)
+	for _, id := range ids {
+		if seen[id] {
+			continue
+		}
+		seen[id] = true
+		if user, ok := usersm[id]; ok {
+			selected = append(selected, user)
+		} else {
+			unknown = append(unknown, id)
+		}
+	}
+	return selected, unknown
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/rkapps/fin-tracker-backend-go/internal/domain"
+)
+
+func TestParseOptions(t *testing.T) {
+
+	usersFile := filepath.Join(t.TempDir(), "users.txt")
+	os.WriteFile(usersFile, []byte("# beta users\nu3\n\nu4\n"), 0600)
+
+	opts, positional, err := parseOptions("sync-all", []string{"--workers", "4", "--users", "u1, u2", "--users-file", usersFile,
+		"--category", "Crypto", "--since", "2026-01-15", "--dry-run"})
+	if err != nil {
+		t.Fatalf("parseOptions() error = %v", err)
+	}
+	if opts.workers != 4 || !opts.dryRun || len(positional) != 0 {
+		t.Errorf("opts = %+v, positional = %v", opts, positional)
+	}
+	if !slices.Equal(opts.users, []string{"u1", "u2", "u3", "u4"}) {
+		t.Errorf("users = %v", opts.users)
+	}
+	if !slices.Equal(opts.categories, []domain.AccountCategory{domain.CategoryCrypto}) {
+		t.Errorf("categories = %v", opts.categories)
+	}
+	if opts.since == nil || opts.since.Format("2006-01-02") != "2026-01-15" {
+		t.Errorf("since = %v", opts.since)
+	}
+
+	// the uid may come before or after the flags
+	for _, args := range [][]string{{"u1", "--simulate"}, {"--simulate", "u1"}} {
+		opts, positional, err = parseOptions("refresh-user", args)
+		if err != nil || !opts.simulate || !slices.Equal(positional, []string{"u1"}) || opts.workers != defaultWorkerCount {
+			t.Errorf("parseOptions(%v) = %+v %v %v", args, opts, positional, err)
+		}
+	}
+
+	opts, positional, err = parseOptions("import-user", []string{"u1.zip", "--as", "test"})
+	if err != nil || opts.as != "test" || !slices.Equal(positional, []string{"u1.zip"}) {
+		t.Errorf("parseOptions(import-user) = %+v %v %v", opts, positional, err)
+	}
+	opts, positional, err = parseOptions("export-user", []string{"--out", "u1.zip", "u1"})
+	if err != nil || opts.out != "u1.zip" || !slices.Equal(positional, []string{"u1"}) {
+		t.Errorf("parseOptions(export-user) = %+v %v %v", opts, positional, err)
+	}
+
+	for _, tt := range []struct {
+		command string
+		args    []string
+	}{
+		{"sync-all", []string{"--category", "stocks"}},
+		{"sync-all", []string{"--since", "15/01/2026"}},
+		{"sync-all", []string{"--workers", "0"}},
+		{"refresh-all", []string{"--since", "2026-01-15"}}, // refresh has no since
+		{"sync-user", []string{"--all"}},
+		{"export-user", []string{"--as", "test"}}, // as is for import-user
+	} {
+		if _, _, err := parseOptions(tt.command, tt.args); err == nil {
+			t.Errorf("parseOptions(%s %v) error = nil", tt.command, tt.args)
+		}
+	}
+
+	users := []*domain.User{{ID: "u1"}, {ID: "u2"}}
+	selected, unknown := selectUsers(users, []string{"u2", "u9", "u2"})
+	if len(selected) != 1 || selected[0].ID != "u2" || !slices.Equal(unknown, []string{"u9"}) {
+		t.Errorf("selectUsers() = %v %v", selected, unknown)
+	}
+}
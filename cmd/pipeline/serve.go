@@ -0,0 +1,106 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	logger "github.com/rkapps/fin-tracker-backend-go/cmd/common/logger"
+	"github.com/rkapps/fin-tracker-backend-go/internal/pipeline"
+)
+
+// default schedules — overridden by PIPELINE_SCHEDULE_<COMMAND> e.g PIPELINE_SCHEDULE_SYNC_ALL
+var defaultSchedules = map[string]string{
+	"sync-all":    "*/15 * * * *",
+	"refresh-all": "0 2 * * *",
+}
+
+// serve runs the tasks on their schedules until ctx is cancelled (SIGTERM) and
+// answers health checks and metric scrapes on PORT. In-flight runs drain before it returns.
+func serve(ctx context.Context, logConfig *logger.Config, metrics *pipeline.Metrics, tasks map[string]pipeline.Task) error {
+
+	serveLog := logConfig.For("pipeline.serve")
+	jitter, err := envDuration("PIPELINE_SCHEDULE_JITTER", 30*time.Second)
+	if err != nil {
+		return err
+	}
+
+	scheduler := pipeline.NewScheduler(jitter, logConfig)
+	for command, task := range tasks {
+		spec := defaultSchedules[command]
+		env := "PIPELINE_SCHEDULE_" + strings.ToUpper(strings.ReplaceAll(command, "-", "_"))
+		if value := os.Getenv(env); len(value) > 0 {
+			spec = value
+		}
+		if spec == "off" || len(spec) == 0 {
+			serveLog.Info("serve", "Command", command, "Schedule", "off")
+			continue
+		}
+		schedule, err := pipeline.ParseSchedule(spec)
+		if err != nil {
+			return fmt.Errorf("%s: %v", env, err)
+		}
+		serveLog.Info("serve", "Command", command, "Schedule", spec)
+		scheduler.Add(command, schedule, task)
+	}
+
+	port := os.Getenv("PORT")
+	if port == "" {
+		port = "8080"
+	}
+	var draining atomic.Bool
+	started := time.Now().UTC()
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+		status := "ok"
+		code := http.StatusOK
+		if draining.Load() {
+			status = "draining"
+			code = http.StatusServiceUnavailable
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(code)
+		json.NewEncoder(w).Encode(map[string]any{
+			"status":  status,
+			"started": started,
+			"tasks":   scheduler.Status(),
+		})
+	})
+	mux.Handle("/metrics", metrics.Handler())
+	server := &http.Server{Addr: ":" + port, Handler: mux, ReadHeaderTimeout: 5 * time.Second}
+
+	serverErr := make(chan error, 1)
+	go func() {
+		serveLog.Info("serve", "Health", ":"+port+"/healthz")
+		if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			serverErr <- err
+		}
+	}()
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		scheduler.Run(ctx)
+	}()
+
+	select {
+	case err := <-serverErr:
+		return fmt.Errorf("health server: %v", err)
+	case <-ctx.Done():
+	}
+
+	// keep answering health checks (as draining) until in-flight runs finish
+	draining.Store(true)
+	<-done
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	return server.Shutdown(shutdownCtx)
+}
@@ -4,6 +4,8 @@ import (
 	"encoding/json"
 	"fmt"
 	"log/slog"
+	"regexp"
+	"strings"
 	"time"
 
 	"github.com/charmbracelet/log"
@@ -18,6 +20,7 @@ type Account struct {
 	Category          AccountCategory   `json:"category" bson:"category"`
 	Type              AccountType       `json:"type" bson:"type"`
 	AlternateNames    []string          `json:"alternateNames,omitempty" bson:"alternateNames,omitempty"`
+	AliasPatterns     []string          `json:"aliasPatterns,omitempty" bson:"aliasPatterns,omitempty"` // regex, case insensitive
 	Detail            AccountDetail     `json:"detail" bson:"detail"`
 	TaxStatus         TaxStatus         `json:"taxStatus" bson:"taxStatus"`
 	LotMatchingMethod LotMatchingMethod `json:"lotMatchingMethod" bson:"lotMatchingMethod"`
@@ -36,6 +39,41 @@ func (a *Account) CollectionName() string {
 
 type Accounts []*Account
 
+// AccountNumber returns the institution account number from the detail.
+func (a *Account) AccountNumber() string {
+	switch d := a.Detail.(type) {
+	case *BankDetail:
+		return d.AccountNumber
+	case *BrokerageDetail:
+		return d.AccountNumber
+	case *EducationDetail:
+		return d.AccountNumber
+	case *CryptoDetail:
+		return d.AccountNumber
+	}
+	return ""
+}
+
+// ValidateAliasPatterns checks the alias patterns compile.
+func (a *Account) ValidateAliasPatterns() error {
+	for _, pattern := range a.AliasPatterns {
+		if _, err := regexp.Compile("(?i)" + pattern); err != nil {
+			return fmt.Errorf("invalid alias pattern %q: %v", pattern, err)
+		}
+	}
+	return nil
+}
+
+// UnresolvedAccountID is the placeholder external account for counterparties
+// that could not be matched to one of the user's accounts.
+func UnresolvedAccountID(uid string) string {
+	return uid + ":unresolved"
+}
+
+func IsUnresolvedAccountID(id string) bool {
+	return strings.HasSuffix(id, ":unresolved")
+}
+
 type AccountCategory string
 
 const (
@@ -47,6 +85,19 @@ const (
 	Category529        AccountCategory = "529"
 )
 
+// AccountCategories lists the valid categories.
+var AccountCategories = []AccountCategory{CategoryCash, CategoryBrokerage, CategoryRetirement, CategoryHSA, CategoryCrypto, Category529}
+
+// ParseAccountCategory returns the category for the name, case insensitive.
+func ParseAccountCategory(name string) (AccountCategory, error) {
+	for _, category := range AccountCategories {
+		if strings.EqualFold(string(category), strings.TrimSpace(name)) {
+			return category, nil
+		}
+	}
+	return "", fmt.Errorf("invalid account category: %s", name)
+}
+
 // AccountType
 type AccountType string
 
@@ -79,6 +130,21 @@ const (
 	TaxStatusExempt   TaxStatus = "tax_exempt"
 )
 
+// NewAccountDetail returns an empty detail of the type kept by the account category.
+func NewAccountDetail(category AccountCategory) (AccountDetail, error) {
+	switch category {
+	case CategoryBrokerage, CategoryRetirement, CategoryHSA:
+		return &BrokerageDetail{}, nil
+	case CategoryCrypto:
+		return &CryptoDetail{}, nil
+	case CategoryCash:
+		return &BankDetail{}, nil
+	case Category529:
+		return &EducationDetail{}, nil
+	}
+	return nil, fmt.Errorf("unknown account type: %s", category)
+}
+
 // MarshalBSON for Account
 func (a *Account) MarshalBSON() ([]byte, error) {
 	type Alias Account
@@ -112,18 +178,9 @@ func (a *Account) UnmarshalBSON(data []byte) error {
 	log.Debug("Account", "UnmarshalBSOn", a)
 
 	// Second pass: unmarshal Details based on AccountType
-	var detail AccountDetail
-	switch a.Category {
-	case CategoryBrokerage, CategoryRetirement, CategoryHSA:
-		detail = &BrokerageDetail{}
-	case CategoryCrypto:
-		detail = &CryptoDetail{}
-	case CategoryCash:
-		detail = &BankDetail{}
-	case Category529:
-		detail = &EducationDetail{}
-	default:
-		return fmt.Errorf("unknown account type: %s", a.Type)
+	detail, err := NewAccountDetail(a.Category)
+	if err != nil {
+		return err
 	}
 
 	if err := bson.Unmarshal(aux.Detail, detail); err != nil {
@@ -151,20 +208,9 @@ func (a *Account) UnmarshalJSON(data []byte) error {
 	slog.Debug("Account", "UnmarshalBSOn", a)
 
 	// Determine detail type
-	var detail AccountDetail
-	switch acategory {
-	case CategoryBrokerage, CategoryRetirement, CategoryHSA:
-		detail = &BrokerageDetail{}
-	case CategoryCrypto:
-		detail = &CryptoDetail{}
-	case CategoryCash:
-		detail = &BankDetail{}
-	case Category529:
-		detail = &EducationDetail{}
-
-	// ...
-	default:
-		return fmt.Errorf("unknown account type: %s", acategory)
+	detail, err := NewAccountDetail(acategory)
+	if err != nil {
+		return err
 	}
 
 	// Unmarshal detail
@@ -1,24 +1,43 @@
 package domain
 
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
 type AccountCredential struct {
-	ID  string
-	UID string
-	// Provider string // "coinbase", "kraken", "alpaca"
+	ID       string `json:"id" bson:"id"`
+	UID      string `json:"-" bson:"uid"`
+	Provider string `json:"provider" bson:"provider"` // "coinbase", "kraken", "alpaca"
+
+	// Encrypted fields — never returned by the api
+	APIKey     string `json:"-" bson:"apikey"`     // Encrypted
+	APISecret  string `json:"-" bson:"apisecret"`  // Encrypted
+	Passphrase string `json:"-" bson:"passphrase"` // Encrypted (some exchanges need this)
 
-	// Encrypted fields
-	APIKey     string // Encrypted
-	APISecret  string // Encrypted
-	Passphrase string // Encrypted (some exchanges need this)
+	// Envelope — data key wrapped by the master key version
+	KeyVersion string `json:"keyVersion,omitempty" bson:"keyVersion,omitempty"`
+	WrappedKey string `json:"-" bson:"wrappedKey,omitempty"`
 
 	// Metadata
-	// Label       string   // User's nickname
-	// Permissions []string // "read", "trade" - what access was granted
-	// ExpiresAt   *time.Time
-	// CreatedAt   time.Time
-	// LastUsed    *time.Time
-	// Active      bool
+	Label       string     `json:"label" bson:"label"`             // User's nickname
+	Permissions []string   `json:"permissions" bson:"permissions"` // "read", "trade" - what access was granted
+	ExpiresAt   *time.Time `json:"expiresAt,omitempty" bson:"expiresAt,omitempty"`
+	CreatedAt   time.Time  `json:"createdAt" bson:"createdAt"`
+	LastUsed    *time.Time `json:"lastUsed,omitempty" bson:"lastUsed,omitempty"`
+	Active      bool       `json:"active" bson:"active"`
 }
 
+const (
+	PermissionRead     = "read"
+	PermissionTrade    = "trade"
+	PermissionWithdraw = "withdraw"
+
+	// CredentialExpiryWarning is how long before expiry users are warned
+	CredentialExpiryWarning = 14 * 24 * time.Hour
+)
+
 // Id returns the unique id for the ticker
 func (a *AccountCredential) Id() string {
 	return a.ID
@@ -27,3 +46,56 @@ func (a *AccountCredential) Id() string {
 func (a *AccountCredential) CollectionName() string {
 	return ACCOUNT_CREDENTIAL_COLLECTION_NAME
 }
+
+// Encrypted returns false for credentials saved before encryption was enabled.
+func (a *AccountCredential) Encrypted() bool {
+	return len(a.KeyVersion) > 0
+}
+
+// SecretFields returns the encrypted fields by name for sealing and opening.
+func (a *AccountCredential) SecretFields() map[string]*string {
+	return map[string]*string{"apiKey": &a.APIKey, "apiSecret": &a.APISecret, "passphrase": &a.Passphrase}
+}
+
+// ValidatePermissions only accepts read access — syncing never needs to trade or withdraw.
+func ValidatePermissions(permissions []string) error {
+	for _, permission := range permissions {
+		switch strings.ToLower(permission) {
+		case PermissionRead:
+		case PermissionTrade, PermissionWithdraw:
+			return fmt.Errorf("credential grants %s permission, only read is needed — create a read-only key", permission)
+		default:
+			return fmt.Errorf("unknown permission: %s", permission)
+		}
+	}
+	return nil
+}
+
+// IsExpired returns true once ExpiresAt has passed.
+func (a *AccountCredential) IsExpired(now time.Time) bool {
+	return a.ExpiresAt != nil && !now.Before(*a.ExpiresAt)
+}
+
+// ExpiresSoon returns true within CredentialExpiryWarning of ExpiresAt.
+func (a *AccountCredential) ExpiresSoon(now time.Time) bool {
+	return a.ExpiresAt != nil && !a.IsExpired(now) && a.ExpiresAt.Sub(now) <= CredentialExpiryWarning
+}
+
+// Usable returns why the credential cannot be used, nil if it can.
+func (a *AccountCredential) Usable(now time.Time) error {
+	if !a.Active {
+		return fmt.Errorf("credential %q is inactive", a.Label)
+	}
+	if a.IsExpired(now) {
+		return fmt.Errorf("credential %q expired on %s", a.Label, a.ExpiresAt.Format("2006-01-02"))
+	}
+	return nil
+}
+
+// ExpiryWarning returns the message shown to users before the credential expires.
+func (a *AccountCredential) ExpiryWarning(now time.Time) string {
+	if !a.ExpiresSoon(now) {
+		return ""
+	}
+	return fmt.Sprintf("credential %q expires on %s", a.Label, a.ExpiresAt.Format("2006-01-02"))
+}
@@ -3,15 +3,25 @@ package domain
 import "time"
 
 type AccountSyncState struct {
-	ID           string     `json:"id" bson:"id"`
-	UID          string     `json:"-" bson:"uid"`
-	LastSyncDate *time.Time `json:"lastSyncDate,omitempty" bson:"lastSyncDate,omitempty"`
-	Resync       bool       `json:"resync" bson:"resync"`
-	Refresh      bool       `json:"refresh" bson:"refresh"`
-	SyncStatus   string     `json:"syncStatus" bson:"syncStatus"` // pending, syncing, success, failed
-	ErrorMessage string     `json:"errorMessage,omitempty" bson:"errorMessage,omitempty"`
+	ID            string     `json:"id" bson:"id"`
+	UID           string     `json:"-" bson:"uid"`
+	LastSyncDate  *time.Time `json:"lastSyncDate,omitempty" bson:"lastSyncDate,omitempty"`
+	Resync        bool       `json:"resync" bson:"resync"`
+	Refresh       bool       `json:"refresh" bson:"refresh"`
+	SyncStatus    string     `json:"syncStatus" bson:"syncStatus"` // pending, syncing, success, failed
+	ErrorMessage  string     `json:"errorMessage,omitempty" bson:"errorMessage,omitempty"`
+	Warning       string     `json:"warning,omitempty" bson:"warning,omitempty"` // e.g. credential about to expire
+	SyncStartDate *time.Time `json:"syncStartDate,omitempty" bson:"syncStartDate,omitempty"`
+	NewActivities int        `json:"newActivities" bson:"newActivities"` // found by the last sync
 }
 
+const (
+	SyncStatusPending = "pending"
+	SyncStatusSyncing = "syncing"
+	SyncStatusSuccess = "success"
+	SyncStatusFailed  = "failed"
+)
+
 // Id returns the unique id for the ticker
 func (a *AccountSyncState) Id() string {
 	return a.ID
@@ -20,3 +30,36 @@ func (a *AccountSyncState) Id() string {
 func (a *AccountSyncState) CollectionName() string {
 	return ACCOUNT_SYNC_STATE_COLLECTION_NAME
 }
+
+// Since returns the date to sync from — nil pulls the full history.
+func (a *AccountSyncState) Since() *time.Time {
+	if a.Resync {
+		return nil
+	}
+	return a.LastSyncDate
+}
+
+// StartSync marks the account as syncing.
+func (a *AccountSyncState) StartSync(start time.Time) {
+	a.SyncStatus = SyncStatusSyncing
+	a.SyncStartDate = &start
+	a.ErrorMessage = ""
+}
+
+// SyncSucceeded moves the sync date to the start of this sync so nothing
+// dated during the sync is missed, and flags a refresh if anything changed.
+func (a *AccountSyncState) SyncSucceeded(newActivities int) {
+	a.SyncStatus = SyncStatusSuccess
+	a.LastSyncDate = a.SyncStartDate
+	a.NewActivities = newActivities
+	if newActivities > 0 || a.Resync {
+		a.Refresh = true
+	}
+	a.Resync = false
+}
+
+// SyncFailed keeps the last sync date so the next sync retries the same window.
+func (a *AccountSyncState) SyncFailed(err error) {
+	a.SyncStatus = SyncStatusFailed
+	a.ErrorMessage = err.Error()
+}
@@ -1,6 +1,7 @@
 package domain
 
 import (
+	"fmt"
 	"time"
 
 	"github.com/shopspring/decimal"
@@ -32,6 +33,18 @@ type AccountSummaryValue struct {
 	MktValue  decimal.Decimal `json:"mktValue"`
 }
 
+// SnapshotDate returns the day of a summary snapshot — midnight UTC.
+func SnapshotDate(date time.Time) time.Time {
+	return date.UTC().Truncate(24 * time.Hour)
+}
+
+// SetSnapshot dates the summary to the day of date, with an id that is the same
+// for every refresh of the account on that day — a snapshot is kept per account per day.
+func (a *AccountSummary) SetSnapshot(date time.Time) {
+	a.Date = SnapshotDate(date)
+	a.ID = fmt.Sprintf("%s-%s", a.AccountID, a.Date.Format(time.DateOnly))
+}
+
 // Id returns the unique id for the ticker
 func (a *AccountSummary) Id() string {
 	return a.ID
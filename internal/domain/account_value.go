@@ -0,0 +1,40 @@
+package domain
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// AccountValue is the value of an account at the close of a day. The history since the
+// account's first activity is rebuilt by replaying the activities and pricing the positions
+// with the ticker history closes; every refresh then keeps the value of the day current.
+type AccountValue struct {
+	ID          string          `json:"id" bson:"id"`
+	UID         string          `json:"-" bson:"uid"`
+	AccountID   string          `json:"accountId" bson:"accountId"`
+	Date        time.Time       `json:"date" bson:"date"`
+	MarketValue decimal.Decimal `json:"marketValue" bson:"marketValue"`
+	CostValue   decimal.Decimal `json:"costValue" bson:"costValue"`
+	Cash        decimal.Decimal `json:"cash" bson:"cash"`
+	NetDeposits decimal.Decimal `json:"netDeposits" bson:"netDeposits"`
+	Income      decimal.Decimal `json:"income" bson:"income"`
+	Unpriced    []string        `json:"unpriced,omitempty" bson:"unpriced,omitempty"` // symbols without a close, valued at cost
+}
+
+// SetDate dates the value to the day of date, with an id that is the same for
+// every computation of the account's value on that day.
+func (v *AccountValue) SetDate(date time.Time) {
+	v.Date = SnapshotDate(date)
+	v.ID = fmt.Sprintf("%s-%s", v.AccountID, v.Date.Format(time.DateOnly))
+}
+
+// Id returns the unique id for the value
+func (v *AccountValue) Id() string {
+	return v.ID
+}
+
+func (v *AccountValue) CollectionName() string {
+	return ACCOUNT_VALUE_COLLECTION_NAME
+}
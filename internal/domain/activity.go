@@ -5,6 +5,7 @@ import (
 	"time"
 
 	"github.com/shopspring/decimal"
+	"go.mongodb.org/mongo-driver/v2/bson"
 )
 
 type Activity struct {
@@ -52,8 +53,14 @@ type Activity struct {
 	RcvAccountID  string `json:"rcvAccountId" bson:"rcvAccountId"`
 	SentAccountID string `json:"sentAccountId"   bson:"sentAccountId"`
 
+	// income — security the income was paid on
+	IncomeSymbol string `json:"incomeSymbol,omitempty" bson:"incomeSymbol,omitempty"`
+	// pairing — e.g dividend and its reinvestment
+	LinkedActivityID string `json:"linkedActivityId,omitempty" bson:"linkedActivityId,omitempty"`
+
 	// type-specific detail
-	Detail ActivityDetail `json:"detail,omitempty"  bson:"detail,omitempty"`
+	Detail     ActivityDetail `json:"detail,omitempty"  bson:"detail,omitempty"`
+	DetailType string         `json:"detailType,omitempty"  bson:"detailType,omitempty"`
 
 	Notes string `json:"notes" bson:"notes"`
 }
@@ -67,6 +74,40 @@ func (a *Activity) CollectionName() string {
 	return ACTIVITY_COLLECTION_NAME
 }
 
+// SetDetail sets the type-specific detail and its type used to unmarshal it.
+func (a *Activity) SetDetail(detail ActivityDetail) {
+	a.Detail = detail
+	a.DetailType = detail.DetailType()
+}
+
+// UnmarshalBSON for Activity
+func (a *Activity) UnmarshalBSON(data []byte) error {
+
+	// First pass: unmarshal everything except Detail
+	type Alias Activity
+	aux := &struct {
+		*Alias `bson:",inline"`
+		Detail bson.Raw `bson:"detail,omitempty"`
+	}{
+		Alias: (*Alias)(a),
+	}
+
+	if err := unmarshalBSONWithDecimal(data, aux); err != nil {
+		return err
+	}
+	if len(aux.Detail) == 0 {
+		return nil
+	}
+
+	// Second pass: unmarshal Detail based on DetailType
+	detail, err := unmarshalActivityDetail(a.DetailType, aux.Detail)
+	if err != nil {
+		return err
+	}
+	a.Detail = detail
+	return nil
+}
+
 func (a *Activity) Debug() string {
 	return fmt.Sprintf("%s-%s", a.TxnType, a.ID)
 }
@@ -75,9 +116,10 @@ type ActivityType string
 
 const (
 	// trades
-	ActivityTypeBuy   ActivityType = "buy"
-	ActivityTypeSell  ActivityType = "sell"
-	ActivityTypeTrade ActivityType = "trade" // crypto swap / FX
+	ActivityTypeBuy      ActivityType = "buy"
+	ActivityTypeSell     ActivityType = "sell"
+	ActivityTypeTrade    ActivityType = "trade"    // crypto swap / FX
+	ActivityTypeReinvest ActivityType = "reinvest" // dividend reinvestment (DRIP) buy
 
 	// income
 	ActivityTypeDividend ActivityType = "dividend"
@@ -121,3 +163,15 @@ func (a Activity) IsDeposit() bool {
 func (a Activity) IsWithdrawal() bool {
 	return a.TxnType == ActivityTypeWithdraw
 }
+
+func (a Activity) IsReinvestment() bool {
+	return a.TxnType == ActivityTypeReinvest
+}
+
+// UnderlyingSymbol returns the security an income activity was paid on.
+func (a Activity) UnderlyingSymbol() string {
+	if len(a.IncomeSymbol) > 0 {
+		return a.IncomeSymbol
+	}
+	return a.SentSymbol
+}
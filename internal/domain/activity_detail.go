@@ -1,9 +1,14 @@
 package domain
 
 import (
+	"bytes"
+	"encoding/json"
+	"fmt"
 	"time"
 
+	"github.com/rkapps/storage-backend-go/mongodb"
 	"github.com/shopspring/decimal"
+	"go.mongodb.org/mongo-driver/v2/bson"
 )
 
 // ActivityDetail holds type-specific fields
@@ -104,3 +109,50 @@ type FeeActivityDetail struct {
 }
 
 func (f FeeActivityDetail) DetailType() string { return "fee" }
+
+// decimalRegistry decodes decimals the same way the storage client does.
+var decimalRegistry = mongodb.GetBsonRegistryForDecimal()
+
+func unmarshalBSONWithDecimal(data []byte, val any) error {
+	dec := bson.NewDecoder(bson.NewDocumentReader(bytes.NewReader(data)))
+	dec.SetRegistry(decimalRegistry)
+	return dec.Decode(val)
+}
+
+// unmarshalActivityDetail decodes the detail for the detail type.
+func unmarshalActivityDetail(detailType string, data bson.Raw) (ActivityDetail, error) {
+	return decodeActivityDetail(detailType, data, unmarshalBSONWithDecimal)
+}
+
+// UnmarshalActivityDetailJSON decodes a JSON detail for the detail type.
+func UnmarshalActivityDetailJSON(detailType string, data []byte) (ActivityDetail, error) {
+	return decodeActivityDetail(detailType, data, json.Unmarshal)
+}
+
+func decodeActivityDetail(detailType string, data []byte, unmarshal func([]byte, any) error) (ActivityDetail, error) {
+	switch detailType {
+	case "brokerage":
+		return decodeDetail[BrokerageActivityDetail](data, unmarshal)
+	case "wallet":
+		return decodeDetail[WalletActivityDetail](data, unmarshal)
+	case "exchange":
+		return decodeDetail[ExchangeActivityDetail](data, unmarshal)
+	case "corporate_action":
+		return decodeDetail[CorporateActionDetail](data, unmarshal)
+	case "transfer":
+		return decodeDetail[TransferActivityDetail](data, unmarshal)
+	case "rollover":
+		return decodeDetail[RolloverActivityDetail](data, unmarshal)
+	case "fee":
+		return decodeDetail[FeeActivityDetail](data, unmarshal)
+	}
+	return nil, fmt.Errorf("unknown activity detail type: %s", detailType)
+}
+
+func decodeDetail[D ActivityDetail](data []byte, unmarshal func([]byte, any) error) (ActivityDetail, error) {
+	var detail D
+	if err := unmarshal(data, &detail); err != nil {
+		return nil, err
+	}
+	return detail, nil
+}
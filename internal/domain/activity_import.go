@@ -38,12 +38,20 @@ type ActivityImport struct { // Fixed capitalization
 	FeeCurrency string          `json:"feeCurrency,omitempty" bson:"feeCurrency,omitempty"`
 	Notes       string          `json:"notes,omitempty" bson:"notes,omitempty"`
 
-	// // Processing
+	// Processing
 	// ProcessedID   string `json:"processedId,omitempty" bson:"processedId,omitempty"`
-	// ProcessStatus string `json:"processStatus" bson:"processStatus"`
-	// ErrorMessage  string `json:"errorMessage,omitempty" bson:"errorMessage,omitempty"`
+	ProcessStatus ImportProcessStatus `json:"processStatus,omitempty" bson:"processStatus,omitempty"`
+	ErrorMessage  string              `json:"errorMessage,omitempty" bson:"errorMessage,omitempty"`
 }
 
+type ImportProcessStatus string
+
+const (
+	ImportStatusMapped   ImportProcessStatus = "mapped"   // converted to an activity
+	ImportStatusUnmapped ImportProcessStatus = "unmapped" // no built-in type or rule matched
+	ImportStatusSkipped  ImportProcessStatus = "skipped"  // ignored by a mapping rule
+)
+
 // Id returns the unique id for the ticker
 func (a *ActivityImport) Id() string {
 	return a.ID
@@ -52,3 +60,83 @@ func (a *ActivityImport) Id() string {
 func (a *ActivityImport) CollectionName() string {
 	return ACTIVITY_IMPORT_COLLECTION_NAME
 }
+
+// IsActivityImportField returns true if the field can be used in a field mapping.
+func IsActivityImportField(field string) bool {
+	switch field {
+	case "rcvAccount", "rcvCurrency", "rcvAmount", "sentAccount", "sentCurrency", "sentAmount", "fee", "feeCurrency":
+		return true
+	}
+	return false
+}
+
+// isActivityImportAmountField returns true for the decimal fields — a field mapping
+// only copies between fields of the same type.
+func isActivityImportAmountField(field string) bool {
+	switch field {
+	case "rcvAmount", "sentAmount", "fee":
+		return true
+	}
+	return false
+}
+
+// ApplyFieldMapping copies the source fields into the target fields.
+// Sources are read before any target is written so swaps work.
+func (a *ActivityImport) ApplyFieldMapping(mapping map[string]string) {
+	values := make(map[string]any)
+	for _, source := range mapping {
+		values[source] = a.field(source)
+	}
+	for target, source := range mapping {
+		a.setField(target, values[source])
+	}
+}
+
+func (a *ActivityImport) field(name string) any {
+	switch name {
+	case "rcvAccount":
+		return a.RcvAccount
+	case "rcvCurrency":
+		return a.RcvCurrency
+	case "rcvAmount":
+		return a.RcvAmount
+	case "sentAccount":
+		return a.SentAccount
+	case "sentCurrency":
+		return a.SentCurrency
+	case "sentAmount":
+		return a.SentAmount
+	case "fee":
+		return a.Fee
+	case "feeCurrency":
+		return a.FeeCurrency
+	}
+	return nil
+}
+
+func (a *ActivityImport) setField(name string, value any) {
+	switch v := value.(type) {
+	case string:
+		switch name {
+		case "rcvAccount":
+			a.RcvAccount = v
+		case "rcvCurrency":
+			a.RcvCurrency = v
+		case "sentAccount":
+			a.SentAccount = v
+		case "sentCurrency":
+			a.SentCurrency = v
+		case "feeCurrency":
+			a.FeeCurrency = v
+		}
+	case decimal.Decimal:
+		switch name {
+		case "rcvAmount":
+			a.RcvAmount = v.Abs()
+		case "sentAmount":
+			a.SentAmount = v.Abs()
+		case "fee":
+			a.Fee = v.Abs()
+		}
+	}
+}
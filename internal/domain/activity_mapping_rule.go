@@ -0,0 +1,101 @@
+package domain
+
+import (
+	"fmt"
+	"regexp"
+)
+
+// ActivityMappingRule maps a broker specific imported txn type to an ActivityType.
+// Rules without an AccountID apply to every account of the user.
+type ActivityMappingRule struct {
+	ID             string       `json:"id" bson:"id"`
+	UID            string       `json:"-" bson:"uid"`
+	AccountID      string       `json:"accountId,omitempty" bson:"accountId,omitempty"`
+	Name           string       `json:"name" bson:"name"`
+	Priority       int          `json:"priority" bson:"priority"`                                 // lower runs first
+	TxnTypePattern string       `json:"txnTypePattern,omitempty" bson:"txnTypePattern,omitempty"` // regex, case insensitive
+	NotesPattern   string       `json:"notesPattern,omitempty" bson:"notesPattern,omitempty"`     // regex, case insensitive
+	ActivityType   ActivityType `json:"activityType,omitempty" bson:"activityType,omitempty"`
+	Skip           bool         `json:"skip" bson:"skip"` // matched rows are ignored on purpose
+
+	// FieldMapping copies imported fields before the activity is built.
+	// Key is the target field, value is the source field — e.g. "rcvAmount": "sentAmount"
+	FieldMapping map[string]string `json:"fieldMapping,omitempty" bson:"fieldMapping,omitempty"`
+
+	// compiled patterns, set by Compile
+	txnTypeRe *regexp.Regexp
+	notesRe   *regexp.Regexp
+}
+
+// Id returns the unique id for the rule
+func (r *ActivityMappingRule) Id() string {
+	return r.ID
+}
+
+func (r *ActivityMappingRule) CollectionName() string {
+	return ACTIVITY_MAPPING_RULE_COLLECTION_NAME
+}
+
+// Validate checks the patterns compile and the rule maps to something.
+func (r *ActivityMappingRule) Validate() error {
+	if r.TxnTypePattern == "" && r.NotesPattern == "" {
+		return fmt.Errorf("txnTypePattern or notesPattern required")
+	}
+	if !r.Skip && r.ActivityType == "" {
+		return fmt.Errorf("activityType required")
+	}
+	for _, pattern := range []string{r.TxnTypePattern, r.NotesPattern} {
+		if _, err := compilePattern(pattern); err != nil {
+			return err
+		}
+	}
+	for target, source := range r.FieldMapping {
+		if !IsActivityImportField(target) {
+			return fmt.Errorf("invalid field mapping target: %s", target)
+		}
+		if !IsActivityImportField(source) {
+			return fmt.Errorf("invalid field mapping source: %s", source)
+		}
+		if isActivityImportAmountField(target) != isActivityImportAmountField(source) {
+			return fmt.Errorf("invalid field mapping %s: source %s has a different type", target, source)
+		}
+	}
+	return nil
+}
+
+// Compile compiles the patterns once for Matches.
+func (r *ActivityMappingRule) Compile() error {
+	var err error
+	if r.txnTypeRe, err = compilePattern(r.TxnTypePattern); err != nil {
+		return err
+	}
+	if r.notesRe, err = compilePattern(r.NotesPattern); err != nil {
+		return err
+	}
+	return nil
+}
+
+func compilePattern(pattern string) (*regexp.Regexp, error) {
+	if pattern == "" {
+		return nil, nil
+	}
+	re, err := regexp.Compile("(?i)" + pattern)
+	if err != nil {
+		return nil, fmt.Errorf("invalid pattern %q: %v", pattern, err)
+	}
+	return re, nil
+}
+
+// Matches returns true if the compiled rule matches the imported activity.
+// Both patterns must match when both are set. A rule that is not compiled never matches.
+func (r *ActivityMappingRule) Matches(iactv *ActivityImport) bool {
+	if r.TxnTypePattern != "" && (r.txnTypeRe == nil || !r.txnTypeRe.MatchString(iactv.TxnType)) {
+		return false
+	}
+	if r.NotesPattern != "" && (r.notesRe == nil || !r.notesRe.MatchString(iactv.Notes)) {
+		return false
+	}
+	return true
+}
+
+type ActivityMappingRules []*ActivityMappingRule
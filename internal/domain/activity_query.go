@@ -0,0 +1,75 @@
+package domain
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+const (
+	// MaxPageLimit caps the page size a client can ask for.
+	MaxPageLimit = 1000
+
+	SortDate   = "date"
+	SortSymbol = "symbol"
+)
+
+// Page selects one page of a query sorted by Sort.
+// Cursor is the NextCursor of the previous page, empty for the first page.
+type Page struct {
+	Limit  int    `json:"limit"`
+	Cursor string `json:"cursor"`
+	Sort   string `json:"sort"` // date or symbol, - prefix for descending e.g -date
+}
+
+// SortField returns the sort field and whether it is descending. Dates sort newest first by default.
+func (p Page) SortField() (string, bool, error) {
+	sort := strings.TrimSpace(p.Sort)
+	if len(sort) == 0 {
+		return SortDate, true, nil
+	}
+	desc := strings.HasPrefix(sort, "-")
+	field := strings.TrimPrefix(sort, "-")
+	if field != SortDate && field != SortSymbol {
+		return "", false, fmt.Errorf("invalid sort: %s", p.Sort)
+	}
+	return field, desc, nil
+}
+
+// PageLimit returns the limit bounded by MaxPageLimit. 0 means everything in one page.
+func (p Page) PageLimit() int {
+	if p.Limit < 0 {
+		return 0
+	}
+	if p.Limit > MaxPageLimit {
+		return MaxPageLimit
+	}
+	return p.Limit
+}
+
+// ActivityQuery filters the activities read from storage. Zero values match everything.
+type ActivityQuery struct {
+	AccountIDs []string
+	StartDate  time.Time
+	EndDate    time.Time
+	Symbols    []string // received or sent symbol
+	TxnTypes   []ActivityType
+	Statuses   []ActivityStatus
+	Page
+}
+
+// LotQuery filters the lots read from storage. Zero values match everything.
+type LotQuery struct {
+	AccountIDs []string
+	StartDate  time.Time // acquisition date
+	EndDate    time.Time
+	Symbols    []string
+	Statuses   []LotStatus
+	Page
+}
+
+// Paged is one page of a query. NextCursor is empty on the last page.
+type Paged[T any] struct {
+	Items      []T    `json:"items"`
+	NextCursor string `json:"nextCursor,omitempty"`
+}
@@ -0,0 +1,74 @@
+package domain
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+)
+
+// AuditEntry records one change a user made to their data. Entries are append only.
+type AuditEntry struct {
+	ID         string        `json:"id" bson:"id"`
+	UID        string        `json:"-" bson:"uid"`                                   // the user whose data changed
+	ActorUID   string        `json:"actorUid" bson:"actorUid"`                       // who made the change
+	EntityType string        `json:"entityType" bson:"entityType"`                   // account, user, transaction
+	EntityID   string        `json:"entityId" bson:"entityId"`                       // the account id, or the uid
+	AccountID  string        `json:"accountId,omitempty" bson:"accountId,omitempty"` // the account the change belongs to
+	Operation  string        `json:"operation" bson:"operation"`                     // create, update, delete, import
+	Changes    []AuditChange `json:"changes,omitempty" bson:"changes,omitempty"`
+	RequestID  string        `json:"requestId,omitempty" bson:"requestId,omitempty"`
+	Time       time.Time     `json:"time" bson:"time"`
+}
+
+// AuditChange is the JSON value of a field before and after the change — an absent
+// value was not set. Imports record counts rather than the imported records.
+type AuditChange struct {
+	Field  string          `json:"field" bson:"field"`
+	Before json.RawMessage `json:"before,omitempty" bson:"before,omitempty"`
+	After  json.RawMessage `json:"after,omitempty" bson:"after,omitempty"`
+}
+
+const (
+	AuditCreate = "create"
+	AuditUpdate = "update"
+	AuditDelete = "delete"
+	AuditImport = "import"
+)
+
+// Id returns the unique id for the entry
+func (e *AuditEntry) Id() string {
+	return e.ID
+}
+
+func (e *AuditEntry) CollectionName() string {
+	return AUDIT_ENTRY_COLLECTION_NAME
+}
+
+type auditCtxKey int
+
+const (
+	actorCtxKey auditCtxKey = iota
+	requestIdCtxKey
+)
+
+// ContextWithActor returns ctx carrying the uid of the signed in user making the request.
+func ContextWithActor(ctx context.Context, uid string) context.Context {
+	return context.WithValue(ctx, actorCtxKey, uid)
+}
+
+// ActorFromCtx returns the uid set by ContextWithActor, empty when there is none e.g a pipeline run.
+func ActorFromCtx(ctx context.Context) string {
+	uid, _ := ctx.Value(actorCtxKey).(string)
+	return uid
+}
+
+// ContextWithRequestID returns ctx carrying the id of the API request.
+func ContextWithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIdCtxKey, id)
+}
+
+// RequestIDFromCtx returns the id set by ContextWithRequestID, empty when there is none.
+func RequestIDFromCtx(ctx context.Context) string {
+	id, _ := ctx.Value(requestIdCtxKey).(string)
+	return id
+}
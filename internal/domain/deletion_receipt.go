@@ -0,0 +1,35 @@
+package domain
+
+import "time"
+
+// DeletionReceipt records the deletion of an account or of a user and everything
+// that belonged to them. Receipts are kept after the user is deleted.
+type DeletionReceipt struct {
+	ID        string         `json:"id" bson:"id"`
+	UID       string         `json:"uid" bson:"uid"`
+	Scope     string         `json:"scope" bson:"scope"`                             // account, user
+	AccountID string         `json:"accountId,omitempty" bson:"accountId,omitempty"` // the account of an account deletion
+	Requested time.Time      `json:"requested" bson:"requested"`
+	Finished  *time.Time     `json:"finished,omitempty" bson:"finished,omitempty"`
+	Status    string         `json:"status" bson:"status"` // running, success, failed
+	Counts    map[string]int `json:"counts" bson:"counts"` // records deleted per collection
+	Error     string         `json:"error,omitempty" bson:"error,omitempty"`
+}
+
+const (
+	DeletionScopeAccount = "account"
+	DeletionScopeUser    = "user"
+
+	DeletionRunning = "running"
+	DeletionSuccess = "success"
+	DeletionFailed  = "failed"
+)
+
+// Id returns the unique id for the receipt
+func (r *DeletionReceipt) Id() string {
+	return r.ID
+}
+
+func (r *DeletionReceipt) CollectionName() string {
+	return DELETION_RECEIPT_COLLECTION_NAME
+}
@@ -0,0 +1,55 @@
+package domain
+
+import "time"
+
+// PipelineRun records one invocation of the pipeline command e.g sync-all.
+type PipelineRun struct {
+	ID        string               `json:"id" bson:"id"`
+	Command   string               `json:"command" bson:"command"`
+	Args      []string             `json:"args,omitempty" bson:"args,omitempty"`
+	Started   time.Time            `json:"started" bson:"started"`
+	Finished  *time.Time           `json:"finished,omitempty" bson:"finished,omitempty"`
+	Workers   int                  `json:"workers" bson:"workers"`
+	Status    string               `json:"status" bson:"status"` // running, success, failed, cancelled
+	Jobs      int                  `json:"jobs" bson:"jobs"`
+	Succeeded int                  `json:"succeeded" bson:"succeeded"`
+	Failed    int                  `json:"failed" bson:"failed"`
+	Skipped   int                  `json:"skipped" bson:"skipped"`
+	Retries   int                  `json:"retries" bson:"retries"`
+	Error     string               `json:"error,omitempty" bson:"error,omitempty"`
+	Outcomes  []PipelineJobOutcome `json:"outcomes,omitempty" bson:"outcomes,omitempty"` // one per user job
+}
+
+// PipelineJobOutcome is the result of one job in the run.
+type PipelineJobOutcome struct {
+	UserID     string `json:"userId" bson:"userId"`
+	Job        string `json:"job" bson:"job"`
+	Status     string `json:"status" bson:"status"` // success, failed
+	Attempts   int    `json:"attempts" bson:"attempts"`
+	DurationMs int64  `json:"durationMs" bson:"durationMs"`
+	Error      string `json:"error,omitempty" bson:"error,omitempty"`
+}
+
+const (
+	PipelineRunRunning   = "running"
+	PipelineRunSuccess   = "success"
+	PipelineRunFailed    = "failed"
+	PipelineRunCancelled = "cancelled"
+)
+
+// Id returns the unique id for the run
+func (r *PipelineRun) Id() string {
+	return r.ID
+}
+
+func (r *PipelineRun) CollectionName() string {
+	return PIPELINE_RUN_COLLECTION_NAME
+}
+
+// Duration is the wall time of a finished run.
+func (r *PipelineRun) Duration() time.Duration {
+	if r.Finished == nil {
+		return 0
+	}
+	return r.Finished.Sub(r.Started)
+}
@@ -1,6 +1,7 @@
 package domain
 
 import (
+	"sort"
 	"time"
 
 	"github.com/shopspring/decimal"
@@ -30,3 +31,42 @@ func (th *TickerHistory) Id() string {
 func (th *TickerHistory) CollectionName() string {
 	return TICKER_HISTORY_COLLECTION_NAME
 }
+
+// TickerSymbol returns the symbol a holding is priced with e.g ETH for staked ETH2.
+func TickerSymbol(symbol string) string {
+	switch symbol {
+	case "ETH2", "WETH":
+		return "ETH"
+	case "mSOL":
+		return "SOL"
+	}
+	return symbol
+}
+
+// DailyCloses returns the daily records of the history, oldest first. Records
+// without a granularity are taken as daily.
+func DailyCloses(history []*TickerHistory) []*TickerHistory {
+	daily := []*TickerHistory{}
+	for _, th := range history {
+		if th.Metadata.Granularity == GranularityDaily || len(th.Metadata.Granularity) == 0 {
+			daily = append(daily, th)
+		}
+	}
+	sort.Slice(daily, func(i, j int) bool {
+		return daily[i].Date.Before(daily[j].Date)
+	})
+	return daily
+}
+
+// CloseAt returns the close of the day of date in the daily closes, or the last
+// close before it e.g on a weekend. False when there is no close that early.
+func CloseAt(daily []*TickerHistory, date time.Time) (decimal.Decimal, bool) {
+	day := SnapshotDate(date)
+	i := sort.Search(len(daily), func(i int) bool {
+		return SnapshotDate(daily[i].Date).After(day)
+	})
+	if i == 0 {
+		return decimal.Zero, false
+	}
+	return daily[i-1].Close, true
+}
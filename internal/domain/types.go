@@ -7,6 +7,10 @@ const (
 	FIELD_ID         = "id"
 	FIELD_ACCOUNT_ID = "account_id"
 	FIELD_DATE       = "date"
+	FIELD_STARTED    = "started"
+	FIELD_COMMAND    = "command"
+	FIELD_TIME       = "time"
+	FIELD_STATUS     = "status"
 
 	//Fields
 	FIELD_SYMBOL             = "symbol"
@@ -34,15 +38,31 @@ const (
 	FIELD_TRANSACTION_DESCRIPTION = "description"
 	FIELD_TRANSACTION_TAG         = "tag"
 
+	// Fields for activities and lots
+	FIELD_ACTIVITY_ID          = "_id"
+	FIELD_ACTIVITY_ACCOUNT_ID  = "accountId"
+	FIELD_ACTIVITY_TXN_TYPE    = "txnType"
+	FIELD_ACTIVITY_STATUS      = "status"
+	FIELD_ACTIVITY_RCV_SYMBOL  = "rcvSymbol"
+	FIELD_ACTIVITY_SENT_SYMBOL = "sentSymbol"
+
 	// portfolio Collections
-	ACCOUNT_COLLECTION_NAME            = "account"
-	ACCOUNT_SYNC_STATE_COLLECTION_NAME = "account_sync_state"
-	ACCOUNT_CREDENTIAL_COLLECTION_NAME = "account_credential"
-	ACCOUNT_SUMMARY_COLLECTION_NAME    = "account_summary"
-	ACTIVITY_COLLECTION_NAME           = "activity"
-	ACTIVITY_IMPORT_COLLECTION_NAME    = "activity_import"
-	ACTIVITY_LOT_COLLECTION_NAME       = "activity_lot"
-	GL_ENTRY_COLLECTION                = "gl_entry"
+	ACCOUNT_COLLECTION_NAME               = "account"
+	ACCOUNT_SYNC_STATE_COLLECTION_NAME    = "account_sync_state"
+	ACCOUNT_CREDENTIAL_COLLECTION_NAME    = "account_credential"
+	ACCOUNT_SUMMARY_COLLECTION_NAME       = "account_summary"
+	ACCOUNT_VALUE_COLLECTION_NAME         = "account_value"
+	ACTIVITY_COLLECTION_NAME              = "activity"
+	ACTIVITY_IMPORT_COLLECTION_NAME       = "activity_import"
+	ACTIVITY_LOT_COLLECTION_NAME          = "activity_lot"
+	ACTIVITY_MAPPING_RULE_COLLECTION_NAME = "activity_mapping_rule"
+	GL_ENTRY_COLLECTION                   = "gl_entry"
+
+	// pipeline collections
+	PIPELINE_RUN_COLLECTION_NAME     = "pipeline_run"
+	USER_LOCK_COLLECTION_NAME        = "user_lock"
+	DELETION_RECEIPT_COLLECTION_NAME = "deletion_receipt"
+	AUDIT_ENTRY_COLLECTION_NAME      = "audit_entry"
 
 	// tickers collection
 	TICKER_CONTROL_COLLECTION_NAME   = "ticker_control"
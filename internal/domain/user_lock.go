@@ -0,0 +1,28 @@
+package domain
+
+import "time"
+
+// UserLock is a lease on a user's portfolio — held while a sync or refresh
+// writes the user's activities, lots and summaries. One lock per user.
+type UserLock struct {
+	ID          string    `json:"id" bson:"id"`               // the uid
+	Operation   string    `json:"operation" bson:"operation"` // sync, refresh
+	Owner       string    `json:"owner" bson:"owner"`         // process holding the lease
+	AcquiredAt  time.Time `json:"acquiredAt" bson:"acquiredAt"`
+	HeartbeatAt time.Time `json:"heartbeatAt" bson:"heartbeatAt"`
+	ExpiresAt   time.Time `json:"expiresAt" bson:"expiresAt"` // extended by each heartbeat
+}
+
+// Id returns the unique id for the lock
+func (l *UserLock) Id() string {
+	return l.ID
+}
+
+func (l *UserLock) CollectionName() string {
+	return USER_LOCK_COLLECTION_NAME
+}
+
+// Expired returns true when the owner stopped heartbeating e.g it crashed.
+func (l *UserLock) Expired(now time.Time) bool {
+	return !now.Before(l.ExpiresAt)
+}
@@ -0,0 +1,23 @@
+package dto
+
+import (
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// UnresolvedCounterparty is a deposit/withdrawal counterparty not matched to an account
+type UnresolvedCounterparty struct {
+	Name       string          `json:"name"`
+	AccountIDs []string        `json:"accountIds"` // accounts the activities were imported into
+	Count      int             `json:"count"`
+	Amount     decimal.Decimal `json:"amount"`
+	FirstDate  time.Time       `json:"firstDate"`
+	LastDate   time.Time       `json:"lastDate"`
+}
+
+// CounterpartyMapping maps a counterparty name to one of the user's accounts
+type CounterpartyMapping struct {
+	Name      string `json:"name"`
+	AccountID string `json:"accountId"`
+}
@@ -0,0 +1,36 @@
+package dto
+
+import "time"
+
+// CredentialInput is the plain text credential posted by the user
+type CredentialInput struct {
+	APIKey      string     `json:"apiKey"`
+	APISecret   string     `json:"apiSecret"`
+	Passphrase  string     `json:"passphrase"`
+	Label       string     `json:"label"`
+	Permissions []string   `json:"permissions"` // defaults to read
+	ExpiresAt   *time.Time `json:"expiresAt"`
+}
+
+// CredentialView describes a saved credential without its secrets
+type CredentialView struct {
+	AccountID     string     `json:"accountId"`
+	Provider      string     `json:"provider"`
+	Label         string     `json:"label"`
+	APIKeyHint    string     `json:"apiKeyHint"` // last 4 characters
+	HasSecret     bool       `json:"hasSecret"`
+	HasPassphrase bool       `json:"hasPassphrase"`
+	KeyVersion    string     `json:"keyVersion"`
+	Permissions   []string   `json:"permissions"`
+	ExpiresAt     *time.Time `json:"expiresAt,omitempty"`
+	CreatedAt     time.Time  `json:"createdAt"`
+	LastUsed      *time.Time `json:"lastUsed,omitempty"`
+	Active        bool       `json:"active"`
+	Warning       string     `json:"warning,omitempty"` // set before expiry
+}
+
+// CredentialTestResult is the outcome of testing a credential against its provider
+type CredentialTestResult struct {
+	OK      bool   `json:"ok"`
+	Message string `json:"message,omitempty"`
+}
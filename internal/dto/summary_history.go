@@ -0,0 +1,38 @@
+package dto
+
+import (
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// SummaryPoint is the value of an account, or of the total, on one day.
+type SummaryPoint struct {
+	Date        time.Time       `json:"date"`
+	MarketValue decimal.Decimal `json:"marketValue"`
+	CostValue   decimal.Decimal `json:"costValue"`
+	Cash        decimal.Decimal `json:"cash"`
+	NetDeposits decimal.Decimal `json:"netDeposits"`
+	Income      decimal.Decimal `json:"income"`
+}
+
+// Add adds the values of other to the point.
+func (p *SummaryPoint) Add(other SummaryPoint) {
+	p.MarketValue = p.MarketValue.Add(other.MarketValue)
+	p.CostValue = p.CostValue.Add(other.CostValue)
+	p.Cash = p.Cash.Add(other.Cash)
+	p.NetDeposits = p.NetDeposits.Add(other.NetDeposits)
+	p.Income = p.Income.Add(other.Income)
+}
+
+type SummarySeries struct {
+	AccountID   string         `json:"accountId"`
+	AccountName string         `json:"accountName"`
+	Points      []SummaryPoint `json:"points"`
+}
+
+// SummaryHistory is the time series of the account summary snapshots, oldest first.
+type SummaryHistory struct {
+	Total    []SummaryPoint   `json:"total"`
+	Accounts []*SummarySeries `json:"accounts"`
+}
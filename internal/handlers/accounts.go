@@ -4,20 +4,23 @@ import (
 	"encoding/json"
 	"log/slog"
 	"net/http"
+	"strconv"
 
 	"firebase.google.com/go/auth"
 	"github.com/gin-gonic/gin"
 	"github.com/rkapps/fin-tracker-backend-go/internal/domain"
+	"github.com/rkapps/fin-tracker-backend-go/internal/dto"
 	"github.com/rkapps/fin-tracker-backend-go/internal/services"
 	"github.com/rkapps/fin-tracker-backend-go/internal/utils"
 )
 
 type AccountsHandler struct {
-	Service services.AccountsService
+	Service         services.AccountsService
+	DeletionService services.DeletionService
 }
 
-func NewAccountsHandler(router *gin.Engine, service services.AccountsService) *AccountsHandler {
-	return &AccountsHandler{service}
+func NewAccountsHandler(router *gin.Engine, service services.AccountsService, deletionService services.DeletionService) *AccountsHandler {
+	return &AccountsHandler{service, deletionService}
 }
 
 func (a *AccountsHandler) RegisterRoutes(router *gin.Engine, fbAuthClient *auth.Client) {
@@ -29,6 +32,21 @@ func (a *AccountsHandler) RegisterRoutes(router *gin.Engine, fbAuthClient *auth.
 	sGroup.PUT(":id", AuthHandler(fbAuthClient, a.UpdateAccount))
 	sGroup.DELETE(":id", AuthHandler(fbAuthClient, a.DeleteAccount))
 	sGroup.POST(":id/activities", AuthHandler(fbAuthClient, a.ImportActivities))
+	sGroup.GET(":id/unmapped", AuthHandler(fbAuthClient, a.GetUnmappedActivities))
+	sGroup.GET("/sync-states", AuthHandler(fbAuthClient, a.GetAccountSyncStates))
+	sGroup.GET(":id/sync-state", AuthHandler(fbAuthClient, a.GetAccountSyncState))
+	sGroup.POST(":id/resync", AuthHandler(fbAuthClient, a.ResyncAccount))
+	sGroup.GET(":id/audit", AuthHandler(fbAuthClient, a.GetAuditEntries))
+
+	cGroup := router.Group("/counterparties")
+	cGroup.GET("/unresolved", AuthHandler(fbAuthClient, a.GetUnresolvedCounterparties))
+	cGroup.POST("/map", AuthHandler(fbAuthClient, a.MapCounterparty))
+
+	rGroup := router.Group("/mapping-rules")
+	rGroup.GET("", AuthHandler(fbAuthClient, a.GetActivityMappingRules))
+	rGroup.POST("", AuthHandler(fbAuthClient, a.SaveActivityMappingRule))
+	rGroup.PUT(":id", AuthHandler(fbAuthClient, a.SaveActivityMappingRule))
+	rGroup.DELETE(":id", AuthHandler(fbAuthClient, a.DeleteActivityMappingRule))
 
 	// sGroup.POST("/load", AuthHandler(fbAuthClient, h.UserService, h.LoadAccounts))
 	// sGroup.GET("/:id/delete", AuthHandler(fbAuthClient, h.UserService, h.DeleteAccount))
@@ -41,7 +59,8 @@ func (a *AccountsHandler) RegisterRoutes(router *gin.Engine, fbAuthClient *auth.
 	// sGroup.GET("/gainloss", AuthHandler(fbAuthClient, h.UserService, h.GetGainLoss))
 }
 
-// DeleteAccount
+// DeleteAccount starts deleting the account and its data — 202 with the receipt
+// to poll at /user/deletions/:id, 409 when a sync or refresh is running for the user.
 func (a *AccountsHandler) DeleteAccount(c *gin.Context) {
 
 	uid, err := getUID(c)
@@ -51,11 +70,12 @@ func (a *AccountsHandler) DeleteAccount(c *gin.Context) {
 	}
 	id := c.Param("id")
 
-	err = a.Service.DeleteAccount(c, uid, id)
+	receipt, err := a.DeletionService.DeleteAccount(c.Request.Context(), uid, id)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, err)
+		respondError(c, err)
 		return
 	}
+	c.JSON(http.StatusAccepted, receipt)
 }
 
 // GetAccounts gets the accounts in the portfolio
@@ -67,9 +87,9 @@ func (a *AccountsHandler) GetAccount(c *gin.Context) {
 		return
 	}
 	id := c.Param("id")
-	accts, err := a.Service.GetAccount(uid, id)
+	accts, err := a.Service.GetAccount(c.Request.Context(), uid, id)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, err)
+		respondError(c, err)
 		return
 	}
 	c.JSON(http.StatusOK, accts)
@@ -84,9 +104,9 @@ func (a *AccountsHandler) GetAccounts(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, err)
 		return
 	}
-	accts, err := a.Service.GetAccounts(uid)
+	accts, err := a.Service.GetAccounts(c.Request.Context(), uid)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, err)
+		respondError(c, err)
 		return
 	}
 	c.JSON(http.StatusOK, accts)
@@ -112,12 +132,10 @@ func (a *AccountsHandler) CreateAccount(c *gin.Context) {
 	}
 	slog.Info("CreateAccount", "UId", uid, "Data", data)
 
-	acct, err := a.Service.CreateAccount(c, uid, data)
+	acct, err := a.Service.CreateAccount(c.Request.Context(), uid, data)
 	if err != nil {
 		slog.Debug("CreateAccount", "Error", err)
-		c.JSON(http.StatusBadRequest, gin.H{
-			"message": err.Error(),
-		})
+		respondError(c, err)
 		return
 	}
 	c.JSON(http.StatusOK, acct)
@@ -142,12 +160,10 @@ func (a *AccountsHandler) UpdateAccount(c *gin.Context) {
 		return
 	}
 
-	err = a.Service.UpdateAccount(c, uid, id, data)
+	err = a.Service.UpdateAccount(c.Request.Context(), uid, id, data)
 	if err != nil {
 		slog.Debug("UpdateAccount", "Error", err)
-		c.JSON(http.StatusBadRequest, gin.H{
-			"message": err.Error(),
-		})
+		respondError(c, err)
 		return
 	}
 
@@ -231,6 +247,216 @@ func (a *AccountsHandler) ImportActivities(c *gin.Context) {
 		return
 	}
 
-	a.Service.ImportActivities(c, uid, acctId, startDate, data)
+	err = a.Service.ImportActivities(c.Request.Context(), uid, acctId, startDate, data)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
 	slog.Info("ImportActivities", "Count", len(data))
 }
+
+// GetUnmappedActivities returns the imported activities that could not be mapped
+func (a *AccountsHandler) GetUnmappedActivities(c *gin.Context) {
+
+	uid, err := getUID(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, err)
+		return
+	}
+	acctId := c.Param("id")
+
+	iactvs, err := a.Service.GetUnmappedActivities(c.Request.Context(), uid, acctId)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, iactvs)
+}
+
+// GetAccountSyncStates returns the sync state of every account
+func (a *AccountsHandler) GetAccountSyncStates(c *gin.Context) {
+
+	uid, err := getUID(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, err)
+		return
+	}
+
+	astates, err := a.Service.GetAccountSyncStates(c.Request.Context(), uid)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, astates)
+}
+
+// GetAccountSyncState returns the sync state of an account
+func (a *AccountsHandler) GetAccountSyncState(c *gin.Context) {
+
+	uid, err := getUID(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, err)
+		return
+	}
+	acctId := c.Param("id")
+
+	astate, err := a.Service.GetAccountSyncState(c.Request.Context(), uid, acctId)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, astate)
+}
+
+// GetAuditEntries returns the changes to an account, newest first — ?limit=100
+func (a *AccountsHandler) GetAuditEntries(c *gin.Context) {
+
+	uid, err := getUID(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, err)
+		return
+	}
+	acctId := c.Param("id")
+	var limit int64
+	if slimit := c.Query("limit"); len(slimit) > 0 {
+		value, err := strconv.ParseInt(slimit, 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"message": "invalid limit: " + slimit,
+			})
+			return
+		}
+		limit = value
+	}
+
+	entries, err := a.Service.GetAuditEntries(c.Request.Context(), uid, acctId, limit)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, entries)
+}
+
+// ResyncAccount flags an account for a full history pull on the next sync
+func (a *AccountsHandler) ResyncAccount(c *gin.Context) {
+
+	uid, err := getUID(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, err)
+		return
+	}
+	acctId := c.Param("id")
+
+	astate, err := a.Service.ResyncAccount(c.Request.Context(), uid, acctId)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, astate)
+}
+
+// GetActivityMappingRules returns the user's mapping rules
+func (a *AccountsHandler) GetActivityMappingRules(c *gin.Context) {
+
+	uid, err := getUID(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, err)
+		return
+	}
+
+	rules, err := a.Service.GetActivityMappingRules(c.Request.Context(), uid)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, rules)
+}
+
+// SaveActivityMappingRule creates or updates a mapping rule
+func (a *AccountsHandler) SaveActivityMappingRule(c *gin.Context) {
+
+	uid, err := getUID(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, err)
+		return
+	}
+
+	var data *domain.ActivityMappingRule
+	err = json.NewDecoder(c.Request.Body).Decode(&data)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+	// a POST creates a rule, a PUT updates the rule of the path — never an id in the body
+	data.ID = c.Param("id")
+
+	rule, err := a.Service.SaveActivityMappingRule(c.Request.Context(), uid, data)
+	if err != nil {
+		slog.Debug("SaveActivityMappingRule", "Error", err)
+		respondError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, rule)
+}
+
+// DeleteActivityMappingRule deletes a mapping rule
+func (a *AccountsHandler) DeleteActivityMappingRule(c *gin.Context) {
+
+	uid, err := getUID(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, err)
+		return
+	}
+	id := c.Param("id")
+
+	err = a.Service.DeleteActivityMappingRule(c.Request.Context(), uid, id)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+}
+
+// GetUnresolvedCounterparties returns the counterparties not matched to an account
+func (a *AccountsHandler) GetUnresolvedCounterparties(c *gin.Context) {
+
+	uid, err := getUID(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, err)
+		return
+	}
+
+	cps, err := a.Service.GetUnresolvedCounterparties(c.Request.Context(), uid)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, cps)
+}
+
+// MapCounterparty maps a counterparty to one of the user's accounts
+func (a *AccountsHandler) MapCounterparty(c *gin.Context) {
+
+	uid, err := getUID(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, err)
+		return
+	}
+
+	var data dto.CounterpartyMapping
+	err = json.NewDecoder(c.Request.Body).Decode(&data)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+
+	err = a.Service.MapCounterparty(c.Request.Context(), uid, data)
+	if err != nil {
+		slog.Debug("MapCounterparty", "Error", err)
+		respondError(c, err)
+		return
+	}
+}
@@ -0,0 +1,60 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"firebase.google.com/go/auth"
+	"github.com/gin-gonic/gin"
+	"github.com/rkapps/fin-tracker-backend-go/internal/services"
+)
+
+type AdminHandler struct {
+	Service services.PipelineRunsService
+}
+
+func NewAdminHandler(router *gin.Engine, service services.PipelineRunsService) *AdminHandler {
+	return &AdminHandler{service}
+}
+
+func (h *AdminHandler) RegisterRoutes(router *gin.Engine, fbAuthClient *auth.Client) {
+
+	sGroup := router.Group("/admin")
+	sGroup.GET("/pipeline-runs", AdminAuthHandler(fbAuthClient, h.GetPipelineRuns))
+	sGroup.GET("/pipeline-runs/:id", AdminAuthHandler(fbAuthClient, h.GetPipelineRun))
+}
+
+// GetPipelineRuns returns recent runs, newest first — ?command=sync-all&limit=50
+func (h *AdminHandler) GetPipelineRuns(c *gin.Context) {
+
+	command := c.Query("command")
+	var limit int64
+	if slimit := c.Query("limit"); len(slimit) > 0 {
+		value, err := strconv.ParseInt(slimit, 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"message": "invalid limit: " + slimit,
+			})
+			return
+		}
+		limit = value
+	}
+
+	runs, err := h.Service.GetRuns(c.Request.Context(), command, limit)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, runs)
+}
+
+// GetPipelineRun returns the run with the per-user outcomes
+func (h *AdminHandler) GetPipelineRun(c *gin.Context) {
+
+	run, err := h.Service.GetRun(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, run)
+}
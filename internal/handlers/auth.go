@@ -3,10 +3,12 @@ package handlers
 import (
 	"log/slog"
 	"net/http"
+	"os"
 	"strings"
 
 	"firebase.google.com/go/auth"
 	"github.com/gin-gonic/gin"
+	"github.com/rkapps/fin-tracker-backend-go/internal/domain"
 )
 
 func AuthHandler(fbAuthClient *auth.Client, f func(c *gin.Context)) func(c *gin.Context) {
@@ -36,10 +38,32 @@ func AuthHandler(fbAuthClient *auth.Client, f func(c *gin.Context)) func(c *gin.
 		}
 		// slog.Debug("AuthHandler", "UID", authToken.UID)
 		c.Set("uid", authToken.UID)
+		c.Request = c.Request.WithContext(domain.ContextWithActor(c.Request.Context(), authToken.UID))
 		f(c)
 	}
 }
 
+// AdminAuthHandler only lets through the uids listed in ADMIN_UIDS (comma separated).
+func AdminAuthHandler(fbAuthClient *auth.Client, f func(c *gin.Context)) func(c *gin.Context) {
+
+	admins := map[string]bool{}
+	for _, uid := range strings.Split(os.Getenv("ADMIN_UIDS"), ",") {
+		if uid = strings.TrimSpace(uid); len(uid) > 0 {
+			admins[uid] = true
+		}
+	}
+
+	return AuthHandler(fbAuthClient, func(c *gin.Context) {
+		uid, _ := getUID(c)
+		if !admins[uid] {
+			slog.Info("AdminAuthHandler", "error", "Not an admin", "UID", uid)
+			c.AbortWithStatusJSON(http.StatusForbidden, "Not authorized.")
+			return
+		}
+		f(c)
+	})
+}
+
 func getUID(c *gin.Context) (string, error) {
 	value, _ := c.Get("uid")
 	id := value.(string)
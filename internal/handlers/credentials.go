@@ -0,0 +1,129 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"firebase.google.com/go/auth"
+	"github.com/gin-gonic/gin"
+	"github.com/rkapps/fin-tracker-backend-go/internal/dto"
+	"github.com/rkapps/fin-tracker-backend-go/internal/services"
+)
+
+type CredentialsHandler struct {
+	Service services.CredentialsService
+}
+
+func NewCredentialsHandler(router *gin.Engine, service services.CredentialsService) *CredentialsHandler {
+	return &CredentialsHandler{service}
+}
+
+func (h *CredentialsHandler) RegisterRoutes(router *gin.Engine, fbAuthClient *auth.Client) {
+
+	sGroup := router.Group("/accounts/:id/credential")
+	sGroup.GET("", AuthHandler(fbAuthClient, h.GetCredential))
+	sGroup.PUT("", AuthHandler(fbAuthClient, h.SaveCredential))
+	sGroup.POST("/test", AuthHandler(fbAuthClient, h.TestCredential))
+	sGroup.DELETE("", AuthHandler(fbAuthClient, h.RevokeCredential))
+
+	cGroup := router.Group("/credentials")
+	cGroup.GET("", AuthHandler(fbAuthClient, h.GetCredentials))
+}
+
+// GetCredentials returns the metadata of all credentials with expiry warnings
+func (h *CredentialsHandler) GetCredentials(c *gin.Context) {
+
+	uid, err := getUID(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, err)
+		return
+	}
+
+	views, err := h.Service.GetCredentials(c.Request.Context(), uid)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, views)
+}
+
+// GetCredential returns the credential metadata of the account
+func (h *CredentialsHandler) GetCredential(c *gin.Context) {
+
+	uid, err := getUID(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, err)
+		return
+	}
+	acctId := c.Param("id")
+
+	view, err := h.Service.GetCredential(c.Request.Context(), uid, acctId)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, view)
+}
+
+// SaveCredential adds or replaces the credential of the account
+func (h *CredentialsHandler) SaveCredential(c *gin.Context) {
+
+	uid, err := getUID(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, err)
+		return
+	}
+	acctId := c.Param("id")
+
+	var input dto.CredentialInput
+	err = json.NewDecoder(c.Request.Body).Decode(&input)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+
+	view, err := h.Service.SaveCredential(c.Request.Context(), uid, acctId, input)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, view)
+}
+
+// TestCredential checks the credential against the provider
+func (h *CredentialsHandler) TestCredential(c *gin.Context) {
+
+	uid, err := getUID(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, err)
+		return
+	}
+	acctId := c.Param("id")
+
+	result, err := h.Service.TestCredential(c.Request.Context(), uid, acctId)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, result)
+}
+
+// RevokeCredential deactivates the credential of the account
+func (h *CredentialsHandler) RevokeCredential(c *gin.Context) {
+
+	uid, err := getUID(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, err)
+		return
+	}
+	acctId := c.Param("id")
+
+	err = h.Service.RevokeCredential(c.Request.Context(), uid, acctId)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
@@ -0,0 +1,41 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rkapps/fin-tracker-backend-go/internal/locks"
+	"github.com/rkapps/fin-tracker-backend-go/internal/storage"
+)
+
+// statusClientClosedRequest is the nginx status for a request the client gave up on.
+const statusClientClosedRequest = 499
+
+// errorStatus maps a service error to its HTTP status code.
+// Errors that are not storage or context errors are the caller's input — 400.
+func errorStatus(err error) int {
+	switch {
+	case errors.Is(err, storage.ErrNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, storage.ErrUnauthorized):
+		return http.StatusForbidden
+	case errors.Is(err, storage.ErrConflict), errors.Is(err, locks.ErrAlreadyRunning):
+		return http.StatusConflict
+	case errors.Is(err, storage.ErrTransient):
+		return http.StatusServiceUnavailable
+	case errors.Is(err, context.DeadlineExceeded):
+		return http.StatusGatewayTimeout
+	case errors.Is(err, context.Canceled):
+		return statusClientClosedRequest
+	}
+	return http.StatusBadRequest
+}
+
+// respondError responds with the status code of the service error and its message.
+func respondError(c *gin.Context, err error) {
+	c.JSON(errorStatus(err), gin.H{
+		"message": err.Error(),
+	})
+}
@@ -1,6 +1,7 @@
 package handlers
 
 import (
+	"errors"
 	"fmt"
 	"net/http"
 	"strconv"
@@ -10,6 +11,8 @@ import (
 	"firebase.google.com/go/auth"
 	"github.com/gin-gonic/gin"
 	"github.com/rkapps/fin-tracker-backend-go/cmd/common/logger"
+	"github.com/rkapps/fin-tracker-backend-go/internal/domain"
+	"github.com/rkapps/fin-tracker-backend-go/internal/locks"
 	"github.com/rkapps/fin-tracker-backend-go/internal/services"
 	"github.com/rkapps/fin-tracker-backend-go/internal/utils"
 )
@@ -29,13 +32,42 @@ func (p *PortfolioHandler) RegisterRoutes(router *gin.Engine, fbAuthClient *auth
 
 	sGroup := router.Group("/portfolio")
 	sGroup.GET("/summary", AuthHandler(fbAuthClient, p.GetSummary))
+	sGroup.GET("/summary/history", AuthHandler(fbAuthClient, p.GetSummaryHistory))
+	sGroup.GET("/values", AuthHandler(fbAuthClient, p.GetValueHistory))
 	sGroup.GET("/holdings", AuthHandler(fbAuthClient, p.GetHoldings))
 	sGroup.GET("/income", AuthHandler(fbAuthClient, p.GetIncome))
 	sGroup.GET("/gainloss", AuthHandler(fbAuthClient, p.GetGainLoss))
 	sGroup.GET("/activities", AuthHandler(fbAuthClient, p.GetActivities))
+	sGroup.GET("/lots", AuthHandler(fbAuthClient, p.GetLots))
+	sGroup.POST("/refresh", AuthHandler(fbAuthClient, p.RefreshPortfolio))
 
 }
 
+// RefreshPortfolio recomputes lots and summaries now rather than waiting for the pipeline.
+// Returns 409 when a sync or refresh is already running for the user.
+func (p *PortfolioHandler) RefreshPortfolio(c *gin.Context) {
+	uid, err := getUID(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, err)
+		return
+	}
+
+	err = p.Service.RefreshUserAccounts(c.Request.Context(), uid, false)
+	if errors.Is(err, locks.ErrAlreadyRunning) {
+		c.JSON(http.StatusConflict, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{})
+}
+
 // GetSummary gets the accounts in the portfolio
 func (p *PortfolioHandler) GetSummary(c *gin.Context) {
 	uid, err := getUID(c)
@@ -44,15 +76,73 @@ func (p *PortfolioHandler) GetSummary(c *gin.Context) {
 		return
 	}
 
-	asumys, err := p.Service.GetSummary(uid)
+	asumys, err := p.Service.GetSummary(c.Request.Context(), uid)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, err)
+		respondError(c, err)
 		return
 	}
 	c.JSON(http.StatusOK, asumys)
 
 }
 
+// GetSummaryHistory gets the daily time series of the account summaries, per account and in total.
+// Filters: acctIds, category, type, startDate, endDate.
+func (p *PortfolioHandler) GetSummaryHistory(c *gin.Context) {
+	uid, err := getUID(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, err)
+		return
+	}
+
+	category := c.Query("category")
+	atype := c.Query("type")
+	acctIds := queryList(c, "acctIds")
+	var startDate, endDate time.Time
+	if sStartDate := c.Query("startDate"); len(sStartDate) > 0 {
+		startDate = utils.DateFromString(sStartDate)
+	}
+	if sEndDate := c.Query("endDate"); len(sEndDate) > 0 {
+		endDate = utils.TruncateToEndOfDay(utils.DateFromString(sEndDate))
+	}
+
+	p.logger.Info("GetSummaryHistory", "Category-Type", fmt.Sprintf("%s-%s", category, atype), "AcctIds", acctIds)
+	history, err := p.Service.GetSummaryHistory(c.Request.Context(), uid, category, atype, acctIds, startDate, endDate)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, history)
+}
+
+// GetValueHistory gets the backfilled daily values of the accounts, per account and in total.
+// Filters: acctIds, category, type, startDate, endDate.
+func (p *PortfolioHandler) GetValueHistory(c *gin.Context) {
+	uid, err := getUID(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, err)
+		return
+	}
+
+	category := c.Query("category")
+	atype := c.Query("type")
+	acctIds := queryList(c, "acctIds")
+	var startDate, endDate time.Time
+	if sStartDate := c.Query("startDate"); len(sStartDate) > 0 {
+		startDate = utils.DateFromString(sStartDate)
+	}
+	if sEndDate := c.Query("endDate"); len(sEndDate) > 0 {
+		endDate = utils.TruncateToEndOfDay(utils.DateFromString(sEndDate))
+	}
+
+	p.logger.Info("GetValueHistory", "Category-Type", fmt.Sprintf("%s-%s", category, atype), "AcctIds", acctIds)
+	history, err := p.Service.GetValueHistory(c.Request.Context(), uid, category, atype, acctIds, startDate, endDate)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, history)
+}
+
 // GetAccounts gets the accounts in the portfolio
 func (p *PortfolioHandler) GetHoldings(c *gin.Context) {
 	uid, err := getUID(c)
@@ -71,47 +161,99 @@ func (p *PortfolioHandler) GetHoldings(c *gin.Context) {
 
 	p.logger.Info("GetHoldings", "Category-type", fmt.Sprintf("%s-%s", category, atype), "AcctIds", acctIds)
 
-	hldgs, err := p.Service.GetHoldings(uid, category, atype, acctIds)
+	hldgs, err := p.Service.GetHoldings(c.Request.Context(), uid, category, atype, acctIds)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, err)
+		respondError(c, err)
 		return
 	}
 	c.JSON(http.StatusOK, hldgs)
 
 }
 
-// GetActivities gets the activities in the portfolio
+// GetActivities gets one page of the activities in the portfolio.
+// Filters: acctIds, category, type, startDate, endDate, symbols, txnTypes, status.
+// Paging: limit, cursor and sort (date, symbol, - for descending) — the next cursor is in X-Next-Cursor.
 func (p *PortfolioHandler) GetActivities(c *gin.Context) {
 	uid, err := getUID(c)
 	if err != nil {
 		c.JSON(http.StatusBadRequest, err)
 		return
 	}
-	ids := c.Query("acctIds")
-	var acctIds []string
-	if len(ids) > 0 {
-		ids = utils.TrimCommas(ids)
-		acctIds = strings.Split(ids, ",")
+	page, err := queryPage(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": err.Error(),
+		})
+		return
 	}
 
 	category := c.Query("category")
 	atype := c.Query("type")
-	sStartDate := c.Query("startDate")
+	query := domain.ActivityQuery{AccountIDs: queryList(c, "acctIds"), Symbols: queryList(c, "symbols"), Page: page}
+	if sStartDate := c.Query("startDate"); len(sStartDate) > 0 {
+		query.StartDate = utils.DateFromString(sStartDate)
+	}
+	if sEndDate := c.Query("endDate"); len(sEndDate) > 0 {
+		query.EndDate = utils.TruncateToEndOfDay(utils.DateFromString(sEndDate))
+	}
+	for _, txnType := range queryList(c, "txnTypes") {
+		query.TxnTypes = append(query.TxnTypes, domain.ActivityType(txnType))
+	}
+	for _, status := range queryList(c, "status") {
+		query.Statuses = append(query.Statuses, domain.ActivityStatus(status))
+	}
 
-	startDate := time.Time{}
-	endDate := time.Time{}
-	if len(sStartDate) > 0 {
-		startDate = utils.DateFromString(sStartDate)
+	p.logger.Info("GetActivities", "Category-Type", fmt.Sprintf("%s-%s-%v", category, atype, query.StartDate), "AcctIds", query.AccountIDs, "Limit", page.Limit)
+
+	actvs, err := p.Service.GetActivities(c.Request.Context(), uid, category, atype, query)
+	if err != nil {
+		respondError(c, err)
+		return
 	}
+	setNextCursor(c, actvs.NextCursor)
+	c.JSON(http.StatusOK, actvs.Items)
 
-	p.logger.Info("GetActivities", "Category-Type", fmt.Sprintf("%s-%s-%v", category, atype, startDate), "AcctIds", acctIds)
+}
 
-	actvs, err := p.Service.GetActivities(uid, category, atype, acctIds, startDate, endDate)
+// GetLots gets one page of the lots in the portfolio.
+// Filters: acctIds, category, type, startDate, endDate (acquisition), symbols, status.
+// Paging: limit, cursor and sort (date, symbol, - for descending) — the next cursor is in X-Next-Cursor.
+func (p *PortfolioHandler) GetLots(c *gin.Context) {
+	uid, err := getUID(c)
 	if err != nil {
 		c.JSON(http.StatusBadRequest, err)
 		return
 	}
-	c.JSON(http.StatusOK, actvs)
+	page, err := queryPage(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+
+	category := c.Query("category")
+	atype := c.Query("type")
+	query := domain.LotQuery{AccountIDs: queryList(c, "acctIds"), Symbols: queryList(c, "symbols"), Page: page}
+	if sStartDate := c.Query("startDate"); len(sStartDate) > 0 {
+		query.StartDate = utils.DateFromString(sStartDate)
+	}
+	if sEndDate := c.Query("endDate"); len(sEndDate) > 0 {
+		query.EndDate = utils.TruncateToEndOfDay(utils.DateFromString(sEndDate))
+	}
+	for _, status := range queryList(c, "status") {
+		query.Statuses = append(query.Statuses, domain.LotStatus(status))
+	}
+
+	p.logger.Info("GetLots", "Category-Type", fmt.Sprintf("%s-%s", category, atype), "AcctIds", query.AccountIDs, "Limit", page.Limit)
+
+	lots, err := p.Service.GetLots(c.Request.Context(), uid, category, atype, query)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	setNextCursor(c, lots.NextCursor)
+	c.JSON(http.StatusOK, lots.Items)
 
 }
 
@@ -146,9 +288,9 @@ func (p *PortfolioHandler) GetIncome(c *gin.Context) {
 	category := c.Query("category")
 	atype := c.Query("type")
 	p.logger.Info("GetIncome", "Category-Type", fmt.Sprintf("%s-%s", category, atype), "AcctIds", acctIds)
-	incomes, err := p.Service.GetIncome(uid, category, atype, acctIds, startDate, endDate)
+	incomes, err := p.Service.GetIncome(c.Request.Context(), uid, category, atype, acctIds, startDate, endDate)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, err)
+		respondError(c, err)
 		return
 	}
 
@@ -0,0 +1,46 @@
+package handlers
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rkapps/fin-tracker-backend-go/internal/domain"
+	"github.com/rkapps/fin-tracker-backend-go/internal/utils"
+)
+
+// headerNextCursor carries the cursor of the next page so list responses stay plain arrays.
+const headerNextCursor = "X-Next-Cursor"
+
+// queryList splits a comma separated query parameter e.g acctIds=a,b.
+func queryList(c *gin.Context, name string) []string {
+	value := utils.TrimCommas(c.Query(name))
+	if len(value) == 0 {
+		return nil
+	}
+	return strings.Split(value, ",")
+}
+
+// queryPage reads the limit, cursor and sort parameters. No limit returns everything.
+func queryPage(c *gin.Context) (domain.Page, error) {
+	page := domain.Page{Cursor: c.Query("cursor"), Sort: c.Query("sort")}
+	if sLimit := c.Query("limit"); len(sLimit) > 0 {
+		limit, err := strconv.Atoi(sLimit)
+		if err != nil || limit < 1 {
+			return page, fmt.Errorf("invalid limit: %s", sLimit)
+		}
+		page.Limit = limit
+	}
+	if _, _, err := page.SortField(); err != nil {
+		return page, err
+	}
+	return page, nil
+}
+
+// setNextCursor tells the client where the next page starts, nothing on the last page.
+func setNextCursor(c *gin.Context, cursor string) {
+	if len(cursor) > 0 {
+		c.Header(headerNextCursor, cursor)
+	}
+}
@@ -0,0 +1,24 @@
+package handlers
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/rkapps/fin-tracker-backend-go/internal/domain"
+)
+
+// RequestIDHeader carries the id of an API request, e.g in the audit trail.
+const RequestIDHeader = "X-Request-ID"
+
+// RequestID keeps the caller's X-Request-ID, or generates one, returns it in the
+// response header and puts it in the request context for the services.
+func RequestID() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.GetHeader(RequestIDHeader)
+		if len(id) == 0 || len(id) > 128 {
+			id = uuid.New().String()
+		}
+		c.Header(RequestIDHeader, id)
+		c.Request = c.Request.WithContext(domain.ContextWithRequestID(c.Request.Context(), id))
+		c.Next()
+	}
+}
@@ -36,10 +36,10 @@ func (h *StocksHandler) RegisterRoutes(router *gin.Engine) {
 // GetTicker gets a single ticker based on the symbol
 func (h *StocksHandler) GetTicker(c *gin.Context) {
 	symbol := c.Param("symbol")
-	tk, err := h.Service.GetTicker(c, symbol)
+	tk, err := h.Service.GetTicker(c.Request.Context(), symbol)
 	if err != nil {
 		slog.Error("GetTicker", "Error", err)
-		c.JSON(http.StatusBadRequest, err)
+		respondError(c, err)
 		return
 	}
 	c.JSON(http.StatusOK, tk)
@@ -47,10 +47,10 @@ func (h *StocksHandler) GetTicker(c *gin.Context) {
 
 // GetTickerGroups returns ticker groups based on sector and industry
 func (h *StocksHandler) GetTickerGroups(c *gin.Context) {
-	tgs, err := h.Service.GetTickerGroups(c)
+	tgs, err := h.Service.GetTickerGroups(c.Request.Context())
 	if err != nil {
 		slog.Error("GetTickerGroups", "Error", err)
-		c.JSON(http.StatusBadRequest, err)
+		respondError(c, err)
 		return
 	}
 	c.JSON(http.StatusOK, tgs)
@@ -61,10 +61,10 @@ func (h *StocksHandler) GetTickerHistory(c *gin.Context) {
 
 	symbol := c.Param("symbol")
 	slog.Info("GetTickerHistory", "symbol", symbol)
-	tk, err := h.Service.GetTickerHistory(c, symbol)
+	tk, err := h.Service.GetTickerHistory(c.Request.Context(), symbol)
 	if err != nil {
 		slog.Error("GetTickerHistory", "Error", err)
-		c.JSON(http.StatusBadRequest, err)
+		respondError(c, err)
 		return
 	}
 	c.JSON(http.StatusOK, tk)
@@ -75,10 +75,10 @@ func (h *StocksHandler) GetTickerEmbeddings(c *gin.Context) {
 
 	symbol := c.Param("symbol")
 	slog.Info("GetTickerEmbeddings", "symbol", symbol)
-	tk, err := h.Service.GetTickerEmbeddings(c, symbol)
+	tk, err := h.Service.GetTickerEmbeddings(c.Request.Context(), symbol)
 	if err != nil {
 		slog.Error("GetTickerSentiments", "Error", err)
-		c.JSON(http.StatusBadRequest, err)
+		respondError(c, err)
 		return
 	}
 	c.JSON(http.StatusOK, tk)
@@ -89,10 +89,10 @@ func (h *StocksHandler) GetTickerSentiments(c *gin.Context) {
 
 	symbol := c.Param("symbol")
 	slog.Info("GetTickerSentiments", "symbol", symbol)
-	tk, err := h.Service.GetTickerSentiments(c, symbol)
+	tk, err := h.Service.GetTickerSentiments(c.Request.Context(), symbol)
 	if err != nil {
 		slog.Error("GetTickerSentiments", "Error", err)
-		c.JSON(http.StatusBadRequest, err)
+		respondError(c, err)
 		return
 	}
 	c.JSON(http.StatusOK, tk)
@@ -103,10 +103,10 @@ func (h *StocksHandler) GetTickers(c *gin.Context) {
 
 	symbols := strings.Split(c.Query("symbols"), ",")
 	slog.Info("GetTickers", "Symbols", symbols)
-	tks, err := h.Service.GetTickers(c, symbols)
+	tks, err := h.Service.GetTickers(c.Request.Context(), symbols)
 	if err != nil {
 		slog.Error("GetTickers", "Error", err)
-		c.JSON(http.StatusBadRequest, err)
+		respondError(c, err)
 		return
 	}
 	c.JSON(http.StatusOK, tks)
@@ -117,9 +117,9 @@ func (h *StocksHandler) SearchTickers(c *gin.Context) {
 	var ts domain.TickerSearch
 	json.NewDecoder(c.Request.Body).Decode(&ts)
 	slog.Info("SearchTickers", "TickerSearch", ts)
-	tks, err := h.Service.SearchTicker(c, ts)
+	tks, err := h.Service.SearchTicker(c.Request.Context(), ts)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, err)
+		respondError(c, err)
 		return
 	}
 	c.JSON(http.StatusOK, tks)
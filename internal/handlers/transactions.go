@@ -47,11 +47,10 @@ func (h *TransactionsHandler) SearchTransactions(c *gin.Context) {
 
 	slog.Info("SearchTransactions started", "Startdate", startDate, "EndDate", endDate)
 
-	txns, err := h.Service.SearchTransactions(uid, startDate, endDate, searchText)
+	txns, err := h.Service.SearchTransactions(c.Request.Context(), uid, startDate, endDate, searchText)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"message": err.Error(),
-		})
+		respondError(c, err)
+		return
 	}
 
 	for _, txn := range txns {
@@ -81,11 +80,10 @@ func (h *TransactionsHandler) SummaryTransactions(c *gin.Context) {
 
 	slog.Info("SummaryTransactions started", "Startdate", startDate, "EndDate", endDate)
 
-	txns, err := h.Service.SummaryTransactions(uid, startDate, endDate)
+	txns, err := h.Service.SummaryTransactions(c.Request.Context(), uid, startDate, endDate)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"message": err.Error(),
-		})
+		respondError(c, err)
+		return
 	}
 
 	slog.Info(fmt.Sprintf("SummaryTransactions count: %d", len(txns)))
@@ -112,14 +110,14 @@ func (h *TransactionsHandler) ImportTransactions(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{
 			"message": err.Error(),
 		})
+		return
 	}
 
-	err = h.Service.ImportTransactions(uid, startDate, endDate, txns)
+	err = h.Service.ImportTransactions(c.Request.Context(), uid, startDate, endDate, txns)
 	if err != nil {
 		slog.Debug("TransactionsHandler", "ImportTransactions", err)
-		c.JSON(http.StatusBadRequest, gin.H{
-			"message": err.Error(),
-		})
+		respondError(c, err)
+		return
 	}
 	slog.Info(fmt.Sprintf("ImportTransactions count: %d", len(txns)))
 	c.JSON(http.StatusOK, "")
@@ -1,10 +1,12 @@
 package handlers
 
 import (
+	"bytes"
 	"encoding/json"
 	"fmt"
 	"log/slog"
 	"net/http"
+	"time"
 
 	"firebase.google.com/go/auth"
 	"github.com/gin-gonic/gin"
@@ -13,11 +15,13 @@ import (
 )
 
 type UserHandler struct {
-	Service services.UserService
+	Service         services.UserService
+	ArchiveService  services.ArchiveService
+	DeletionService services.DeletionService
 }
 
-func NewUserHandler(router *gin.Engine, service services.UserService) *UserHandler {
-	return &UserHandler{service}
+func NewUserHandler(router *gin.Engine, service services.UserService, archiveService services.ArchiveService, deletionService services.DeletionService) *UserHandler {
+	return &UserHandler{service, archiveService, deletionService}
 }
 
 func (h *UserHandler) RegisterRoutes(router *gin.Engine, fbauthclient *auth.Client) {
@@ -25,6 +29,9 @@ func (h *UserHandler) RegisterRoutes(router *gin.Engine, fbauthclient *auth.Clie
 	sGroup := router.Group("/user")
 	sGroup.GET("", AuthHandler(fbauthclient, h.GetUser))
 	sGroup.PUT("", AuthHandler(fbauthclient, h.SaveUser))
+	sGroup.DELETE("", AuthHandler(fbauthclient, h.DeleteUser))
+	sGroup.GET("/export", AuthHandler(fbauthclient, h.ExportUser))
+	sGroup.GET("/deletions/:id", AuthHandler(fbauthclient, h.GetDeletionReceipt))
 }
 
 func (h *UserHandler) GetUser(c *gin.Context) {
@@ -37,7 +44,11 @@ func (h *UserHandler) GetUser(c *gin.Context) {
 		})
 		return
 	}
-	user, err := h.Service.GetUser(uid)
+	user, err := h.Service.GetUser(c.Request.Context(), uid)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
 	if user == nil {
 		c.JSON(http.StatusNotFound, gin.H{
 			"message": "User not found",
@@ -59,13 +70,61 @@ func (h *UserHandler) SaveUser(c *gin.Context) {
 	}
 
 	user.ID = uid
-	err = h.Service.SaveUser(user)
+	err = h.Service.SaveUser(c.Request.Context(), user)
 	if err != nil {
 		slog.Debug("SaveUser", "Error", err)
-		c.JSON(http.StatusBadRequest, gin.H{
-			"message": err.Error(),
-		})
+		respondError(c, err)
+		return
+	}
+
+}
+
+// ExportUser downloads the archive of the user's data.
+func (h *UserHandler) ExportUser(c *gin.Context) {
+	uid, err := getUID(c)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	// built in memory so a failed export is still an error response
+	var buf bytes.Buffer
+	if _, err := h.ArchiveService.Export(c.Request.Context(), uid, &buf); err != nil {
+		slog.Debug("ExportUser", "Error", err)
+		respondError(c, err)
+		return
+	}
+	filename := fmt.Sprintf("fin-tracker-%s.zip", time.Now().UTC().Format(time.DateOnly))
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+	c.Data(http.StatusOK, "application/zip", buf.Bytes())
+}
+
+// DeleteUser starts deleting the user and all their data — 202 with the receipt
+// to poll, 409 when a sync or refresh is running for the user.
+func (h *UserHandler) DeleteUser(c *gin.Context) {
+	uid, err := getUID(c)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	receipt, err := h.DeletionService.DeleteUser(c.Request.Context(), uid)
+	if err != nil {
+		respondError(c, err)
 		return
 	}
+	c.JSON(http.StatusAccepted, receipt)
+}
 
+// GetDeletionReceipt returns the receipt of an account or user deletion.
+func (h *UserHandler) GetDeletionReceipt(c *gin.Context) {
+	uid, err := getUID(c)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	receipt, err := h.DeletionService.GetReceipt(c.Request.Context(), uid, c.Param("id"))
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, receipt)
 }
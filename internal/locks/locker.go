@@ -0,0 +1,225 @@
+package locks
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/rkapps/fin-tracker-backend-go/cmd/common/logger"
+	"github.com/rkapps/fin-tracker-backend-go/internal/domain"
+	"github.com/rkapps/fin-tracker-backend-go/internal/storage"
+)
+
+// ErrAlreadyRunning is returned when another sync or refresh holds the user's lease.
+var ErrAlreadyRunning = errors.New("already running")
+
+// errLeaseLost is the cancel cause when the heartbeat loses the lease.
+var errLeaseLost = errors.New("lease lost")
+
+// DefaultTTL is how long a lease survives without a heartbeat.
+const DefaultTTL = 2 * time.Minute
+
+// releaseTimeout bounds the delete of the lock when a lease is released.
+const releaseTimeout = 10 * time.Second
+
+// Locker hands out per-user leases stored in LockStorage so that runs in
+// different processes (pipeline, API) cannot interleave their writes.
+type Locker struct {
+	storage storage.LockStorage
+	owner   string
+	ttl     time.Duration
+	now     func() time.Time
+	logger  *logger.Logger
+}
+
+func NewLocker(storage storage.LockStorage, owner string, ttl time.Duration, logConfig *logger.Config) Locker {
+	if ttl <= 0 {
+		ttl = DefaultTTL
+	}
+	return Locker{storage: storage, owner: owner, ttl: ttl, now: time.Now, logger: logConfig.For("locks")}
+}
+
+// NewOwner identifies this process — host, pid and a random suffix.
+func NewOwner() string {
+	host, _ := os.Hostname()
+	return fmt.Sprintf("%s-%d-%s", host, os.Getpid(), uuid.New().String()[:8])
+}
+
+// Lease is a held lock. Its context is cancelled when the lease is released or lost.
+type Lease struct {
+	locker Locker
+	lock   *domain.UserLock
+	ctx    context.Context
+	cancel context.CancelCauseFunc
+	done   chan struct{}
+	once   sync.Once
+}
+
+// Acquire takes the user's lease for the operation. A lease whose owner stopped
+// heartbeating is taken over. Returns ErrAlreadyRunning when the lease is held.
+func (l Locker) Acquire(ctx context.Context, uid string, operation string) (*Lease, error) {
+
+	now := l.timestamp()
+	lock := &domain.UserLock{
+		ID:          uid,
+		Operation:   operation,
+		Owner:       l.owner,
+		AcquiredAt:  now,
+		HeartbeatAt: now,
+		ExpiresAt:   now.Add(l.ttl),
+	}
+
+	err := l.storage.CreateUserLock(ctx, lock)
+	if errors.Is(err, storage.ErrAlreadyExists) {
+		err = l.takeover(ctx, lock, now)
+	}
+	if err != nil {
+		return nil, err
+	}
+	l.logger.Debug("Acquire", "UID", uid, "Operation", operation, "Owner", l.owner)
+
+	lctx, cancel := context.WithCancelCause(ctx)
+	lease := &Lease{locker: l, lock: lock, ctx: lctx, cancel: cancel, done: make(chan struct{})}
+	go lease.heartbeat()
+	return lease, nil
+}
+
+// takeover replaces an expired lease with a compare-and-swap on its owner and expiry.
+// If two processes take over at once only one swap wins.
+func (l Locker) takeover(ctx context.Context, lock *domain.UserLock, now time.Time) error {
+
+	existing, err := l.storage.GetUserLock(ctx, lock.ID)
+	if err != nil {
+		return err
+	}
+	if existing == nil {
+		// released in between — the insert decides
+		err = l.storage.CreateUserLock(ctx, lock)
+		if errors.Is(err, storage.ErrAlreadyExists) {
+			return fmt.Errorf("%s %w for user %s", lock.Operation, ErrAlreadyRunning, lock.ID)
+		}
+		return err
+	}
+	if !existing.Expired(now) {
+		return fmt.Errorf("%s %w for user %s (owner %s, expires %s)", existing.Operation, ErrAlreadyRunning,
+			lock.ID, existing.Owner, existing.ExpiresAt.Format(time.RFC3339))
+	}
+	l.logger.Warn("Acquire", "UID", lock.ID, "Stale owner", existing.Owner, "Expired", existing.ExpiresAt)
+	err = l.storage.ReplaceUserLock(ctx, existing, lock)
+	if errors.Is(err, storage.ErrConflict) {
+		return fmt.Errorf("%s %w for user %s", lock.Operation, ErrAlreadyRunning, lock.ID)
+	}
+	return err
+}
+
+// timestamp is now at the millisecond precision every store keeps, so the
+// expiry read back compares equal to the one written.
+func (l Locker) timestamp() time.Time {
+	return l.now().UTC().Truncate(time.Millisecond)
+}
+
+// WithLock runs fn while holding the user's lease. fn gets the lease context so
+// it stops when the lease is lost.
+func (l Locker) WithLock(ctx context.Context, uid string, operation string, fn func(ctx context.Context) error) error {
+	lease, err := l.Acquire(ctx, uid, operation)
+	if err != nil {
+		return err
+	}
+	defer lease.Release()
+
+	err = fn(lease.Context())
+	if err != nil && lease.Lost() {
+		return fmt.Errorf("%s lease lost for user %s: %w", operation, uid, err)
+	}
+	return err
+}
+
+// Context is cancelled when the lease is released or lost.
+func (l *Lease) Context() context.Context {
+	return l.ctx
+}
+
+// Lost returns true when the heartbeat found the lease taken over or expired.
+func (l *Lease) Lost() bool {
+	return errors.Is(context.Cause(l.ctx), errLeaseLost)
+}
+
+// Release stops the heartbeat and deletes the lock if this process still owns it.
+func (l *Lease) Release() error {
+	var err error
+	l.once.Do(func() {
+		l.cancel(nil)
+		<-l.done
+
+		// the lease context is done by now — release even when the caller's context was cancelled
+		ctx, cancel := context.WithTimeout(context.WithoutCancel(l.ctx), releaseTimeout)
+		defer cancel()
+		existing, gerr := l.locker.storage.GetUserLock(ctx, l.lock.ID)
+		if gerr != nil {
+			err = gerr
+			return
+		}
+		if existing == nil || existing.Owner != l.lock.Owner {
+			return
+		}
+		err = l.locker.storage.DeleteUserLock(ctx, l.lock.ID)
+		l.locker.logger.Debug("Release", "UID", l.lock.ID, "Operation", l.lock.Operation, "Held", time.Since(l.lock.AcquiredAt))
+	})
+	return err
+}
+
+// heartbeat extends the lease every third of the ttl until the context ends.
+func (l *Lease) heartbeat() {
+	defer close(l.done)
+
+	ticker := time.NewTicker(l.locker.ttl / 3)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-l.ctx.Done():
+			return
+		case <-ticker.C:
+			if err := l.extend(); err != nil {
+				l.locker.logger.Error("heartbeat", "UID", l.lock.ID, "Owner", l.lock.Owner, "Error", err)
+				l.cancel(errLeaseLost)
+				return
+			}
+		}
+	}
+}
+
+// extend pushes out the expiry with a compare-and-swap on the lease held. A lease
+// that changed owner or could not be extended before it expired is lost.
+func (l *Lease) extend() error {
+	now := l.locker.timestamp()
+	lock := *l.lock
+	lock.HeartbeatAt = now
+	lock.ExpiresAt = now.Add(l.locker.ttl)
+	err := l.locker.storage.ReplaceUserLock(l.ctx, l.lock, &lock)
+	if err == nil {
+		l.lock = &lock
+		return nil
+	}
+	if errors.Is(err, storage.ErrConflict) {
+		// an extend that failed after writing leaves the lock ours with a newer expiry
+		existing, gerr := l.locker.storage.GetUserLock(l.ctx, l.lock.ID)
+		if gerr == nil && (existing == nil || existing.Owner != l.lock.Owner) {
+			return fmt.Errorf("lease taken over")
+		}
+		if gerr == nil {
+			l.lock = existing
+			return nil
+		}
+		err = gerr
+	}
+	// a storage blip is retried on the next tick while the lease is still ours
+	if l.lock.Expired(now) {
+		return fmt.Errorf("lease expired: %v", err)
+	}
+	l.locker.logger.Warn("heartbeat", "UID", l.lock.ID, "Error", err)
+	return nil
+}
@@ -0,0 +1,160 @@
+package locks
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/rkapps/fin-tracker-backend-go/cmd/common/logger"
+	"github.com/rkapps/fin-tracker-backend-go/internal/domain"
+	"github.com/rkapps/fin-tracker-backend-go/internal/storage/memory"
+)
+
+func TestLockerAcquire(t *testing.T) {
+
+	store := memory.NewFinTrackerMemoryStorage()
+	pipeline := NewLocker(store, "pipeline", time.Minute, logger.New())
+	api := NewLocker(store, "api", time.Minute, logger.New())
+
+	lease, err := pipeline.Acquire(context.Background(), "u1", "refresh")
+	if err != nil {
+		t.Fatalf("Acquire() error = %v", err)
+	}
+
+	_, err = api.Acquire(context.Background(), "u1", "sync")
+	if !errors.Is(err, ErrAlreadyRunning) {
+		t.Fatalf("second Acquire() error = %v, want ErrAlreadyRunning", err)
+	}
+
+	// other users are not blocked
+	other, err := api.Acquire(context.Background(), "u2", "sync")
+	if err != nil {
+		t.Fatalf("Acquire(u2) error = %v", err)
+	}
+	other.Release()
+
+	if err := lease.Release(); err != nil {
+		t.Fatalf("Release() error = %v", err)
+	}
+	if lease.Context().Err() == nil {
+		t.Errorf("lease context not cancelled on release")
+	}
+
+	err = api.WithLock(context.Background(), "u1", "sync", func(ctx context.Context) error {
+		// a nested run for the same user is refused rather than interleaved
+		return pipeline.WithLock(ctx, "u1", "refresh", func(ctx context.Context) error { return nil })
+	})
+	if !errors.Is(err, ErrAlreadyRunning) {
+		t.Errorf("nested WithLock() error = %v, want ErrAlreadyRunning", err)
+	}
+	if lock, _ := store.GetUserLock(context.Background(), "u1"); lock != nil {
+		t.Errorf("lock not released: %+v", lock)
+	}
+}
+
+func TestLockerStaleTakeover(t *testing.T) {
+
+	store := memory.NewFinTrackerMemoryStorage()
+	crashed := NewLocker(store, "crashed", time.Minute, logger.New())
+	lease, err := crashed.Acquire(context.Background(), "u1", "refresh")
+	if err != nil {
+		t.Fatalf("Acquire() error = %v", err)
+	}
+	// the owner stops heartbeating without releasing
+	lease.cancel(nil)
+	<-lease.done
+
+	later := NewLocker(store, "later", time.Minute, logger.New())
+	later.now = func() time.Time { return time.Now().Add(2 * time.Minute) }
+	taken, err := later.Acquire(context.Background(), "u1", "sync")
+	if err != nil {
+		t.Fatalf("takeover Acquire() error = %v", err)
+	}
+	if lock, _ := store.GetUserLock(context.Background(), "u1"); lock == nil || lock.Owner != "later" {
+		t.Fatalf("lock = %+v, want owner later", lock)
+	}
+
+	// the stale owner's release must not delete the new lease
+	lease.Release()
+	if lock, _ := store.GetUserLock(context.Background(), "u1"); lock == nil || lock.Owner != "later" {
+		t.Errorf("stale release removed the new lease: %+v", lock)
+	}
+	taken.Release()
+}
+
+func TestLockerConcurrentTakeover(t *testing.T) {
+
+	store := memory.NewFinTrackerMemoryStorage()
+	stale := time.Now().Add(-time.Minute).UTC().Truncate(time.Millisecond)
+	store.CreateUserLock(context.Background(), &domain.UserLock{ID: "u1", Owner: "crashed", ExpiresAt: stale})
+
+	// every process sees the stale lease at once — only one swap may win
+	const processes = 20
+	var (
+		wg     sync.WaitGroup
+		mu     sync.Mutex
+		leases []*Lease
+	)
+	for i := 0; i < processes; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			locker := NewLocker(store, fmt.Sprintf("p%d", i), time.Minute, logger.New())
+			lease, err := locker.Acquire(context.Background(), "u1", "sync")
+			if err != nil {
+				if !errors.Is(err, ErrAlreadyRunning) {
+					t.Errorf("Acquire(p%d) error = %v, want ErrAlreadyRunning", i, err)
+				}
+				return
+			}
+			mu.Lock()
+			leases = append(leases, lease)
+			mu.Unlock()
+		}(i)
+	}
+	wg.Wait()
+
+	if len(leases) != 1 {
+		t.Fatalf("%d processes took over the stale lease, want 1", len(leases))
+	}
+	if lock, _ := store.GetUserLock(context.Background(), "u1"); lock == nil || lock.Owner != leases[0].lock.Owner {
+		t.Errorf("lock = %+v, want owner %s", lock, leases[0].lock.Owner)
+	}
+	leases[0].Release()
+}
+
+func TestLeaseHeartbeat(t *testing.T) {
+
+	store := memory.NewFinTrackerMemoryStorage()
+	locker := NewLocker(store, "pipeline", 30*time.Millisecond, logger.New())
+	lease, err := locker.Acquire(context.Background(), "u1", "refresh")
+	if err != nil {
+		t.Fatalf("Acquire() error = %v", err)
+	}
+	first, _ := store.GetUserLock(context.Background(), "u1")
+
+	time.Sleep(50 * time.Millisecond)
+	extended, _ := store.GetUserLock(context.Background(), "u1")
+	if !extended.ExpiresAt.After(first.ExpiresAt) {
+		t.Errorf("heartbeat did not extend the lease: %s <= %s", extended.ExpiresAt, first.ExpiresAt)
+	}
+
+	// someone takes over — the heartbeat notices and cancels the work
+	store.DeleteUserLock(context.Background(), "u1")
+	store.CreateUserLock(context.Background(), &domain.UserLock{ID: "u1", Owner: "other", ExpiresAt: time.Now().Add(time.Minute)})
+	select {
+	case <-lease.Context().Done():
+	case <-time.After(time.Second):
+		t.Fatalf("lease context not cancelled after takeover")
+	}
+	if !lease.Lost() {
+		t.Errorf("Lost() = false after takeover")
+	}
+	lease.Release()
+	if lock, _ := store.GetUserLock(context.Background(), "u1"); lock == nil || lock.Owner != "other" {
+		t.Errorf("lost lease release removed the other owner's lock: %+v", lock)
+	}
+}
@@ -0,0 +1,25 @@
+package migrations
+
+import (
+	"context"
+	"os"
+
+	"github.com/rkapps/fin-tracker-backend-go/internal/domain"
+	"github.com/rkapps/storage-backend-go/migrations"
+	"github.com/rkapps/storage-backend-go/mongodb"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+)
+
+func init() {
+
+	migrations.Register(os.Getenv("FINTRACKER_DB_NAME"), 16, "Activity Mapping Rules Schema",
+		func(database *mongodb.MongoDatabase) error {
+			col := mongodb.GetMongoRepository[string, *domain.ActivityMappingRule](database)
+			return col.CreateIndexes(context.Background(), []mongo.IndexModel{createIdIndex(), createUIDIndex()})
+		},
+		func(client *mongodb.MongoDatabase) error {
+			return nil
+		},
+	)
+
+}
@@ -0,0 +1,34 @@
+package migrations
+
+import (
+	"context"
+	"os"
+
+	"github.com/rkapps/fin-tracker-backend-go/internal/domain"
+	"github.com/rkapps/storage-backend-go/migrations"
+	"github.com/rkapps/storage-backend-go/mongodb"
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+func init() {
+
+	// credentials saved before the metadata existed stay usable
+	migrations.Register(os.Getenv("FINTRACKER_DB_NAME"), 17, "Account Credential Metadata",
+		func(database *mongodb.MongoDatabase) error {
+			col := mongodb.GetMongoRepository[string, *domain.AccountCredential](database)
+			acreds, err := col.Find(context.Background(), bson.M{"active": bson.M{"$exists": false}}, bson.D{}, 0, 0)
+			if err != nil || len(acreds) == 0 {
+				return err
+			}
+			ids := []string{}
+			for _, acred := range acreds {
+				ids = append(ids, acred.ID)
+			}
+			return col.UpdateMany(context.Background(), ids, bson.M{"active": true, "permissions": []string{domain.PermissionRead}})
+		},
+		func(client *mongodb.MongoDatabase) error {
+			return nil
+		},
+	)
+
+}
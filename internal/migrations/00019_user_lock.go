@@ -0,0 +1,26 @@
+package migrations
+
+import (
+	"context"
+	"os"
+
+	"github.com/rkapps/fin-tracker-backend-go/internal/domain"
+	"github.com/rkapps/storage-backend-go/migrations"
+	"github.com/rkapps/storage-backend-go/mongodb"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+)
+
+func init() {
+
+	// the unique id index is what makes acquiring a lock atomic
+	migrations.Register(os.Getenv("FINTRACKER_DB_NAME"), 19, "User Lock Schema",
+		func(database *mongodb.MongoDatabase) error {
+			col := mongodb.GetMongoRepository[string, *domain.UserLock](database)
+			return col.CreateIndexes(context.Background(), []mongo.IndexModel{createIdIndex()})
+		},
+		func(client *mongodb.MongoDatabase) error {
+			return nil
+		},
+	)
+
+}
@@ -0,0 +1,62 @@
+package migrations
+
+import (
+	"context"
+	"os"
+
+	"github.com/rkapps/fin-tracker-backend-go/internal/domain"
+	"github.com/rkapps/storage-backend-go/migrations"
+	"github.com/rkapps/storage-backend-go/mongodb"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+func init() {
+
+	// compound indexes for the paged activity and lot queries — equality fields
+	// first, then the sort with the id tie breaker
+	migrations.Register(os.Getenv("FINTRACKER_DB_NAME"), 20, "Activity Query Indexes",
+		func(database *mongodb.MongoDatabase) error {
+			col := mongodb.GetMongoRepository[string, *domain.Activity](database)
+			if err := col.CreateIndexes(context.Background(), []mongo.IndexModel{
+				{
+					Keys:    bson.D{{Key: domain.FIELD_UID, Value: 1}, {Key: domain.FIELD_DATE, Value: -1}, {Key: domain.FIELD_ACTIVITY_ID, Value: -1}},
+					Options: options.Index().SetName("idx_uid_date_id"),
+				},
+				{
+					Keys: bson.D{{Key: domain.FIELD_UID, Value: 1}, {Key: domain.FIELD_ACTIVITY_ACCOUNT_ID, Value: 1},
+						{Key: domain.FIELD_DATE, Value: -1}, {Key: domain.FIELD_ACTIVITY_ID, Value: -1}},
+					Options: options.Index().SetName("idx_uid_accountid_date_id"),
+				},
+				{
+					Keys:    bson.D{{Key: domain.FIELD_UID, Value: 1}, {Key: domain.FIELD_ACTIVITY_TXN_TYPE, Value: 1}, {Key: domain.FIELD_DATE, Value: -1}},
+					Options: options.Index().SetName("idx_uid_txntype_date"),
+				},
+				{
+					Keys:    bson.D{{Key: domain.FIELD_UID, Value: 1}, {Key: domain.FIELD_ACTIVITY_RCV_SYMBOL, Value: 1}, {Key: domain.FIELD_ACTIVITY_ID, Value: 1}},
+					Options: options.Index().SetName("idx_uid_rcvsymbol_id"),
+				},
+			}); err != nil {
+				return err
+			}
+
+			lcol := mongodb.GetMongoRepository[string, *domain.ActivityLot](database)
+			return lcol.CreateIndexes(context.Background(), []mongo.IndexModel{
+				{
+					Keys: bson.D{{Key: domain.FIELD_UID, Value: 1}, {Key: domain.FIELD_ACTIVITY_STATUS, Value: 1},
+						{Key: domain.FIELD_ACTIVITY_ACCOUNT_ID, Value: 1}, {Key: domain.FIELD_SYMBOL, Value: 1}},
+					Options: options.Index().SetName("idx_uid_status_accountid_symbol"),
+				},
+				{
+					Keys:    bson.D{{Key: domain.FIELD_UID, Value: 1}, {Key: domain.FIELD_DATE, Value: -1}, {Key: domain.FIELD_ACTIVITY_ID, Value: -1}},
+					Options: options.Index().SetName("idx_uid_date_id"),
+				},
+			})
+		},
+		func(client *mongodb.MongoDatabase) error {
+			return nil
+		},
+	)
+
+}
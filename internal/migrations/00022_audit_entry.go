@@ -0,0 +1,36 @@
+package migrations
+
+import (
+	"context"
+	"os"
+
+	"github.com/rkapps/fin-tracker-backend-go/internal/domain"
+	"github.com/rkapps/storage-backend-go/migrations"
+	"github.com/rkapps/storage-backend-go/mongodb"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+func init() {
+
+	migrations.Register(os.Getenv("FINTRACKER_DB_NAME"), 22, "Audit Entry Schema",
+		func(database *mongodb.MongoDatabase) error {
+			col := mongodb.GetMongoRepository[string, *domain.AuditEntry](database)
+			return col.CreateIndexes(context.Background(), []mongo.IndexModel{createIdIndex(),
+				{
+					Keys:    bson.D{{Key: domain.FIELD_UID, Value: 1}, {Key: domain.FIELD_TIME, Value: -1}},
+					Options: options.Index().SetName("idx_uid_time"),
+				},
+				{
+					Keys:    bson.D{{Key: domain.FIELD_UID, Value: 1}, {Key: domain.FIELD_ACTIVITY_ACCOUNT_ID, Value: 1}, {Key: domain.FIELD_TIME, Value: -1}},
+					Options: options.Index().SetName("idx_uid_accountid_time"),
+				},
+			})
+		},
+		func(client *mongodb.MongoDatabase) error {
+			return nil
+		},
+	)
+
+}
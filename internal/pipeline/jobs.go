@@ -1,12 +1,55 @@
 package pipeline
 
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/rkapps/fin-tracker-backend-go/internal/domain"
+)
+
 // SyncAccountsJob is the unit of work for one user.
 type SyncAccountsJob struct {
-	UserID string // TODO: match domain type (e.g. uuid.UUID)
-	// TODO: add metadata (e.g. provider hints)
+	UserID     string                   // TODO: match domain type (e.g. uuid.UUID)
+	Categories []domain.AccountCategory // only accounts in these categories, empty means all
+	Since      *time.Time               // overrides each account's last sync date
+}
+
+func (j SyncAccountsJob) User() string {
+	return j.UserID
 }
+
+func (j SyncAccountsJob) String() string {
+	s := fmt.Sprintf("sync user=%s", j.UserID)
+	if len(j.Categories) > 0 {
+		s += " categories=" + joinCategories(j.Categories)
+	}
+	if j.Since != nil {
+		s += " since=" + j.Since.Format(time.DateOnly)
+	}
+	return s
+}
+
 type RefreshPortfolioJob struct {
 	UserID   string // TODO: match domain type (e.g. uuid.UUID)
-	Simulate bool
-	// TODO: add metadata (e.g. provider hints)
+	Simulate bool   // run gain/loss without saving
+}
+
+func (j RefreshPortfolioJob) User() string {
+	return j.UserID
+}
+
+func (j RefreshPortfolioJob) String() string {
+	if j.Simulate {
+		return fmt.Sprintf("refresh user=%s simulate", j.UserID)
+	}
+	return fmt.Sprintf("refresh user=%s", j.UserID)
+}
+
+func joinCategories(categories []domain.AccountCategory) string {
+	names := make([]string, len(categories))
+	for i, category := range categories {
+		names[i] = string(category)
+	}
+	return strings.Join(names, ",")
 }
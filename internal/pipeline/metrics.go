@@ -0,0 +1,154 @@
+package pipeline
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// DefaultDurationBuckets are the job duration histogram bounds in seconds.
+var DefaultDurationBuckets = []float64{0.1, 0.5, 1, 5, 15, 30, 60, 120, 300, 600}
+
+// Metrics counts jobs per pipeline for the life of the process.
+// Rendered in the Prometheus text format — scraped in serve mode,
+// written once at the end of a single run.
+type Metrics struct {
+	mu        sync.Mutex
+	buckets   []float64
+	pipelines map[string]*pipelineMetrics
+}
+
+type pipelineMetrics struct {
+	total     uint64 // jobs started
+	succeeded uint64
+	failed    uint64
+	retries   uint64 // attempts beyond the first
+	inFlight  int64
+	bucketHit []uint64 // per bucket, cumulated when rendered
+	sum       float64
+	count     uint64
+}
+
+func NewMetrics() *Metrics {
+	return &Metrics{buckets: DefaultDurationBuckets, pipelines: make(map[string]*pipelineMetrics)}
+}
+
+func (m *Metrics) pipeline(name string) *pipelineMetrics {
+	pm, ok := m.pipelines[name]
+	if !ok {
+		pm = &pipelineMetrics{bucketHit: make([]uint64, len(m.buckets))}
+		m.pipelines[name] = pm
+	}
+	return pm
+}
+
+func (m *Metrics) jobStarted(name string) {
+	if m == nil {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	pm := m.pipeline(name)
+	pm.total++
+	pm.inFlight++
+}
+
+func (m *Metrics) jobFinished(name string, attempts int, duration time.Duration, err error) {
+	if m == nil {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	pm := m.pipeline(name)
+	pm.inFlight--
+	if err == nil {
+		pm.succeeded++
+	} else {
+		pm.failed++
+	}
+	if attempts > 1 {
+		pm.retries += uint64(attempts - 1)
+	}
+	seconds := duration.Seconds()
+	for i, bound := range m.buckets {
+		if seconds <= bound {
+			pm.bucketHit[i]++
+			break
+		}
+	}
+	pm.sum += seconds
+	pm.count++
+}
+
+// WriteTo renders the metrics in the Prometheus text exposition format.
+func (m *Metrics) WriteTo(w io.Writer) (int64, error) {
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	names := make([]string, 0, len(m.pipelines))
+	for name := range m.pipelines {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	cw := &countingWriter{w: bufio.NewWriter(w)}
+	counter := func(metric string, help string, kind string, value func(pm *pipelineMetrics) string) {
+		fmt.Fprintf(cw, "# HELP %s %s\n# TYPE %s %s\n", metric, help, metric, kind)
+		for _, name := range names {
+			fmt.Fprintf(cw, "%s{pipeline=%q} %s\n", metric, name, value(m.pipelines[name]))
+		}
+	}
+	counter("pipeline_jobs_total", "Jobs started.", "counter", func(pm *pipelineMetrics) string { return strconv.FormatUint(pm.total, 10) })
+	counter("pipeline_jobs_succeeded_total", "Jobs that succeeded.", "counter", func(pm *pipelineMetrics) string { return strconv.FormatUint(pm.succeeded, 10) })
+	counter("pipeline_jobs_failed_total", "Jobs that failed after all attempts.", "counter", func(pm *pipelineMetrics) string { return strconv.FormatUint(pm.failed, 10) })
+	counter("pipeline_job_retries_total", "Job attempts beyond the first.", "counter", func(pm *pipelineMetrics) string { return strconv.FormatUint(pm.retries, 10) })
+	counter("pipeline_jobs_in_flight", "Jobs running now.", "gauge", func(pm *pipelineMetrics) string { return strconv.FormatInt(pm.inFlight, 10) })
+
+	metric := "pipeline_job_duration_seconds"
+	fmt.Fprintf(cw, "# HELP %s Job duration including retries.\n# TYPE %s histogram\n", metric, metric)
+	for _, name := range names {
+		pm := m.pipelines[name]
+		var cumulative uint64
+		for i, bound := range m.buckets {
+			cumulative += pm.bucketHit[i]
+			fmt.Fprintf(cw, "%s_bucket{pipeline=%q,le=%q} %d\n", metric, name, strconv.FormatFloat(bound, 'g', -1, 64), cumulative)
+		}
+		fmt.Fprintf(cw, "%s_bucket{pipeline=%q,le=\"+Inf\"} %d\n", metric, name, pm.count)
+		fmt.Fprintf(cw, "%s_sum{pipeline=%q} %s\n", metric, name, strconv.FormatFloat(pm.sum, 'g', -1, 64))
+		fmt.Fprintf(cw, "%s_count{pipeline=%q} %d\n", metric, name, pm.count)
+	}
+
+	if err := cw.w.Flush(); err != nil {
+		return cw.n, err
+	}
+	return cw.n, cw.err
+}
+
+// Handler serves the metrics for scraping.
+func (m *Metrics) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		m.WriteTo(w)
+	})
+}
+
+type countingWriter struct {
+	w   *bufio.Writer
+	n   int64
+	err error
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	if err != nil && c.err == nil {
+		c.err = err
+	}
+	return n, err
+}
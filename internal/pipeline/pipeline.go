@@ -3,50 +3,120 @@ package pipeline
 import (
 	"context"
 	"sync"
+	"time"
+
+	"github.com/rkapps/fin-tracker-backend-go/cmd/common/logger"
 )
 
 type Pipeline[J any] struct {
-	pool    *WorkerPool[J]
-	fetchFn func(ctx context.Context) ([]J, error)
-	// TODO: add logger
+	name        string
+	workerCount int
+	handler     Handler[J]
+	retry       RetryPolicy
+	drain       time.Duration
+	metrics     *Metrics
+	fetchFn     func(ctx context.Context) ([]J, error)
+	logger      *logger.Logger
 }
 
 func NewPipeline[J any](
+	name string,
 	workerCount int,
 	handler Handler[J],
 	fetchFn func(ctx context.Context) ([]J, error),
+	logConfig *logger.Config,
 ) *Pipeline[J] {
 	return &Pipeline[J]{
-		pool:    NewWorkerPool(workerCount, handler),
-		fetchFn: fetchFn,
+		name:        name,
+		workerCount: workerCount,
+		handler:     handler,
+		retry:       DefaultRetryPolicy(),
+		fetchFn:     fetchFn,
+		logger:      logConfig.For("pipeline." + name),
 	}
 }
 
+// WithRetry replaces the default retry policy.
+func (p *Pipeline[J]) WithRetry(retry RetryPolicy) *Pipeline[J] {
+	p.retry = retry
+	return p
+}
+
+// WithDrain lets in-flight jobs run for up to grace after the run context is
+// cancelled (e.g SIGTERM) instead of being cancelled with it. No new jobs start.
+func (p *Pipeline[J]) WithDrain(grace time.Duration) *Pipeline[J] {
+	p.drain = grace
+	return p
+}
+
+// WithMetrics counts this pipeline's jobs in m — shared by the pipelines of the process.
+func (p *Pipeline[J]) WithMetrics(m *Metrics) *Pipeline[J] {
+	p.metrics = m
+	return p
+}
+
 // Run fetches all jobs via fetchFn and dispatches to the worker pool.
-func (p *Pipeline[J]) Run(ctx context.Context) error {
+// The error joins the errors of every failed job.
+func (p *Pipeline[J]) Run(ctx context.Context) (*Summary[J], error) {
 	jobs, err := p.fetchFn(ctx)
 	if err != nil {
-		return err
+		return nil, err
 	}
 	return p.run(ctx, jobs)
 }
 
-// RunForUser dispatches a single job — fetchFn is bypassed.
-func (p *Pipeline[J]) RunForOne(ctx context.Context, job J) error {
+// RunForOne dispatches a single job — fetchFn is bypassed.
+func (p *Pipeline[J]) RunForOne(ctx context.Context, job J) (*Summary[J], error) {
 	return p.run(ctx, []J{job})
 }
 
-// run is the shared dispatch path.
-func (p *Pipeline[J]) run(ctx context.Context, jobs []J) error {
+// run is the shared dispatch path. A pool only runs once so each run gets its own.
+func (p *Pipeline[J]) run(ctx context.Context, jobs []J) (*Summary[J], error) {
 	var wg sync.WaitGroup
 
-	p.pool.Start(ctx, &wg)
-	p.pool.Dispatch(ctx, jobs)
+	summary := &Summary[J]{Name: p.name, Started: time.Now(), Jobs: len(jobs)}
+	p.logger.Info("run", "Jobs", len(jobs), "Workers", p.workerCount, "MaxAttempts", p.retry.attempts(), "Timeout", p.retry.Timeout)
+
+	jobCtx, cancel := drainContext(ctx, p.drain)
+	defer cancel()
+
+	pool := NewWorkerPool(p.workerCount, p.handler, p.retry, p.logger)
+	pool.name = p.name
+	pool.metrics = p.metrics
+	pool.Start(ctx, jobCtx, &wg)
+	pool.Dispatch(ctx, jobs)
 
 	wg.Wait()
-	close(p.pool.results)
-	p.pool.Wait()
+	close(pool.results)
+	for _, result := range pool.Wait() {
+		summary.add(result)
+	}
+
+	summary.Finished = time.Now()
+	summary.Skipped = summary.Jobs - len(summary.Results)
+	summary.Log(p.logger)
 
-	// TODO: return aggregated error
-	return nil
+	return summary, summary.Err()
+}
+
+// drainContext returns a context cancelled grace after ctx, or with ctx when grace is 0.
+func drainContext(ctx context.Context, grace time.Duration) (context.Context, context.CancelFunc) {
+	if grace <= 0 {
+		return context.WithCancel(ctx)
+	}
+	jobCtx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+	go func() {
+		select {
+		case <-jobCtx.Done():
+		case <-ctx.Done():
+			timer := time.NewTimer(grace)
+			defer timer.Stop()
+			select {
+			case <-jobCtx.Done():
+			case <-timer.C:
+				cancel()
+			}
+		}
+	}()
+	return jobCtx, cancel
 }
@@ -0,0 +1,323 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/rkapps/fin-tracker-backend-go/cmd/common/logger"
+	"github.com/rkapps/fin-tracker-backend-go/internal/domain"
+	"github.com/rkapps/fin-tracker-backend-go/internal/storage"
+)
+
+func TestPipelineSummary(t *testing.T) {
+
+	handler := func(ctx context.Context, job SyncAccountsJob) error {
+		if job.UserID == "u2" || job.UserID == "u4" {
+			return fmt.Errorf("provider down")
+		}
+		return nil
+	}
+	fetch := func(ctx context.Context) ([]SyncAccountsJob, error) {
+		return []SyncAccountsJob{{UserID: "u1"}, {UserID: "u2"}, {UserID: "u3"}, {UserID: "u4"}}, nil
+	}
+
+	p := NewPipeline("sync", 2, handler, fetch, logger.New())
+	summary, err := p.Run(context.Background())
+	if err == nil {
+		t.Fatalf("Run() error = nil, want joined job errors")
+	}
+	if summary.Jobs != 4 || summary.Succeeded != 2 || summary.Failed != 2 || summary.Skipped != 0 {
+		t.Errorf("summary = %d jobs %d ok %d failed %d skipped", summary.Jobs, summary.Succeeded, summary.Failed, summary.Skipped)
+	}
+	if len(summary.Results) != 4 {
+		t.Errorf("results = %d, want 4", len(summary.Results))
+	}
+
+	// the pipeline can run again — e.g. from the scheduler
+	if _, err := p.RunForOne(context.Background(), SyncAccountsJob{UserID: "u1"}); err != nil {
+		t.Errorf("RunForOne() error = %v", err)
+	}
+
+	tests := []struct {
+		name   string
+		policy FailurePolicy
+		fail   bool
+	}{
+		{"default allows no failures", FailurePolicy{}, true},
+		{"count within limit", FailurePolicy{MaxFailures: 2, MaxFailureRate: -1}, false},
+		{"rate above limit", FailurePolicy{MaxFailures: -1, MaxFailureRate: 0.25}, true},
+		{"rate within limit", FailurePolicy{MaxFailures: -1, MaxFailureRate: 0.5}, false},
+	}
+	for _, tt := range tests {
+		err := tt.policy.Exceeded(summary.Failed, summary.FailureRate())
+		if (err != nil) != tt.fail {
+			t.Errorf("%s: Exceeded() = %v", tt.name, err)
+		}
+	}
+
+	if summary.Err() == nil {
+		t.Errorf("Err() = nil, want joined job errors")
+	}
+
+	run := &domain.PipelineRun{Command: "sync-all", Status: domain.PipelineRunRunning}
+	summary.Record(run, false)
+	if run.Status != domain.PipelineRunFailed || run.Finished == nil || len(run.Outcomes) != 4 {
+		t.Errorf("Record() status = %s, outcomes = %d", run.Status, len(run.Outcomes))
+	}
+	for _, outcome := range run.Outcomes {
+		failed := outcome.UserID == "u2" || outcome.UserID == "u4"
+		if failed != (outcome.Status == domain.PipelineRunFailed) || failed != (len(outcome.Error) > 0) {
+			t.Errorf("outcome %s = %s %q", outcome.UserID, outcome.Status, outcome.Error)
+		}
+	}
+}
+
+func TestRunWithRetry(t *testing.T) {
+
+	policy := RetryPolicy{MaxAttempts: 3, BaseDelay: time.Millisecond, Jitter: 0.2, Timeout: 20 * time.Millisecond}
+
+	calls := 0
+	flaky := func(ctx context.Context, job string) error {
+		calls++
+		if calls < 3 {
+			return Transient(fmt.Errorf("connection reset"))
+		}
+		return nil
+	}
+	attempts, err := runWithRetry(context.Background(), policy, flaky, "flaky")
+	if err != nil || attempts != 3 {
+		t.Errorf("transient: attempts = %d, err = %v, want 3 and nil", attempts, err)
+	}
+
+	calls = 0
+	invalid := func(ctx context.Context, job string) error {
+		calls++
+		return fmt.Errorf("account number not set")
+	}
+	attempts, err = runWithRetry(context.Background(), policy, invalid, "invalid")
+	if err == nil || attempts != 1 {
+		t.Errorf("permanent: attempts = %d, err = %v, want 1 and an error", attempts, err)
+	}
+
+	// transient storage errors are retried without a marker
+	calls = 0
+	unavailable := func(ctx context.Context, job string) error {
+		calls++
+		if calls < 2 {
+			return fmt.Errorf("error getting user accounts: %w", storage.ErrTransient)
+		}
+		return nil
+	}
+	attempts, err = runWithRetry(context.Background(), policy, unavailable, "unavailable")
+	if err != nil || attempts != 2 {
+		t.Errorf("storage transient: attempts = %d, err = %v, want 2 and nil", attempts, err)
+	}
+
+	// each attempt times out on its own deadline and the timeout is retried
+	slow := func(ctx context.Context, job string) error {
+		<-ctx.Done()
+		return fmt.Errorf("sync interrupted: %v", ctx.Err())
+	}
+	attempts, err = runWithRetry(context.Background(), policy, slow, "slow")
+	if !errors.Is(err, context.DeadlineExceeded) || attempts != 3 {
+		t.Errorf("timeout: attempts = %d, err = %v, want 3 and deadline exceeded", attempts, err)
+	}
+
+	// a cancelled run stops retrying
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	attempts, err = runWithRetry(ctx, policy, slow, "cancelled")
+	if err == nil || attempts != 1 {
+		t.Errorf("cancelled: attempts = %d, err = %v, want 1 and an error", attempts, err)
+	}
+
+	// only network timeouts are retried, a refused connection or unknown host is not
+	for err, want := range map[error]bool{
+		&net.OpError{Op: "dial", Net: "tcp", Err: os.ErrDeadlineExceeded}: true,
+		&net.DNSError{Name: "api.example.com", IsTimeout: true}:           true,
+		&net.OpError{Op: "dial", Net: "tcp", Err: syscall.ECONNREFUSED}:   false,
+		&net.DNSError{Name: "api.example.com", IsNotFound: true}:          false,
+	} {
+		if got := IsRetryable(fmt.Errorf("fetch error: %w", err)); got != want {
+			t.Errorf("IsRetryable(%v) = %v, want %v", err, got, want)
+		}
+	}
+
+	backoff := RetryPolicy{BaseDelay: time.Second, MaxDelay: 5 * time.Second}
+	for attempt, want := range map[int]time.Duration{1: 0, 2: time.Second, 3: 2 * time.Second, 4: 4 * time.Second, 5: 5 * time.Second, 10: 5 * time.Second} {
+		if got := backoff.Backoff(attempt); got != want {
+			t.Errorf("Backoff(%d) = %s, want %s", attempt, got, want)
+		}
+	}
+}
+
+func TestParseSchedule(t *testing.T) {
+
+	at := func(s string) time.Time {
+		v, err := time.Parse("2006-01-02 15:04", s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return v
+	}
+	tests := []struct {
+		spec  string
+		after string
+		want  string
+	}{
+		{"*/15 * * * *", "2026-03-10 10:07", "2026-03-10 10:15"},
+		{"*/15 * * * *", "2026-03-10 10:45", "2026-03-10 11:00"},
+		{"0 2 * * *", "2026-03-10 02:00", "2026-03-11 02:00"},
+		{"@nightly", "2026-12-31 23:59", "2027-01-01 00:00"},
+		{"30 6 * * 1-5", "2026-03-13 07:00", "2026-03-16 06:30"}, // friday => monday
+		{"0 0 1,15 * *", "2026-02-02 00:00", "2026-02-15 00:00"},
+		{"0 0 * * 7", "2026-03-10 00:00", "2026-03-15 00:00"}, // 7 is sunday
+		{"@every 90m", "2026-03-10 10:07", "2026-03-10 11:37"},
+	}
+	for _, tt := range tests {
+		schedule, err := ParseSchedule(tt.spec)
+		if err != nil {
+			t.Errorf("ParseSchedule(%q) error = %v", tt.spec, err)
+			continue
+		}
+		if got := schedule.Next(at(tt.after)); !got.Equal(at(tt.want)) {
+			t.Errorf("%q Next(%s) = %s, want %s", tt.spec, tt.after, got.Format("2006-01-02 15:04"), tt.want)
+		}
+	}
+
+	for _, spec := range []string{"", "* * * *", "60 * * * *", "*/0 * * * *", "5-1 * * * *", "@every 10s", "@every soon"} {
+		if _, err := ParseSchedule(spec); err == nil {
+			t.Errorf("ParseSchedule(%q) error = nil", spec)
+		}
+	}
+	if schedule, _ := ParseSchedule("0 0 30 2 *"); !schedule.Next(at("2026-01-01 00:00")).IsZero() {
+		t.Errorf("30th of february should never fire")
+	}
+}
+
+func TestSchedulerSkipsWhileRunning(t *testing.T) {
+
+	release := make(chan struct{})
+	started := make(chan struct{}, 10)
+	task := func(ctx context.Context) error {
+		started <- struct{}{}
+		<-release
+		return nil
+	}
+
+	scheduler := NewScheduler(0, logger.New())
+	scheduler.Add("sync-all", everySchedule{time.Minute}, task)
+	entry := scheduler.entries[0]
+
+	ctx, cancel := context.WithCancel(context.Background())
+	scheduler.trigger(ctx, entry)
+	<-started
+	scheduler.trigger(ctx, entry) // still running — skipped
+
+	status := scheduler.Status()
+	if len(status) != 1 || !status[0].Running || status[0].Skipped != 1 {
+		t.Errorf("Status() = %+v, want running with 1 skipped", status)
+	}
+
+	// Run returns only after the in-flight task drains
+	done := make(chan struct{})
+	go func() {
+		scheduler.Run(ctx)
+		close(done)
+	}()
+	cancel()
+	select {
+	case <-done:
+		t.Fatalf("Run returned before the in-flight task finished")
+	case <-time.After(20 * time.Millisecond):
+	}
+	close(release)
+	<-done
+	if status := scheduler.Status(); status[0].Running || status[0].Runs != 1 {
+		t.Errorf("Status() = %+v, want 1 finished run", status)
+	}
+
+	// a timer firing after Run stopped waiting does not start the task
+	scheduler.trigger(ctx, entry)
+	if status := scheduler.Status(); status[0].Running || status[0].Runs != 1 || len(started) != 0 {
+		t.Errorf("Status() after stop = %+v, want no new run", status)
+	}
+}
+
+func TestPipelineDrain(t *testing.T) {
+
+	ctx, cancel := context.WithCancel(context.Background())
+	picked := make(chan struct{})
+	handler := func(jctx context.Context, job SyncAccountsJob) error {
+		close(picked)
+		cancel() // SIGTERM while the job is in flight
+		select {
+		case <-jctx.Done():
+			return jctx.Err()
+		case <-time.After(20 * time.Millisecond):
+			return nil
+		}
+	}
+	fetch := func(ctx context.Context) ([]SyncAccountsJob, error) {
+		return []SyncAccountsJob{{UserID: "u1"}}, nil
+	}
+
+	p := NewPipeline("sync", 1, handler, fetch, logger.New()).WithRetry(NoRetry()).WithDrain(time.Second)
+	summary, err := p.Run(ctx)
+	<-picked
+	if err != nil || summary.Succeeded != 1 {
+		t.Errorf("drained job: err = %v, succeeded = %d", err, summary.Succeeded)
+	}
+}
+
+func TestPipelineMetrics(t *testing.T) {
+
+	calls := 0
+	handler := func(ctx context.Context, job SyncAccountsJob) error {
+		if job.UserID == "u2" {
+			calls++
+			if calls == 1 {
+				return Transient(fmt.Errorf("connection reset"))
+			}
+		}
+		if job.UserID == "u3" {
+			return fmt.Errorf("provider down")
+		}
+		return nil
+	}
+	fetch := func(ctx context.Context) ([]SyncAccountsJob, error) {
+		return []SyncAccountsJob{{UserID: "u1"}, {UserID: "u2"}, {UserID: "u3"}}, nil
+	}
+
+	metrics := NewMetrics()
+	retry := RetryPolicy{MaxAttempts: 2, BaseDelay: time.Millisecond}
+	NewPipeline("sync", 1, handler, fetch, logger.New()).WithRetry(retry).WithMetrics(metrics).Run(context.Background())
+
+	var b strings.Builder
+	if _, err := metrics.WriteTo(&b); err != nil {
+		t.Fatalf("WriteTo() error = %v", err)
+	}
+	out := b.String()
+	for _, want := range []string{
+		"# TYPE pipeline_jobs_total counter\n",
+		`pipeline_jobs_total{pipeline="sync"} 3`,
+		`pipeline_jobs_succeeded_total{pipeline="sync"} 2`,
+		`pipeline_jobs_failed_total{pipeline="sync"} 1`,
+		`pipeline_job_retries_total{pipeline="sync"} 1`,
+		`pipeline_jobs_in_flight{pipeline="sync"} 0`,
+		`pipeline_job_duration_seconds_bucket{pipeline="sync",le="0.1"} 3`,
+		`pipeline_job_duration_seconds_bucket{pipeline="sync",le="+Inf"} 3`,
+		`pipeline_job_duration_seconds_count{pipeline="sync"} 3`,
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("metrics missing %q\n%s", want, out)
+		}
+	}
+}
@@ -6,7 +6,8 @@ import "time"
 type Result[J any] struct {
 	Job      J
 	Err      error
-	Duration time.Duration
+	Duration time.Duration // all attempts including backoff
+	Attempts int           // handler calls, 0 if the run was cancelled before the first
 	// TODO: add outcome metadata (e.g. accounts processed count)
 }
 
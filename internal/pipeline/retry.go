@@ -0,0 +1,182 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"net"
+	"time"
+
+	"github.com/rkapps/fin-tracker-backend-go/internal/storage"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+)
+
+// RetryPolicy decides how often and how long a job is attempted.
+type RetryPolicy struct {
+	MaxAttempts int           // total attempts including the first, < 1 means 1
+	BaseDelay   time.Duration // delay before the second attempt, doubled for each one after
+	MaxDelay    time.Duration // cap on the backoff delay, 0 means no cap
+	Jitter      float64       // 0..1, fraction of the delay randomised e.g 0.2 => delay ±20%
+	Timeout     time.Duration // per attempt, 0 means the job runs with the parent context
+
+	// Retryable classifies a failed attempt. Defaults to IsRetryable.
+	Retryable func(err error) bool
+}
+
+// DefaultRetryPolicy retries transient failures three times over a few seconds.
+func DefaultRetryPolicy() RetryPolicy {
+	return RetryPolicy{
+		MaxAttempts: 3,
+		BaseDelay:   time.Second,
+		MaxDelay:    30 * time.Second,
+		Jitter:      0.2,
+		Timeout:     5 * time.Minute,
+	}
+}
+
+// NoRetry runs each job once with no timeout — the behaviour before retries existed.
+func NoRetry() RetryPolicy {
+	return RetryPolicy{MaxAttempts: 1}
+}
+
+// Backoff returns the delay before the given attempt (2 is the first retry).
+func (p RetryPolicy) Backoff(attempt int) time.Duration {
+	if attempt < 2 || p.BaseDelay <= 0 {
+		return 0
+	}
+	delay := p.BaseDelay
+	for i := 2; i < attempt; i++ {
+		delay *= 2
+		if p.MaxDelay > 0 && delay >= p.MaxDelay {
+			break
+		}
+	}
+	if p.MaxDelay > 0 && delay > p.MaxDelay {
+		delay = p.MaxDelay
+	}
+	if p.Jitter > 0 {
+		// spread retries of jobs that failed together e.g a provider outage
+		delta := float64(delay) * p.Jitter * (2*rand.Float64() - 1)
+		delay += time.Duration(delta)
+	}
+	return delay
+}
+
+func (p RetryPolicy) attempts() int {
+	if p.MaxAttempts < 1 {
+		return 1
+	}
+	return p.MaxAttempts
+}
+
+func (p RetryPolicy) retryable(err error) bool {
+	if p.Retryable != nil {
+		return p.Retryable(err)
+	}
+	return IsRetryable(err)
+}
+
+// permanentError marks an error that must not be retried.
+type permanentError struct {
+	err error
+}
+
+func (e *permanentError) Error() string { return e.err.Error() }
+func (e *permanentError) Unwrap() error { return e.err }
+
+// Permanent wraps err so the job fails without further attempts.
+func Permanent(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &permanentError{err: err}
+}
+
+// transientError marks an error that is worth another attempt.
+type transientError struct {
+	err error
+}
+
+func (e *transientError) Error() string { return e.err.Error() }
+func (e *transientError) Unwrap() error { return e.err }
+
+// Transient wraps err so the job is retried when attempts remain.
+func Transient(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &transientError{err: err}
+}
+
+// IsRetryable is the default classification — explicit markers first, then
+// transient storage errors, Mongo network errors and timeouts. Other network errors
+// e.g connection refused or an unknown host are a misconfigured endpoint and are
+// treated as permanent like anything unrecognised.
+func IsRetryable(err error) bool {
+	if err == nil {
+		return false
+	}
+	var perr *permanentError
+	if errors.As(err, &perr) {
+		return false
+	}
+	var terr *transientError
+	if errors.As(err, &terr) {
+		return true
+	}
+	if errors.Is(err, context.Canceled) {
+		return false
+	}
+	if errors.Is(err, context.DeadlineExceeded) || errors.Is(err, storage.ErrTransient) {
+		return true
+	}
+	if mongo.IsNetworkError(err) || mongo.IsTimeout(err) {
+		return true
+	}
+	var nerr net.Error
+	return errors.As(err, &nerr) && nerr.Timeout()
+}
+
+// runWithRetry calls the handler until it succeeds, fails permanently or runs out of attempts.
+// Each attempt gets its own timeout derived from ctx.
+func runWithRetry[J any](ctx context.Context, policy RetryPolicy, handler Handler[J], job J) (int, error) {
+	var err error
+	attempt := 0
+	for attempt < policy.attempts() {
+		attempt++
+		if delay := policy.Backoff(attempt); delay > 0 {
+			timer := time.NewTimer(delay)
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				return attempt - 1, err
+			case <-timer.C:
+			}
+		}
+
+		err = runAttempt(ctx, policy.Timeout, handler, job)
+		if err == nil {
+			return attempt, nil
+		}
+		// a cancelled run is not retried even if the attempt timed out first
+		if ctx.Err() != nil || !policy.retryable(err) {
+			return attempt, err
+		}
+	}
+	return attempt, err
+}
+
+func runAttempt[J any](ctx context.Context, timeout time.Duration, handler Handler[J], job J) error {
+	if timeout <= 0 {
+		return handler(ctx, job)
+	}
+	actx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	err := handler(actx, job)
+	// handlers often flatten errors with %v — keep the timeout classifiable
+	if err != nil && errors.Is(actx.Err(), context.DeadlineExceeded) && !errors.Is(err, context.DeadlineExceeded) {
+		return fmt.Errorf("%w after %s: %v", context.DeadlineExceeded, timeout, err)
+	}
+	return err
+}
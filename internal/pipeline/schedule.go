@@ -0,0 +1,176 @@
+package pipeline
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Schedule returns the next run time after the given time.
+type Schedule interface {
+	Next(after time.Time) time.Time
+}
+
+// ParseSchedule parses a 5 field cron spec (minute hour day-of-month month day-of-week)
+// with *, lists, ranges and steps e.g "*/15 * * * *", "0 2 * * *", "30 6 * * 1-5".
+// The shorthands @hourly, @daily (@nightly), @weekly and @every <duration> are also accepted.
+func ParseSchedule(spec string) (Schedule, error) {
+
+	spec = strings.TrimSpace(spec)
+	switch spec {
+	case "@hourly":
+		spec = "0 * * * *"
+	case "@daily", "@nightly":
+		spec = "0 0 * * *"
+	case "@weekly":
+		spec = "0 0 * * 0"
+	}
+	if every, ok := strings.CutPrefix(spec, "@every "); ok {
+		interval, err := time.ParseDuration(strings.TrimSpace(every))
+		if err != nil {
+			return nil, fmt.Errorf("invalid schedule %q: %v", spec, err)
+		}
+		if interval < time.Minute {
+			return nil, fmt.Errorf("invalid schedule %q: interval below 1m", spec)
+		}
+		return everySchedule{interval}, nil
+	}
+
+	fields := strings.Fields(spec)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("invalid schedule %q: want 5 fields", spec)
+	}
+	var cs cronSchedule
+	var err error
+	bounds := []struct {
+		field    *uint64
+		min, max int
+	}{
+		{&cs.minute, 0, 59},
+		{&cs.hour, 0, 23},
+		{&cs.dom, 1, 31},
+		{&cs.month, 1, 12},
+		{&cs.dow, 0, 7}, // 0 and 7 are sunday
+	}
+	for i, b := range bounds {
+		if *b.field, err = parseCronField(fields[i], b.min, b.max); err != nil {
+			return nil, fmt.Errorf("invalid schedule %q: %v", spec, err)
+		}
+	}
+	if cs.dow&(1<<7) != 0 {
+		cs.dow |= 1
+	}
+	cs.domAny = fields[2] == "*"
+	cs.dowAny = fields[4] == "*"
+	cs.spec = spec
+	return cs, nil
+}
+
+// parseCronField returns the allowed values of one field as a bit set.
+func parseCronField(field string, min int, max int) (uint64, error) {
+
+	var bits uint64
+	for _, part := range strings.Split(field, ",") {
+		rng, sstep, hasStep := strings.Cut(part, "/")
+		step := 1
+		if hasStep {
+			value, err := strconv.Atoi(sstep)
+			if err != nil || value < 1 {
+				return 0, fmt.Errorf("invalid step %q", part)
+			}
+			step = value
+		}
+
+		lo, hi := min, max
+		if rng != "*" {
+			slo, shi, isRange := strings.Cut(rng, "-")
+			var err error
+			if lo, err = strconv.Atoi(slo); err != nil {
+				return 0, fmt.Errorf("invalid value %q", part)
+			}
+			hi = lo
+			if isRange {
+				if hi, err = strconv.Atoi(shi); err != nil {
+					return 0, fmt.Errorf("invalid range %q", part)
+				}
+			} else if hasStep {
+				// e.g 5/15 => from 5 every 15
+				hi = max
+			}
+		}
+		if lo < min || hi > max || lo > hi {
+			return 0, fmt.Errorf("%q out of range %d-%d", part, min, max)
+		}
+		for v := lo; v <= hi; v += step {
+			bits |= 1 << v
+		}
+	}
+	return bits, nil
+}
+
+type cronSchedule struct {
+	spec                          string
+	minute, hour, dom, month, dow uint64
+	domAny, dowAny                bool
+}
+
+// Next walks forward a field at a time. Times are in the location of after.
+func (s cronSchedule) Next(after time.Time) time.Time {
+
+	t := after.Truncate(time.Minute).Add(time.Minute)
+	// a spec like "0 0 30 2 *" never matches — give up after 5 years
+	limit := t.AddDate(5, 0, 0)
+	for t.Before(limit) {
+		if s.month&(1<<int(t.Month())) == 0 {
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, t.Location())
+			continue
+		}
+		if !s.dayMatches(t) {
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, t.Location())
+			continue
+		}
+		if s.hour&(1<<t.Hour()) == 0 {
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, t.Location())
+			continue
+		}
+		if s.minute&(1<<t.Minute()) == 0 {
+			t = t.Add(time.Minute)
+			continue
+		}
+		return t
+	}
+	return time.Time{}
+}
+
+// dayMatches follows cron — when both day fields are restricted either may match.
+func (s cronSchedule) dayMatches(t time.Time) bool {
+	dom := s.dom&(1<<t.Day()) != 0
+	dow := s.dow&(1<<int(t.Weekday())) != 0
+	switch {
+	case s.domAny && s.dowAny:
+		return true
+	case s.domAny:
+		return dow
+	case s.dowAny:
+		return dom
+	default:
+		return dom || dow
+	}
+}
+
+func (s cronSchedule) String() string {
+	return s.spec
+}
+
+type everySchedule struct {
+	interval time.Duration
+}
+
+func (s everySchedule) Next(after time.Time) time.Time {
+	return after.Add(s.interval)
+}
+
+func (s everySchedule) String() string {
+	return "@every " + s.interval.String()
+}
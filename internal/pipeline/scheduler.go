@@ -0,0 +1,207 @@
+package pipeline
+
+import (
+	"context"
+	"fmt"
+	"math/rand/v2"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/rkapps/fin-tracker-backend-go/cmd/common/logger"
+)
+
+// Task is a scheduled command e.g sync-all.
+type Task func(ctx context.Context) error
+
+// Scheduler runs tasks on their schedules until its context is cancelled.
+// A task still running when it is due again is skipped, not queued.
+type Scheduler struct {
+	jitter  time.Duration
+	entries []*scheduleEntry
+	now     func() time.Time
+	logger  *logger.Logger
+
+	// stopped is set before Run waits, so no task is added to wg after Wait starts
+	mu      sync.Mutex
+	stopped bool
+	wg      sync.WaitGroup
+}
+
+type scheduleEntry struct {
+	name     string
+	schedule Schedule
+	task     Task
+
+	mu         sync.Mutex
+	running    bool
+	next       time.Time
+	lastStart  time.Time
+	lastFinish time.Time
+	lastErr    error
+	runs       int
+	skipped    int
+}
+
+// TaskStatus is the health view of one scheduled task.
+type TaskStatus struct {
+	Name       string     `json:"name"`
+	Schedule   string     `json:"schedule"`
+	Running    bool       `json:"running"`
+	Next       time.Time  `json:"next"`
+	LastStart  *time.Time `json:"lastStart,omitempty"`
+	LastFinish *time.Time `json:"lastFinish,omitempty"`
+	LastError  string     `json:"lastError,omitempty"`
+	Runs       int        `json:"runs"`
+	Skipped    int        `json:"skipped"` // due while the previous run was still going
+}
+
+// NewScheduler delays each run by a random 0..jitter so that replicas
+// (and tasks sharing a schedule) do not all hit Mongo and providers at once.
+func NewScheduler(jitter time.Duration, logConfig *logger.Config) *Scheduler {
+	return &Scheduler{jitter: jitter, now: time.Now, logger: logConfig.For("pipeline.scheduler")}
+}
+
+// Add registers a task. Must be called before Run.
+func (s *Scheduler) Add(name string, schedule Schedule, task Task) {
+	s.entries = append(s.entries, &scheduleEntry{name: name, schedule: schedule, task: task})
+}
+
+// Run blocks until ctx is cancelled, then waits for in-flight tasks to return.
+// Tasks get ctx — draining them is up to the task e.g Pipeline.WithDrain.
+func (s *Scheduler) Run(ctx context.Context) {
+
+	for _, entry := range s.entries {
+		go s.loop(ctx, entry)
+	}
+	<-ctx.Done()
+	s.mu.Lock()
+	s.stopped = true
+	s.mu.Unlock()
+	s.logger.Info("Run", "Status", "draining", "Running", s.running())
+	s.wg.Wait()
+	s.logger.Info("Run", "Status", "stopped")
+}
+
+func (s *Scheduler) loop(ctx context.Context, entry *scheduleEntry) {
+
+	for {
+		next := entry.schedule.Next(s.now())
+		if next.IsZero() {
+			s.logger.Error("loop", "Task", entry.name, "Error", "schedule never fires")
+			return
+		}
+		entry.mu.Lock()
+		entry.next = next
+		entry.mu.Unlock()
+
+		wait := time.Until(next) + s.delay()
+		s.logger.Debug("loop", "Task", entry.name, "Next", next, "Wait", wait)
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+		s.trigger(ctx, entry)
+	}
+}
+
+func (s *Scheduler) delay() time.Duration {
+	if s.jitter <= 0 {
+		return 0
+	}
+	return rand.N(s.jitter)
+}
+
+// trigger starts the task unless the previous run is still going or Run is draining.
+func (s *Scheduler) trigger(ctx context.Context, entry *scheduleEntry) {
+
+	s.mu.Lock()
+	if s.stopped {
+		s.mu.Unlock()
+		s.logger.Debug("trigger", "Task", entry.name, "Skipped", "scheduler stopped")
+		return
+	}
+	s.wg.Add(1)
+	s.mu.Unlock()
+
+	entry.mu.Lock()
+	if entry.running {
+		entry.skipped++
+		entry.mu.Unlock()
+		s.wg.Done()
+		s.logger.Warn("trigger", "Task", entry.name, "Skipped", "previous run still running")
+		return
+	}
+	entry.running = true
+	entry.lastStart = s.now()
+	entry.mu.Unlock()
+
+	go func() {
+		defer s.wg.Done()
+		s.logger.Info("trigger", "Task", entry.name)
+
+		err := entry.task(ctx)
+
+		entry.mu.Lock()
+		entry.running = false
+		entry.lastFinish = s.now()
+		entry.lastErr = err
+		entry.runs++
+		duration := entry.lastFinish.Sub(entry.lastStart)
+		entry.mu.Unlock()
+		if err != nil {
+			s.logger.Error("trigger", "Task", entry.name, "Duration", duration, "Error", err)
+		} else {
+			s.logger.Info("trigger", "Task", entry.name, "Duration", duration)
+		}
+	}()
+}
+
+func (s *Scheduler) running() int {
+	count := 0
+	for _, entry := range s.entries {
+		entry.mu.Lock()
+		if entry.running {
+			count++
+		}
+		entry.mu.Unlock()
+	}
+	return count
+}
+
+// Status returns the state of each task, ordered by name.
+func (s *Scheduler) Status() []TaskStatus {
+
+	statuses := []TaskStatus{}
+	for _, entry := range s.entries {
+		entry.mu.Lock()
+		status := TaskStatus{
+			Name:     entry.name,
+			Schedule: fmt.Sprintf("%v", entry.schedule),
+			Running:  entry.running,
+			Next:     entry.next,
+			Runs:     entry.runs,
+			Skipped:  entry.skipped,
+		}
+		if !entry.lastStart.IsZero() {
+			lastStart := entry.lastStart
+			status.LastStart = &lastStart
+		}
+		if !entry.lastFinish.IsZero() {
+			lastFinish := entry.lastFinish
+			status.LastFinish = &lastFinish
+		}
+		if entry.lastErr != nil {
+			status.LastError = entry.lastErr.Error()
+		}
+		entry.mu.Unlock()
+		statuses = append(statuses, status)
+	}
+	sort.Slice(statuses, func(i, j int) bool {
+		return statuses[i].Name < statuses[j].Name
+	})
+	return statuses
+}
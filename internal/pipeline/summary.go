@@ -0,0 +1,146 @@
+package pipeline
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/rkapps/fin-tracker-backend-go/cmd/common/logger"
+	"github.com/rkapps/fin-tracker-backend-go/internal/domain"
+)
+
+// Summary aggregates the results of one pipeline run.
+type Summary[J any] struct {
+	Name      string
+	Started   time.Time
+	Finished  time.Time
+	Jobs      int // dispatched
+	Succeeded int
+	Failed    int
+	Skipped   int // not run — context cancelled before the job was picked up
+	Retries   int // attempts beyond the first, across all jobs
+	Results   []Result[J]
+}
+
+// Duration is the wall time of the run.
+func (s *Summary[J]) Duration() time.Duration {
+	return s.Finished.Sub(s.Started)
+}
+
+// FailureRate is failed over attempted jobs.
+func (s *Summary[J]) FailureRate() float64 {
+	attempted := s.Succeeded + s.Failed
+	if attempted == 0 {
+		return 0
+	}
+	return float64(s.Failed) / float64(attempted)
+}
+
+// Err joins the job errors, nil when every job succeeded.
+func (s *Summary[J]) Err() error {
+	errs := []error{}
+	for _, result := range s.Results {
+		if !result.succeeded() {
+			errs = append(errs, fmt.Errorf("%v: %w", result.Job, result.Err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Log writes one line per failed job (and per job at debug) followed by the totals.
+func (s *Summary[J]) Log(plog *logger.Logger) {
+	for _, result := range s.Results {
+		if result.succeeded() {
+			plog.Debug(s.Name, "Job", fmt.Sprintf("%v", result.Job), "Status", "ok", "Attempts", result.Attempts, "Duration", result.Duration)
+		} else {
+			plog.Error(s.Name, "Job", fmt.Sprintf("%v", result.Job), "Status", "failed", "Attempts", result.Attempts, "Duration", result.Duration, "Error", result.Err)
+		}
+	}
+	plog.Info(s.Name, "Jobs", s.Jobs, "Succeeded", s.Succeeded, "Failed", s.Failed, "Skipped", s.Skipped, "Retries", s.Retries, "Duration", s.Duration())
+}
+
+// String renders the summary as a plain text table.
+func (s *Summary[J]) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s: %d jobs, %d succeeded, %d failed, %d skipped, %d retries in %s\n",
+		s.Name, s.Jobs, s.Succeeded, s.Failed, s.Skipped, s.Retries, s.Duration().Round(time.Millisecond))
+	for _, result := range s.Results {
+		status := "ok"
+		message := ""
+		if !result.succeeded() {
+			status = "failed"
+			message = result.Err.Error()
+		}
+		fmt.Fprintf(&b, "  %-40v %-7s %2d %10s  %s\n", result.Job, status, result.Attempts, result.Duration.Round(time.Millisecond), message)
+	}
+	return b.String()
+}
+
+// Record copies the totals and per-user outcomes onto the run history.
+// The run is finished with the status derived from the results.
+func (s *Summary[J]) Record(run *domain.PipelineRun, cancelled bool) {
+	finished := s.Finished
+	run.Finished = &finished
+	run.Jobs = s.Jobs
+	run.Succeeded = s.Succeeded
+	run.Failed = s.Failed
+	run.Skipped = s.Skipped
+	run.Retries = s.Retries
+	run.Outcomes = []domain.PipelineJobOutcome{}
+	for _, result := range s.Results {
+		outcome := domain.PipelineJobOutcome{
+			Job:        fmt.Sprintf("%v", result.Job),
+			Status:     domain.PipelineRunSuccess,
+			Attempts:   result.Attempts,
+			DurationMs: result.Duration.Milliseconds(),
+		}
+		if job, ok := any(result.Job).(interface{ User() string }); ok {
+			outcome.UserID = job.User()
+		}
+		if !result.succeeded() {
+			outcome.Status = domain.PipelineRunFailed
+			outcome.Error = result.Err.Error()
+		}
+		run.Outcomes = append(run.Outcomes, outcome)
+	}
+
+	switch {
+	case cancelled:
+		run.Status = domain.PipelineRunCancelled
+	case s.Failed > 0:
+		run.Status = domain.PipelineRunFailed
+	default:
+		run.Status = domain.PipelineRunSuccess
+	}
+}
+
+func (s *Summary[J]) add(result Result[J]) {
+	s.Results = append(s.Results, result)
+	if result.Attempts > 1 {
+		s.Retries += result.Attempts - 1
+	}
+	if result.succeeded() {
+		s.Succeeded++
+	} else {
+		s.Failed++
+	}
+}
+
+// FailurePolicy decides when a run with failed jobs fails as a whole.
+// A run always fails when it exceeds either limit; zero values allow no failures.
+type FailurePolicy struct {
+	MaxFailures    int     // negative disables the count check
+	MaxFailureRate float64 // 0..1, negative disables the rate check
+}
+
+// Exceeded returns an error describing the breach, nil if the run is within the policy.
+func (p FailurePolicy) Exceeded(failed int, rate float64) error {
+	if p.MaxFailures >= 0 && failed > p.MaxFailures {
+		return fmt.Errorf("%d jobs failed, limit %d", failed, p.MaxFailures)
+	}
+	if p.MaxFailureRate >= 0 && failed > 0 && rate > p.MaxFailureRate {
+		return fmt.Errorf("failure rate %.1f%%, limit %.1f%%", rate*100, p.MaxFailureRate*100)
+	}
+	return nil
+}
@@ -2,9 +2,11 @@ package pipeline
 
 import (
 	"context"
+	"fmt"
 	"sync"
 	"time"
-	// TODO: import logger
+
+	"github.com/rkapps/fin-tracker-backend-go/cmd/common/logger"
 )
 
 // Handler is the function signature the worker calls for each job.
@@ -18,33 +20,42 @@ type WorkerPool[J any] struct {
 	jobs        chan J
 	results     chan Result[J]
 	handler     Handler[J]
+	retry       RetryPolicy
+	name        string   // pipeline name, labels the metrics
+	metrics     *Metrics // nil when not collected
 	collectDone chan struct{}
-	// TODO: add logger
+	collected   []Result[J] // owned by the collector until collectDone is closed
+	logger      *logger.Logger
 }
 
 // NewWorkerPool constructs a WorkerPool for job type J.
-func NewWorkerPool[J any](workerCount int, handler Handler[J]) *WorkerPool[J] {
+// Each job is attempted according to the retry policy.
+func NewWorkerPool[J any](workerCount int, handler Handler[J], retry RetryPolicy, plog *logger.Logger) *WorkerPool[J] {
 	return &WorkerPool[J]{
 		workerCount: workerCount,
 		handler:     handler,
+		retry:       retry,
 		collectDone: make(chan struct{}),
 		jobs:        make(chan J, workerCount*2),
 		results:     make(chan Result[J], workerCount),
+		logger:      plog,
 	}
 }
 
 // Start launches N worker goroutines and one collector goroutine.
-func (wp *WorkerPool[J]) Start(ctx context.Context, wg *sync.WaitGroup) {
+// Workers stop picking up jobs when ctx is cancelled; jobs run with jobCtx
+// so that in-flight jobs can outlive ctx while draining.
+func (wp *WorkerPool[J]) Start(ctx context.Context, jobCtx context.Context, wg *sync.WaitGroup) {
 	for i := 0; i < wp.workerCount; i++ {
 		wg.Add(1)
-		go wp.work(ctx, wg)
+		go wp.work(ctx, jobCtx, wg)
 	}
 
 	go wp.collect()
 }
 
 // work processes jobs until the channel is closed or ctx is cancelled.
-func (wp *WorkerPool[J]) work(ctx context.Context, wg *sync.WaitGroup) {
+func (wp *WorkerPool[J]) work(ctx context.Context, jobCtx context.Context, wg *sync.WaitGroup) {
 	defer wg.Done()
 
 	for job := range wp.jobs {
@@ -53,8 +64,11 @@ func (wp *WorkerPool[J]) work(ctx context.Context, wg *sync.WaitGroup) {
 			return
 		default:
 			start := time.Now()
-			err := wp.handler(ctx, job)
-			wp.results <- Result[J]{Job: job, Err: err, Duration: time.Since(start)}
+			wp.metrics.jobStarted(wp.name)
+			attempts, err := runWithRetry(jobCtx, wp.retry, wp.handler, job)
+			duration := time.Since(start)
+			wp.metrics.jobFinished(wp.name, attempts, duration, err)
+			wp.results <- Result[J]{Job: job, Err: err, Duration: duration, Attempts: attempts}
 		}
 	}
 }
@@ -66,11 +80,11 @@ func (wp *WorkerPool[J]) collect() {
 
 	for result := range wp.results {
 		if result.succeeded() {
-			// TODO: log success with job details and duration
+			wp.logger.Debug("collect", "Job", fmt.Sprintf("%v", result.Job), "Attempts", result.Attempts, "Duration", result.Duration)
 		} else {
-			// TODO: log error with job details and err
+			wp.logger.Warn("collect", "Job", fmt.Sprintf("%v", result.Job), "Attempts", result.Attempts, "Duration", result.Duration, "Error", result.Err)
 		}
-		// TODO: emit metrics (success/error counters, duration histogram)
+		wp.collected = append(wp.collected, result)
 	}
 }
 
@@ -88,8 +102,9 @@ func (wp *WorkerPool[J]) Dispatch(ctx context.Context, jobs []J) {
 	}
 }
 
-// Wait blocks until the collector has finished draining.
+// Wait blocks until the collector has finished draining and returns the results.
 // Must be called after wg.Wait() and close(wp.results).
-func (wp *WorkerPool[J]) Wait() {
+func (wp *WorkerPool[J]) Wait() []Result[J] {
 	<-wp.collectDone
+	return wp.collected
 }
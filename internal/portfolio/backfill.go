@@ -0,0 +1,228 @@
+package portfolio
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/rkapps/fin-tracker-backend-go/internal/domain"
+	"github.com/shopspring/decimal"
+)
+
+// position is a symbol held in an account at the end of a day.
+type position struct {
+	symbol    string
+	qty       decimal.Decimal
+	costValue decimal.Decimal
+}
+
+// dayPositions are the positions by account at the end of a day with activities.
+type dayPositions struct {
+	day       time.Time
+	positions map[string][]*position
+}
+
+// closes are the daily closes of a symbol, oldest first. The days are valued in
+// order, so at only moves forward.
+type closes struct {
+	history []*domain.TickerHistory
+	next    int
+	price   decimal.Decimal
+	priced  bool
+}
+
+// at returns the close of the day, or the last close before it e.g on a weekend.
+func (c *closes) at(day time.Time) (decimal.Decimal, bool) {
+	for c.next < len(c.history) && !domain.SnapshotDate(c.history[c.next].Date).After(day) {
+		c.price = c.history[c.next].Close
+		c.priced = true
+		c.next++
+	}
+	return c.price, c.priced
+}
+
+// BackfillValues rebuilds the daily value of the user's accounts from their first activity
+// to today and replaces the stored series. The lots do not keep when they were sold, so the
+// stored activities are replayed through a gain/loss run and the open lots at the end of
+// each day are priced with the ticker history closes. Returns the number of values saved.
+func (p Portfolio) BackfillValues(ctx context.Context, uid string) (int, error) {
+
+	user, err := p.storage.GetUser(ctx, uid)
+	if err != nil {
+		return 0, fmt.Errorf("User record does not exist: %w", err)
+	}
+	accts, err := p.storage.GetAccounts(ctx, uid)
+	if err != nil {
+		return 0, fmt.Errorf("error getting user accounts: %w", err)
+	}
+	actvs, err := p.storage.GetActivities(ctx, uid)
+	if err != nil {
+		return 0, fmt.Errorf("error getting user activities: %w", err)
+	}
+
+	values, err := p.replayValues(ctx, user, accts, actvs, domain.SnapshotDate(time.Now()))
+	if err != nil {
+		return 0, err
+	}
+
+	// replace the series — values of a day no longer valued are deleted
+	ovalues, err := p.storage.GetAccountValues(ctx, uid, time.Time{}, time.Time{})
+	if err != nil {
+		return 0, err
+	}
+	saved := make(map[string]bool)
+	for _, value := range values {
+		saved[value.ID] = true
+	}
+	ids := []string{}
+	for _, ovalue := range ovalues {
+		if !saved[ovalue.ID] {
+			ids = append(ids, ovalue.ID)
+		}
+	}
+	if err := p.storage.SaveAccountValues(ctx, values); err != nil {
+		return 0, err
+	}
+	if err := p.storage.DeleteAccountValues(ctx, ids); err != nil {
+		return 0, err
+	}
+	p.logger.Info("BackfillValues", "UID", uid, "Values", len(values), "Deleted", len(ids))
+	return len(values), nil
+}
+
+// replayValues values every account from its first activity to today, like summarizeData
+// values them today: net deposits and income from the activities, cash and cost from the
+// lots and market value at the close. Positions without a close are valued at cost.
+func (p Portfolio) replayValues(ctx context.Context, user *domain.User, accts []*domain.Account, actvs []*domain.Activity, today time.Time) ([]*domain.AccountValue, error) {
+
+	acctsm := make(map[string]*domain.Account)
+	for _, acct := range accts {
+		acctsm[acct.ID] = acct
+	}
+
+	days := []dayPositions{}
+	gl := NewGainLoss(accts, user.LotMatchingMethod, true, p.logConfig)
+	_, err := gl.Replay(ctx, actvs, func(day time.Time, lots []*domain.ActivityLot) {
+		days = append(days, dayPositions{day: day, positions: positionsOf(acctsm, lots)})
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error replaying activities: %w", err)
+	}
+	if len(days) == 0 {
+		return []*domain.AccountValue{}, nil
+	}
+	closesm := p.loadCloses(ctx, user, days)
+
+	// the run sorted the activities by date
+	values := []*domain.AccountValue{}
+	flowsm := make(map[string]*domain.AccountValue)
+	order := []string{}
+	var positions map[string][]*position
+	next, nextDay := 0, 0
+	for day := days[0].day; !day.After(today); day = day.AddDate(0, 0, 1) {
+
+		for ; nextDay < len(days) && !days[nextDay].day.After(day); nextDay++ {
+			positions = days[nextDay].positions
+		}
+		for ; next < len(actvs) && !domain.SnapshotDate(actvs[next].Date).After(day); next++ {
+			actv := actvs[next]
+			if _, ok := acctsm[actv.AccountID]; !ok {
+				continue
+			}
+			flows := flowsm[actv.AccountID]
+			if flows == nil {
+				flows = &domain.AccountValue{}
+				flowsm[actv.AccountID] = flows
+				order = append(order, actv.AccountID)
+			}
+			if actv.IsIncome() {
+				flows.Income = flows.Income.Add(actv.RcvAmount)
+			} else if actv.IsDeposit() {
+				flows.NetDeposits = flows.NetDeposits.Add(actv.RcvAmount)
+			} else if actv.IsWithdrawal() {
+				flows.NetDeposits = flows.NetDeposits.Sub(actv.SentAmount)
+			}
+		}
+
+		for _, acctId := range order {
+			value := &domain.AccountValue{UID: user.ID, AccountID: acctId}
+			value.SetDate(day)
+			value.NetDeposits = flowsm[acctId].NetDeposits
+			value.Income = flowsm[acctId].Income
+			for _, pos := range positions[acctId] {
+				if pos.symbol == user.CurrencyCode {
+					value.Cash = value.Cash.Add(pos.costValue)
+					continue
+				}
+				value.CostValue = value.CostValue.Add(pos.costValue)
+				if price, ok := closesm[pos.symbol].at(day); ok {
+					value.MarketValue = value.MarketValue.Add(pos.qty.Mul(price))
+				} else {
+					value.MarketValue = value.MarketValue.Add(pos.costValue)
+					value.Unpriced = append(value.Unpriced, pos.symbol)
+				}
+			}
+			values = append(values, value)
+		}
+	}
+	return values, nil
+}
+
+// positionsOf adds up the open lots by account and symbol, sorted by symbol. Lots of
+// cash accounts and of the unresolved placeholder are not held, like in GetHoldings.
+func positionsOf(acctsm map[string]*domain.Account, lots []*domain.ActivityLot) map[string][]*position {
+
+	positions := make(map[string][]*position)
+	positionsm := make(map[string]*position)
+	for _, lot := range lots {
+		if lot.Status != domain.LotStatusOpen || domain.IsUnresolvedAccountID(lot.AccountID) {
+			continue
+		}
+		acct := acctsm[lot.AccountID]
+		if acct == nil || acct.Category == domain.CategoryCash {
+			continue
+		}
+		key := getAccountSymbolKey(lot.AccountID, lot.Symbol)
+		pos := positionsm[key]
+		if pos == nil {
+			pos = &position{symbol: lot.Symbol}
+			positionsm[key] = pos
+			positions[lot.AccountID] = append(positions[lot.AccountID], pos)
+		}
+		pos.qty = pos.qty.Add(lot.Qty)
+		pos.costValue = pos.costValue.Add(lot.CostValue)
+	}
+	for _, acctPositions := range positions {
+		sort.Slice(acctPositions, func(i, j int) bool {
+			return acctPositions[i].symbol < acctPositions[j].symbol
+		})
+	}
+	return positions
+}
+
+// loadCloses reads the daily ticker history of every symbol held. USD is priced
+// at 1 like in GetTickerPriceDiff; a symbol without history is left unpriced.
+func (p Portfolio) loadCloses(ctx context.Context, user *domain.User, days []dayPositions) map[string]*closes {
+
+	closesm := make(map[string]*closes)
+	for _, day := range days {
+		for _, positions := range day.positions {
+			for _, pos := range positions {
+				if _, ok := closesm[pos.symbol]; ok || pos.symbol == user.CurrencyCode {
+					continue
+				}
+				if pos.symbol == "USD" {
+					closesm[pos.symbol] = &closes{price: decimal.NewFromInt(1), priced: true}
+					continue
+				}
+				history, err := p.tstorage.GetTickerHistory(ctx, domain.TickerSymbol(pos.symbol))
+				if err != nil {
+					p.logger.Warn("loadCloses", "Symbol", pos.symbol, "Error", err)
+				}
+				closesm[pos.symbol] = &closes{history: domain.DailyCloses(history)}
+			}
+		}
+	}
+	return closesm
+}
@@ -0,0 +1,82 @@
+package portfolio
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/rkapps/fin-tracker-backend-go/cmd/common/logger"
+	"github.com/rkapps/fin-tracker-backend-go/internal/domain"
+	"github.com/rkapps/fin-tracker-backend-go/internal/storage/memory"
+	"github.com/shopspring/decimal"
+)
+
+func TestReplayValues(t *testing.T) {
+
+	ctx := context.Background()
+	day := func(d int) time.Time { return time.Date(2024, 3, d, 0, 0, 0, 0, time.UTC) }
+	dec := decimal.NewFromInt
+
+	ts := memory.NewTickerMemoryStorage()
+	history := []*domain.TickerHistory{}
+	for d, close := range map[int]int64{4: 50, 5: 55} {
+		th := &domain.TickerHistory{ID: fmt.Sprintf("AAPL-%d", d), Date: day(d).Add(20 * time.Hour), Close: dec(close)}
+		th.Metadata.Symbol = "AAPL"
+		th.Metadata.Granularity = domain.GranularityDaily
+		history = append(history, th)
+	}
+	ts.SaveTickerHistory(ctx, history)
+
+	user := &domain.User{ID: "u1", CurrencyCode: "USD", LotMatchingMethod: domain.LotMatchingFIFO}
+	accts := []*domain.Account{{ID: "a1", Category: domain.CategoryBrokerage}}
+	actvs := []*domain.Activity{
+		{ID: "x4", AccountID: "a1", TxnType: domain.ActivityTypeDividend, Date: day(6), RcvSymbol: "USD", RcvAmount: dec(5)},
+		{ID: "x2", AccountID: "a1", TxnType: domain.ActivityTypeBuy, Date: day(4), RcvSymbol: "AAPL", RcvQuantity: dec(10),
+			RcvAmount: dec(500), SentSymbol: "USD", SentAmount: dec(500)},
+		{ID: "x3", AccountID: "a1", TxnType: domain.ActivityTypeBuy, Date: day(4), RcvSymbol: "XYZ", RcvQuantity: dec(1),
+			RcvAmount: dec(100), SentSymbol: "USD", SentAmount: dec(100)},
+		{ID: "x1", AccountID: "a1", TxnType: domain.ActivityTypeDeposit, Date: day(1), SentAccountID: "bank",
+			RcvSymbol: "USD", RcvAmount: dec(1000)},
+	}
+
+	logConfig := logger.New()
+	p := NewPortfolio(memory.NewFinTrackerMemoryStorage(), ts, nil, logConfig, logConfig.For("portfolio"))
+	values, err := p.replayValues(ctx, user, accts, actvs, day(7))
+	if err != nil {
+		t.Fatalf("replayValues() error = %v", err)
+	}
+	if len(values) != 7 {
+		t.Fatalf("replayValues() = %d values, want one a day from 2024-03-01 to 2024-03-07", len(values))
+	}
+
+	// a day without activities or a close carries the day before forward
+	want := []struct {
+		id                                   string
+		market, cost, cash, deposits, income int64
+		unpriced                             int
+	}{
+		{"a1-2024-03-01", 0, 0, 1000, 1000, 0, 0},
+		{"a1-2024-03-03", 0, 0, 1000, 1000, 0, 0},
+		{"a1-2024-03-04", 600, 600, 400, 1000, 0, 1},
+		{"a1-2024-03-05", 650, 600, 400, 1000, 0, 1},
+		{"a1-2024-03-06", 650, 600, 405, 1000, 5, 1},
+		{"a1-2024-03-07", 650, 600, 405, 1000, 5, 1},
+	}
+	valuesm := map[string]*domain.AccountValue{}
+	for _, value := range values {
+		valuesm[value.ID] = value
+	}
+	for _, w := range want {
+		v := valuesm[w.id]
+		if v == nil {
+			t.Errorf("value %s missing", w.id)
+			continue
+		}
+		if !v.MarketValue.Equal(dec(w.market)) || !v.CostValue.Equal(dec(w.cost)) || !v.Cash.Equal(dec(w.cash)) ||
+			!v.NetDeposits.Equal(dec(w.deposits)) || !v.Income.Equal(dec(w.income)) || len(v.Unpriced) != w.unpriced {
+			t.Errorf("value %s = market %v cost %v cash %v deposits %v income %v unpriced %v, want %+v",
+				w.id, v.MarketValue, v.CostValue, v.Cash, v.NetDeposits, v.Income, v.Unpriced, w)
+		}
+	}
+}
@@ -0,0 +1,91 @@
+package portfolio
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/rkapps/fin-tracker-backend-go/internal/domain"
+	"github.com/rkapps/fin-tracker-backend-go/internal/portfolio/refresher"
+	"github.com/rkapps/fin-tracker-backend-go/internal/secrets"
+)
+
+// accountCredentials are the credentials loaded for one refresh or sync.
+type accountCredentials struct {
+	creds    map[string]refresher.Credentials     // decrypted, keyed by account id
+	acreds   map[string]*domain.AccountCredential // stored records of the decrypted ones
+	unusable map[string]error                     // inactive, expired or undecryptable
+}
+
+// loadCredentials decrypts the usable credentials of the accounts. Only accounts
+// without a credential fall back to the provider key — an account whose credential
+// is inactive, expired or cannot be decrypted is unusable and must not be pulled.
+func (p Portfolio) loadCredentials(ctx context.Context, uid string, accts []domain.Account, now time.Time) accountCredentials {
+
+	ac := accountCredentials{
+		creds:    make(map[string]refresher.Credentials),
+		acreds:   make(map[string]*domain.AccountCredential),
+		unusable: make(map[string]error),
+	}
+	acreds, err := p.storage.GetAccountCredentials(ctx, uid)
+	if err != nil {
+		// without the records it is unknown which accounts have their own credential
+		p.logger.Warn("loadCredentials", "UID", uid, "Error", err)
+		for _, acct := range accts {
+			ac.unusable[acct.ID] = fmt.Errorf("error getting credentials: %w", err)
+		}
+		return ac
+	}
+	acredsm := make(map[string]*domain.AccountCredential)
+	for _, acred := range acreds {
+		acredsm[acred.ID] = acred
+	}
+
+	for _, acct := range accts {
+		acred, ok := acredsm[acct.ID]
+		if !ok {
+			continue
+		}
+		if err := acred.Usable(now); err != nil {
+			ac.unusable[acct.ID] = err
+			continue
+		}
+		if !acred.Encrypted() {
+			ac.unusable[acct.ID] = fmt.Errorf("credential %q is not encrypted, save it again", acred.Label)
+			continue
+		}
+		if p.cipher == nil {
+			ac.unusable[acct.ID] = fmt.Errorf("credential %q cannot be decrypted, no master key configured", acred.Label)
+			continue
+		}
+		// the stored credential is kept encrypted, a copy is opened
+		opened := *acred
+		env := secrets.Envelope{KeyVersion: acred.KeyVersion, WrappedKey: acred.WrappedKey}
+		if err := p.cipher.Open(env, opened.ID, opened.SecretFields()); err != nil {
+			p.logger.Warn("loadCredentials", "Account", acct.ID, "Error", err)
+			ac.unusable[acct.ID] = err
+			continue
+		}
+		ac.creds[acct.ID] = refresher.Credentials{APIKey: opened.APIKey, APISecret: opened.APISecret, Passphrase: opened.Passphrase}
+		ac.acreds[acct.ID] = acred
+	}
+	return ac
+}
+
+// withCredentials attaches the decrypted credentials to the context for the
+// batch refreshers and syncers.
+func (p Portfolio) withCredentials(ctx context.Context, ac accountCredentials) context.Context {
+	return refresher.WithCredentials(ctx, ac.creds)
+}
+
+// markCredentialUsed records when the account's credential was last used.
+func (p Portfolio) markCredentialUsed(ctx context.Context, ac accountCredentials, acctId string, now time.Time) {
+	acred, ok := ac.acreds[acctId]
+	if !ok {
+		return
+	}
+	acred.LastUsed = &now
+	if err := p.storage.SaveAccountCredential(ctx, acred); err != nil {
+		p.logger.Warn("markCredentialUsed", "Account", acctId, "Error", err)
+	}
+}
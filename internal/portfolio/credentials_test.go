@@ -0,0 +1,110 @@
+package portfolio
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/rkapps/fin-tracker-backend-go/cmd/common/logger"
+	"github.com/rkapps/fin-tracker-backend-go/internal/domain"
+	"github.com/rkapps/fin-tracker-backend-go/internal/secrets"
+	"github.com/rkapps/fin-tracker-backend-go/internal/storage/memory"
+)
+
+func TestSyncNeverFallsBackToTheProviderKey(t *testing.T) {
+
+	var (
+		mu     sync.Mutex
+		pulled = map[string]string{} // account number -> authorization
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		pulled[strings.Split(r.URL.Path, "/")[2]] = r.Header.Get("Authorization")
+		mu.Unlock()
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"transactions":[]}`))
+	}))
+	defer server.Close()
+	t.Setenv("EXCHANGE_URL_MOCKEX", server.URL)
+	t.Setenv("EXCHANGE_API_KEY_MOCKEX", "shared")
+
+	kms, err := secrets.NewLocalKMS("v1", map[string][]byte{"v1": bytes.Repeat([]byte{7}, 32)})
+	if err != nil {
+		t.Fatalf("NewLocalKMS() error = %v", err)
+	}
+	cipher := secrets.NewCipher(kms)
+
+	ctx := context.Background()
+	s := memory.NewFinTrackerMemoryStorage()
+	now := time.Now()
+	expired := now.Add(-time.Hour)
+	credential := func(acctId string, active bool, expiresAt *time.Time, encrypt bool) *domain.AccountCredential {
+		acred := &domain.AccountCredential{ID: acctId, UID: "u1", Provider: "mockex", Label: acctId,
+			APIKey: "own-" + acctId, Active: active, ExpiresAt: expiresAt}
+		if encrypt {
+			env, err := cipher.Seal(acred.ID, acred.SecretFields())
+			if err != nil {
+				t.Fatalf("Seal() error = %v", err)
+			}
+			acred.KeyVersion, acred.WrappedKey = env.KeyVersion, env.WrappedKey
+		}
+		return acred
+	}
+	acreds := []*domain.AccountCredential{
+		credential("active", true, nil, true),
+		credential("revoked", false, nil, true),
+		credential("expired", true, &expired, true),
+		credential("plain", true, nil, false),
+	}
+	for _, acred := range acreds {
+		if err := s.SaveAccountCredential(ctx, acred); err != nil {
+			t.Fatalf("SaveAccountCredential() error = %v", err)
+		}
+	}
+	for _, acctId := range []string{"active", "revoked", "expired", "plain", "shared"} {
+		acct := &domain.Account{ID: acctId, UID: "u1", Category: domain.CategoryCrypto, Type: domain.TypeExchange,
+			Detail: &domain.CryptoDetail{Exchange: "mockex", AccountNumber: acctId}}
+		if err := s.SaveAccount(ctx, acct); err != nil {
+			t.Fatalf("SaveAccount() error = %v", err)
+		}
+	}
+
+	logConfig := logger.New()
+	p := NewPortfolio(s, memory.NewTickerMemoryStorage(), cipher, logConfig, logConfig.For("portfolio"))
+	if err := p.SyncUserAccounts(ctx, "u1", SyncOptions{}); err == nil {
+		t.Errorf("SyncUserAccounts() error = nil, want the unusable credentials to fail")
+	}
+
+	// only the account with its own usable credential and the one without any are pulled
+	want := map[string]string{"active": "Bearer own-active", "shared": "Bearer shared"}
+	if len(pulled) != len(want) {
+		t.Errorf("pulled = %v, want %v", pulled, want)
+	}
+	for acctNumber, auth := range want {
+		if pulled[acctNumber] != auth {
+			t.Errorf("%s authorization = %q, want %q", acctNumber, pulled[acctNumber], auth)
+		}
+	}
+
+	astates, _ := s.GetAccountSyncStates(ctx, "u1")
+	for _, astate := range astates {
+		wantStatus := domain.SyncStatusFailed
+		if _, ok := want[astate.ID]; ok {
+			wantStatus = domain.SyncStatusSuccess
+		}
+		if astate.SyncStatus != wantStatus {
+			t.Errorf("sync state %s = %s (%s), want %s", astate.ID, astate.SyncStatus, astate.ErrorMessage, wantStatus)
+		}
+	}
+
+	// the refresh fails instead of pulling them with the provider key
+	if _, err := p.refreshUserActivities(ctx, "u1", []*domain.Account{{ID: "revoked", UID: "u1", Category: domain.CategoryCrypto,
+		Type: domain.TypeExchange, Detail: &domain.CryptoDetail{Exchange: "mockex", AccountNumber: "revoked"}}}); err == nil {
+		t.Errorf("refreshUserActivities() with a revoked credential error = nil")
+	}
+}
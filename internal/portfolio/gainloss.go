@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"sort"
 	"strings"
+	"time"
 
 	"github.com/rkapps/fin-tracker-backend-go/cmd/common/logger"
 	"github.com/rkapps/fin-tracker-backend-go/internal/domain"
@@ -31,6 +32,7 @@ type GainLoss struct {
 	logConfig         *logger.Config
 	logger            *logger.Logger
 	simulate          bool
+	dayEnd            func(day time.Time, lots []*domain.ActivityLot) // set by Replay
 }
 
 // GainLossResult is the output of one GL run.
@@ -74,14 +76,28 @@ func (gl *GainLoss) Run(ctx context.Context, actvs []*domain.Activity) (GainLoss
 	//       accumulate lots and GL entries
 	gr := &GainLossResult{}
 	uactvs := []*domain.Activity{}
-	sort.Slice(actvs, func(i, j int) bool {
-		return actvs[i].Date.Before(actvs[j].Date)
+	// stable so a dividend stays ahead of its reinvestment on the same date — income
+	// also goes first when the activities come back from storage in another order
+	sort.SliceStable(actvs, func(i, j int) bool {
+		if !actvs[i].Date.Equal(actvs[j].Date) {
+			return actvs[i].Date.Before(actvs[j].Date)
+		}
+		return actvs[i].IsIncome() && !actvs[j].IsIncome()
 	})
 
+	day := time.Time{}
 	for i, actv := range actvs {
 		if i > 10 {
 			// break
 		}
+		if gl.dayEnd != nil {
+			if next := domain.SnapshotDate(actv.Date); !next.Equal(day) {
+				if !day.IsZero() {
+					gl.dayEnd(day, utils.FlattenMap(gl.lotsMap))
+				}
+				day = next
+			}
+		}
 
 		gl.logger.Debug("---Run---", "Activity", actv.Debug())
 
@@ -110,6 +126,10 @@ func (gl *GainLoss) Run(ctx context.Context, actvs []*domain.Activity) (GainLoss
 		uactvs = append(uactvs, actv)
 	}
 
+	if gl.dayEnd != nil && !day.IsZero() {
+		gl.dayEnd(day, utils.FlattenMap(gl.lotsMap))
+	}
+
 	gr.Lots = utils.FlattenMap(gl.lotsMap)
 	gr.Actvs = uactvs
 	gl.logger.Info("Run", "UpdatedActivities", len(gr.Actvs))
@@ -117,6 +137,15 @@ func (gl *GainLoss) Run(ctx context.Context, actvs []*domain.Activity) (GainLoss
 	return *gr, nil
 }
 
+// Replay runs the activities like Run and calls dayEnd with the lots at the end of
+// every day that has an activity, oldest first. The lots change as the run goes on,
+// so dayEnd must copy what it keeps.
+func (gl *GainLoss) Replay(ctx context.Context, actvs []*domain.Activity, dayEnd func(day time.Time, lots []*domain.ActivityLot)) (GainLossResult, error) {
+	gl.dayEnd = dayEnd
+	defer func() { gl.dayEnd = nil }()
+	return gl.Run(ctx, actvs)
+}
+
 // lot creation
 func (gl *GainLoss) CreateAssetLot(ctx context.Context, actv *domain.Activity, acctId string, symbol string, qty decimal.Decimal, value decimal.Decimal) *domain.ActivityLot {
 
@@ -266,7 +295,7 @@ func (gl GainLoss) UpdateCashLot(ctx context.Context, actv *domain.Activity, acc
 	logger.Debug("UpdateCashLot", "Prev Qty", fmt.Sprintf("%v", lot.CostValue))
 
 	switch actv.TxnType {
-	case domain.ActivityTypeBuy, domain.ActivityTypeWithdraw:
+	case domain.ActivityTypeBuy, domain.ActivityTypeReinvest, domain.ActivityTypeWithdraw:
 		lot.Qty = lot.Qty.Sub(amount)
 		lot.CostValue = lot.CostValue.Sub(amount)
 	default:
@@ -275,7 +304,10 @@ func (gl GainLoss) UpdateCashLot(ctx context.Context, actv *domain.Activity, acc
 	}
 
 	logger.Debug("UpdateCashLot", "Updated Qty", fmt.Sprintf("%v", lot.CostValue))
-	lot.Cost = lot.CostValue.Div(lot.Qty)
+	// a reinvested dividend leaves the cash balance at zero
+	if !lot.Qty.IsZero() {
+		lot.Cost = lot.CostValue.Div(lot.Qty)
+	}
 
 	return lot, nil
 }
@@ -332,7 +364,9 @@ func (gl GainLoss) UpdateBankLot(ctx context.Context, actv *domain.Activity) (*d
 	}
 
 	logger.Debug("UpdateBankLot", "Updated Qty", fmt.Sprintf("%v", lot.CostValue))
-	lot.Cost = lot.CostValue.Div(lot.Qty)
+	if !lot.Qty.IsZero() {
+		lot.Cost = lot.CostValue.Div(lot.Qty)
+	}
 
 	return lot, nil
 }
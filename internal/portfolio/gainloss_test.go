@@ -0,0 +1,80 @@
+package portfolio
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/rkapps/fin-tracker-backend-go/cmd/common/logger"
+	"github.com/rkapps/fin-tracker-backend-go/internal/domain"
+	"github.com/rkapps/fin-tracker-backend-go/internal/portfolio/refresher"
+	"github.com/rkapps/fin-tracker-backend-go/internal/storage/memory"
+	"github.com/shopspring/decimal"
+)
+
+func TestWalletLotCost(t *testing.T) {
+
+	const address = "0xabc0000000000000000000000000000000000001"
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Query().Get("action") {
+		case "txlist":
+			w.Write([]byte(`{"status":"1","message":"OK","result":[
+				{"timeStamp":"1700000000","hash":"0x01","from":"0xfeed","to":"` + address + `","value":"2000000000000000000","isError":"0"},
+				{"timeStamp":"1700100000","hash":"0x02","from":"` + address + `","to":"0xrouter","value":"500000000000000000","isError":"0"}
+			]}`))
+		case "tokentx":
+			w.Write([]byte(`{"status":"1","message":"OK","result":[
+				{"timeStamp":"1700100000","hash":"0x02","from":"0xrouter","to":"` + address + `","value":"1500000000","tokenSymbol":"USDC","tokenDecimal":"6"}
+			]}`))
+		}
+	}))
+	defer server.Close()
+
+	ctx := context.Background()
+	ts := memory.NewTickerMemoryStorage()
+	for _, symbol := range []struct {
+		symbol string
+		day    int
+		close  int64
+	}{{"ETH", 14, 2000}, {"ETH", 16, 2400}, {"USDC", 14, 1}} {
+		th := &domain.TickerHistory{ID: fmt.Sprintf("%s-%d", symbol.symbol, symbol.day),
+			Date: time.Date(2023, 11, symbol.day, 0, 0, 0, 0, time.UTC), Close: decimal.NewFromInt(symbol.close)}
+		th.Metadata.Symbol = symbol.symbol
+		ts.SaveTickerHistory(ctx, []*domain.TickerHistory{th})
+	}
+
+	logConfig := logger.New()
+	account := &domain.Account{ID: "w1", UID: "u1", Category: domain.CategoryCrypto, Type: domain.TypeHotWallet,
+		Detail: &domain.CryptoDetail{Blockchain: "ethereum", Address: address}}
+	chain := refresher.ChainConfig{Blockchain: "ethereum", NativeSymbol: "ETH", BaseURL: server.URL}
+	actvs, err := refresher.NewWalletAccountRefresher(chain, ts, logConfig).Refresh(ctx, *account, logConfig)
+	if err != nil {
+		t.Fatalf("Refresh() error = %v", err)
+	}
+
+	result, err := NewGainLoss([]*domain.Account{account}, domain.LotMatchingFIFO, true, logConfig).Run(ctx, actvs)
+	if err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+
+	// 2 ETH received at 2000, 0.5 swapped on 2023-11-16 for USDC at the last USDC close
+	want := map[string]struct{ qty, cost string }{"ETH": {"1.5", "3000"}, "USDC": {"1500", "1500"}}
+	for _, lot := range result.Lots {
+		w, ok := want[lot.Symbol]
+		if !ok {
+			t.Errorf("unexpected lot %s", lot.Symbol)
+			continue
+		}
+		if !lot.Qty.Equal(decimal.RequireFromString(w.qty)) || !lot.CostValue.Equal(decimal.RequireFromString(w.cost)) {
+			t.Errorf("lot %s = %v cost %v, want %s cost %s", lot.Symbol, lot.Qty, lot.CostValue, w.qty, w.cost)
+		}
+		delete(want, lot.Symbol)
+	}
+	for symbol := range want {
+		t.Errorf("lot %s missing", symbol)
+	}
+}
@@ -1,6 +1,7 @@
 package portfolio
 
 import (
+	"context"
 	"fmt"
 	"strings"
 
@@ -10,11 +11,25 @@ import (
 	"github.com/shopspring/decimal"
 )
 
-// group accounts by provider
+// group accounts by provider — the exchange for exchange accounts, the chain for wallets
 func groupAccountsByProvider(accounts []domain.Account) map[string][]domain.Account {
 	accountm := make(map[string][]domain.Account)
 
-	//TODO
+	for _, account := range accounts {
+		detail, ok := account.Detail.(*domain.CryptoDetail)
+		if !ok {
+			continue
+		}
+		provider := detail.Exchange
+		if account.Type != domain.TypeExchange || len(provider) == 0 {
+			provider = detail.Blockchain
+		}
+		provider = strings.ToLower(strings.TrimSpace(provider))
+		if len(provider) == 0 {
+			continue
+		}
+		accountm[provider] = append(accountm[provider], account)
+	}
 	return accountm
 }
 
@@ -70,7 +85,7 @@ func filterBankAccount(acctIdsm map[string]*domain.Account, acctId string) bool
 	return true
 }
 
-func GetHoldings(storage storage.TickerStorageService, logger *logger.Logger, byAccount bool,
+func GetHoldings(ctx context.Context, storage storage.TickerStorageService, logger *logger.Logger, byAccount bool,
 	accts []*domain.Account, acctIds []string, lots []*domain.ActivityLot) ([]*domain.HoldingSummary, error) {
 
 	hldgs := []*domain.HoldingSummary{}
@@ -87,12 +102,15 @@ func GetHoldings(storage storage.TickerStorageService, logger *logger.Logger, by
 	}
 
 	// get tickermap
-	tm := GetTickersMapforLots(storage, lots)
+	tm := GetTickersMapforLots(ctx, storage, lots)
 	var key string
 	for _, lot := range lots {
 		if lot.Status != domain.LotStatusOpen {
 			continue
 		}
+		if domain.IsUnresolvedAccountID(lot.AccountID) {
+			continue
+		}
 		acct := acctsm[lot.AccountID]
 		if acct == nil {
 			logger.Error("GetHoldings - Account not found", "AccountId", lot.AccountID, "LotId", lot.ID)
@@ -171,20 +189,14 @@ func GetHoldings(storage storage.TickerStorageService, logger *logger.Logger, by
 
 }
 
-func GetTickersMapforLots(storage storage.TickerStorageService, lots []*domain.ActivityLot) map[string]domain.Ticker {
+func GetTickersMapforLots(ctx context.Context, storage storage.TickerStorageService, lots []*domain.ActivityLot) map[string]domain.Ticker {
 	tm := make(map[string]domain.Ticker)
 
 	tsymbols := []string{}
 	tsymbolsm := make(map[string]string)
 	for _, lot := range lots {
 
-		symbol := lot.Symbol
-		if strings.Compare(symbol, "ETH2") == 0 ||
-			strings.Compare(symbol, "WETH") == 0 {
-			symbol = "ETH"
-		} else if strings.Compare(symbol, "mSOL") == 0 {
-			symbol = "SOL"
-		}
+		symbol := domain.TickerSymbol(lot.Symbol)
 		if _, ok := tsymbolsm[symbol]; ok {
 			continue
 		}
@@ -192,7 +204,7 @@ func GetTickersMapforLots(storage storage.TickerStorageService, lots []*domain.A
 		tsymbolsm[symbol] = symbol
 	}
 
-	ts, _ := storage.GetTickers(tsymbols)
+	ts, _ := storage.GetTickers(ctx, tsymbols)
 	for _, ticker := range ts {
 		tm[ticker.Symbol] = *ticker
 	}
@@ -202,15 +214,7 @@ func GetTickersMapforLots(storage storage.TickerStorageService, lots []*domain.A
 
 func GetTickerPriceDiff(tm map[string]domain.Ticker, symbol string) domain.Ticker {
 
-	var ticker domain.Ticker
-	if strings.Compare(symbol, "ETH2") == 0 ||
-		strings.Compare(symbol, "WETH") == 0 {
-		ticker = tm["ETH"]
-	} else if strings.Compare(symbol, "mSOL") == 0 {
-		ticker = tm["SOL"]
-	} else {
-		ticker = tm[symbol]
-	}
+	ticker := tm[domain.TickerSymbol(symbol)]
 	if len(ticker.Symbol) == 0 {
 		ticker = domain.Ticker{}
 		ticker.Symbol = symbol
@@ -12,10 +12,17 @@ func ResolveProcessor(actv domain.Activity, lm LotManager, logConfig *logger.Con
 	switch actv.TxnType {
 	case domain.ActivityTypeDividend, domain.ActivityTypeInterest, domain.ActivityTypeRollover, domain.ActivityTypeDeposit, domain.ActivityTypeWithdraw:
 		return NewCashActivityProcessor(logConfig), nil
-	case domain.ActivityTypeBuy:
+	case domain.ActivityTypeBuy, domain.ActivityTypeReinvest:
 		return NewAcquisitionActivityProcessor(logConfig), nil
 	case domain.ActivityTypeSell:
 		return NewDisposalActivityProcessor(logConfig), nil
+	case domain.ActivityTypeTransfer, domain.ActivityTypeIncome:
+		// income in kind e.g staking rewards lands as a new lot like an inbound transfer
+		return NewTransferActivityProcessor(logConfig), nil
+	case domain.ActivityTypeTrade:
+		return NewTradeActivityProcessor(logConfig), nil
+	case domain.ActivityTypeFee:
+		return NewFeeActivityProcessor(logConfig), nil
 	}
 
 	return nil, fmt.Errorf("%s activity processor not available.", actv.TxnType)
@@ -0,0 +1,114 @@
+package processor
+
+import (
+	"context"
+
+	"github.com/rkapps/fin-tracker-backend-go/cmd/common/logger"
+	"github.com/rkapps/fin-tracker-backend-go/internal/domain"
+)
+
+// TransferActivityProcessor moves an asset in or out of a wallet.
+// The counterparty is an external address so no lot is created on the other side.
+type TransferActivityProcessor struct {
+	logger *logger.Logger
+}
+
+func NewTransferActivityProcessor(logConfig *logger.Config) TransferActivityProcessor {
+	plog := logConfig.For("processor.transfer")
+	return TransferActivityProcessor{logger: plog}
+}
+
+// ensures TransferActivityProcessor implements ActivityProcessor at compile time
+var _ ActivityProcessor = (*TransferActivityProcessor)(nil)
+
+func (p TransferActivityProcessor) Process(ctx context.Context, actv *domain.Activity, lm LotManager) (*ProcessorResult, error) {
+
+	p.logger.Debug("Process")
+	newctx := logger.WithContext(ctx, p.logger)
+	pr := NewProcessResult()
+
+	if actv.RcvQuantity.IsPositive() {
+		lm.CreateAssetLot(newctx, actv, actv.AccountID, actv.RcvSymbol, actv.RcvQuantity, actv.RcvAmount)
+		pr.Value = actv.RcvAmount
+	}
+	if actv.SentQuantity.IsPositive() {
+		if _, err := lm.ReduceLotQty(newctx, actv); err != nil {
+			return nil, err
+		}
+		pr.Value = actv.SentAmount
+	}
+	if err := reduceFee(newctx, actv, lm); err != nil {
+		return nil, err
+	}
+	return pr, nil
+}
+
+// TradeActivityProcessor swaps one asset for another within the same account.
+type TradeActivityProcessor struct {
+	logger *logger.Logger
+}
+
+func NewTradeActivityProcessor(logConfig *logger.Config) TradeActivityProcessor {
+	plog := logConfig.For("processor.trade")
+	return TradeActivityProcessor{logger: plog}
+}
+
+// ensures TradeActivityProcessor implements ActivityProcessor at compile time
+var _ ActivityProcessor = (*TradeActivityProcessor)(nil)
+
+func (p TradeActivityProcessor) Process(ctx context.Context, actv *domain.Activity, lm LotManager) (*ProcessorResult, error) {
+
+	p.logger.Debug("Process")
+	newctx := logger.WithContext(ctx, p.logger)
+	pr := NewProcessResult()
+
+	if _, err := lm.ReduceLotQty(newctx, actv); err != nil {
+		return nil, err
+	}
+	lm.CreateAssetLot(newctx, actv, actv.AccountID, actv.RcvSymbol, actv.RcvQuantity, actv.RcvAmount)
+	if err := reduceFee(newctx, actv, lm); err != nil {
+		return nil, err
+	}
+
+	pr.Value = actv.RcvAmount
+	p.logger.Debug("Process", "Sent", actv.SentSymbol, "Rcv", actv.RcvSymbol)
+	return pr, nil
+}
+
+// FeeActivityProcessor handles standalone fees e.g. gas for a contract call.
+type FeeActivityProcessor struct {
+	logger *logger.Logger
+}
+
+func NewFeeActivityProcessor(logConfig *logger.Config) FeeActivityProcessor {
+	plog := logConfig.For("processor.fee")
+	return FeeActivityProcessor{logger: plog}
+}
+
+// ensures FeeActivityProcessor implements ActivityProcessor at compile time
+var _ ActivityProcessor = (*FeeActivityProcessor)(nil)
+
+func (p FeeActivityProcessor) Process(ctx context.Context, actv *domain.Activity, lm LotManager) (*ProcessorResult, error) {
+
+	p.logger.Debug("Process")
+	newctx := logger.WithContext(ctx, p.logger)
+	pr := NewProcessResult()
+
+	if _, err := lm.ReduceLotQty(newctx, actv); err != nil {
+		return nil, err
+	}
+	pr.Value = actv.SentAmount
+	return pr, nil
+}
+
+// reduceFee consumes the fee paid in an asset (e.g. gas) from the account's lots.
+func reduceFee(ctx context.Context, actv *domain.Activity, lm LotManager) error {
+	if !actv.Fee.IsPositive() || len(actv.FeeCurrency) == 0 {
+		return nil
+	}
+	factv := *actv
+	factv.SentSymbol = actv.FeeCurrency
+	factv.SentQuantity = actv.Fee
+	_, err := lm.ReduceLotQty(ctx, &factv)
+	return err
+}
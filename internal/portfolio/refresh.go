@@ -2,12 +2,12 @@ package portfolio
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log/slog"
 	"sync"
 	"time"
 
-	"github.com/google/uuid"
 	"github.com/rkapps/fin-tracker-backend-go/internal/domain"
 	"github.com/rkapps/fin-tracker-backend-go/internal/portfolio/refresher"
 	"golang.org/x/sync/errgroup"
@@ -16,22 +16,22 @@ import (
 func (p Portfolio) RefreshUserAccounts(ctx context.Context, uid string, simulate bool) error {
 
 	var err error
-	user, err := p.storage.GetUser(uid)
+	user, err := p.storage.GetUser(ctx, uid)
 	if err != nil {
-		return fmt.Errorf("User record does not exist")
+		return fmt.Errorf("User record does not exist: %w", err)
 	}
 
 	p.logger.Info("RefreshUserAccounts", "UID", uid, "CurrencyCode", user.CurrencyCode)
 	p.logger.Trace("RefreshUserAccounts", "UID", uid, "Simulate", simulate)
-	accts, err := p.storage.GetAccounts(uid)
+	accts, err := p.storage.GetAccounts(ctx, uid)
 	if err != nil {
-		return fmt.Errorf("error getting user accounts: %v", err)
+		return fmt.Errorf("error getting user accounts: %w", err)
 	}
 
-	actvs, err := p.refreshUserActivities(ctx, accts)
+	actvs, err := p.refreshUserActivities(ctx, uid, accts)
 	if err != nil {
 		p.logger.Error("RefreshUserAccounts", "Error", err)
-		return fmt.Errorf("error refreshing user activities")
+		return fmt.Errorf("error refreshing user activities: %w", err)
 	}
 
 	p.logger.Info("RefreshUserAccounts", "Activities", len(actvs))
@@ -40,24 +40,25 @@ func (p Portfolio) RefreshUserAccounts(ctx context.Context, uid string, simulate
 	glResult, err := gl.Run(ctx, actvs)
 	if err != nil {
 		p.logger.Error("RefreshUserAccounts", "Run", err)
-		return fmt.Errorf("error running gainloss")
+		return fmt.Errorf("error running gainloss: %w", err)
 	}
 
-	asumys, err := p.summarizeData(uid, accts, glResult.Actvs, glResult.Lots)
+	asumys, err := p.summarizeData(ctx, uid, accts, glResult.Actvs, glResult.Lots)
 	if err != nil {
 		p.logger.Error("RefreshUserAccounts", "SummarizeData", err)
-		return fmt.Errorf("error summarizing data")
+		return fmt.Errorf("error summarizing data: %w", err)
 	}
 
-	if !simulate {
-		err = p.saveData(uid, asumys, glResult.Actvs, glResult.Lots)
+	if simulate {
+		return nil
 	}
-
-	// gain loss here
-	return err
+	if err = p.saveData(ctx, uid, asumys, glResult.Actvs, glResult.Lots); err != nil {
+		return err
+	}
+	return p.clearRefreshFlags(ctx, uid)
 }
 
-func (p Portfolio) refreshUserActivities(ctx context.Context, accts []*domain.Account) ([]*domain.Activity, error) {
+func (p Portfolio) refreshUserActivities(ctx context.Context, uid string, accts []*domain.Account) ([]*domain.Activity, error) {
 
 	// split accounts by pattern — per account vs per type batch
 	var (
@@ -70,7 +71,7 @@ func (p Portfolio) refreshUserActivities(ctx context.Context, accts []*domain.Ac
 			continue
 		}
 		switch account.Type {
-		case domain.TypeExchange, domain.TypeHotWallet:
+		case domain.TypeExchange:
 			batchAccounts = append(batchAccounts, *account)
 		default:
 			singleAccounts = append(singleAccounts, *account)
@@ -82,13 +83,23 @@ func (p Portfolio) refreshUserActivities(ctx context.Context, accts []*domain.Ac
 		activities []*domain.Activity
 	)
 
+	// an account with an inactive or expired credential fails the refresh rather
+	// than being pulled with the provider key or dropped from the portfolio
+	ac := p.loadCredentials(ctx, uid, batchAccounts, time.Now())
+	for _, account := range batchAccounts {
+		if err, ok := ac.unusable[account.ID]; ok {
+			return nil, fmt.Errorf("account %s: %w", account.ID, err)
+		}
+	}
+	ctx = p.withCredentials(ctx, ac)
+
 	g, ctx := errgroup.WithContext(ctx)
 
-	// fan out per account — brokerage, wallet, imported
+	// fan out per account — brokerage, wallet (hot and imported), imported
 	for _, account := range singleAccounts {
 		account := account
 		g.Go(func() error {
-			refresher, err := refresher.ResolveRefresher(p.storage, account, p.logConfig)
+			refresher, err := refresher.ResolveRefresher(p.storage, p.tstorage, account, p.logConfig)
 			if err != nil {
 				return err
 			}
@@ -105,18 +116,20 @@ func (p Portfolio) refreshUserActivities(ctx context.Context, accts []*domain.Ac
 		})
 	}
 
-	// one call for all exchange accounts — grouped by exchange type
-	// TODO: group exchangeAccounts by exchange provider (e.g. Coinbase, Binance)
-	//       each provider gets one Refresh(ctx, []account) call
+	// one call for all exchange accounts — grouped by exchange provider (e.g. Coinbase, Binance)
 	for provider, providerAccounts := range groupAccountsByProvider(batchAccounts) {
 		providerAccounts := providerAccounts
 		provider := provider
 		g.Go(func() error {
-			refresher, err := refresher.ResolveBatchRefresher(provider)
+			batchRefresher, err := refresher.ResolveBatchRefresher(provider, p.logConfig)
+			if errors.Is(err, refresher.ErrProviderNotSupported) {
+				p.logger.Warn("refreshUserActivities", "Provider", provider, "Error", err)
+				return nil
+			}
 			if err != nil {
 				return err
 			}
-			result, err := refresher.Refresh(ctx, provider, providerAccounts)
+			result, err := batchRefresher.Refresh(ctx, provider, providerAccounts)
 			if err != nil {
 				return err
 			}
@@ -134,10 +147,10 @@ func (p Portfolio) refreshUserActivities(ctx context.Context, accts []*domain.Ac
 	return activities, nil
 }
 
-func (p Portfolio) summarizeData(uid string, accts []*domain.Account, actvs []*domain.Activity, lots []*domain.ActivityLot) ([]*domain.AccountSummary, error) {
+func (p Portfolio) summarizeData(ctx context.Context, uid string, accts []*domain.Account, actvs []*domain.Activity, lots []*domain.ActivityLot) ([]*domain.AccountSummary, error) {
 
 	asumys := []*domain.AccountSummary{}
-	user, err := p.storage.GetUser(uid)
+	user, err := p.storage.GetUser(ctx, uid)
 	if err != nil {
 		return asumys, err
 	}
@@ -162,8 +175,7 @@ func (p Portfolio) summarizeData(uid string, accts []*domain.Account, actvs []*d
 			asummary.AccountID = actv.AccountID
 			asummary.AccountName = acct.Name
 			asummary.UID = uid
-			asummary.Date = time.Now()
-			asummary.ID = uuid.New().String()
+			asummary.SetSnapshot(time.Now())
 			asummary.SectorHldgs = make(map[string]*domain.AccountSummaryValue)
 			asummary.AssetTypeHlgds = make(map[string]*domain.AccountSummaryValue)
 			asummarym[key] = asummary
@@ -181,9 +193,9 @@ func (p Portfolio) summarizeData(uid string, accts []*domain.Account, actvs []*d
 	}
 
 	// get holding with symbol to get cash
-	hldgs, err := GetHoldings(p.tstorage, p.logger, false, accts, []string{}, lots)
+	hldgs, err := GetHoldings(ctx, p.tstorage, p.logger, false, accts, []string{}, lots)
 	if err != nil {
-		return asumys, fmt.Errorf("getholdings error: %v", err)
+		return asumys, fmt.Errorf("getholdings error: %w", err)
 	}
 
 	for _, hldg := range hldgs {
@@ -240,50 +252,76 @@ func (p Portfolio) summarizeData(uid string, accts []*domain.Account, actvs []*d
 	return asumys, nil
 }
 
-func (p Portfolio) saveData(uid string, asumys []*domain.AccountSummary, actvs []*domain.Activity, lots []*domain.ActivityLot) error {
-
-	ids := []string{}
+func (p Portfolio) saveData(ctx context.Context, uid string, asumys []*domain.AccountSummary, actvs []*domain.Activity, lots []*domain.ActivityLot) error {
 
-	// clear and delete activitysummaries
-	clear(ids)
-	oasumys, err := p.storage.GetAccountSummaries(uid)
+	// summaries are daily snapshots — delete those of an earlier refresh today that this one
+	// does not replace, e.g of an account that has no activities any more
+	today := domain.SnapshotDate(time.Now())
+	oasumys, err := p.storage.GetAccountSummariesByDate(ctx, uid, today, time.Time{})
 	if err != nil {
 		return err
 	}
+	saved := make(map[string]bool)
+	for _, asum := range asumys {
+		saved[asum.ID] = true
+	}
+	ids := []string{}
 	for _, oasum := range oasumys {
-		ids = append(ids, oasum.ID)
+		if !saved[oasum.ID] {
+			ids = append(ids, oasum.ID)
+		}
+	}
+	if err := p.storage.DeleteAccountSummaries(ctx, ids); err != nil {
+		return err
 	}
-	p.storage.DeleteAccountSummaries(ids)
 
-	clear(ids)
 	// get and delete activities
-	oactvs, _ := p.storage.GetActivities(uid)
+	oactvs, err := p.storage.GetActivities(ctx, uid)
+	if err != nil {
+		return err
+	}
+	ids = []string{}
 	for _, oactv := range oactvs {
 		ids = append(ids, oactv.ID)
 	}
-	p.storage.DeleteActivities(ids)
+	if err := p.storage.DeleteActivities(ctx, ids); err != nil {
+		return err
+	}
 
-	// clear and delete activitylogs
-	clear(ids)
-	olots, _ := p.storage.GetActivityLots(uid)
+	// get and delete activity lots
+	olots, err := p.storage.GetActivityLots(ctx, uid)
+	if err != nil {
+		return err
+	}
+	ids = []string{}
 	for _, olot := range olots {
 		ids = append(ids, olot.ID)
 	}
-
-	err = p.storage.DeleteActivityLots(ids)
-	if err != nil {
+	if err := p.storage.DeleteActivityLots(ctx, ids); err != nil {
 		return err
 	}
 
-	err = p.storage.SaveAccountSummaries(asumys)
-	if err != nil {
+	if err := p.storage.SaveAccountSummaries(ctx, asumys); err != nil {
 		return err
 	}
-
-	err = p.storage.SaveActivities(actvs)
-	if err != nil {
+	if err := p.storage.SaveActivities(ctx, actvs); err != nil {
+		return err
+	}
+	if err := p.storage.SaveActivityLots(ctx, lots); err != nil {
 		return err
 	}
 
-	return p.storage.SaveActivityLots(lots)
+	// keep the backfilled value series current with today's summaries
+	values := []*domain.AccountValue{}
+	for _, asum := range asumys {
+		value := &domain.AccountValue{UID: uid, AccountID: asum.AccountID, MarketValue: asum.MarketValue,
+			CostValue: asum.CostValue, Cash: asum.Cash, NetDeposits: asum.NetDeposits, Income: asum.Income}
+		value.SetDate(today)
+		values = append(values, value)
+	}
+	if err := p.storage.SaveAccountValues(ctx, values); err != nil {
+		return err
+	}
+	p.pruneSummaries(ctx, uid, today)
+	return nil
 }
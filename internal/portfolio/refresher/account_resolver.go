@@ -0,0 +1,106 @@
+package refresher
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/rkapps/fin-tracker-backend-go/internal/domain"
+)
+
+const (
+	// minimum trailing digits needed to match on an account number
+	minAccountSuffix = 4
+)
+
+var trailingDigits = regexp.MustCompile(`(\d+)\D*$`)
+
+// AccountResolver resolves the counter account of deposits and withdrawals.
+// Matches in order: account id, name and alternate names, regex aliases and
+// finally the account number suffix (e.g. "CHASE CHECKING ...1234").
+type AccountResolver struct {
+	uid     string
+	accts   []*domain.Account
+	aliases map[string][]*regexp.Regexp
+}
+
+func NewAccountResolver(uid string, accts []*domain.Account) AccountResolver {
+
+	aliases := make(map[string][]*regexp.Regexp)
+	for _, acct := range accts {
+		for _, pattern := range acct.AliasPatterns {
+			re, err := regexp.Compile("(?i)" + pattern)
+			if err != nil {
+				continue
+			}
+			aliases[acct.ID] = append(aliases[acct.ID], re)
+		}
+	}
+	return AccountResolver{uid: uid, accts: accts, aliases: aliases}
+}
+
+// Resolve returns the account id for the counterparty.
+// Returns the unresolved placeholder and false when nothing matches.
+func (r AccountResolver) Resolve(acctId string, counterparty string) (string, bool) {
+
+	if len(acctId) > 0 {
+		for _, acct := range r.accts {
+			if acct.ID == acctId {
+				return acctId, true
+			}
+		}
+	}
+
+	name := strings.TrimSpace(counterparty)
+	if len(name) == 0 {
+		return domain.UnresolvedAccountID(r.uid), false
+	}
+
+	for _, acct := range r.accts {
+		if strings.EqualFold(acct.Name, name) {
+			return acct.ID, true
+		}
+		for _, alt := range acct.AlternateNames {
+			if strings.EqualFold(alt, name) {
+				return acct.ID, true
+			}
+		}
+	}
+
+	for _, acct := range r.accts {
+		for _, re := range r.aliases[acct.ID] {
+			if re.MatchString(name) {
+				return acct.ID, true
+			}
+		}
+	}
+
+	if id := r.resolveBySuffix(name); len(id) > 0 {
+		return id, true
+	}
+
+	return domain.UnresolvedAccountID(r.uid), false
+}
+
+// resolveBySuffix matches the trailing digits of the counterparty with the
+// end of an account number. Ambiguous matches are not resolved.
+func (r AccountResolver) resolveBySuffix(name string) string {
+
+	m := trailingDigits.FindStringSubmatch(name)
+	if m == nil || len(m[1]) < minAccountSuffix {
+		return ""
+	}
+	suffix := m[1]
+
+	matched := ""
+	for _, acct := range r.accts {
+		number := acct.AccountNumber()
+		if len(number) < minAccountSuffix || !strings.HasSuffix(number, suffix) && !strings.HasSuffix(suffix, number) {
+			continue
+		}
+		if len(matched) > 0 {
+			return ""
+		}
+		matched = acct.ID
+	}
+	return matched
+}
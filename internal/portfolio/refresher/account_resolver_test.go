@@ -0,0 +1,40 @@
+package refresher
+
+import (
+	"testing"
+
+	"github.com/rkapps/fin-tracker-backend-go/internal/domain"
+)
+
+func TestAccountResolver(t *testing.T) {
+
+	accts := []*domain.Account{
+		{ID: "chk", Name: "Checking", Category: domain.CategoryCash, Detail: &domain.BankDetail{AccountNumber: "000981234"}},
+		{ID: "sav", Name: "Savings", Category: domain.CategoryCash, AlternateNames: []string{"ALLY SAVINGS"},
+			AliasPatterns: []string{`^ally bank.*transfer`}, Detail: &domain.BankDetail{AccountNumber: "55550001"}},
+	}
+	resolver := NewAccountResolver("u1", accts)
+
+	tests := []struct {
+		name         string
+		acctId       string
+		counterparty string
+		want         string
+		ok           bool
+	}{
+		{"account id", "chk", "", "chk", true},
+		{"alternate name", "", "ally savings", "sav", true},
+		{"alias pattern", "", "ALLY BANK ONLINE TRANSFER", "sav", true},
+		{"account number suffix", "", "CHASE CHECKING ...1234", "chk", true},
+		{"short suffix", "", "CHECKING 34", domain.UnresolvedAccountID("u1"), false},
+		{"unknown", "", "Venmo", domain.UnresolvedAccountID("u1"), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := resolver.Resolve(tt.acctId, tt.counterparty)
+			if got != tt.want || ok != tt.ok {
+				t.Errorf("Resolve(%q, %q) = %q, %v; want %q, %v", tt.acctId, tt.counterparty, got, ok, tt.want, tt.ok)
+			}
+		})
+	}
+}
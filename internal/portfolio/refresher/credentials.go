@@ -0,0 +1,52 @@
+package refresher
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/rkapps/fin-tracker-backend-go/cmd/common/logger"
+	"github.com/rkapps/fin-tracker-backend-go/internal/domain"
+)
+
+// Credentials are the decrypted api secrets of an account.
+type Credentials struct {
+	APIKey     string
+	APISecret  string
+	Passphrase string
+}
+
+type credentialsKey struct{}
+
+// WithCredentials attaches the decrypted credentials keyed by account id.
+// They only live for the duration of the refresh or sync.
+func WithCredentials(ctx context.Context, creds map[string]Credentials) context.Context {
+	return context.WithValue(ctx, credentialsKey{}, creds)
+}
+
+func credentialsFor(ctx context.Context, acctId string) (Credentials, bool) {
+	creds, ok := ctx.Value(credentialsKey{}).(map[string]Credentials)
+	if !ok {
+		return Credentials{}, false
+	}
+	cred, ok := creds[acctId]
+	return cred, ok
+}
+
+// CredentialTester is implemented by batch refreshers that can verify credentials.
+// It returns the permissions the provider reports for the key, if it reports them.
+type CredentialTester interface {
+	TestCredentials(ctx context.Context, account domain.Account, creds Credentials) ([]string, error)
+}
+
+// TestCredentials checks the credentials against the account's provider.
+func TestCredentials(ctx context.Context, provider string, account domain.Account, creds Credentials, logConfig *logger.Config) ([]string, error) {
+	batchRefresher, err := ResolveBatchRefresher(provider, logConfig)
+	if err != nil {
+		return nil, err
+	}
+	tester, ok := batchRefresher.(CredentialTester)
+	if !ok {
+		return nil, fmt.Errorf("%w: %s cannot test credentials", ErrProviderNotSupported, provider)
+	}
+	return tester.TestCredentials(ctx, account, creds)
+}
@@ -0,0 +1,316 @@
+package refresher
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rkapps/fin-tracker-backend-go/cmd/common/logger"
+	"github.com/rkapps/fin-tracker-backend-go/internal/domain"
+	"github.com/shopspring/decimal"
+)
+
+// ExchangeTx is a transaction returned by the REST exchange api.
+type ExchangeTx struct {
+	ID            string          `json:"id"`
+	Type          string          `json:"type"` // buy, sell, trade, deposit, withdraw, send, receive, reward, fee
+	Time          time.Time       `json:"time"`
+	Asset         string          `json:"asset"`
+	Quantity      decimal.Decimal `json:"quantity"`
+	QuoteAsset    string          `json:"quoteAsset,omitempty"`
+	QuoteQuantity decimal.Decimal `json:"quoteQuantity"`
+	Fee           decimal.Decimal `json:"fee"`
+	FeeCurrency   string          `json:"feeCurrency,omitempty"`
+	OrderID       string          `json:"orderId,omitempty"`
+	TradeID       string          `json:"tradeId,omitempty"`
+	OrderType     string          `json:"orderType,omitempty"`
+	Address       string          `json:"address,omitempty"` // counterparty for send/receive
+}
+
+type exchangeTxPage struct {
+	Transactions []ExchangeTx `json:"transactions"`
+	NextCursor   string       `json:"nextCursor"`
+}
+
+// RestExchangeRefresher is the reference batch refresher. It reads
+// GET {baseURL}/accounts/{accountNumber}/transactions for every account of the provider.
+type RestExchangeRefresher struct {
+	config     ProviderConfig
+	httpClient *http.Client
+	logger     *logger.Logger
+}
+
+// ensures RestExchangeRefresher implements BatchAccountRefresher at compile time
+var _ BatchAccountRefresher = (*RestExchangeRefresher)(nil)
+
+func NewRestExchangeRefresher(config ProviderConfig, logConfig *logger.Config) BatchAccountRefresher {
+	plog := logConfig.For("refresher.exchange")
+	return RestExchangeRefresher{
+		config:     config,
+		httpClient: &http.Client{Timeout: 30 * time.Second},
+		logger:     plog,
+	}
+}
+
+func (r RestExchangeRefresher) Refresh(ctx context.Context, provider string, accounts []domain.Account) ([]*domain.Activity, error) {
+
+	r.logger.Info("Refresh", "Provider", provider, "Accounts", len(accounts))
+	actvs := []*domain.Activity{}
+	for _, account := range accounts {
+		acctNumber := account.AccountNumber()
+		if len(acctNumber) == 0 {
+			r.logger.Warn("Refresh", "Account", account.ID, "Error", "account number not set")
+			continue
+		}
+		creds, _ := credentialsFor(ctx, account.ID)
+		txs, err := r.transactions(ctx, acctNumber, creds, sinceFor(ctx, account.ID))
+		if err != nil {
+			return nil, fmt.Errorf("%s account %s: %w", provider, account.ID, err)
+		}
+		r.logger.Debug("Refresh", "Account", account.ID, "Transactions", len(txs))
+		for _, tx := range txs {
+			actv := r.mapActivity(account, tx)
+			if actv == nil {
+				r.logger.Warn("Refresh", "Account", account.ID, "Unknown type", tx.Type, "Id", tx.ID)
+				continue
+			}
+			actvs = append(actvs, actv)
+		}
+	}
+	return actvs, nil
+}
+
+// TestCredentials reads the account with the credentials.
+// The account response may list the permissions granted to the key.
+func (r RestExchangeRefresher) TestCredentials(ctx context.Context, account domain.Account, creds Credentials) ([]string, error) {
+
+	acctNumber := account.AccountNumber()
+	if len(acctNumber) == 0 {
+		return nil, fmt.Errorf("account number not set")
+	}
+	endpoint := fmt.Sprintf("%s/accounts/%s", strings.TrimRight(r.config.BaseURL, "/"), url.PathEscape(acctNumber))
+	req, err := r.newRequest(ctx, endpoint, creds)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := r.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusUnauthorized, http.StatusForbidden:
+		return nil, fmt.Errorf("credentials rejected by %s", r.config.Provider)
+	default:
+		return nil, statusError("account", resp.StatusCode)
+	}
+
+	var body struct {
+		Permissions []string `json:"permissions"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return nil, nil
+	}
+	return body.Permissions, nil
+}
+
+// newRequest authenticates with the account credentials, falling back to the provider api key.
+// Requests are signed with HMAC-SHA256(secret, timestamp + method + path).
+func (r RestExchangeRefresher) newRequest(ctx context.Context, endpoint string, creds Credentials) (*http.Request, error) {
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return nil, err
+	}
+	apiKey := creds.APIKey
+	if len(apiKey) == 0 {
+		apiKey = r.config.APIKey
+	}
+	if len(apiKey) > 0 {
+		req.Header.Set("Authorization", "Bearer "+apiKey)
+	}
+	if len(creds.APISecret) > 0 {
+		timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+		mac := hmac.New(sha256.New, []byte(creds.APISecret))
+		mac.Write([]byte(timestamp + req.Method + req.URL.RequestURI()))
+		req.Header.Set("X-Timestamp", timestamp)
+		req.Header.Set("X-Signature", hex.EncodeToString(mac.Sum(nil)))
+	}
+	if len(creds.Passphrase) > 0 {
+		req.Header.Set("X-Passphrase", creds.Passphrase)
+	}
+	return req, nil
+}
+
+// transactions follows the cursor until all pages are read. A since only reads
+// the transactions after it.
+func (r RestExchangeRefresher) transactions(ctx context.Context, acctNumber string, creds Credentials, since *time.Time) ([]ExchangeTx, error) {
+
+	txs := []ExchangeTx{}
+	cursor := ""
+	for {
+		endpoint := fmt.Sprintf("%s/accounts/%s/transactions", strings.TrimRight(r.config.BaseURL, "/"), url.PathEscape(acctNumber))
+		params := url.Values{}
+		if since != nil {
+			params.Set("since", since.UTC().Format(time.RFC3339))
+		}
+		if len(cursor) > 0 {
+			params.Set("cursor", cursor)
+		}
+		if len(params) > 0 {
+			endpoint += "?" + params.Encode()
+		}
+		req, err := r.newRequest(ctx, endpoint, creds)
+		if err != nil {
+			return nil, err
+		}
+		resp, err := r.httpClient.Do(req)
+		if err != nil {
+			return nil, err
+		}
+		var page exchangeTxPage
+		err = func() error {
+			defer resp.Body.Close()
+			if resp.StatusCode != http.StatusOK {
+				return statusError("transactions", resp.StatusCode)
+			}
+			return json.NewDecoder(resp.Body).Decode(&page)
+		}()
+		if err != nil {
+			return nil, err
+		}
+		txs = append(txs, page.Transactions...)
+		if len(page.NextCursor) == 0 {
+			return txs, nil
+		}
+		cursor = page.NextCursor
+	}
+}
+
+func (r RestExchangeRefresher) mapActivity(account domain.Account, tx ExchangeTx) *domain.Activity {
+
+	actv := &domain.Activity{}
+	actv.ID = fmt.Sprintf("%s-%s", account.ID, tx.ID)
+	actv.UID = account.UID
+	actv.AccountID = account.ID
+	actv.Date = tx.Time
+	actv.Status = domain.ActivityStatusSettled
+	actv.SourceID = tx.ID
+	actv.SourceType = "api"
+	actv.Fee = tx.Fee
+	actv.FeeCurrency = strings.ToUpper(tx.FeeCurrency)
+
+	asset := strings.ToUpper(tx.Asset)
+	quote := strings.ToUpper(tx.QuoteAsset)
+
+	txnType := strings.ToLower(tx.Type)
+	switch txnType {
+	case "buy", "trade":
+		actv.TxnType = domain.ActivityTypeBuy
+		if txnType == "trade" {
+			actv.TxnType = domain.ActivityTypeTrade
+		}
+		actv.RcvSymbol = asset
+		actv.RcvQuantity = tx.Quantity
+		actv.RcvAmount = tx.QuoteQuantity
+		actv.RcvAccountID = account.ID
+		actv.SentSymbol = quote
+		actv.SentQuantity = tx.QuoteQuantity
+		actv.SentAmount = tx.QuoteQuantity
+		actv.SentAccountID = account.ID
+		if tx.Quantity.IsPositive() {
+			actv.RcvPrice = tx.QuoteQuantity.Div(tx.Quantity)
+		}
+
+	case "sell":
+		actv.TxnType = domain.ActivityTypeSell
+		actv.SentSymbol = asset
+		actv.SentQuantity = tx.Quantity
+		actv.SentAmount = tx.QuoteQuantity
+		actv.SentAccountID = account.ID
+		actv.RcvSymbol = quote
+		actv.RcvQuantity = tx.QuoteQuantity
+		actv.RcvAmount = tx.QuoteQuantity
+		actv.RcvAccountID = account.ID
+		if tx.Quantity.IsPositive() {
+			actv.SentPrice = tx.QuoteQuantity.Div(tx.Quantity)
+		}
+
+	case "deposit":
+		// fiat from a bank that is not tracked here
+		actv.TxnType = domain.ActivityTypeDeposit
+		actv.RcvSymbol = asset
+		actv.RcvQuantity = tx.Quantity
+		actv.RcvAmount = tx.Quantity
+		actv.RcvAccountID = account.ID
+		actv.SentSymbol = asset
+		actv.SentQuantity = tx.Quantity
+		actv.SentAmount = tx.Quantity
+		actv.SentAccountID = domain.UnresolvedAccountID(account.UID)
+
+	case "withdraw":
+		actv.TxnType = domain.ActivityTypeWithdraw
+		actv.SentSymbol = asset
+		actv.SentQuantity = tx.Quantity
+		actv.SentAmount = tx.Quantity
+		actv.SentAccountID = account.ID
+		actv.RcvSymbol = asset
+		actv.RcvQuantity = tx.Quantity
+		actv.RcvAmount = tx.Quantity
+		actv.RcvAccountID = domain.UnresolvedAccountID(account.UID)
+
+	case "receive":
+		actv.TxnType = domain.ActivityTypeTransfer
+		actv.RcvSymbol = asset
+		actv.RcvQuantity = tx.Quantity
+		actv.RcvAccountID = account.ID
+		actv.SentAccount = tx.Address
+
+	case "send":
+		actv.TxnType = domain.ActivityTypeTransfer
+		actv.SentSymbol = asset
+		actv.SentQuantity = tx.Quantity
+		actv.SentAccountID = account.ID
+		actv.RcvAccount = tx.Address
+
+	case "reward":
+		// staking rewards and other income paid in kind
+		actv.TxnType = domain.ActivityTypeIncome
+		actv.RcvSymbol = asset
+		actv.RcvQuantity = tx.Quantity
+		actv.RcvAmount = tx.QuoteQuantity
+		actv.RcvAccountID = account.ID
+		actv.IncomeSymbol = asset
+
+	case "fee":
+		actv.TxnType = domain.ActivityTypeFee
+		actv.SentSymbol = asset
+		actv.SentQuantity = tx.Quantity
+		actv.SentAccountID = account.ID
+
+	default:
+		return nil
+	}
+
+	tradingPair := ""
+	if len(quote) > 0 {
+		tradingPair = fmt.Sprintf("%s/%s", asset, quote)
+	}
+	actv.SetDetail(domain.ExchangeActivityDetail{
+		Exchange:    r.config.Provider,
+		OrderID:     tx.OrderID,
+		TradeID:     tx.TradeID,
+		TradingPair: tradingPair,
+		OrderType:   tx.OrderType,
+	})
+	return actv
+}
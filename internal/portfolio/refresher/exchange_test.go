@@ -0,0 +1,120 @@
+package refresher
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/rkapps/fin-tracker-backend-go/cmd/common/logger"
+	"github.com/rkapps/fin-tracker-backend-go/internal/domain"
+	"github.com/shopspring/decimal"
+)
+
+func TestRestExchangeRefresher(t *testing.T) {
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/accounts/ACC-1/transactions" {
+			http.NotFound(w, r)
+			return
+		}
+		if r.Header.Get("Authorization") != "Bearer secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Query().Get("cursor") {
+		case "":
+			w.Write([]byte(`{"transactions":[
+				{"id":"t1","type":"deposit","time":"2024-01-02T00:00:00Z","asset":"usd","quantity":"1000"},
+				{"id":"t2","type":"buy","time":"2024-01-03T00:00:00Z","asset":"btc","quantity":"0.02","quoteAsset":"usd","quoteQuantity":"800","fee":"4","feeCurrency":"usd","orderId":"o2"}
+			],"nextCursor":"p2"}`))
+		case "p2":
+			w.Write([]byte(`{"transactions":[
+				{"id":"t3","type":"reward","time":"2024-01-04T00:00:00Z","asset":"eth","quantity":"0.01","quoteAsset":"usd","quoteQuantity":"25"},
+				{"id":"t4","type":"margin","time":"2024-01-05T00:00:00Z","asset":"btc","quantity":"1"},
+				{"id":"t5","type":"TRADE","time":"2024-01-06T00:00:00Z","asset":"eth","quantity":"0.5","quoteAsset":"btc","quoteQuantity":"0.025"}
+			]}`))
+		}
+	}))
+	defer server.Close()
+
+	t.Setenv("EXCHANGE_URL_MOCKEX", server.URL)
+	t.Setenv("EXCHANGE_API_KEY_MOCKEX", "secret")
+
+	r, err := ResolveBatchRefresher("mockex", logger.New())
+	if err != nil {
+		t.Fatalf("ResolveBatchRefresher() error = %v", err)
+	}
+
+	accounts := []domain.Account{
+		{ID: "x1", UID: "u1", Category: domain.CategoryCrypto, Type: domain.TypeExchange,
+			Detail: &domain.CryptoDetail{Exchange: "mockex", AccountNumber: "ACC-1"}},
+	}
+	actvs, err := r.Refresh(context.Background(), "mockex", accounts)
+	if err != nil {
+		t.Fatalf("Refresh() error = %v", err)
+	}
+	// the unknown margin row is skipped
+	if len(actvs) != 4 {
+		t.Fatalf("Refresh() returned %d activities, want 4", len(actvs))
+	}
+
+	deposit := actvs[0]
+	if deposit.TxnType != domain.ActivityTypeDeposit || deposit.SentAccountID != domain.UnresolvedAccountID("u1") {
+		t.Errorf("deposit = %s from %s", deposit.TxnType, deposit.SentAccountID)
+	}
+
+	buy := actvs[1]
+	if buy.ID != "x1-t2" || buy.RcvSymbol != "BTC" || buy.SentSymbol != "USD" || !buy.RcvPrice.Equal(decimal.NewFromInt(40000)) {
+		t.Errorf("buy = %s %s/%s at %v", buy.ID, buy.RcvSymbol, buy.SentSymbol, buy.RcvPrice)
+	}
+	if !buy.Fee.Equal(decimal.NewFromInt(4)) || buy.DetailType != "exchange" {
+		t.Errorf("buy fee = %v detail %s", buy.Fee, buy.DetailType)
+	}
+
+	if actvs[2].TxnType != domain.ActivityTypeIncome || actvs[2].RcvSymbol != "ETH" {
+		t.Errorf("reward = %s %s", actvs[2].TxnType, actvs[2].RcvSymbol)
+	}
+
+	// types are matched case insensitive
+	if trade := actvs[3]; trade.TxnType != domain.ActivityTypeTrade || trade.RcvSymbol != "ETH" || trade.SentSymbol != "BTC" {
+		t.Errorf("trade = %s %s/%s", trade.TxnType, trade.RcvSymbol, trade.SentSymbol)
+	}
+}
+
+func TestResolveBatchRefresherNotConfigured(t *testing.T) {
+	_, err := ResolveBatchRefresher("unknownex", logger.New())
+	if !errors.Is(err, ErrProviderNotSupported) {
+		t.Errorf("ResolveBatchRefresher() error = %v, want ErrProviderNotSupported", err)
+	}
+}
+
+func TestRestExchangeTestCredentials(t *testing.T) {
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer user-key" || r.Header.Get("X-Signature") == "" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.Write([]byte(`{"permissions":["read","trade"]}`))
+	}))
+	defer server.Close()
+
+	t.Setenv("EXCHANGE_URL_MOCKEX", server.URL)
+	account := domain.Account{ID: "x1", Category: domain.CategoryCrypto, Type: domain.TypeExchange,
+		Detail: &domain.CryptoDetail{Exchange: "mockex", AccountNumber: "ACC-1"}}
+
+	permissions, err := TestCredentials(context.Background(), "mockex", account, Credentials{APIKey: "user-key", APISecret: "s"}, logger.New())
+	if err != nil {
+		t.Fatalf("TestCredentials() error = %v", err)
+	}
+	if err := domain.ValidatePermissions(permissions); err == nil {
+		t.Errorf("ValidatePermissions(%v) should reject trade", permissions)
+	}
+
+	if _, err := TestCredentials(context.Background(), "mockex", account, Credentials{APIKey: "wrong"}, logger.New()); err == nil {
+		t.Errorf("TestCredentials() with a rejected key should fail")
+	}
+}
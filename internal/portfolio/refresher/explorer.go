@@ -0,0 +1,175 @@
+package refresher
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ChainConfig describes an EVM chain served by an explorer compatible endpoint.
+type ChainConfig struct {
+	Blockchain   string
+	NativeSymbol string
+	BaseURL      string
+	APIKey       string
+}
+
+// evmChains lists the supported chains and their native currency.
+var evmChains = map[string]ChainConfig{
+	"ethereum": {Blockchain: "ethereum", NativeSymbol: "ETH", BaseURL: "https://api.etherscan.io/api"},
+	"arbitrum": {Blockchain: "arbitrum", NativeSymbol: "ETH"},
+	"base":     {Blockchain: "base", NativeSymbol: "ETH"},
+	"optimism": {Blockchain: "optimism", NativeSymbol: "ETH"},
+	"polygon":  {Blockchain: "polygon", NativeSymbol: "POL"},
+	"bsc":      {Blockchain: "bsc", NativeSymbol: "BNB"},
+}
+
+// ResolveChainConfig returns the chain config with the endpoint read from
+// EXPLORER_URL_<CHAIN> and EXPLORER_API_KEY_<CHAIN> — e.g. EXPLORER_URL_ETHEREUM.
+func ResolveChainConfig(blockchain string) (ChainConfig, error) {
+	chain := strings.ToLower(blockchain)
+	config, ok := evmChains[chain]
+	if !ok {
+		return ChainConfig{}, fmt.Errorf("blockchain not supported: %s", blockchain)
+	}
+	env := strings.ToUpper(chain)
+	if baseURL := os.Getenv("EXPLORER_URL_" + env); len(baseURL) > 0 {
+		config.BaseURL = baseURL
+	}
+	config.APIKey = os.Getenv("EXPLORER_API_KEY_" + env)
+	if len(config.BaseURL) == 0 {
+		return ChainConfig{}, fmt.Errorf("explorer url not configured for %s", blockchain)
+	}
+	return config, nil
+}
+
+// ExplorerTx is a native or token transfer returned by the explorer.
+type ExplorerTx struct {
+	BlockNumber     string `json:"blockNumber"`
+	TimeStamp       string `json:"timeStamp"`
+	Hash            string `json:"hash"`
+	From            string `json:"from"`
+	To              string `json:"to"`
+	Value           string `json:"value"`
+	Gas             string `json:"gas"`
+	GasPrice        string `json:"gasPrice"`
+	GasUsed         string `json:"gasUsed"`
+	IsError         string `json:"isError"`
+	ContractAddress string `json:"contractAddress"`
+	TokenSymbol     string `json:"tokenSymbol"`
+	TokenDecimal    string `json:"tokenDecimal"`
+}
+
+type explorerResponse struct {
+	Status  string          `json:"status"`
+	Message string          `json:"message"`
+	Result  json.RawMessage `json:"result"`
+}
+
+// ExplorerClient calls an etherscan compatible account API.
+type ExplorerClient struct {
+	baseURL    string
+	apiKey     string
+	httpClient *http.Client
+}
+
+func NewExplorerClient(baseURL string, apiKey string) ExplorerClient {
+	return ExplorerClient{
+		baseURL:    baseURL,
+		apiKey:     apiKey,
+		httpClient: &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+// NativeTransfers returns the normal transactions of the address.
+func (c ExplorerClient) NativeTransfers(ctx context.Context, address string, startBlock int64) ([]ExplorerTx, error) {
+	return c.accountTxs(ctx, "txlist", address, startBlock)
+}
+
+// TokenTransfers returns the ERC-20 token transfers of the address.
+func (c ExplorerClient) TokenTransfers(ctx context.Context, address string, startBlock int64) ([]ExplorerTx, error) {
+	return c.accountTxs(ctx, "tokentx", address, startBlock)
+}
+
+// BlockByTime returns the last block mined at or before t.
+func (c ExplorerClient) BlockByTime(ctx context.Context, t time.Time) (int64, error) {
+
+	params := url.Values{}
+	params.Set("module", "block")
+	params.Set("action", "getblocknobytime")
+	params.Set("timestamp", strconv.FormatInt(t.Unix(), 10))
+	params.Set("closest", "before")
+
+	eresp, err := c.get(ctx, "getblocknobytime", params)
+	if err != nil {
+		return 0, err
+	}
+	if eresp.Status != "1" {
+		return 0, fmt.Errorf("explorer getblocknobytime error: %s", eresp.Message)
+	}
+	var block string
+	if err := json.Unmarshal(eresp.Result, &block); err != nil {
+		return 0, fmt.Errorf("explorer getblocknobytime result error: %w", err)
+	}
+	return strconv.ParseInt(block, 10, 64)
+}
+
+func (c ExplorerClient) accountTxs(ctx context.Context, action string, address string, startBlock int64) ([]ExplorerTx, error) {
+
+	params := url.Values{}
+	params.Set("module", "account")
+	params.Set("action", action)
+	params.Set("address", address)
+	params.Set("startblock", fmt.Sprintf("%d", startBlock))
+	params.Set("endblock", "99999999")
+	params.Set("sort", "asc")
+
+	eresp, err := c.get(ctx, action, params)
+	if err != nil {
+		return nil, err
+	}
+
+	txs := []ExplorerTx{}
+	// status 0 with "No transactions found" is an empty result, not an error
+	if eresp.Status != "1" {
+		if strings.Contains(strings.ToLower(eresp.Message), "no transactions") {
+			return txs, nil
+		}
+		return nil, fmt.Errorf("explorer %s error: %s", action, eresp.Message)
+	}
+	if err := json.Unmarshal(eresp.Result, &txs); err != nil {
+		return nil, fmt.Errorf("explorer %s result error: %w", action, err)
+	}
+	return txs, nil
+}
+
+func (c ExplorerClient) get(ctx context.Context, action string, params url.Values) (*explorerResponse, error) {
+
+	if len(c.apiKey) > 0 {
+		params.Set("apikey", c.apiKey)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.baseURL+"?"+params.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("explorer %s error: %w", action, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, statusError("explorer "+action, resp.StatusCode)
+	}
+
+	var eresp explorerResponse
+	if err := json.NewDecoder(resp.Body).Decode(&eresp); err != nil {
+		return nil, fmt.Errorf("explorer %s decode error: %w", action, err)
+	}
+	return &eresp, nil
+}
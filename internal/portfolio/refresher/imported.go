@@ -3,6 +3,7 @@ package refresher
 import (
 	"context"
 	"fmt"
+	"sort"
 	"strings"
 
 	"github.com/rkapps/fin-tracker-backend-go/cmd/common/logger"
@@ -30,134 +31,212 @@ func (r ImportedAccountRefresher) Refresh(ctx context.Context, account domain.Ac
 	// if err != nil {
 	// 	return actvs, fmt.Errorf("User record does not exist")
 	// }
-	accts, err := r.storage.GetAccounts(account.UID)
+	accts, err := r.storage.GetAccounts(ctx, account.UID)
 	if err != nil {
-		return nil, fmt.Errorf("accounts not found for user")
+		return nil, fmt.Errorf("accounts not found for user: %w", err)
 	}
-	acctsm := make(map[string]*domain.Account)
-	for _, acct := range accts {
-		acctsm[acct.ID] = acct
+	resolver := NewAccountResolver(account.UID, accts)
+
+	iactvs, err := r.storage.GetImortedActivities(ctx, account.UID, account.ID)
+	if err != nil {
+		return nil, err
 	}
 
-	iactvs, err := r.storage.GetImortedActivities(account.UID, account.ID)
+	rules, err := r.storage.GetActivityMappingRules(ctx, account.UID)
 	if err != nil {
-		return actvs, nil
+		return nil, fmt.Errorf("mapping rules not found for user: %w", err)
 	}
+	rules = filterMappingRules(rules, account.ID)
+
+	updated := []*domain.ActivityImport{}
 	for _, iactv := range iactvs {
 
 		r.logger.Debug("Refresh", "Activity", iactv.ID)
 
-		actv := &domain.Activity{}
-		actv.UID = iactv.UID
-		actv.ID = iactv.ID
-		actv.AccountID = iactv.AccountID
-		actv.Date = *iactv.Date
-		actv.TxnType = domain.ActivityType(strings.ToLower(iactv.TxnType))
-		actv.Notes = iactv.Notes
-
-		if actv.TxnType == domain.ActivityTypeDeposit {
-			actv.RcvAccountID = resolveAccount(acctsm, actv.AccountID, iactv.RcvAccount)
-			actv.SentAccountID = resolveAccount(acctsm, actv.SentAccountID, iactv.SentAccount)
-			if len(actv.SentAccountID) == 0 {
-				r.logger.Error("Sent Bank error", "Id", actv.ID, "SentAccount", actv.SentAccount)
-				return nil, fmt.Errorf("bank error: %s", actv.SentAccountID)
+		status := domain.ImportStatusMapped
+		errMessage := ""
+
+		// mapping rules take precedence over the built-in txn types
+		src := iactv
+		txnType := domain.ActivityType(strings.ToLower(iactv.TxnType))
+		if rule := matchMappingRule(rules, iactv); rule != nil {
+			r.logger.Debug("Refresh", "Rule", rule.Name, "TxnType", iactv.TxnType)
+			if rule.Skip {
+				status = domain.ImportStatusSkipped
+			} else {
+				mapped := *iactv
+				mapped.ApplyFieldMapping(rule.FieldMapping)
+				src = &mapped
+				txnType = rule.ActivityType
 			}
 		}
 
-		if actv.TxnType == domain.ActivityTypeWithdraw {
-			actv.RcvAccountID = resolveAccount(acctsm, actv.RcvAccountID, iactv.RcvAccount)
-			actv.SentAccountID = resolveAccount(acctsm, actv.AccountID, iactv.SentAccount)
-			if len(actv.RcvAccountID) == 0 {
-				r.logger.Error("Rcv Bank error", "Id", actv.ID, "RcvAccount", actv.RcvAccount)
-				return nil, fmt.Errorf("bank error: %s", actv.RcvAccountID)
+		if status == domain.ImportStatusMapped {
+			actv := r.mapActivity(account, resolver, src, txnType)
+			if actv == nil {
+				r.logger.Warn("Refresh", "Unmapped", iactv.ID, "TxnType", iactv.TxnType)
+				status = domain.ImportStatusUnmapped
+				errMessage = fmt.Sprintf("no mapping for txn type: %s", iactv.TxnType)
+			} else {
+				actvs = append(actvs, actv)
 			}
 		}
 
-		switch iactv.TxnType {
-		case string(domain.ActivityTypeRollover), string(domain.ActivityTypeInterest), string(domain.ActivityTypeDividend):
-			actv.RcvAmount = iactv.RcvAmount
-			actv.RcvQuantity = iactv.RcvAmount
-			actv.RcvPrice = decimal.NewFromFloat(1.0)
-			actv.RcvSymbol = iactv.RcvCurrency
-			actv.SentSymbol = iactv.SentCurrency
-			actv.RcvAccountID = account.ID
-
-			actv.Status = domain.ActivityStatusPending
-
-		case string(domain.ActivityTypeBuy):
-			actv.RcvQuantity = iactv.RcvAmount
-			actv.RcvSymbol = iactv.RcvCurrency
-			actv.RcvAmount = iactv.SentAmount
-			actv.RcvPrice = actv.RcvAmount.Div(actv.RcvQuantity)
-			actv.RcvAccountID = account.ID
-
-			actv.SentAmount = iactv.SentAmount
-			actv.SentSymbol = iactv.SentCurrency
-			actv.SentQuantity = iactv.SentAmount
-			actv.SentPrice = decimal.NewFromFloat(1.0)
-			actv.SentAccountID = account.ID
-
-			actv.Status = domain.ActivityStatusPending
-
-		case string(domain.ActivityTypeSell):
-			actv.RcvQuantity = iactv.RcvAmount
-			actv.RcvSymbol = iactv.RcvCurrency
-			actv.RcvAmount = iactv.RcvAmount
-			actv.RcvAccountID = account.ID
-			actv.SentAmount = iactv.RcvAmount
-			actv.SentSymbol = iactv.SentCurrency
-			actv.SentQuantity = iactv.SentAmount
-			actv.SentPrice = decimal.NewFromFloat(1.0)
-			actv.SentAccountID = account.ID
-			actv.Status = domain.ActivityStatusPending
-
-		case string(domain.ActivityTypeDeposit):
-
-			actv.RcvQuantity = iactv.RcvAmount
-			actv.RcvSymbol = iactv.RcvCurrency
-			actv.RcvAmount = iactv.RcvAmount
-			actv.RcvAccount = iactv.RcvAccount
-			actv.RcvPrice = decimal.NewFromFloat(1.0)
-			actv.SentSymbol = iactv.RcvCurrency
-			actv.SentQuantity = iactv.RcvAmount
-			actv.SentAccount = iactv.SentAccount
-			actv.SentPrice = decimal.NewFromFloat(1.0)
-			actv.SentAmount = iactv.RcvAmount
-			actv.Status = domain.ActivityStatusPending
-
-		case string(domain.ActivityTypeWithdraw):
-			actv.RcvQuantity = iactv.SentAmount
-			actv.RcvSymbol = iactv.SentCurrency
-			actv.RcvAmount = iactv.SentAmount
-			actv.RcvAccount = iactv.RcvAccount
-			actv.RcvPrice = decimal.NewFromFloat(1.0)
-			actv.SentAmount = iactv.SentAmount
-			actv.SentSymbol = iactv.SentCurrency
-			actv.SentQuantity = iactv.SentAmount
-			actv.SentAccount = iactv.SentAccount
-			actv.SentPrice = decimal.NewFromFloat(1.0)
-			actv.Status = domain.ActivityStatusPending
-
-		default:
-			continue
+		if iactv.ProcessStatus != status || iactv.ErrorMessage != errMessage {
+			iactv.ProcessStatus = status
+			iactv.ErrorMessage = errMessage
+			updated = append(updated, iactv)
+		}
+	}
+
+	// pair dividends with their reinvestment buys
+	actvs = pairReinvestments(actvs, r.logger)
+
+	// report the processing status back on the imported rows
+	if len(updated) > 0 {
+		r.logger.Info("Refresh", "Account", account.ID, "StatusUpdates", len(updated))
+		if err := r.storage.SaveImportedActivities(ctx, updated); err != nil {
+			return nil, err
 		}
-		actvs = append(actvs, actv)
 	}
 	return actvs, nil
 
 }
 
-func resolveAccount(acctsm map[string]*domain.Account, acctId string, account string) string {
+// mapActivity converts an imported row into an activity for the txn type.
+// Returns nil when the txn type is not supported.
+func (r ImportedAccountRefresher) mapActivity(account domain.Account, resolver AccountResolver,
+	iactv *domain.ActivityImport, txnType domain.ActivityType) *domain.Activity {
+
+	actv := &domain.Activity{}
+	actv.UID = iactv.UID
+	actv.ID = iactv.ID
+	actv.AccountID = iactv.AccountID
+	actv.Date = *iactv.Date
+	actv.TxnType = txnType
+	actv.Notes = iactv.Notes
+
+	// unresolved counter accounts are booked against the placeholder account
+	var ok bool
+	if actv.TxnType == domain.ActivityTypeDeposit {
+		actv.RcvAccountID, _ = resolver.Resolve(actv.AccountID, iactv.RcvAccount)
+		actv.SentAccountID, ok = resolver.Resolve(actv.SentAccountID, iactv.SentAccount)
+		if !ok {
+			r.logger.Warn("Sent Account unresolved", "Id", actv.ID, "SentAccount", iactv.SentAccount)
+		}
+	}
 
-	for _, acct := range acctsm {
-		if acct.ID == acctId {
-			return acctId
+	if actv.TxnType == domain.ActivityTypeWithdraw {
+		actv.RcvAccountID, ok = resolver.Resolve(actv.RcvAccountID, iactv.RcvAccount)
+		actv.SentAccountID, _ = resolver.Resolve(actv.AccountID, iactv.SentAccount)
+		if !ok {
+			r.logger.Warn("Rcv Account unresolved", "Id", actv.ID, "RcvAccount", iactv.RcvAccount)
 		}
-		for _, name := range acct.AlternateNames {
-			if strings.Compare(strings.ToLower(name), strings.ToLower(account)) == 0 {
-				return acct.ID
-			}
+	}
+
+	switch actv.TxnType {
+	case domain.ActivityTypeRollover, domain.ActivityTypeInterest, domain.ActivityTypeDividend:
+		actv.RcvAmount = iactv.RcvAmount
+		actv.RcvQuantity = iactv.RcvAmount
+		actv.RcvPrice = decimal.NewFromFloat(1.0)
+		actv.RcvSymbol = iactv.RcvCurrency
+		actv.SentSymbol = iactv.SentCurrency
+		actv.RcvAccountID = account.ID
+		if actv.IsIncome() {
+			actv.IncomeSymbol = iactv.SentCurrency
+		}
+
+		actv.Status = domain.ActivityStatusPending
+
+	case domain.ActivityTypeBuy, domain.ActivityTypeReinvest:
+		actv.RcvQuantity = iactv.RcvAmount
+		actv.RcvSymbol = iactv.RcvCurrency
+		actv.RcvAmount = iactv.SentAmount
+		actv.RcvPrice = actv.RcvAmount.Div(actv.RcvQuantity)
+		actv.RcvAccountID = account.ID
+
+		actv.SentAmount = iactv.SentAmount
+		actv.SentSymbol = iactv.SentCurrency
+		actv.SentQuantity = iactv.SentAmount
+		actv.SentPrice = decimal.NewFromFloat(1.0)
+		actv.SentAccountID = account.ID
+
+		actv.Status = domain.ActivityStatusPending
+
+	case domain.ActivityTypeSell:
+		actv.RcvQuantity = iactv.RcvAmount
+		actv.RcvSymbol = iactv.RcvCurrency
+		actv.RcvAmount = iactv.RcvAmount
+		actv.RcvAccountID = account.ID
+		actv.SentAmount = iactv.RcvAmount
+		actv.SentSymbol = iactv.SentCurrency
+		actv.SentQuantity = iactv.SentAmount
+		actv.SentPrice = decimal.NewFromFloat(1.0)
+		actv.SentAccountID = account.ID
+		actv.Status = domain.ActivityStatusPending
+
+	case domain.ActivityTypeDeposit:
+
+		actv.RcvQuantity = iactv.RcvAmount
+		actv.RcvSymbol = iactv.RcvCurrency
+		actv.RcvAmount = iactv.RcvAmount
+		actv.RcvAccount = iactv.RcvAccount
+		actv.RcvPrice = decimal.NewFromFloat(1.0)
+		actv.SentSymbol = iactv.RcvCurrency
+		actv.SentQuantity = iactv.RcvAmount
+		actv.SentAccount = iactv.SentAccount
+		actv.SentPrice = decimal.NewFromFloat(1.0)
+		actv.SentAmount = iactv.RcvAmount
+		actv.Status = domain.ActivityStatusPending
+
+	case domain.ActivityTypeWithdraw:
+		actv.RcvQuantity = iactv.SentAmount
+		actv.RcvSymbol = iactv.SentCurrency
+		actv.RcvAmount = iactv.SentAmount
+		actv.RcvAccount = iactv.RcvAccount
+		actv.RcvPrice = decimal.NewFromFloat(1.0)
+		actv.SentAmount = iactv.SentAmount
+		actv.SentSymbol = iactv.SentCurrency
+		actv.SentQuantity = iactv.SentAmount
+		actv.SentAccount = iactv.SentAccount
+		actv.SentPrice = decimal.NewFromFloat(1.0)
+		actv.Status = domain.ActivityStatusPending
+
+	default:
+		return nil
+	}
+	return actv
+}
+
+// filterMappingRules returns the user wide rules and the rules for the account, compiled.
+// Rules whose patterns do not compile never apply.
+func filterMappingRules(rules domain.ActivityMappingRules, acctId string) domain.ActivityMappingRules {
+	frules := domain.ActivityMappingRules{}
+	for _, rule := range rules {
+		if rule.AccountID != "" && rule.AccountID != acctId {
+			continue
+		}
+		if err := rule.Compile(); err != nil {
+			continue
+		}
+		frules = append(frules, rule)
+	}
+	// account specific rules win over user wide rules with the same priority
+	sort.SliceStable(frules, func(i, j int) bool {
+		if frules[i].Priority != frules[j].Priority {
+			return frules[i].Priority < frules[j].Priority
+		}
+		return frules[i].AccountID != "" && frules[j].AccountID == ""
+	})
+	return frules
+}
+
+// matchMappingRule returns the first rule matching the imported activity.
+func matchMappingRule(rules domain.ActivityMappingRules, iactv *domain.ActivityImport) *domain.ActivityMappingRule {
+	for _, rule := range rules {
+		if rule.Matches(iactv) {
+			return rule
 		}
 	}
-	return ""
+	return nil
 }
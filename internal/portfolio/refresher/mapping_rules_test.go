@@ -0,0 +1,107 @@
+package refresher
+
+import (
+	"testing"
+
+	"github.com/rkapps/fin-tracker-backend-go/internal/domain"
+	"github.com/shopspring/decimal"
+)
+
+func TestMatchMappingRule(t *testing.T) {
+
+	rules := domain.ActivityMappingRules{
+		{ID: "user-late", Priority: 5, TxnTypePattern: "^div"},
+		{ID: "other-acct", AccountID: "a2", Priority: 0, TxnTypePattern: ".*"},
+		{ID: "user-first", Priority: 1, TxnTypePattern: "^journal", NotesPattern: "dividend"},
+		{ID: "acct-first", AccountID: "a1", Priority: 1, TxnTypePattern: "^journal"},
+		{ID: "broken", Priority: 0, TxnTypePattern: "("},
+		{ID: "notes-only", Priority: 9, NotesPattern: "fee"},
+	}
+	frules := filterMappingRules(rules, "a1")
+
+	tests := []struct {
+		name    string
+		txnType string
+		notes   string
+		want    string
+	}{
+		{"account rule wins a priority tie", "Journal", "dividend", "acct-first"},
+		{"lower priority runs first", "DIVIDEND", "", "user-late"},
+		{"both patterns must match", "Dividend", "fee", "user-late"},
+		{"notes pattern", "Adjustment", "Wire FEE", "notes-only"},
+		{"no rule matches", "Adjustment", "", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := ""
+			if rule := matchMappingRule(frules, &domain.ActivityImport{TxnType: tt.txnType, Notes: tt.notes}); rule != nil {
+				got = rule.ID
+			}
+			if got != tt.want {
+				t.Errorf("matchMappingRule(%q, %q) = %q, want %q", tt.txnType, tt.notes, got, tt.want)
+			}
+		})
+	}
+
+	// rules of another account and rules that do not compile never apply
+	for _, rule := range frules {
+		if rule.ID == "other-acct" {
+			t.Errorf("filterMappingRules(a1) kept the rule of a2")
+		}
+		if rule.ID == "broken" {
+			t.Errorf("filterMappingRules(a1) kept the rule with an invalid pattern")
+		}
+	}
+}
+
+func TestApplyFieldMapping(t *testing.T) {
+
+	tests := []struct {
+		name    string
+		mapping map[string]string
+		want    domain.ActivityImport
+	}{
+		{"copy", map[string]string{"rcvAmount": "sentAmount"},
+			domain.ActivityImport{RcvCurrency: "USD", RcvAmount: decimal.NewFromInt(5), SentCurrency: "VTI", SentAmount: decimal.NewFromInt(5)}},
+		{"swap", map[string]string{"rcvCurrency": "sentCurrency", "sentCurrency": "rcvCurrency"},
+			domain.ActivityImport{RcvCurrency: "VTI", RcvAmount: decimal.NewFromInt(10), SentCurrency: "USD", SentAmount: decimal.NewFromInt(5)}},
+		{"unknown field is ignored", map[string]string{"notes": "rcvCurrency", "rcvCurrency": "notes"},
+			domain.ActivityImport{RcvCurrency: "USD", RcvAmount: decimal.NewFromInt(10), SentCurrency: "VTI", SentAmount: decimal.NewFromInt(5)}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := domain.ActivityImport{RcvCurrency: "USD", RcvAmount: decimal.NewFromInt(10), SentCurrency: "VTI", SentAmount: decimal.NewFromInt(5)}
+			got.ApplyFieldMapping(tt.mapping)
+			if got.RcvCurrency != tt.want.RcvCurrency || !got.RcvAmount.Equal(tt.want.RcvAmount) ||
+				got.SentCurrency != tt.want.SentCurrency || !got.SentAmount.Equal(tt.want.SentAmount) {
+				t.Errorf("ApplyFieldMapping(%v) = %+v, want %+v", tt.mapping, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateFieldMapping(t *testing.T) {
+
+	tests := []struct {
+		name    string
+		mapping map[string]string
+		wantErr string
+	}{
+		{"same type", map[string]string{"rcvAmount": "sentAmount", "rcvCurrency": "sentCurrency"}, ""},
+		{"unknown target", map[string]string{"notes": "rcvCurrency"}, "invalid field mapping target: notes"},
+		{"amount from text", map[string]string{"rcvAmount": "rcvCurrency"}, "invalid field mapping rcvAmount: source rcvCurrency has a different type"},
+		{"text from amount", map[string]string{"feeCurrency": "fee"}, "invalid field mapping feeCurrency: source fee has a different type"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rule := &domain.ActivityMappingRule{TxnTypePattern: "^journal", ActivityType: domain.ActivityTypeBuy, FieldMapping: tt.mapping}
+			got := ""
+			if err := rule.Validate(); err != nil {
+				got = err.Error()
+			}
+			if got != tt.wantErr {
+				t.Errorf("Validate() error = %q, want %q", got, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,69 @@
+package refresher
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/rkapps/fin-tracker-backend-go/cmd/common/logger"
+)
+
+// ErrProviderNotSupported is returned when no batch refresher is registered
+// or configured for a provider.
+var ErrProviderNotSupported = errors.New("provider not supported")
+
+// ProviderConfig is the endpoint of an exchange provider.
+type ProviderConfig struct {
+	Provider string
+	BaseURL  string
+	APIKey   string
+}
+
+// BatchRefresherFactory builds the batch refresher of a provider.
+type BatchRefresherFactory func(config ProviderConfig, logConfig *logger.Config) BatchAccountRefresher
+
+var (
+	batchRefreshersMu sync.RWMutex
+	batchRefreshers   = make(map[string]BatchRefresherFactory)
+)
+
+// RegisterBatchRefresher registers a provider specific batch refresher.
+// Providers without a registration fall back to the REST exchange refresher.
+func RegisterBatchRefresher(provider string, factory BatchRefresherFactory) {
+	batchRefreshersMu.Lock()
+	defer batchRefreshersMu.Unlock()
+	batchRefreshers[strings.ToLower(provider)] = factory
+}
+
+// ResolveProviderConfig reads EXCHANGE_URL_<PROVIDER> and EXCHANGE_API_KEY_<PROVIDER>
+// — e.g. EXCHANGE_URL_COINBASE.
+func ResolveProviderConfig(provider string) (ProviderConfig, error) {
+	env := strings.ToUpper(strings.ReplaceAll(provider, "-", "_"))
+	config := ProviderConfig{
+		Provider: strings.ToLower(provider),
+		BaseURL:  os.Getenv("EXCHANGE_URL_" + env),
+		APIKey:   os.Getenv("EXCHANGE_API_KEY_" + env),
+	}
+	if len(config.BaseURL) == 0 {
+		return config, fmt.Errorf("%w: exchange url not configured for %s", ErrProviderNotSupported, provider)
+	}
+	return config, nil
+}
+
+func ResolveBatchRefresher(provider string, logConfig *logger.Config) (BatchAccountRefresher, error) {
+
+	config, err := ResolveProviderConfig(provider)
+	if err != nil {
+		return nil, err
+	}
+
+	batchRefreshersMu.RLock()
+	factory, ok := batchRefreshers[config.Provider]
+	batchRefreshersMu.RUnlock()
+	if !ok {
+		factory = NewRestExchangeRefresher
+	}
+	return factory(config, logConfig), nil
+}
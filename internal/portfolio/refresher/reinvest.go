@@ -0,0 +1,152 @@
+package refresher
+
+import (
+	"time"
+
+	"github.com/rkapps/fin-tracker-backend-go/cmd/common/logger"
+	"github.com/rkapps/fin-tracker-backend-go/internal/domain"
+	"github.com/rkapps/fin-tracker-backend-go/internal/utils"
+	"github.com/shopspring/decimal"
+)
+
+const (
+	// reported reinvestments settle a few days after the dividend at most
+	reinvestWindow = 5 * 24 * time.Hour
+)
+
+var reinvestTolerance = decimal.NewFromFloat(0.01)
+
+// pairReinvestments links dividends with the buys that reinvested them. Buys the
+// provider reports as reinvestments pair within the window, a plain buy only pairs
+// on the dividend date for the exact amount so real trades keep their cost basis.
+// A paired buy becomes a reinvest activity dated on the dividend so the lot opens
+// on the dividend date at the reinvestment price. Reinvest activities without a
+// dividend get one created so the income is still recorded.
+func pairReinvestments(actvs []*domain.Activity, logger *logger.Logger) []*domain.Activity {
+
+	dividends := []*domain.Activity{}
+	for _, actv := range actvs {
+		if actv.TxnType == domain.ActivityTypeDividend {
+			dividends = append(dividends, actv)
+		}
+	}
+
+	for _, actv := range actvs {
+		if actv.TxnType != domain.ActivityTypeBuy && actv.TxnType != domain.ActivityTypeReinvest {
+			continue
+		}
+		if len(actv.LinkedActivityID) > 0 {
+			continue
+		}
+
+		div := matchDividend(dividends, actv)
+		if div == nil {
+			continue
+		}
+		logger.Debug("pairReinvestments", "Dividend", div.ID, "Reinvest", actv.ID)
+
+		actv.TxnType = domain.ActivityTypeReinvest
+		actv.Date = div.Date
+		actv.LinkedActivityID = div.ID
+		div.LinkedActivityID = actv.ID
+	}
+
+	// reinvestments reported without the dividend
+	for _, actv := range actvs {
+		if !actv.IsReinvestment() || len(actv.LinkedActivityID) > 0 {
+			continue
+		}
+		div := newReinvestedDividend(actv)
+		logger.Debug("pairReinvestments", "Created Dividend", div.ID, "Reinvest", actv.ID)
+		actv.LinkedActivityID = div.ID
+		actvs = append(actvs, div)
+	}
+
+	return dividendsFirst(actvs)
+}
+
+// dividendsFirst moves each paired dividend right before its reinvestment. They share
+// a date and the gain/loss run keeps the order of a date, so the cash is received
+// before the reinvestment spends it.
+func dividendsFirst(actvs []*domain.Activity) []*domain.Activity {
+
+	reinvests := make(map[string]bool)
+	for _, actv := range actvs {
+		if actv.IsReinvestment() {
+			reinvests[actv.ID] = true
+		}
+	}
+	paired := make(map[string]*domain.Activity)
+	for _, actv := range actvs {
+		if actv.TxnType == domain.ActivityTypeDividend && reinvests[actv.LinkedActivityID] {
+			paired[actv.LinkedActivityID] = actv
+		}
+	}
+
+	ordered := make([]*domain.Activity, 0, len(actvs))
+	for _, actv := range actvs {
+		if actv.TxnType == domain.ActivityTypeDividend && reinvests[actv.LinkedActivityID] {
+			continue
+		}
+		if div := paired[actv.ID]; div != nil {
+			ordered = append(ordered, div)
+		}
+		ordered = append(ordered, actv)
+	}
+	return ordered
+}
+
+// matchDividend finds the unpaired dividend the buy reinvested.
+func matchDividend(dividends []*domain.Activity, buy *domain.Activity) *domain.Activity {
+
+	drip := buy.IsReinvestment()
+
+	for _, div := range dividends {
+		if len(div.LinkedActivityID) > 0 {
+			continue
+		}
+		if div.AccountID != buy.AccountID || div.UnderlyingSymbol() != buy.RcvSymbol {
+			continue
+		}
+		if div.RcvSymbol != buy.SentSymbol {
+			continue
+		}
+		if !drip {
+			if !utils.DateEqual(div.Date, buy.Date) || !div.RcvAmount.Equal(buy.SentAmount) {
+				continue
+			}
+			return div
+		}
+		diff := buy.Date.Sub(div.Date)
+		if diff < 0 || diff > reinvestWindow {
+			continue
+		}
+		if div.RcvAmount.Sub(buy.SentAmount).Abs().GreaterThan(reinvestTolerance) {
+			continue
+		}
+		return div
+	}
+	return nil
+}
+
+// newReinvestedDividend creates the dividend paid out for a reinvestment.
+func newReinvestedDividend(reinvest *domain.Activity) *domain.Activity {
+
+	div := &domain.Activity{}
+	div.ID = reinvest.ID + "-div"
+	div.UID = reinvest.UID
+	div.AccountID = reinvest.AccountID
+	div.Date = reinvest.Date
+	div.TxnType = domain.ActivityTypeDividend
+	div.Status = reinvest.Status
+	div.RcvSymbol = reinvest.SentSymbol
+	div.RcvAmount = reinvest.SentAmount
+	div.RcvQuantity = reinvest.SentAmount
+	div.RcvPrice = decimal.NewFromFloat(1.0)
+	div.RcvAccountID = reinvest.AccountID
+	div.SentSymbol = reinvest.RcvSymbol
+	div.IncomeSymbol = reinvest.RcvSymbol
+	div.LinkedActivityID = reinvest.ID
+	div.Notes = reinvest.Notes
+	return div
+}
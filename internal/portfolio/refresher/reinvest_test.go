@@ -0,0 +1,65 @@
+package refresher
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/rkapps/fin-tracker-backend-go/cmd/common/logger"
+	"github.com/rkapps/fin-tracker-backend-go/internal/domain"
+	"github.com/shopspring/decimal"
+)
+
+func TestPairReinvestments(t *testing.T) {
+
+	day := func(d int) time.Time { return time.Date(2024, 3, d, 0, 0, 0, 0, time.UTC) }
+	dividend := func(id string, d int, amount float64) *domain.Activity {
+		return &domain.Activity{ID: id, AccountID: "a1", TxnType: domain.ActivityTypeDividend, Date: day(d),
+			RcvSymbol: "USD", RcvAmount: decimal.NewFromFloat(amount), IncomeSymbol: "VTI"}
+	}
+	buy := func(id string, txnType domain.ActivityType, d int, amount float64) *domain.Activity {
+		return &domain.Activity{ID: id, AccountID: "a1", TxnType: txnType, Date: day(d),
+			RcvSymbol: "VTI", SentSymbol: "USD", SentAmount: decimal.NewFromFloat(amount)}
+	}
+
+	// want lists id:type:day:linked in the order returned
+	tests := []struct {
+		name  string
+		actvs []*domain.Activity
+		want  []string
+	}{
+		{"reinvest within the window is paired",
+			[]*domain.Activity{dividend("d", 1, 10), buy("r", domain.ActivityTypeReinvest, 3, 10.01)},
+			[]string{"d:dividend:1:r", "r:reinvest:1:d"}},
+		{"reinvest after the window is not paired",
+			[]*domain.Activity{dividend("d", 1, 10), buy("r", domain.ActivityTypeReinvest, 9, 10)},
+			[]string{"d:dividend:1:", "r-div:dividend:9:r", "r:reinvest:9:r-div"}},
+		{"buy on the dividend date for the exact amount is paired",
+			[]*domain.Activity{dividend("d", 1, 10), buy("b", domain.ActivityTypeBuy, 1, 10)},
+			[]string{"d:dividend:1:b", "b:reinvest:1:d"}},
+		{"buy near a dividend stays a buy",
+			[]*domain.Activity{dividend("d", 1, 10), buy("b", domain.ActivityTypeBuy, 3, 10)},
+			[]string{"d:dividend:1:", "b:buy:3:"}},
+		{"buy on the dividend date of another amount stays a buy",
+			[]*domain.Activity{dividend("d", 1, 10), buy("b", domain.ActivityTypeBuy, 1, 10.01)},
+			[]string{"d:dividend:1:", "b:buy:1:"}},
+		{"unmatched reinvest gets a dividend",
+			[]*domain.Activity{buy("r", domain.ActivityTypeReinvest, 4, 7)},
+			[]string{"r-div:dividend:4:r", "r:reinvest:4:r-div"}},
+		{"dividend listed after its reinvest on the same day moves first",
+			[]*domain.Activity{buy("r", domain.ActivityTypeReinvest, 5, 10), buy("b", domain.ActivityTypeBuy, 5, 3), dividend("d", 5, 10)},
+			[]string{"d:dividend:5:r", "r:reinvest:5:d", "b:buy:5:"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			actvs := pairReinvestments(tt.actvs, logger.New().For("refresher"))
+			got := []string{}
+			for _, actv := range actvs {
+				got = append(got, fmt.Sprintf("%s:%s:%d:%s", actv.ID, actv.TxnType, actv.Date.Day(), actv.LinkedActivityID))
+			}
+			if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+				t.Errorf("pairReinvestments() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
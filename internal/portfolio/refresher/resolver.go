@@ -25,15 +25,24 @@ func (t TestBatchAccountRefresher) Refresh(ctx context.Context, provider string,
 	return actvs, nil
 }
 
-func ResolveRefresher(storage storage.FinTrackerStorageService, account domain.Account, logConfig *logger.Config) (AccountRefresher, error) {
+func ResolveRefresher(storage storage.FinTrackerStorageService, tstorage storage.TickerStorageService, account domain.Account, logConfig *logger.Config) (AccountRefresher, error) {
 	slog.Debug("ResolveRefresher", "Account Cateogory", account.Category)
 	switch account.Category {
 	case domain.CategoryBrokerage, domain.CategoryRetirement:
 		return NewImportAccountRefresher(storage, logConfig), nil
+	case domain.CategoryCrypto:
+		switch account.Type {
+		case domain.TypeHotWallet, domain.TypeImported:
+			detail, ok := account.Detail.(*domain.CryptoDetail)
+			if !ok || len(detail.Address) == 0 {
+				return NewImportAccountRefresher(storage, logConfig), nil
+			}
+			chain, err := ResolveChainConfig(detail.Blockchain)
+			if err != nil {
+				return nil, fmt.Errorf("refresher error: %w", err)
+			}
+			return NewWalletAccountRefresher(chain, tstorage, logConfig), nil
+		}
 	}
 	return nil, fmt.Errorf("refresher error: %s", account.Category)
 }
-
-func ResolveBatchRefresher(provider string) (BatchAccountRefresher, error) {
-	return TestBatchAccountRefresher{}, nil
-}
@@ -0,0 +1,26 @@
+package refresher
+
+import (
+	"context"
+	"time"
+)
+
+type sinceKey struct{}
+
+// WithSince attaches the sync cursor keyed by account id. Refreshers only pull the
+// provider history after it; accounts without a cursor pull their full history.
+func WithSince(ctx context.Context, since map[string]time.Time) context.Context {
+	return context.WithValue(ctx, sinceKey{}, since)
+}
+
+func sinceFor(ctx context.Context, acctId string) *time.Time {
+	since, ok := ctx.Value(sinceKey{}).(map[string]time.Time)
+	if !ok {
+		return nil
+	}
+	t, ok := since[acctId]
+	if !ok {
+		return nil
+	}
+	return &t
+}
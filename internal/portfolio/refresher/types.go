@@ -2,9 +2,12 @@ package refresher
 
 import (
 	"context"
+	"fmt"
+	"net/http"
 
 	"github.com/rkapps/fin-tracker-backend-go/cmd/common/logger"
 	"github.com/rkapps/fin-tracker-backend-go/internal/domain"
+	"github.com/rkapps/fin-tracker-backend-go/internal/pipeline"
 )
 
 // AccountRefresher refreshes a single account — brokerage, wallet, imported.
@@ -16,3 +19,13 @@ type AccountRefresher interface {
 type BatchAccountRefresher interface {
 	Refresh(ctx context.Context, provider string, accounts []domain.Account) ([]*domain.Activity, error)
 }
+
+// statusError is the error of an unexpected provider response. Provider outages
+// and rate limits are transient so the pipeline retries the job.
+func statusError(prefix string, code int) error {
+	err := fmt.Errorf("%s status: %d", prefix, code)
+	if code >= http.StatusInternalServerError || code == http.StatusTooManyRequests {
+		return pipeline.Transient(err)
+	}
+	return err
+}
@@ -0,0 +1,304 @@
+package refresher
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rkapps/fin-tracker-backend-go/cmd/common/logger"
+	"github.com/rkapps/fin-tracker-backend-go/internal/domain"
+	"github.com/rkapps/fin-tracker-backend-go/internal/storage"
+	"github.com/shopspring/decimal"
+)
+
+const (
+	nativeDecimals = 18
+)
+
+// WalletAccountRefresher pulls the on-chain history of an EVM wallet address. The explorer
+// only reports quantities, so the transfers are valued at the ticker history close of their day.
+type WalletAccountRefresher struct {
+	chain    ChainConfig
+	client   ExplorerClient
+	tstorage storage.TickerStorageService
+	logger   *logger.Logger
+}
+
+func NewWalletAccountRefresher(chain ChainConfig, tstorage storage.TickerStorageService, logConfig *logger.Config) WalletAccountRefresher {
+	plog := logConfig.For("refresher.wallet")
+	return WalletAccountRefresher{chain: chain, client: NewExplorerClient(chain.BaseURL, chain.APIKey), tstorage: tstorage, logger: plog}
+}
+
+// walletTransfer is a single asset movement within a transaction.
+type walletTransfer struct {
+	from   string
+	to     string
+	symbol string
+	qty    decimal.Decimal
+}
+
+// walletPrices are the daily closes of the symbols moved by a wallet, by symbol.
+type walletPrices map[string][]*domain.TickerHistory
+
+// value returns the close of the symbol on the day of date and the value of qty at
+// that close. Both are zero when the symbol has no close that early.
+func (p walletPrices) value(symbol string, qty decimal.Decimal, date time.Time) (decimal.Decimal, decimal.Decimal) {
+	price, ok := domain.CloseAt(p[symbol], date)
+	if !ok {
+		return decimal.Zero, decimal.Zero
+	}
+	return price, qty.Mul(price)
+}
+
+// walletTx groups the transfers and gas of one transaction hash.
+type walletTx struct {
+	hash      string
+	date      time.Time
+	from      string
+	to        string
+	gasPrice  decimal.Decimal
+	gasUsed   decimal.Decimal
+	gasTotal  decimal.Decimal
+	transfers []walletTransfer
+}
+
+func (r WalletAccountRefresher) Refresh(ctx context.Context, account domain.Account, logConfig *logger.Config) ([]*domain.Activity, error) {
+
+	detail, ok := account.Detail.(*domain.CryptoDetail)
+	if !ok || len(detail.Address) == 0 {
+		return nil, fmt.Errorf("wallet address not set for account: %s", account.ID)
+	}
+	address := strings.ToLower(detail.Address)
+	r.logger.Debug("Refresh", "Account", account.ID, "Address", address)
+
+	// an incremental sync starts at the last block before the cursor
+	startBlock := int64(0)
+	if since := sinceFor(ctx, account.ID); since != nil {
+		block, err := r.client.BlockByTime(ctx, *since)
+		if err != nil {
+			return nil, err
+		}
+		startBlock = block
+	}
+
+	native, err := r.client.NativeTransfers(ctx, address, startBlock)
+	if err != nil {
+		return nil, err
+	}
+	tokens, err := r.client.TokenTransfers(ctx, address, startBlock)
+	if err != nil {
+		return nil, err
+	}
+	r.logger.Info("Refresh", "Account", account.ID, "Native", len(native), "Tokens", len(tokens))
+
+	txs := r.groupTransactions(address, native, tokens)
+	prices := r.loadPrices(ctx, txs)
+
+	actvs := []*domain.Activity{}
+	for _, tx := range txs {
+		actvs = append(actvs, r.toActivities(account, address, tx, prices)...)
+	}
+	return actvs, nil
+}
+
+// loadPrices reads the daily closes of the native symbol and the tokens moved. A symbol
+// without history keeps a zero amount on its transfers.
+func (r WalletAccountRefresher) loadPrices(ctx context.Context, txs []*walletTx) walletPrices {
+
+	prices := walletPrices{}
+	if r.tstorage == nil {
+		return prices
+	}
+	symbols := []string{r.chain.NativeSymbol}
+	for _, tx := range txs {
+		for _, t := range tx.transfers {
+			symbols = append(symbols, t.symbol)
+		}
+	}
+	for _, symbol := range symbols {
+		if _, ok := prices[symbol]; ok {
+			continue
+		}
+		history, err := r.tstorage.GetTickerHistory(ctx, domain.TickerSymbol(symbol))
+		if err != nil {
+			r.logger.Warn("loadPrices", "Symbol", symbol, "Error", err)
+		} else if len(history) == 0 {
+			r.logger.Warn("loadPrices", "Symbol", symbol, "Error", "no ticker history")
+		}
+		prices[symbol] = domain.DailyCloses(history)
+	}
+	return prices
+}
+
+// groupTransactions merges native and token transfers by hash in block order.
+func (r WalletAccountRefresher) groupTransactions(address string, native []ExplorerTx, tokens []ExplorerTx) []*walletTx {
+
+	txs := []*walletTx{}
+	txsm := make(map[string]*walletTx)
+
+	getTx := func(etx ExplorerTx) *walletTx {
+		tx := txsm[etx.Hash]
+		if tx == nil {
+			tx = &walletTx{hash: etx.Hash, date: parseTimestamp(etx.TimeStamp)}
+			txsm[etx.Hash] = tx
+			txs = append(txs, tx)
+		}
+		return tx
+	}
+
+	for _, etx := range native {
+		tx := getTx(etx)
+		tx.from = strings.ToLower(etx.From)
+		tx.to = strings.ToLower(etx.To)
+
+		// only the sender pays gas
+		if tx.from == address {
+			tx.gasPrice = parseDecimal(etx.GasPrice)
+			tx.gasUsed = parseDecimal(etx.GasUsed)
+			tx.gasTotal = tx.gasPrice.Mul(tx.gasUsed).Shift(-nativeDecimals)
+		}
+		// a failed transaction only costs gas
+		if etx.IsError == "1" {
+			continue
+		}
+		qty := parseDecimal(etx.Value).Shift(-nativeDecimals)
+		if qty.IsPositive() {
+			tx.transfers = append(tx.transfers, walletTransfer{from: tx.from, to: tx.to, symbol: r.chain.NativeSymbol, qty: qty})
+		}
+	}
+
+	for _, etx := range tokens {
+		tx := getTx(etx)
+		decimals, _ := strconv.Atoi(etx.TokenDecimal)
+		qty := parseDecimal(etx.Value).Shift(int32(-decimals))
+		if !qty.IsPositive() {
+			continue
+		}
+		tx.transfers = append(tx.transfers, walletTransfer{
+			from:   strings.ToLower(etx.From),
+			to:     strings.ToLower(etx.To),
+			symbol: strings.ToUpper(etx.TokenSymbol),
+			qty:    qty,
+		})
+	}
+
+	sort.SliceStable(txs, func(i, j int) bool {
+		return txs[i].date.Before(txs[j].date)
+	})
+	return txs
+}
+
+// toActivities turns one transaction into trade, transfer or fee activities.
+// Gas is recorded as the fee of the first activity.
+func (r WalletAccountRefresher) toActivities(account domain.Account, address string, tx *walletTx, prices walletPrices) []*domain.Activity {
+
+	ins := []walletTransfer{}
+	outs := []walletTransfer{}
+	for _, t := range tx.transfers {
+		if t.to == address {
+			ins = append(ins, t)
+		}
+		if t.from == address {
+			outs = append(outs, t)
+		}
+	}
+
+	actvs := []*domain.Activity{}
+	newActivity := func(txnType domain.ActivityType) *domain.Activity {
+		actv := &domain.Activity{}
+		actv.ID = fmt.Sprintf("%s-%s", account.ID, tx.hash)
+		if len(actvs) > 0 {
+			actv.ID = fmt.Sprintf("%s-%d", actv.ID, len(actvs))
+		}
+		actv.UID = account.UID
+		actv.AccountID = account.ID
+		actv.TxnType = txnType
+		actv.Date = tx.date
+		actv.Status = domain.ActivityStatusSettled
+		actv.SourceID = tx.hash
+		actv.SourceType = "api"
+		actv.SetDetail(domain.WalletActivityDetail{
+			Hash:        tx.hash,
+			FromAddress: tx.from,
+			ToAddress:   tx.to,
+			Blockchain:  r.chain.Blockchain,
+			GasPrice:    tx.gasPrice,
+			GasUsed:     tx.gasUsed,
+			GasTotal:    tx.gasTotal,
+		})
+		actvs = append(actvs, actv)
+		return actv
+	}
+
+	if len(ins) == 1 && len(outs) == 1 && ins[0].symbol != outs[0].symbol {
+		// swap
+		actv := newActivity(domain.ActivityTypeTrade)
+		actv.RcvSymbol = ins[0].symbol
+		actv.RcvQuantity = ins[0].qty
+		actv.RcvAccountID = account.ID
+		actv.SentSymbol = outs[0].symbol
+		actv.SentQuantity = outs[0].qty
+		actv.SentAccountID = account.ID
+
+		// both sides are worth the same — the received side's close, else the sent side's
+		_, amount := prices.value(actv.RcvSymbol, actv.RcvQuantity, tx.date)
+		if amount.IsZero() {
+			_, amount = prices.value(actv.SentSymbol, actv.SentQuantity, tx.date)
+		}
+		actv.RcvAmount = amount
+		actv.RcvPrice = amount.Div(actv.RcvQuantity)
+		actv.SentAmount = amount
+		actv.SentPrice = amount.Div(actv.SentQuantity)
+	} else {
+		for _, t := range ins {
+			actv := newActivity(domain.ActivityTypeTransfer)
+			actv.RcvSymbol = t.symbol
+			actv.RcvQuantity = t.qty
+			actv.RcvPrice, actv.RcvAmount = prices.value(t.symbol, t.qty, tx.date)
+			actv.RcvAccountID = account.ID
+			actv.SentAccount = t.from
+		}
+		for _, t := range outs {
+			actv := newActivity(domain.ActivityTypeTransfer)
+			actv.SentSymbol = t.symbol
+			actv.SentQuantity = t.qty
+			actv.SentPrice, actv.SentAmount = prices.value(t.symbol, t.qty, tx.date)
+			actv.SentAccountID = account.ID
+			actv.RcvAccount = t.to
+		}
+	}
+
+	if tx.gasTotal.IsPositive() {
+		if len(actvs) == 0 {
+			// contract call without transfers e.g. token approval
+			actv := newActivity(domain.ActivityTypeFee)
+			actv.SentSymbol = r.chain.NativeSymbol
+			actv.SentQuantity = tx.gasTotal
+			actv.SentPrice, actv.SentAmount = prices.value(actv.SentSymbol, tx.gasTotal, tx.date)
+			actv.SentAccountID = account.ID
+		} else {
+			actvs[0].Fee = tx.gasTotal
+			actvs[0].FeeCurrency = r.chain.NativeSymbol
+		}
+	}
+	return actvs
+}
+
+func parseTimestamp(ts string) time.Time {
+	secs, err := strconv.ParseInt(ts, 10, 64)
+	if err != nil {
+		return time.Time{}
+	}
+	return time.Unix(secs, 0).UTC()
+}
+
+func parseDecimal(value string) decimal.Decimal {
+	d, err := decimal.NewFromString(value)
+	if err != nil {
+		return decimal.Zero
+	}
+	return d
+}
@@ -0,0 +1,97 @@
+package refresher
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/rkapps/fin-tracker-backend-go/cmd/common/logger"
+	"github.com/rkapps/fin-tracker-backend-go/internal/domain"
+	"github.com/rkapps/fin-tracker-backend-go/internal/storage/memory"
+	"github.com/shopspring/decimal"
+)
+
+const testAddress = "0xabc0000000000000000000000000000000000001"
+
+func TestWalletAccountRefresher(t *testing.T) {
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Query().Get("action") {
+		case "txlist":
+			w.Write([]byte(`{"status":"1","message":"OK","result":[
+				{"timeStamp":"1700000000","hash":"0x01","from":"0xfeed","to":"` + testAddress + `","value":"2000000000000000000","gasPrice":"10","gasUsed":"21000","isError":"0"},
+				{"timeStamp":"1700000100","hash":"0x02","from":"` + testAddress + `","to":"0xrouter","value":"500000000000000000","gasPrice":"20000000000","gasUsed":"100000","isError":"0"},
+				{"timeStamp":"1700000200","hash":"0x03","from":"` + testAddress + `","to":"0xtoken","value":"0","gasPrice":"10000000000","gasUsed":"50000","isError":"0"},
+				{"timeStamp":"1700000300","hash":"0x04","from":"` + testAddress + `","to":"0xfeed","value":"1000000000000000000","gasPrice":"10000000000","gasUsed":"21000","isError":"1"}
+			]}`))
+		case "tokentx":
+			w.Write([]byte(`{"status":"1","message":"OK","result":[
+				{"timeStamp":"1700000100","hash":"0x02","from":"0xrouter","to":"` + testAddress + `","value":"1500000000","tokenSymbol":"usdc","tokenDecimal":"6"}
+			]}`))
+		}
+	}))
+	defer server.Close()
+
+	// ETH closed at 2000 the day before and on the day of the transactions, USDC has no history
+	ts := memory.NewTickerMemoryStorage()
+	for i, close := range []int64{1800, 2000} {
+		th := &domain.TickerHistory{ID: fmt.Sprintf("ETH-%d", i), Date: time.Date(2023, 11, 13+i, 0, 0, 0, 0, time.UTC), Close: decimal.NewFromInt(close)}
+		th.Metadata.Symbol = "ETH"
+		ts.SaveTickerHistory(context.Background(), []*domain.TickerHistory{th})
+	}
+
+	chain := ChainConfig{Blockchain: "ethereum", NativeSymbol: "ETH", BaseURL: server.URL}
+	r := NewWalletAccountRefresher(chain, ts, logger.New())
+
+	account := domain.Account{ID: "w1", UID: "u1", Category: domain.CategoryCrypto, Type: domain.TypeHotWallet,
+		Detail: &domain.CryptoDetail{Blockchain: "ethereum", Address: testAddress}}
+	actvs, err := r.Refresh(context.Background(), account, logger.New())
+	if err != nil {
+		t.Fatalf("Refresh() error = %v", err)
+	}
+	if len(actvs) != 4 {
+		t.Fatalf("Refresh() returned %d activities, want 4", len(actvs))
+	}
+
+	// inbound transfer — sender pays gas so no fee
+	in := actvs[0]
+	if in.TxnType != domain.ActivityTypeTransfer || in.RcvSymbol != "ETH" || !in.RcvQuantity.Equal(decimal.NewFromInt(2)) || !in.Fee.IsZero() {
+		t.Errorf("inbound = %s %s %v fee %v", in.TxnType, in.RcvSymbol, in.RcvQuantity, in.Fee)
+	}
+	if !in.RcvAmount.Equal(decimal.NewFromInt(4000)) || !in.RcvPrice.Equal(decimal.NewFromInt(2000)) {
+		t.Errorf("inbound amount = %v at %v, want 4000 at the close of the day", in.RcvAmount, in.RcvPrice)
+	}
+
+	// swap ETH for USDC with gas as fee
+	swap := actvs[1]
+	if swap.TxnType != domain.ActivityTypeTrade || swap.SentSymbol != "ETH" || swap.RcvSymbol != "USDC" {
+		t.Errorf("swap = %s %s -> %s", swap.TxnType, swap.SentSymbol, swap.RcvSymbol)
+	}
+	if !swap.RcvQuantity.Equal(decimal.NewFromInt(1500)) || !swap.SentQuantity.Equal(decimal.RequireFromString("0.5")) {
+		t.Errorf("swap qty = %v -> %v", swap.SentQuantity, swap.RcvQuantity)
+	}
+	// USDC has no close, so both sides are valued at the ETH sent
+	if !swap.RcvAmount.Equal(decimal.NewFromInt(1000)) || !swap.SentAmount.Equal(decimal.NewFromInt(1000)) {
+		t.Errorf("swap amount = %v -> %v, want 1000", swap.SentAmount, swap.RcvAmount)
+	}
+	if !swap.Fee.Equal(decimal.RequireFromString("0.002")) || swap.FeeCurrency != "ETH" {
+		t.Errorf("swap fee = %v %s", swap.Fee, swap.FeeCurrency)
+	}
+	if swap.DetailType != "wallet" {
+		t.Errorf("swap detailType = %s", swap.DetailType)
+	}
+
+	// contract call and failed transaction only cost gas
+	for _, actv := range actvs[2:] {
+		if actv.TxnType != domain.ActivityTypeFee || actv.SentSymbol != "ETH" {
+			t.Errorf("%s = %s %s, want fee", actv.ID, actv.TxnType, actv.SentSymbol)
+		}
+	}
+	if !actvs[3].SentQuantity.Equal(decimal.RequireFromString("0.00021")) || !actvs[3].SentAmount.Equal(decimal.RequireFromString("0.42")) {
+		t.Errorf("failed tx fee = %v worth %v", actvs[3].SentQuantity, actvs[3].SentAmount)
+	}
+}
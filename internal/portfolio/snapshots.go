@@ -0,0 +1,67 @@
+package portfolio
+
+import (
+	"context"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/rkapps/fin-tracker-backend-go/internal/domain"
+)
+
+// daily summary snapshots are kept for a year by default
+const defaultSummaryRetentionDays = 365
+
+// summaryRetentionDays reads SUMMARY_RETENTION_DAYS, the days daily snapshots are kept for.
+// 0 keeps every snapshot.
+func (p Portfolio) summaryRetentionDays() int {
+	value := os.Getenv("SUMMARY_RETENTION_DAYS")
+	if len(value) == 0 {
+		return defaultSummaryRetentionDays
+	}
+	days, err := strconv.Atoi(value)
+	if err != nil || days < 0 {
+		p.logger.Warn("summaryRetentionDays", "Error", "invalid SUMMARY_RETENTION_DAYS", "Value", value)
+		return defaultSummaryRetentionDays
+	}
+	return days
+}
+
+// pruneSummaries thins the snapshots older than the retention to the last one of each
+// month per account, so a long time series stays available at a monthly grain.
+// Pruning is best effort — the refresh has been saved, so an error is only logged.
+func (p Portfolio) pruneSummaries(ctx context.Context, uid string, today time.Time) {
+
+	days := p.summaryRetentionDays()
+	if days == 0 {
+		return
+	}
+	cutoff := today.AddDate(0, 0, -days)
+	asumys, err := p.storage.GetAccountSummariesByDate(ctx, uid, time.Time{}, cutoff.Add(-time.Nanosecond))
+	if err != nil {
+		p.logger.Warn("pruneSummaries", "UID", uid, "Error", err)
+		return
+	}
+
+	// oldest first — the last snapshot of the month is kept
+	monthKey := func(asum *domain.AccountSummary) string {
+		return asum.AccountID + "-" + asum.Date.UTC().Format("2006-01")
+	}
+	kept := make(map[string]string)
+	for _, asum := range asumys {
+		kept[monthKey(asum)] = asum.ID
+	}
+	ids := []string{}
+	for _, asum := range asumys {
+		if kept[monthKey(asum)] != asum.ID {
+			ids = append(ids, asum.ID)
+		}
+	}
+	if len(ids) == 0 {
+		return
+	}
+	p.logger.Info("pruneSummaries", "UID", uid, "Before", cutoff.Format(time.DateOnly), "Deleted", len(ids))
+	if err := p.storage.DeleteAccountSummaries(ctx, ids); err != nil {
+		p.logger.Warn("pruneSummaries", "UID", uid, "Error", err)
+	}
+}
@@ -0,0 +1,49 @@
+package portfolio
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/rkapps/fin-tracker-backend-go/cmd/common/logger"
+	"github.com/rkapps/fin-tracker-backend-go/internal/domain"
+	"github.com/rkapps/fin-tracker-backend-go/internal/storage/memory"
+)
+
+func TestPruneSummaries(t *testing.T) {
+
+	t.Setenv("SUMMARY_RETENTION_DAYS", "30")
+	ctx := context.Background()
+	s := memory.NewFinTrackerMemoryStorage()
+	today := time.Date(2024, 6, 30, 0, 0, 0, 0, time.UTC)
+	asumys := []*domain.AccountSummary{}
+	for day := today.AddDate(0, -3, 0); !day.After(today); day = day.AddDate(0, 0, 1) {
+		asum := &domain.AccountSummary{UID: "u1", AccountID: "a1"}
+		asum.SetSnapshot(day)
+		asumys = append(asumys, asum)
+	}
+	s.SaveAccountSummaries(ctx, asumys)
+
+	logConfig := logger.New()
+	NewPortfolio(s, nil, nil, logConfig, logConfig.For("portfolio")).pruneSummaries(ctx, "u1", today)
+
+	asumys, _ = s.GetAccountSummaries(ctx, "u1")
+	got := map[string]bool{}
+	for _, asum := range asumys {
+		got[asum.ID] = true
+	}
+	// the last 30 days are kept daily, the months before at their last snapshot
+	for _, id := range []string{"a1-2024-03-31", "a1-2024-04-30", "a1-2024-05-30", "a1-2024-05-31", "a1-2024-06-30"} {
+		if !got[id] {
+			t.Errorf("snapshot %s pruned, want it kept", id)
+		}
+	}
+	for _, id := range []string{"a1-2024-04-29", "a1-2024-05-29"} {
+		if got[id] {
+			t.Errorf("snapshot %s kept, want it pruned", id)
+		}
+	}
+	if len(asumys) != 2+31+1 {
+		t.Errorf("snapshots after pruning = %d, want %d", len(asumys), 2+31+1)
+	}
+}
@@ -2,17 +2,24 @@ package portfolio
 
 import (
 	"context"
-	"log/slog"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
 
 	"github.com/rkapps/fin-tracker-backend-go/internal/domain"
+	"github.com/rkapps/fin-tracker-backend-go/internal/portfolio/refresher"
 	"github.com/rkapps/fin-tracker-backend-go/internal/portfolio/syncer"
 	"golang.org/x/sync/errgroup"
 )
 
-func (p Portfolio) SyncUserAccounts(ctx context.Context, uid string) error {
+// SyncUserAccounts pulls the activities of exchange and wallet accounts after their
+// last sync, saves them and moves each AccountSyncState through syncing to success or failed.
+// Accounts with new activities are flagged for the refresh pipeline.
+func (p Portfolio) SyncUserAccounts(ctx context.Context, uid string, opts SyncOptions) error {
 
-	slog.Info("RefreshUser", "UID", uid)
-	accts, err := p.storage.GetAccounts(uid)
+	p.logger.Info("SyncUserAccounts", "UID", uid, "Categories", opts.Categories, "Since", opts.Since)
+	accts, err := p.storage.GetAccounts(ctx, uid)
 	if err != nil {
 		return err
 	}
@@ -20,26 +27,186 @@ func (p Portfolio) SyncUserAccounts(ctx context.Context, uid string) error {
 	// filter — only exchange and wallet accounts
 	var syncable []domain.Account
 	for _, account := range accts {
+		if !opts.includes(account.Category) {
+			continue
+		}
 		switch account.Type {
 		case domain.TypeExchange, domain.TypeHotWallet:
 			syncable = append(syncable, *account)
 		}
 	}
+	if len(syncable) == 0 {
+		return nil
+	}
+
+	astatesm, err := p.getSyncStates(ctx, uid, syncable)
+	if err != nil {
+		return err
+	}
+
+	// mark every account syncing before any provider is called
+	start := time.Now().UTC()
+	for _, account := range syncable {
+		astate := astatesm[account.ID]
+		astate.StartSync(start)
+		if err := p.storage.SaveAccountSyncState(ctx, astate); err != nil {
+			return fmt.Errorf("error saving sync state: %w", err)
+		}
+	}
+
+	var (
+		mu   sync.Mutex
+		errs []error
+		ac   accountCredentials
+	)
+	finish := func(acctId string, newActivities int, err error) {
+		mu.Lock()
+		defer mu.Unlock()
+		astate := astatesm[acctId]
+		if err != nil {
+			p.logger.Warn("SyncUserAccounts", "Account", acctId, "Error", err)
+			astate.SyncFailed(err)
+			errs = append(errs, fmt.Errorf("account %s: %w", acctId, err))
+		} else {
+			astate.SyncSucceeded(newActivities)
+			p.markCredentialUsed(ctx, ac, acctId, start)
+		}
+		astate.Warning = ""
+		if acred, ok := ac.acreds[acctId]; ok {
+			astate.Warning = acred.ExpiryWarning(start)
+		}
+		if serr := p.storage.SaveAccountSyncState(ctx, astate); serr != nil {
+			p.logger.Error("SyncUserAccounts", "Account", acctId, "Error", serr)
+		}
+	}
+
+	// accounts with an inactive or expired credential are not synced
+	ac = p.loadCredentials(ctx, uid, syncable, start)
+	ready := []domain.Account{}
+	for _, account := range syncable {
+		if err, ok := ac.unusable[account.ID]; ok {
+			finish(account.ID, 0, err)
+			continue
+		}
+		ready = append(ready, account)
+	}
 
 	// group by provider/chain and fan out
+	ctx = p.withCredentials(ctx, ac)
 	g, ctx := errgroup.WithContext(ctx)
 
-	for provider, providerAccounts := range groupAccountsByProvider(syncable) {
+	grouped := groupAccountsByProvider(ready)
+	for _, account := range ready {
+		if !containsAccount(grouped, account.ID) {
+			finish(account.ID, 0, fmt.Errorf("exchange or blockchain not set"))
+		}
+	}
+
+	for provider, providerAccounts := range grouped {
 		providerAccounts := providerAccounts
 		provider := provider
 		g.Go(func() error {
-			syncer, err := syncer.ResolveBatchSyncer(provider)
+			batchSyncer, err := syncer.ResolveBatchSyncer(provider, p.tstorage, p.logConfig)
 			if err != nil {
+				for _, account := range providerAccounts {
+					finish(account.ID, 0, err)
+				}
+				if errors.Is(err, refresher.ErrProviderNotSupported) {
+					return nil
+				}
 				return err
 			}
-			return syncer.Sync(ctx, providerAccounts)
+
+			requests := make([]syncer.SyncRequest, len(providerAccounts))
+			for i, account := range providerAccounts {
+				since := astatesm[account.ID].Since()
+				if opts.Since != nil {
+					since = opts.Since
+				}
+				requests[i] = syncer.SyncRequest{Account: account, Since: since}
+			}
+			// the pulled activities are kept until the refresh replaces them
+			for _, result := range batchSyncer.Sync(ctx, requests) {
+				err := result.Err
+				if err == nil && len(result.Activities) > 0 {
+					if serr := p.storage.SaveActivities(ctx, result.Activities); serr != nil {
+						err = fmt.Errorf("error saving activities: %w", serr)
+					}
+				}
+				finish(result.AccountID, result.NewActivities, err)
+			}
+			return nil
 		})
 	}
 
-	return g.Wait()
+	if err := g.Wait(); err != nil {
+		return err
+	}
+	// the causes are kept so a provider outage is retried by the pipeline
+	if len(errs) > 0 {
+		return fmt.Errorf("%d of %d accounts failed to sync: %w", len(errs), len(syncable), errors.Join(errs...))
+	}
+	return nil
+}
+
+// getSyncStates returns the sync state of each account, creating missing ones.
+func (p Portfolio) getSyncStates(ctx context.Context, uid string, accts []domain.Account) (map[string]*domain.AccountSyncState, error) {
+
+	astates, err := p.storage.GetAccountSyncStates(ctx, uid)
+	if err != nil {
+		return nil, fmt.Errorf("error getting sync states: %w", err)
+	}
+	astatesm := make(map[string]*domain.AccountSyncState)
+	for _, astate := range astates {
+		astatesm[astate.ID] = astate
+	}
+	for _, account := range accts {
+		if _, ok := astatesm[account.ID]; !ok {
+			astatesm[account.ID] = &domain.AccountSyncState{ID: account.ID, UID: uid, SyncStatus: domain.SyncStatusPending}
+		}
+	}
+	return astatesm, nil
+}
+
+// NeedsRefresh returns true when any account of the user is flagged for refresh.
+func (p Portfolio) NeedsRefresh(ctx context.Context, uid string) (bool, error) {
+	astates, err := p.storage.GetAccountSyncStates(ctx, uid)
+	if err != nil {
+		return false, err
+	}
+	for _, astate := range astates {
+		if astate.Refresh {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// clearRefreshFlags resets the refresh flags once the portfolio is recomputed.
+func (p Portfolio) clearRefreshFlags(ctx context.Context, uid string) error {
+	astates, err := p.storage.GetAccountSyncStates(ctx, uid)
+	if err != nil {
+		return err
+	}
+	for _, astate := range astates {
+		if !astate.Refresh {
+			continue
+		}
+		astate.Refresh = false
+		if err := p.storage.SaveAccountSyncState(ctx, astate); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func containsAccount(grouped map[string][]domain.Account, acctId string) bool {
+	for _, accts := range grouped {
+		for _, acct := range accts {
+			if acct.ID == acctId {
+				return true
+			}
+		}
+	}
+	return false
 }
@@ -0,0 +1,176 @@
+package portfolio
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/rkapps/fin-tracker-backend-go/cmd/common/logger"
+	"github.com/rkapps/fin-tracker-backend-go/internal/domain"
+	"github.com/rkapps/fin-tracker-backend-go/internal/pipeline"
+	"github.com/rkapps/fin-tracker-backend-go/internal/storage/memory"
+)
+
+func TestSyncUserAccountsIncremental(t *testing.T) {
+
+	const address = "0xabc0000000000000000000000000000000000001"
+	var (
+		mu     sync.Mutex
+		params = map[string]string{}
+	)
+	record := func(key, value string) {
+		mu.Lock()
+		defer mu.Unlock()
+		params[key] = value
+	}
+
+	// the providers only return the history after the cursor they are given
+	exchange := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		record("since", r.URL.Query().Get("since"))
+		w.Header().Set("Content-Type", "application/json")
+		if len(r.URL.Query().Get("since")) > 0 {
+			w.Write([]byte(`{"transactions":[
+				{"id":"t2","type":"deposit","time":"2024-01-10T00:00:00Z","asset":"usd","quantity":"50"}
+			]}`))
+			return
+		}
+		w.Write([]byte(`{"transactions":[
+			{"id":"t1","type":"deposit","time":"2024-01-02T00:00:00Z","asset":"usd","quantity":"1000"},
+			{"id":"t2","type":"deposit","time":"2024-01-10T00:00:00Z","asset":"usd","quantity":"50"}
+		]}`))
+	}))
+	defer exchange.Close()
+
+	explorer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Query().Get("action") {
+		case "getblocknobytime":
+			record("timestamp", r.URL.Query().Get("timestamp"))
+			w.Write([]byte(`{"status":"1","message":"OK","result":"200"}`))
+		case "txlist":
+			record("startblock", r.URL.Query().Get("startblock"))
+			if r.URL.Query().Get("startblock") != "200" {
+				w.Write([]byte(`{"status":"1","message":"OK","result":[
+					{"blockNumber":"100","timeStamp":"1704153600","hash":"0x01","from":"0xfeed","to":"` + address + `","value":"1000000000000000000","isError":"0"},
+					{"blockNumber":"300","timeStamp":"1704844800","hash":"0x02","from":"0xfeed","to":"` + address + `","value":"1000000000000000000","isError":"0"}
+				]}`))
+				return
+			}
+			w.Write([]byte(`{"status":"1","message":"OK","result":[
+				{"blockNumber":"300","timeStamp":"1704844800","hash":"0x02","from":"0xfeed","to":"` + address + `","value":"1000000000000000000","isError":"0"}
+			]}`))
+		default:
+			w.Write([]byte(`{"status":"0","message":"No transactions found","result":[]}`))
+		}
+	}))
+	defer explorer.Close()
+
+	t.Setenv("EXCHANGE_URL_MOCKEX", exchange.URL)
+	t.Setenv("EXPLORER_URL_ETHEREUM", explorer.URL)
+
+	ctx := context.Background()
+	s := memory.NewFinTrackerMemoryStorage()
+	for _, acct := range []*domain.Account{
+		{ID: "x1", UID: "u1", Category: domain.CategoryCrypto, Type: domain.TypeExchange,
+			Detail: &domain.CryptoDetail{Exchange: "mockex", AccountNumber: "ACC-1"}},
+		{ID: "w1", UID: "u1", Category: domain.CategoryCrypto, Type: domain.TypeHotWallet,
+			Detail: &domain.CryptoDetail{Blockchain: "ethereum", Address: address}},
+	} {
+		if err := s.SaveAccount(ctx, acct); err != nil {
+			t.Fatalf("SaveAccount() error = %v", err)
+		}
+	}
+	lastSync := time.Date(2024, 1, 5, 0, 0, 0, 0, time.UTC)
+	for _, acctId := range []string{"x1", "w1"} {
+		astate := &domain.AccountSyncState{ID: acctId, UID: "u1", SyncStatus: domain.SyncStatusSuccess, LastSyncDate: &lastSync}
+		if err := s.SaveAccountSyncState(ctx, astate); err != nil {
+			t.Fatalf("SaveAccountSyncState() error = %v", err)
+		}
+	}
+
+	logConfig := logger.New()
+	p := NewPortfolio(s, memory.NewTickerMemoryStorage(), nil, logConfig, logConfig.For("portfolio"))
+	if err := p.SyncUserAccounts(ctx, "u1", SyncOptions{}); err != nil {
+		t.Fatalf("SyncUserAccounts() error = %v", err)
+	}
+
+	if params["since"] != "2024-01-05T00:00:00Z" {
+		t.Errorf("exchange since = %q, want the last sync date", params["since"])
+	}
+	if params["timestamp"] != "1704412800" || params["startblock"] != "200" {
+		t.Errorf("explorer timestamp = %q startblock = %q, want the block of the last sync date", params["timestamp"], params["startblock"])
+	}
+
+	// only the activities after the cursor are saved
+	actvs, err := s.GetActivities(ctx, "u1")
+	if err != nil {
+		t.Fatalf("GetActivities() error = %v", err)
+	}
+	ids := []string{}
+	for _, actv := range actvs {
+		ids = append(ids, actv.ID)
+	}
+	sort.Strings(ids)
+	if len(ids) != 2 || ids[0] != "w1-0x02" || ids[1] != "x1-t2" {
+		t.Errorf("saved activities = %v, want [w1-0x02 x1-t2]", ids)
+	}
+
+	// --since of the pipeline overrides the last sync date
+	since := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	if err := p.SyncUserAccounts(ctx, "u1", SyncOptions{Categories: []domain.AccountCategory{domain.CategoryCrypto}, Since: &since}); err != nil {
+		t.Fatalf("SyncUserAccounts(since) error = %v", err)
+	}
+	if params["since"] != "2024-01-01T00:00:00Z" || params["timestamp"] != "1704067200" {
+		t.Errorf("since option = %q %q, want 2024-01-01", params["since"], params["timestamp"])
+	}
+
+	astates, _ := s.GetAccountSyncStates(ctx, "u1")
+	for _, astate := range astates {
+		if astate.SyncStatus != domain.SyncStatusSuccess || !astate.Refresh {
+			t.Errorf("sync state %s = %s refresh %v, want success flagged for refresh", astate.ID, astate.SyncStatus, astate.Refresh)
+		}
+	}
+}
+
+func TestProviderErrorsAreRetryable(t *testing.T) {
+
+	ctx := context.Background()
+	tests := []struct {
+		status    int
+		retryable bool
+	}{
+		{http.StatusServiceUnavailable, true},
+		{http.StatusTooManyRequests, true},
+		{http.StatusBadRequest, false},
+	}
+	for _, tt := range tests {
+		t.Run(http.StatusText(tt.status), func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(tt.status)
+			}))
+			defer server.Close()
+			t.Setenv("EXCHANGE_URL_MOCKEX", server.URL)
+
+			s := memory.NewFinTrackerMemoryStorage()
+			s.SaveUser(ctx, &domain.User{ID: "u1", CurrencyCode: "USD", LotMatchingMethod: domain.LotMatchingFIFO})
+			s.SaveAccount(ctx, &domain.Account{ID: "x1", UID: "u1", Category: domain.CategoryCrypto, Type: domain.TypeExchange,
+				Detail: &domain.CryptoDetail{Exchange: "mockex", AccountNumber: "ACC-1"}})
+
+			logConfig := logger.New()
+			p := NewPortfolio(s, memory.NewTickerMemoryStorage(), nil, logConfig, logConfig.For("portfolio"))
+			serr := p.SyncUserAccounts(ctx, "u1", SyncOptions{})
+			rerr := p.RefreshUserAccounts(ctx, "u1", true)
+			for name, err := range map[string]error{"SyncUserAccounts": serr, "RefreshUserAccounts": rerr} {
+				if err == nil {
+					t.Errorf("%s() error = nil", name)
+				} else if pipeline.IsRetryable(err) != tt.retryable {
+					t.Errorf("IsRetryable(%s() = %v) = %v, want %v", name, err, !tt.retryable, tt.retryable)
+				}
+			}
+		})
+	}
+}
@@ -2,16 +2,116 @@ package syncer
 
 import (
 	"context"
+	"time"
 
+	"github.com/rkapps/fin-tracker-backend-go/cmd/common/logger"
 	"github.com/rkapps/fin-tracker-backend-go/internal/domain"
+	"github.com/rkapps/fin-tracker-backend-go/internal/portfolio/refresher"
+	"github.com/rkapps/fin-tracker-backend-go/internal/storage"
 )
 
-type TestBatchAccountSyncer struct{}
+// fetchFn pulls the provider activities of the accounts.
+type fetchFn func(ctx context.Context, accounts []domain.Account) ([]*domain.Activity, error)
 
-func (t TestBatchAccountSyncer) Sync(ctx context.Context, accts []domain.Account) error {
-	return nil
+// ActivitySyncer syncs accounts by pulling their activities after the last sync
+// from the provider. The providers are asked for the history after Since; the
+// activities are filtered again as providers may return the cursor's day.
+type ActivitySyncer struct {
+	provider   string
+	fetch      fetchFn
+	perAccount bool // wallets are fetched one address at a time
+	logger     *logger.Logger
 }
 
-func ResolveBatchSyncer(provider string) (BatchAccountSyncer, error) {
-	return TestBatchAccountSyncer{}, nil
+// ensures ActivitySyncer implements BatchAccountSyncer at compile time
+var _ BatchAccountSyncer = (*ActivitySyncer)(nil)
+
+func (s ActivitySyncer) Sync(ctx context.Context, requests []SyncRequest) []SyncResult {
+
+	batches := [][]SyncRequest{requests}
+	if s.perAccount {
+		batches = [][]SyncRequest{}
+		for _, req := range requests {
+			batches = append(batches, []SyncRequest{req})
+		}
+	}
+
+	results := []SyncResult{}
+	for _, batch := range batches {
+		results = append(results, s.syncBatch(ctx, batch)...)
+	}
+	return results
+}
+
+func (s ActivitySyncer) syncBatch(ctx context.Context, requests []SyncRequest) []SyncResult {
+
+	accounts := make([]domain.Account, len(requests))
+	for i, req := range requests {
+		accounts[i] = req.Account
+	}
+
+	since := make(map[string]time.Time)
+	for _, req := range requests {
+		if req.Since != nil {
+			since[req.Account.ID] = *req.Since
+		}
+	}
+
+	results := make([]SyncResult, len(requests))
+	actvs, err := s.fetch(refresher.WithSince(ctx, since), accounts)
+	if err != nil {
+		s.logger.Error("Sync", "Provider", s.provider, "Error", err)
+		for i, req := range requests {
+			results[i] = SyncResult{AccountID: req.Account.ID, Err: err}
+		}
+		return results
+	}
+
+	for i, req := range requests {
+		result := SyncResult{AccountID: req.Account.ID}
+		for _, actv := range actvs {
+			if actv.AccountID != req.Account.ID {
+				continue
+			}
+			if req.Since == nil || actv.Date.After(*req.Since) {
+				result.Activities = append(result.Activities, actv)
+			}
+		}
+		result.NewActivities = len(result.Activities)
+		s.logger.Debug("Sync", "Account", req.Account.ID, "NewActivities", result.NewActivities)
+		results[i] = result
+	}
+	return results
+}
+
+// ResolveBatchSyncer returns the syncer for an exchange or a chain.
+func ResolveBatchSyncer(provider string, tstorage storage.TickerStorageService, logConfig *logger.Config) (BatchAccountSyncer, error) {
+
+	plog := logConfig.For("syncer")
+
+	// wallets are grouped by chain
+	if chain, err := refresher.ResolveChainConfig(provider); err == nil {
+		wallet := refresher.NewWalletAccountRefresher(chain, tstorage, logConfig)
+		fetch := func(ctx context.Context, accounts []domain.Account) ([]*domain.Activity, error) {
+			actvs := []*domain.Activity{}
+			for _, account := range accounts {
+				result, err := wallet.Refresh(ctx, account, logConfig)
+				if err != nil {
+					return nil, err
+				}
+				actvs = append(actvs, result...)
+			}
+			return actvs, nil
+		}
+		return ActivitySyncer{provider: provider, fetch: fetch, perAccount: true, logger: plog}, nil
+	}
+
+	batch, err := refresher.ResolveBatchRefresher(provider, logConfig)
+	if err != nil {
+		return nil, err
+	}
+	fetch := func(ctx context.Context, accounts []domain.Account) ([]*domain.Activity, error) {
+		return batch.Refresh(ctx, provider, accounts)
+	}
+	return ActivitySyncer{provider: provider, fetch: fetch, logger: plog}, nil
 }
@@ -0,0 +1,78 @@
+package syncer
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/rkapps/fin-tracker-backend-go/cmd/common/logger"
+	"github.com/rkapps/fin-tracker-backend-go/internal/domain"
+)
+
+func TestActivitySyncer(t *testing.T) {
+
+	day := func(d int) time.Time { return time.Date(2024, 1, d, 0, 0, 0, 0, time.UTC) }
+	actvs := []*domain.Activity{
+		{AccountID: "a1", Date: day(1)},
+		{AccountID: "a1", Date: day(5)},
+		{AccountID: "a1", Date: day(9)},
+		{AccountID: "a2", Date: day(2)},
+	}
+	fetch := func(ctx context.Context, accounts []domain.Account) ([]*domain.Activity, error) {
+		for _, account := range accounts {
+			if account.ID == "bad" {
+				return nil, fmt.Errorf("provider down")
+			}
+		}
+		return actvs, nil
+	}
+
+	since := day(4)
+	requests := []SyncRequest{
+		{Account: domain.Account{ID: "a1"}, Since: &since}, // incremental
+		{Account: domain.Account{ID: "a2"}},                // full history
+		{Account: domain.Account{ID: "bad"}},
+	}
+
+	s := ActivitySyncer{provider: "test", fetch: fetch, perAccount: true, logger: logger.New().For("syncer")}
+	results := s.Sync(context.Background(), requests)
+	if len(results) != 3 {
+		t.Fatalf("Sync() returned %d results, want 3", len(results))
+	}
+	if results[0].NewActivities != 2 || results[0].Err != nil {
+		t.Errorf("a1 = %d %v, want 2 new", results[0].NewActivities, results[0].Err)
+	}
+	if results[1].NewActivities != 1 || results[1].Err != nil {
+		t.Errorf("a2 = %d %v, want 1 new", results[1].NewActivities, results[1].Err)
+	}
+	// a failing account does not fail the others
+	if results[2].Err == nil {
+		t.Errorf("bad account error = nil")
+	}
+}
+
+func TestAccountSyncStateLifecycle(t *testing.T) {
+
+	astate := &domain.AccountSyncState{ID: "a1", Resync: true}
+	if astate.Since() != nil {
+		t.Fatalf("Since() with resync should pull the full history")
+	}
+
+	start := time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC)
+	astate.StartSync(start)
+	astate.SyncSucceeded(0)
+	if astate.SyncStatus != domain.SyncStatusSuccess || astate.Resync || !astate.Refresh {
+		t.Errorf("after resync = %+v", astate)
+	}
+	if !astate.Since().Equal(start) {
+		t.Errorf("Since() = %v, want %v", astate.Since(), start)
+	}
+
+	astate.Refresh = false
+	astate.StartSync(start.AddDate(0, 0, 1))
+	astate.SyncFailed(fmt.Errorf("timeout"))
+	if astate.SyncStatus != domain.SyncStatusFailed || !astate.Since().Equal(start) || astate.Refresh {
+		t.Errorf("after failure = %+v", astate)
+	}
+}
@@ -2,6 +2,7 @@ package syncer
 
 import (
 	"context"
+	"time"
 
 	"github.com/rkapps/fin-tracker-backend-go/internal/domain"
 )
@@ -9,5 +10,20 @@ import (
 // BatchAccountSyncer — the only syncer interface needed.
 // Exchange and Wallet always sync as a batch by provider/chain.
 type BatchAccountSyncer interface {
-	Sync(ctx context.Context, accounts []domain.Account) error
+	Sync(ctx context.Context, requests []SyncRequest) []SyncResult
+}
+
+// SyncRequest is one account to sync. A nil Since pulls the full history.
+type SyncRequest struct {
+	Account domain.Account
+	Since   *time.Time
+}
+
+// SyncResult is the outcome of syncing one account. Activities are the ones
+// pulled after Since, to be saved by the caller.
+type SyncResult struct {
+	AccountID     string
+	NewActivities int
+	Activities    []*domain.Activity
+	Err           error
 }
@@ -1,20 +1,36 @@
 package portfolio
 
 import (
+	"slices"
+	"time"
+
 	"github.com/rkapps/fin-tracker-backend-go/cmd/common/logger"
+	"github.com/rkapps/fin-tracker-backend-go/internal/domain"
+	"github.com/rkapps/fin-tracker-backend-go/internal/secrets"
 	"github.com/rkapps/fin-tracker-backend-go/internal/storage"
 )
 
 type Portfolio struct {
 	storage       storage.FinTrackerStorageService
 	tstorage      storage.TickerStorageService
+	cipher        *secrets.Cipher // nil when no master key is configured
 	logger        *logger.Logger
 	logConfig     *logger.Config
 	acctLotSeqMap map[string]int // scoped to one GL run
 
 }
 
-func NewPortfolio(storage storage.FinTrackerStorageService, tstorage storage.TickerStorageService, logConfig *logger.Config, logger *logger.Logger) Portfolio {
+func NewPortfolio(storage storage.FinTrackerStorageService, tstorage storage.TickerStorageService, cipher *secrets.Cipher, logConfig *logger.Config, logger *logger.Logger) Portfolio {
 	acctLotSeqm := make(map[string]int)
-	return Portfolio{storage: storage, logConfig: logConfig, logger: logger, acctLotSeqMap: acctLotSeqm}
+	return Portfolio{storage: storage, tstorage: tstorage, cipher: cipher, logConfig: logConfig, logger: logger, acctLotSeqMap: acctLotSeqm}
+}
+
+// SyncOptions narrows a sync — the zero value syncs every account from its last sync.
+type SyncOptions struct {
+	Categories []domain.AccountCategory // only accounts in these categories, empty means all
+	Since      *time.Time               // sync from this date instead of each account's last sync
+}
+
+func (o SyncOptions) includes(category domain.AccountCategory) bool {
+	return len(o.Categories) == 0 || slices.Contains(o.Categories, category)
 }
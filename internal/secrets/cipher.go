@@ -0,0 +1,120 @@
+package secrets
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+)
+
+// Envelope is the wrapped data key stored next to the encrypted fields.
+type Envelope struct {
+	KeyVersion string
+	WrappedKey string // base64
+}
+
+// Cipher encrypts record fields with a per record data key wrapped by the KMS.
+type Cipher struct {
+	kms KMS
+}
+
+func NewCipher(kms KMS) *Cipher {
+	return &Cipher{kms: kms}
+}
+
+// NewCipherFromEnv returns ErrNoMasterKey when no master key is configured.
+func NewCipherFromEnv() (*Cipher, error) {
+	kms, err := NewKMSFromEnv()
+	if err != nil {
+		return nil, err
+	}
+	return NewCipher(kms), nil
+}
+
+func (c *Cipher) CurrentVersion() string {
+	return c.kms.CurrentVersion()
+}
+
+// Seal encrypts the fields in place with a new data key. Empty fields stay empty.
+// Fields are keyed by name and bound to the record id and their name, so a ciphertext
+// copied to another record or field does not open.
+func (c *Cipher) Seal(id string, fields map[string]*string) (Envelope, error) {
+
+	dek := make([]byte, 32)
+	if _, err := rand.Read(dek); err != nil {
+		return Envelope{}, err
+	}
+	wrapped, err := c.kms.WrapKey(dek)
+	if err != nil {
+		return Envelope{}, fmt.Errorf("wrap key error: %v", err)
+	}
+	for name, field := range fields {
+		if len(*field) == 0 {
+			continue
+		}
+		ciphertext, err := seal(dek, []byte(*field), fieldAAD(id, name))
+		if err != nil {
+			return Envelope{}, err
+		}
+		*field = base64.StdEncoding.EncodeToString(ciphertext)
+	}
+	return Envelope{KeyVersion: c.kms.CurrentVersion(), WrappedKey: base64.StdEncoding.EncodeToString(wrapped)}, nil
+}
+
+// Open decrypts the fields of the record in place.
+func (c *Cipher) Open(env Envelope, id string, fields map[string]*string) error {
+
+	dek, err := c.unwrap(env)
+	if err != nil {
+		return err
+	}
+	for name, field := range fields {
+		if len(*field) == 0 {
+			continue
+		}
+		ciphertext, err := base64.StdEncoding.DecodeString(*field)
+		if err != nil {
+			return fmt.Errorf("decode field error: %v", err)
+		}
+		plaintext, err := open(dek, ciphertext, fieldAAD(id, name))
+		if err != nil {
+			return fmt.Errorf("decrypt field %s error: %v", name, err)
+		}
+		*field = string(plaintext)
+	}
+	return nil
+}
+
+// Rewrap wraps the data key with the current master key version.
+// The encrypted fields are untouched — rotation only rewrites the envelope.
+func (c *Cipher) Rewrap(env Envelope) (Envelope, bool, error) {
+
+	if env.KeyVersion == c.kms.CurrentVersion() {
+		return env, false, nil
+	}
+	dek, err := c.unwrap(env)
+	if err != nil {
+		return env, false, err
+	}
+	wrapped, err := c.kms.WrapKey(dek)
+	if err != nil {
+		return env, false, fmt.Errorf("wrap key error: %v", err)
+	}
+	return Envelope{KeyVersion: c.kms.CurrentVersion(), WrappedKey: base64.StdEncoding.EncodeToString(wrapped)}, true, nil
+}
+
+// fieldAAD is the additional data a field is sealed with.
+func fieldAAD(id string, name string) []byte {
+	return []byte(id + ":" + name)
+}
+
+func (c *Cipher) unwrap(env Envelope) ([]byte, error) {
+	wrapped, err := base64.StdEncoding.DecodeString(env.WrappedKey)
+	if err != nil {
+		return nil, fmt.Errorf("decode key error: %v", err)
+	}
+	dek, err := c.kms.UnwrapKey(env.KeyVersion, wrapped)
+	if err != nil {
+		return nil, fmt.Errorf("unwrap key error: %v", err)
+	}
+	return dek, nil
+}
@@ -0,0 +1,88 @@
+package secrets
+
+import (
+	"bytes"
+	"encoding/base64"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCipherSealOpenRewrap(t *testing.T) {
+
+	v1 := bytes.Repeat([]byte{1}, 32)
+	v2 := bytes.Repeat([]byte{2}, 32)
+	kms1, err := NewLocalKMS("v1", map[string][]byte{"v1": v1})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	apiKey, secret, passphrase := "key-1234", "s3cret", ""
+	fields := map[string]*string{"apiKey": &apiKey, "apiSecret": &secret, "passphrase": &passphrase}
+	env, err := NewCipher(kms1).Seal("c1", fields)
+	if err != nil {
+		t.Fatalf("Seal() error = %v", err)
+	}
+	if env.KeyVersion != "v1" || apiKey == "key-1234" || secret == "s3cret" || passphrase != "" {
+		t.Fatalf("Seal() = %+v %q %q %q", env, apiKey, secret, passphrase)
+	}
+
+	// rotate — v2 is current and v1 is kept to unwrap old envelopes
+	kms2, err := NewLocalKMS("v2", map[string][]byte{"v1": v1, "v2": v2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	c2 := NewCipher(kms2)
+	renv, changed, err := c2.Rewrap(env)
+	if err != nil || !changed || renv.KeyVersion != "v2" {
+		t.Fatalf("Rewrap() = %+v %v %v", renv, changed, err)
+	}
+	if _, changed, _ := c2.Rewrap(renv); changed {
+		t.Errorf("Rewrap() of a current envelope should not change it")
+	}
+
+	// a ciphertext copied to another record or field does not open
+	copied := secret
+	if err := c2.Open(renv, "c2", map[string]*string{"apiSecret": &copied}); err == nil {
+		t.Errorf("Open() of another record should fail")
+	}
+	copied = secret
+	if err := c2.Open(renv, "c1", map[string]*string{"apiKey": &copied}); err == nil {
+		t.Errorf("Open() of another field should fail")
+	}
+
+	if err := c2.Open(renv, "c1", fields); err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	if apiKey != "key-1234" || secret != "s3cret" {
+		t.Errorf("Open() = %q %q", apiKey, secret)
+	}
+
+	// the old master key cannot open the rewrapped envelope
+	if err := NewCipher(kms1).Open(renv, "c1", map[string]*string{"apiKey": &apiKey}); err == nil {
+		t.Errorf("Open() with a retired version should fail")
+	}
+}
+
+func TestNewKMSFromEnv(t *testing.T) {
+
+	t.Setenv("CREDENTIAL_MASTER_KEY_FILE", "")
+	t.Setenv("CREDENTIAL_MASTER_KEYS", "")
+	if _, err := NewKMSFromEnv(); err != ErrNoMasterKey {
+		t.Errorf("NewKMSFromEnv() error = %v, want ErrNoMasterKey", err)
+	}
+
+	key := base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{7}, 32))
+	path := filepath.Join(t.TempDir(), "master.keys")
+	if err := os.WriteFile(path, []byte("# rotated 2024\nv3:"+key+"\nv2:"+key+"\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("CREDENTIAL_MASTER_KEY_FILE", path)
+	kms, err := NewKMSFromEnv()
+	if err != nil {
+		t.Fatalf("NewKMSFromEnv() error = %v", err)
+	}
+	if kms.CurrentVersion() != "v3" {
+		t.Errorf("CurrentVersion() = %s, want v3", kms.CurrentVersion())
+	}
+}
@@ -0,0 +1,154 @@
+package secrets
+
+import (
+	"bufio"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// ErrNoMasterKey is returned when no master key is configured.
+var ErrNoMasterKey = errors.New("credential master key not configured")
+
+// KMS wraps and unwraps data keys with a versioned master key.
+// LocalKMS is the default — a cloud KMS can be plugged in behind the same interface.
+type KMS interface {
+	CurrentVersion() string
+	WrapKey(dek []byte) ([]byte, error)                       // always wraps with the current version
+	UnwrapKey(version string, wrapped []byte) ([]byte, error) // any known version
+}
+
+// LocalKMS holds the master keys in memory.
+type LocalKMS struct {
+	current string
+	keys    map[string][]byte
+}
+
+// ensures LocalKMS implements KMS at compile time
+var _ KMS = (*LocalKMS)(nil)
+
+// NewLocalKMS creates a KMS from 32 byte AES keys keyed by version.
+func NewLocalKMS(current string, keys map[string][]byte) (*LocalKMS, error) {
+	if _, ok := keys[current]; !ok {
+		return nil, fmt.Errorf("master key version not found: %s", current)
+	}
+	for version, key := range keys {
+		if len(key) != 32 {
+			return nil, fmt.Errorf("master key %s must be 32 bytes", version)
+		}
+	}
+	return &LocalKMS{current: current, keys: keys}, nil
+}
+
+// NewKMSFromEnv loads the master keys from CREDENTIAL_MASTER_KEY_FILE or
+// CREDENTIAL_MASTER_KEYS. Each entry is "<version>:<base64 key>"; the file has
+// one entry per line. The first entry is current unless
+// CREDENTIAL_MASTER_KEY_VERSION is set.
+func NewKMSFromEnv() (*LocalKMS, error) {
+
+	entries := []string{}
+	if path := os.Getenv("CREDENTIAL_MASTER_KEY_FILE"); len(path) > 0 {
+		file, err := os.Open(path)
+		if err != nil {
+			return nil, fmt.Errorf("master key file error: %v", err)
+		}
+		defer file.Close()
+		scanner := bufio.NewScanner(file)
+		for scanner.Scan() {
+			line := strings.TrimSpace(scanner.Text())
+			if len(line) == 0 || strings.HasPrefix(line, "#") {
+				continue
+			}
+			entries = append(entries, line)
+		}
+		if err := scanner.Err(); err != nil {
+			return nil, fmt.Errorf("master key file error: %v", err)
+		}
+	} else if env := os.Getenv("CREDENTIAL_MASTER_KEYS"); len(env) > 0 {
+		for _, entry := range strings.Split(env, ",") {
+			if entry = strings.TrimSpace(entry); len(entry) > 0 {
+				entries = append(entries, entry)
+			}
+		}
+	}
+	if len(entries) == 0 {
+		return nil, ErrNoMasterKey
+	}
+
+	current := ""
+	keys := make(map[string][]byte)
+	for _, entry := range entries {
+		version, encoded, ok := strings.Cut(entry, ":")
+		if !ok {
+			return nil, fmt.Errorf("invalid master key entry, want <version>:<base64 key>")
+		}
+		key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(encoded))
+		if err != nil {
+			return nil, fmt.Errorf("master key %s: %v", version, err)
+		}
+		version = strings.TrimSpace(version)
+		keys[version] = key
+		if len(current) == 0 {
+			current = version
+		}
+	}
+	if version := os.Getenv("CREDENTIAL_MASTER_KEY_VERSION"); len(version) > 0 {
+		current = version
+	}
+	return NewLocalKMS(current, keys)
+}
+
+func (k *LocalKMS) CurrentVersion() string {
+	return k.current
+}
+
+func (k *LocalKMS) WrapKey(dek []byte) ([]byte, error) {
+	return seal(k.keys[k.current], dek, nil)
+}
+
+func (k *LocalKMS) UnwrapKey(version string, wrapped []byte) ([]byte, error) {
+	key, ok := k.keys[version]
+	if !ok {
+		return nil, fmt.Errorf("master key version not found: %s", version)
+	}
+	return open(key, wrapped, nil)
+}
+
+// seal encrypts with AES-GCM and prefixes the nonce. The additional data is
+// authenticated but not stored, open must be given the same.
+func seal(key []byte, plaintext []byte, aad []byte) ([]byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return gcm.Seal(nonce, nonce, plaintext, aad), nil
+}
+
+func open(key []byte, ciphertext []byte, aad []byte) ([]byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(ciphertext) < gcm.NonceSize() {
+		return nil, fmt.Errorf("ciphertext too short")
+	}
+	nonce, data := ciphertext[:gcm.NonceSize()], ciphertext[gcm.NonceSize():]
+	return gcm.Open(nil, nonce, data, aad)
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
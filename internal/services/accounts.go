@@ -4,47 +4,57 @@ import (
 	"context"
 	"crypto/sha1"
 	"encoding/hex"
+	"errors"
 	"fmt"
+	"strings"
 	"time"
 
 	"github.com/google/uuid"
 	"github.com/rkapps/fin-tracker-backend-go/cmd/common/logger"
 	"github.com/rkapps/fin-tracker-backend-go/internal/domain"
+	"github.com/rkapps/fin-tracker-backend-go/internal/dto"
 	"github.com/rkapps/fin-tracker-backend-go/internal/storage"
+	"github.com/rkapps/fin-tracker-backend-go/internal/utils"
+	"github.com/shopspring/decimal"
 )
 
 type AccountsService struct {
 	storage storage.FinTrackerStorageService
+	audit   auditor
 	logger  *logger.Logger
 }
 
-func NewAccountsService(storage storage.FinTrackerStorageService) AccountsService {
-	logger := logger.New()
-	alog := logger.For("accounts")
-	return AccountsService{storage: storage, logger: alog}
+func NewAccountsService(logConfig *logger.Config, storage storage.FinTrackerStorageService) AccountsService {
+	alog := logConfig.For("accounts")
+	return AccountsService{storage: storage, audit: newAuditor(logConfig, storage), logger: alog}
 }
 
 func (a AccountsService) CreateAccount(ctx context.Context, uid string, acct *domain.Account) (*domain.Account, error) {
+	// before the sync state is created, so an invalid account leaves nothing behind
+	if err := acct.ValidateAliasPatterns(); err != nil {
+		return nil, err
+	}
 	acct.UID = uid
 	acct.ID = uuid.New().String()
 	acct.CreatedAt = time.Now()
 	a.logger.Info("CreateAccount", "Account", acct)
 
-	// create account state
-	err := a.CreateAccountState(ctx, uid, acct.ID)
+	err := a.saveAccount(ctx, uid, acct.ID, acct)
 	if err != nil {
-		return nil, fmt.Errorf("CreateAccountState error: %v", err)
+		return nil, fmt.Errorf("CreateAccount error: %w", err)
 	}
 
-	// // create account credential
-	// a.CreateAccountCredential(ctx, uid, acct.ID)
-
-	err = a.UpdateAccount(ctx, uid, acct.ID, acct)
+	// create account state — after the account it refers to
+	err = a.CreateAccountState(ctx, uid, acct.ID)
 	if err != nil {
-		return nil, fmt.Errorf("CreateAccount error: %v", err)
+		return nil, fmt.Errorf("CreateAccountState error: %w", err)
 	}
 
-	return a.GetAccount(uid, acct.ID)
+	// // create account credential
+	// a.CreateAccountCredential(ctx, uid, acct.ID)
+	a.audit.record(ctx, uid, auditEntityAccount, acct.ID, acct.ID, domain.AuditCreate, auditChanges(nil, acct))
+
+	return a.GetAccount(ctx, uid, acct.ID)
 }
 
 func (a AccountsService) CreateAccountState(ctx context.Context, uid string, id string) error {
@@ -52,60 +62,98 @@ func (a AccountsService) CreateAccountState(ctx context.Context, uid string, id
 	astate := &domain.AccountSyncState{}
 	astate.UID = uid
 	astate.ID = id
-	return a.storage.SaveAccountSyncState(astate)
+	astate.SyncStatus = domain.SyncStatusPending
+	astate.Refresh = true
+	return a.storage.SaveAccountSyncState(ctx, astate)
 }
 
-func (a AccountsService) CreateAccountCredential(ctx context.Context, uid string, id string, acred *domain.AccountCredential) error {
-	//
-	acred.UID = uid
-	acred.ID = id
-	return a.storage.SaveAccountCredential(acred)
+func (a AccountsService) GetAccountSyncStates(ctx context.Context, uid string) ([]*domain.AccountSyncState, error) {
+	return a.storage.GetAccountSyncStates(ctx, uid)
 }
 
-func (a AccountsService) DeleteAccount(ctx context.Context, uid string, acctId string) error {
+func (a AccountsService) GetAccountSyncState(ctx context.Context, uid string, acctId string) (*domain.AccountSyncState, error) {
+	astate, err := a.storage.GetAccountSyncState(ctx, uid, acctId)
+	if err != nil || astate == nil {
+		return nil, lookupError("sync state", acctId, err)
+	}
+	return astate, nil
+}
 
-	var err error
-	// Delete activities
-	if err = a.DeleteImportedActivities(ctx, uid, acctId, time.Time{}); err != nil {
-		return err
+// ResyncAccount flags the account for a full history pull on the next sync.
+func (a AccountsService) ResyncAccount(ctx context.Context, uid string, acctId string) (*domain.AccountSyncState, error) {
+	if _, err := a.storage.GetAccount(ctx, uid, acctId); err != nil {
+		return nil, lookupError("account", acctId, err)
 	}
-	if err = a.DeleteActivities(ctx, uid, acctId, time.Time{}); err != nil {
-		return err
+	astate, err := a.getOrNewSyncState(ctx, uid, acctId)
+	if err != nil {
+		return nil, err
+	}
+	astate.Resync = true
+	a.logger.Info("ResyncAccount", "AccountId", acctId)
+	if err := a.storage.SaveAccountSyncState(ctx, astate); err != nil {
+		return nil, err
 	}
-	if err = a.DeleteActivityLots(ctx, uid, acctId, time.Time{}); err != nil {
+	return astate, nil
+}
+
+// flagRefresh marks the account so the refresh pipeline recomputes the user.
+func (a AccountsService) flagRefresh(ctx context.Context, uid string, acctId string) error {
+	astate, err := a.getOrNewSyncState(ctx, uid, acctId)
+	if err != nil {
 		return err
 	}
-	return a.storage.DeleteAccount(uid, acctId)
+	astate.Refresh = true
+	return a.storage.SaveAccountSyncState(ctx, astate)
+}
+
+// getOrNewSyncState returns the account's sync state or a pending one when it has none yet.
+func (a AccountsService) getOrNewSyncState(ctx context.Context, uid string, acctId string) (*domain.AccountSyncState, error) {
+	astate, err := a.storage.GetAccountSyncState(ctx, uid, acctId)
+	if errors.Is(err, storage.ErrNotFound) || (err == nil && astate == nil) {
+		return &domain.AccountSyncState{ID: acctId, UID: uid, SyncStatus: domain.SyncStatusPending}, nil
+	}
+	return astate, err
+}
+
+func (a AccountsService) CreateAccountCredential(ctx context.Context, uid string, id string, acred *domain.AccountCredential) error {
+	//
+	acred.UID = uid
+	acred.ID = id
+	return a.storage.SaveAccountCredential(ctx, acred)
 }
 
 func (a AccountsService) DeleteImportedActivities(ctx context.Context, uid string, acctId string, startDate time.Time) error {
+	_, err := a.deleteImportedActivities(ctx, uid, acctId, startDate)
+	return err
+}
 
-	actvs, err := a.storage.GetImortedActivities(uid, acctId)
+// deleteImportedActivities returns the number of imported activities deleted.
+func (a AccountsService) deleteImportedActivities(ctx context.Context, uid string, acctId string, startDate time.Time) (int, error) {
+
+	actvs, err := a.storage.GetImortedActivities(ctx, uid, acctId)
 	if err != nil {
+		return 0, err
 	}
 	ids := []string{}
 	// find ids to delete
 	for _, actv := range actvs {
-		if actv.Date.Before(startDate) {
+		if actv.Date != nil && actv.Date.Before(startDate) {
 			continue
 		}
 		ids = append(ids, actv.ID)
 	}
 	a.logger.Info("DeleteImportActivities", "Ids", len(ids))
 	// Delete activities
-	a.storage.DeleteImortedActivities(ids)
-	return nil
+	return len(ids), a.storage.DeleteImortedActivities(ctx, ids)
 }
 
 func (a AccountsService) DeleteActivities(ctx context.Context, uid string, acctId string, startDate time.Time) error {
 
-	actvs, err := a.storage.GetActivitiesForAccount(uid, acctId)
+	actvs, err := a.storage.GetActivitiesForAccount(ctx, uid, acctId)
 	if err != nil {
+		return err
 	}
 	ids := []string{}
-	if len(ids) == 0 {
-		return nil
-	}
 	// find ids to delete
 	for _, actv := range actvs {
 		if actv.Date.Before(startDate) {
@@ -115,38 +163,127 @@ func (a AccountsService) DeleteActivities(ctx context.Context, uid string, acctI
 	}
 	a.logger.Info("DeleteActivities", "Ids", len(ids))
 	// Delete activities
-	a.storage.DeleteActivities(ids)
-	return nil
+	return a.storage.DeleteActivities(ctx, ids)
 }
 
 func (a AccountsService) DeleteActivityLots(ctx context.Context, uid string, acctId string, startDate time.Time) error {
 
-	actvs, err := a.storage.GetActivityLotsForAccount(uid, acctId)
+	actvs, err := a.storage.GetActivityLotsForAccount(ctx, uid, acctId)
 	if err != nil {
+		return err
 	}
 	ids := []string{}
-	if len(ids) == 0 {
-		return nil
-	}
 	// find ids to delete
 	for _, actv := range actvs {
-		if actv.Date.Before(startDate) {
+		if actv.Date != nil && actv.Date.Before(startDate) {
 			continue
 		}
 		ids = append(ids, actv.ID)
 	}
 	a.logger.Info("DeleteActivityLots", "Ids", len(ids))
 	// Delete activities
-	a.storage.DeleteActivityLots(ids)
-	return nil
+	return a.storage.DeleteActivityLots(ctx, ids)
 }
 
-func (a AccountsService) GetAccounts(uid string) (domain.Accounts, error) {
-	return a.storage.GetAccounts(uid)
+func (a AccountsService) DeleteActivityMappingRule(ctx context.Context, uid string, id string) error {
+	return a.storage.DeleteActivityMappingRule(ctx, uid, id)
 }
 
-func (a AccountsService) GetAccount(uid string, id string) (*domain.Account, error) {
-	return a.storage.GetAccount(uid, id)
+func (a AccountsService) GetActivityMappingRules(ctx context.Context, uid string) (domain.ActivityMappingRules, error) {
+	return a.storage.GetActivityMappingRules(ctx, uid)
+}
+
+// GetUnmappedActivities returns the imported activities the last refresh could not map.
+func (a AccountsService) GetUnmappedActivities(ctx context.Context, uid string, acctId string) ([]*domain.ActivityImport, error) {
+
+	iactvs, err := a.storage.GetImortedActivities(ctx, uid, acctId)
+	if err != nil {
+		return nil, err
+	}
+	unmapped := []*domain.ActivityImport{}
+	for _, iactv := range iactvs {
+		if iactv.ProcessStatus == domain.ImportStatusUnmapped {
+			unmapped = append(unmapped, iactv)
+		}
+	}
+	return unmapped, nil
+}
+
+// GetUnresolvedCounterparties returns the deposit and withdrawal counterparties
+// booked against the unresolved placeholder account by the last refresh.
+func (a AccountsService) GetUnresolvedCounterparties(ctx context.Context, uid string) ([]*dto.UnresolvedCounterparty, error) {
+
+	actvs, err := a.storage.GetActivities(ctx, uid)
+	if err != nil {
+		return nil, err
+	}
+
+	cps := []*dto.UnresolvedCounterparty{}
+	cpsm := make(map[string]*dto.UnresolvedCounterparty)
+	for _, actv := range actvs {
+
+		var name string
+		var amount decimal.Decimal
+		if actv.IsDeposit() && domain.IsUnresolvedAccountID(actv.SentAccountID) {
+			name = actv.SentAccount
+			amount = actv.RcvAmount
+		} else if actv.IsWithdrawal() && domain.IsUnresolvedAccountID(actv.RcvAccountID) {
+			name = actv.RcvAccount
+			amount = actv.SentAmount
+		} else {
+			continue
+		}
+
+		key := strings.ToLower(strings.TrimSpace(name))
+		cp := cpsm[key]
+		if cp == nil {
+			cp = &dto.UnresolvedCounterparty{Name: name, FirstDate: actv.Date, LastDate: actv.Date}
+			cpsm[key] = cp
+			cps = append(cps, cp)
+		}
+		if !utils.CheckStringInArray(actv.AccountID, cp.AccountIDs) {
+			cp.AccountIDs = append(cp.AccountIDs, actv.AccountID)
+		}
+		cp.Count++
+		cp.Amount = cp.Amount.Add(amount)
+		if actv.Date.Before(cp.FirstDate) {
+			cp.FirstDate = actv.Date
+		}
+		if actv.Date.After(cp.LastDate) {
+			cp.LastDate = actv.Date
+		}
+	}
+	return cps, nil
+}
+
+// MapCounterparty adds the counterparty as an alternate name of the account
+// so the next refresh resolves it.
+func (a AccountsService) MapCounterparty(ctx context.Context, uid string, mapping dto.CounterpartyMapping) error {
+
+	name := strings.TrimSpace(mapping.Name)
+	if len(name) == 0 {
+		return fmt.Errorf("counterparty name required")
+	}
+	acct, err := a.storage.GetAccount(ctx, uid, mapping.AccountID)
+	if err != nil || acct == nil {
+		return lookupError("account", mapping.AccountID, err)
+	}
+	for _, alt := range acct.AlternateNames {
+		if strings.EqualFold(alt, name) {
+			return nil
+		}
+	}
+	a.logger.Info("MapCounterparty", "Name", name, "AccountId", acct.ID)
+	acct.AlternateNames = append(acct.AlternateNames, name)
+	return a.UpdateAccount(ctx, uid, acct.ID, acct)
+}
+
+func (a AccountsService) GetAccounts(ctx context.Context, uid string) (domain.Accounts, error) {
+	return a.storage.GetAccounts(ctx, uid)
+}
+
+func (a AccountsService) GetAccount(ctx context.Context, uid string, id string) (*domain.Account, error) {
+	return a.storage.GetAccount(ctx, uid, id)
 }
 
 func (a AccountsService) ImportActivities(ctx context.Context, uid string, acctId string, startDate time.Time, actvs []*domain.ActivityImport) error {
@@ -154,7 +291,10 @@ func (a AccountsService) ImportActivities(ctx context.Context, uid string, acctI
 	a.logger.Info("ImportActivities", "AccountId", acctId)
 
 	// first delete the activites from the startDate
-	a.DeleteImportedActivities(ctx, uid, acctId, startDate)
+	replaced, err := a.deleteImportedActivities(ctx, uid, acctId, startDate)
+	if err != nil {
+		return err
+	}
 
 	// Import activites
 	for _, actv := range actvs {
@@ -177,7 +317,14 @@ func (a AccountsService) ImportActivities(ctx context.Context, uid string, acctI
 		actv.AccountID = acctId
 		actv.ID = id
 	}
-	return a.storage.SaveImportedActivities(actvs)
+	if err := a.storage.SaveImportedActivities(ctx, actvs); err != nil {
+		return err
+	}
+	a.audit.record(ctx, uid, auditEntityAccount, acctId, acctId, domain.AuditImport, []domain.AuditChange{
+		auditChange("activities", replaced, len(actvs)),
+		auditChange("startDate", nil, startDate),
+	})
+	return a.flagRefresh(ctx, uid, acctId)
 }
 
 func (a AccountsService) LoadAccounts(ctx context.Context, user domain.User, accts domain.Accounts) error {
@@ -193,9 +340,70 @@ func (a AccountsService) LoadAccounts(ctx context.Context, user domain.User, acc
 	return nil
 }
 
+func (a AccountsService) SaveActivityMappingRule(ctx context.Context, uid string, rule *domain.ActivityMappingRule) (*domain.ActivityMappingRule, error) {
+
+	if err := rule.Validate(); err != nil {
+		return nil, err
+	}
+	if len(rule.AccountID) > 0 {
+		if _, err := a.storage.GetAccount(ctx, uid, rule.AccountID); err != nil {
+			return nil, lookupError("account", rule.AccountID, err)
+		}
+	}
+	if len(rule.ID) == 0 {
+		rule.ID = uuid.New().String()
+	} else if err := a.ownMappingRule(ctx, uid, rule.ID); err != nil {
+		return nil, err
+	}
+	rule.UID = uid
+	a.logger.Info("SaveActivityMappingRule", "Rule", rule.ID, "ActivityType", rule.ActivityType)
+
+	if err := a.storage.SaveActivityMappingRule(ctx, rule); err != nil {
+		return nil, err
+	}
+	return rule, nil
+}
+
+// ownMappingRule returns not found unless the rule exists and is the user's, so an update
+// can neither create a rule with a chosen id nor tell another user's rule apart.
+func (a AccountsService) ownMappingRule(ctx context.Context, uid string, id string) error {
+	rules, err := a.storage.GetActivityMappingRules(ctx, uid)
+	if err != nil {
+		return err
+	}
+	for _, rule := range rules {
+		if rule.ID == id {
+			return nil
+		}
+	}
+	return storage.NotFoundError(id)
+}
+
 func (a AccountsService) UpdateAccount(ctx context.Context, uid string, id string, acct *domain.Account) error {
+	// the previous values are only needed for the audit trail
+	before, _ := a.storage.GetAccount(ctx, uid, id)
+	if err := a.saveAccount(ctx, uid, id, acct); err != nil {
+		return err
+	}
+	a.audit.record(ctx, uid, auditEntityAccount, id, id, domain.AuditUpdate, auditChanges(before, acct))
+	return nil
+}
+
+// GetAuditEntries returns the most recent changes to the account — they are kept after it is deleted.
+func (a AccountsService) GetAuditEntries(ctx context.Context, uid string, acctId string, limit int64) ([]*domain.AuditEntry, error) {
+	return a.audit.entries(ctx, uid, acctId, limit)
+}
+
+func (a AccountsService) saveAccount(ctx context.Context, uid string, id string, acct *domain.Account) error {
+	if err := acct.ValidateAliasPatterns(); err != nil {
+		return err
+	}
 	acct.UID = uid
 	acct.ID = id
 	acct.UpdatedAt = time.Now()
-	return a.storage.SaveAccount(acct)
+	if err := a.storage.SaveAccount(ctx, acct); err != nil {
+		return err
+	}
+	// names, aliases and lot matching change how activities are computed
+	return a.flagRefresh(ctx, uid, id)
 }
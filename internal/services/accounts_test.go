@@ -0,0 +1,61 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/rkapps/fin-tracker-backend-go/cmd/common/logger"
+	"github.com/rkapps/fin-tracker-backend-go/internal/domain"
+	"github.com/rkapps/fin-tracker-backend-go/internal/storage"
+	"github.com/rkapps/fin-tracker-backend-go/internal/storage/memory"
+)
+
+func TestSaveActivityMappingRuleOwnership(t *testing.T) {
+
+	ctx := context.Background()
+	accounts := NewAccountsService(logger.New(), memory.NewFinTrackerMemoryStorage())
+
+	rule, err := accounts.SaveActivityMappingRule(ctx, "u1", &domain.ActivityMappingRule{Name: "fees", NotesPattern: "fee", Skip: true})
+	if err != nil {
+		t.Fatalf("SaveActivityMappingRule() error = %v", err)
+	}
+
+	// another user's rule and an unknown id are not found rather than upserted
+	for _, tc := range []struct{ uid, id string }{{"u2", rule.ID}, {"u1", "missing"}} {
+		_, err := accounts.SaveActivityMappingRule(ctx, tc.uid, &domain.ActivityMappingRule{ID: tc.id, Name: "taken", NotesPattern: "x", Skip: true})
+		if !errors.Is(err, storage.ErrNotFound) {
+			t.Errorf("SaveActivityMappingRule(%s, %s) error = %v, want ErrNotFound", tc.uid, tc.id, err)
+		}
+	}
+	if rules, _ := accounts.GetActivityMappingRules(ctx, "u2"); len(rules) != 0 {
+		t.Errorf("GetActivityMappingRules(u2) = %d, want 0", len(rules))
+	}
+
+	rule.Name = "all fees"
+	if _, err := accounts.SaveActivityMappingRule(ctx, "u1", rule); err != nil {
+		t.Errorf("SaveActivityMappingRule() update error = %v", err)
+	}
+	if rules, _ := accounts.GetActivityMappingRules(ctx, "u1"); len(rules) != 1 || rules[0].Name != "all fees" {
+		t.Errorf("GetActivityMappingRules(u1) = %v, want the updated rule", rules)
+	}
+}
+
+func TestCreateAccountValidatesAliasPatterns(t *testing.T) {
+
+	ctx := context.Background()
+	s := memory.NewFinTrackerMemoryStorage()
+	accounts := NewAccountsService(logger.New(), s)
+
+	_, err := accounts.CreateAccount(ctx, "u1", &domain.Account{Name: "Checking", Category: domain.CategoryCash,
+		Detail: &domain.BankDetail{}, AliasPatterns: []string{"ally("}})
+	if err == nil {
+		t.Fatalf("CreateAccount() with an invalid alias pattern error = nil")
+	}
+	if accts, _ := s.GetAccounts(ctx, "u1"); len(accts) != 0 {
+		t.Errorf("GetAccounts() = %d, want 0", len(accts))
+	}
+	if astates, _ := s.GetAccountSyncStates(ctx, "u1"); len(astates) != 0 {
+		t.Errorf("GetAccountSyncStates() = %d, want 0", len(astates))
+	}
+}
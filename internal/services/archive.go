@@ -0,0 +1,449 @@
+package services
+
+import (
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/rkapps/fin-tracker-backend-go/cmd/common/logger"
+	"github.com/rkapps/fin-tracker-backend-go/internal/domain"
+	"github.com/rkapps/fin-tracker-backend-go/internal/locks"
+	"github.com/rkapps/fin-tracker-backend-go/internal/storage"
+	"github.com/rkapps/storage-backend-go/mongodb"
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+// ArchiveVersion is the format written by Export — Import reads this version and older.
+const ArchiveVersion = 1
+
+const archiveManifest = "manifest.json"
+
+// Collections of the archive, one JSON lines file each. Records are Mongo extended
+// JSON of their storage documents so decimals, dates and details round-trip exactly.
+// GL entries are not kept by any store yet and are not archived.
+const (
+	ArchiveUser                 = "user"
+	ArchiveAccounts             = "accounts"
+	ArchiveAccountSyncStates    = "account_sync_states"
+	ArchiveAccountCredentials   = "account_credentials"
+	ArchiveActivityMappingRules = "activity_mapping_rules"
+	ArchiveActivityImports      = "activity_imports"
+	ArchiveActivities           = "activities"
+	ArchiveActivityLots         = "activity_lots"
+	ArchiveAccountSummaries     = "account_summaries"
+	ArchiveTransactions         = "transactions"
+)
+
+// archiveRegistry encodes decimals the same way the storage client does.
+var archiveRegistry = mongodb.GetBsonRegistryForDecimal()
+
+// ArchiveManifest describes an archive — the counts are records per collection.
+type ArchiveManifest struct {
+	Version    int            `json:"version"`
+	UID        string         `json:"uid"`
+	ExportedAt time.Time      `json:"exportedAt"`
+	Counts     map[string]int `json:"counts"`
+}
+
+// ImportOptions of an archive restore.
+type ImportOptions struct {
+	// UID restores into this user instead of the archived one e.g to clone a user into
+	// a test user. The record ids are remapped so the original user's records are never
+	// overwritten — the same archive always maps to the same ids.
+	UID string
+}
+
+// ArchiveService exports one user's data to a versioned archive and restores it.
+type ArchiveService struct {
+	storage storage.FinTrackerStorageService
+	locker  locks.Locker // a restore does not run alongside a sync or refresh of the user
+	logger  *logger.Logger
+}
+
+func NewArchiveService(logConfig *logger.Config, storage storage.FinTrackerStorageService) ArchiveService {
+	locker := locks.NewLocker(storage, locks.NewOwner(), locks.DefaultTTL, logConfig)
+	return ArchiveService{storage: storage, locker: locker, logger: logConfig.For("archive")}
+}
+
+// Export writes the user's data as a zip archive. Credentials are exported without
+// their secrets — restored credentials must be entered again.
+func (s ArchiveService) Export(ctx context.Context, uid string, w io.Writer) (*ArchiveManifest, error) {
+
+	manifest := &ArchiveManifest{Version: ArchiveVersion, UID: uid, ExportedAt: time.Now().UTC(), Counts: map[string]int{}}
+	zw := zip.NewWriter(w)
+
+	// a user is only saved once they change their settings
+	users := []*domain.User{}
+	user, err := s.storage.GetUser(ctx, uid)
+	if err == nil && user != nil {
+		users = append(users, user)
+	} else if err != nil && !errors.Is(err, storage.ErrNotFound) {
+		return nil, err
+	}
+	if err := writeArchive(zw, manifest, ArchiveUser, users, nil); err != nil {
+		return nil, err
+	}
+	if err := exportCollection(ctx, zw, manifest, ArchiveAccounts, s.storage.GetAccounts, uid); err != nil {
+		return nil, err
+	}
+	if err := exportCollection(ctx, zw, manifest, ArchiveAccountSyncStates, s.storage.GetAccountSyncStates, uid); err != nil {
+		return nil, err
+	}
+	acreds, err := s.storage.GetAccountCredentials(ctx, uid)
+	if err != nil {
+		return nil, err
+	}
+	if err := writeArchive(zw, manifest, ArchiveAccountCredentials, acreds, func(acred *domain.AccountCredential) *domain.AccountCredential {
+		metadata := *acred
+		revoke(&metadata)
+		metadata.Active = acred.Active
+		return &metadata
+	}); err != nil {
+		return nil, err
+	}
+	rules, err := s.storage.GetActivityMappingRules(ctx, uid)
+	if err != nil {
+		return nil, err
+	}
+	if err := writeArchive(zw, manifest, ArchiveActivityMappingRules, rules, nil); err != nil {
+		return nil, err
+	}
+
+	// imports are kept per account
+	accts, err := s.storage.GetAccounts(ctx, uid)
+	if err != nil {
+		return nil, err
+	}
+	imports := []*domain.ActivityImport{}
+	for _, acct := range accts {
+		actvs, err := s.storage.GetImortedActivities(ctx, uid, acct.ID)
+		if err != nil {
+			return nil, err
+		}
+		imports = append(imports, actvs...)
+	}
+	if err := writeArchive(zw, manifest, ArchiveActivityImports, imports, nil); err != nil {
+		return nil, err
+	}
+	if err := exportCollection(ctx, zw, manifest, ArchiveActivities, s.storage.GetActivities, uid); err != nil {
+		return nil, err
+	}
+	if err := exportCollection(ctx, zw, manifest, ArchiveActivityLots, s.storage.GetActivityLots, uid); err != nil {
+		return nil, err
+	}
+	if err := exportCollection(ctx, zw, manifest, ArchiveAccountSummaries, s.storage.GetAccountSummaries, uid); err != nil {
+		return nil, err
+	}
+	txns, err := s.storage.SearchTransactions(ctx, uid, allTransactionsFrom, allTransactionsTo, "")
+	if err != nil {
+		return nil, err
+	}
+	if err := writeArchive(zw, manifest, ArchiveTransactions, txns, nil); err != nil {
+		return nil, err
+	}
+
+	mw, err := zw.Create(archiveManifest)
+	if err != nil {
+		return nil, err
+	}
+	encoder := json.NewEncoder(mw)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(manifest); err != nil {
+		return nil, err
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	s.logger.Info("Export", "UID", uid, "Counts", manifest.Counts)
+	return manifest, nil
+}
+
+// Import restores an archive written by Export. Records are upserted and the
+// transactions in the archived date range replaced, so importing the same archive
+// again changes nothing. Credentials that already exist keep their secrets, new
+// ones are restored inactive.
+func (s ArchiveService) Import(ctx context.Context, r io.ReaderAt, size int64, opts ImportOptions) (*ArchiveManifest, error) {
+
+	zr, err := zip.NewReader(r, size)
+	if err != nil {
+		return nil, fmt.Errorf("invalid archive: %w", err)
+	}
+	files := map[string]*zip.File{}
+	for _, file := range zr.File {
+		files[file.Name] = file
+	}
+	manifest, err := readManifest(files[archiveManifest])
+	if err != nil {
+		return nil, err
+	}
+
+	uid := manifest.UID
+	if len(opts.UID) > 0 {
+		uid = opts.UID
+	}
+	restored := &ArchiveManifest{Version: manifest.Version, UID: uid, ExportedAt: manifest.ExportedAt, Counts: map[string]int{}}
+	err = s.locker.WithLock(ctx, uid, "import", func(ctx context.Context) error {
+		return s.restore(ctx, files, archiveIdMap(manifest.UID, uid), restored)
+	})
+	if err != nil {
+		return nil, err
+	}
+	s.logger.Info("Import", "ArchivedUID", manifest.UID, "UID", uid, "Counts", restored.Counts)
+	return restored, nil
+}
+
+// restore saves the records of the archive files for the restored user.
+func (s ArchiveService) restore(ctx context.Context, files map[string]*zip.File, remap func(id string) string, restored *ArchiveManifest) error {
+
+	uid := restored.UID
+	// parents before the records that refer to them
+	if err := importCollection(files, restored, ArchiveUser, func(user *domain.User) error {
+		user.ID = uid
+		return s.storage.SaveUser(ctx, user)
+	}); err != nil {
+		return err
+	}
+	if err := importCollection(files, restored, ArchiveAccounts, func(acct *domain.Account) error {
+		acct.ID, acct.UID = remap(acct.ID), uid
+		return s.storage.SaveAccount(ctx, acct)
+	}); err != nil {
+		return err
+	}
+	if err := importCollection(files, restored, ArchiveAccountSyncStates, func(astate *domain.AccountSyncState) error {
+		astate.ID, astate.UID = remap(astate.ID), uid
+		return s.storage.SaveAccountSyncState(ctx, astate)
+	}); err != nil {
+		return err
+	}
+	if err := importCollection(files, restored, ArchiveAccountCredentials, func(acred *domain.AccountCredential) error {
+		acred.ID, acred.UID = remap(acred.ID), uid
+		_, err := s.storage.GetAccountCredential(ctx, uid, acred.ID)
+		if err == nil {
+			return nil
+		}
+		if !errors.Is(err, storage.ErrNotFound) {
+			return err
+		}
+		revoke(acred)
+		return s.storage.SaveAccountCredential(ctx, acred)
+	}); err != nil {
+		return err
+	}
+	if err := importCollection(files, restored, ArchiveActivityMappingRules, func(rule *domain.ActivityMappingRule) error {
+		rule.ID, rule.UID, rule.AccountID = remap(rule.ID), uid, remap(rule.AccountID)
+		return s.storage.SaveActivityMappingRule(ctx, rule)
+	}); err != nil {
+		return err
+	}
+
+	if err := importBatches(files, restored, ArchiveActivityImports, func(actvs []*domain.ActivityImport) error {
+		for _, actv := range actvs {
+			actv.ID, actv.UID, actv.AccountID = remap(actv.ID), uid, remap(actv.AccountID)
+		}
+		return s.storage.SaveImportedActivities(ctx, actvs)
+	}); err != nil {
+		return err
+	}
+	if err := importBatches(files, restored, ArchiveActivities, func(actvs []*domain.Activity) error {
+		for _, actv := range actvs {
+			actv.ID, actv.UID, actv.AccountID = remap(actv.ID), uid, remap(actv.AccountID)
+			actv.RcvAccountID, actv.SentAccountID = remap(actv.RcvAccountID), remap(actv.SentAccountID)
+			actv.LinkedActivityID = remap(actv.LinkedActivityID)
+		}
+		return s.storage.SaveActivities(ctx, actvs)
+	}); err != nil {
+		return err
+	}
+	if err := importBatches(files, restored, ArchiveActivityLots, func(lots []*domain.ActivityLot) error {
+		for _, lot := range lots {
+			lot.ID, lot.UID, lot.AccountID = remap(lot.ID), uid, remap(lot.AccountID)
+			lot.ActivityID, lot.SellActivityID = remap(lot.ActivityID), remap(lot.SellActivityID)
+		}
+		return s.storage.SaveActivityLots(ctx, lots)
+	}); err != nil {
+		return err
+	}
+	if err := importBatches(files, restored, ArchiveAccountSummaries, func(asumys []*domain.AccountSummary) error {
+		for _, asumy := range asumys {
+			asumy.ID, asumy.UID, asumy.AccountID = remap(asumy.ID), uid, remap(asumy.AccountID)
+		}
+		return s.storage.SaveAccountSummaries(ctx, asumys)
+	}); err != nil {
+		return err
+	}
+
+	// transactions get new ids on import — replacing the archived range keeps a re-import idempotent
+	txns, err := readArchive[domain.Transaction](files[ArchiveTransactions+".jsonl"])
+	if err != nil {
+		return err
+	}
+	if len(txns) > 0 {
+		start, end := txns[0].Date, txns[0].Date
+		for _, txn := range txns {
+			if txn.Date.Before(start) {
+				start = txn.Date
+			}
+			if txn.Date.After(end) {
+				end = txn.Date
+			}
+		}
+		if err := s.storage.ImportTransactions(ctx, uid, start, end, txns); err != nil {
+			return err
+		}
+	}
+	restored.Counts[ArchiveTransactions] = len(txns)
+	return nil
+}
+
+// revoke wipes the secrets of a credential, the metadata is kept.
+func revoke(acred *domain.AccountCredential) {
+	acred.Active = false
+	acred.APIKey = ""
+	acred.APISecret = ""
+	acred.Passphrase = ""
+	acred.KeyVersion = ""
+	acred.WrappedKey = ""
+}
+
+// archiveIdMap returns the ids of the restored records. Restoring into the archived
+// user keeps them, a clone gets ids derived from the new user and the archived id.
+func archiveIdMap(archivedUID string, uid string) func(id string) string {
+	if archivedUID == uid {
+		return func(id string) string { return id }
+	}
+	return func(id string) string {
+		switch {
+		case len(id) == 0:
+			return id
+		case domain.IsUnresolvedAccountID(id):
+			return domain.UnresolvedAccountID(uid)
+		}
+		return uuid.NewSHA1(uuid.NameSpaceOID, []byte(uid+"/"+id)).String()
+	}
+}
+
+// exportCollection writes the user's records returned by get.
+func exportCollection[T any, S ~[]*T](ctx context.Context, zw *zip.Writer, manifest *ArchiveManifest, name string,
+	get func(ctx context.Context, uid string) (S, error), uid string) error {
+	records, err := get(ctx, uid)
+	if err != nil {
+		return err
+	}
+	return writeArchive(zw, manifest, name, records, nil)
+}
+
+// writeArchive writes the records to the collection file, one extended JSON
+// document per line. export, when set, returns the record to write.
+func writeArchive[T any, S ~[]*T](zw *zip.Writer, manifest *ArchiveManifest, name string, records S, export func(*T) *T) error {
+	w, err := zw.Create(name + ".jsonl")
+	if err != nil {
+		return err
+	}
+	for _, record := range records {
+		if export != nil {
+			record = export(record)
+		}
+		encoder := bson.NewEncoder(bson.NewExtJSONValueWriter(w, false, false))
+		encoder.SetRegistry(archiveRegistry)
+		if err := encoder.Encode(record); err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+		if _, err := w.Write([]byte("\n")); err != nil {
+			return err
+		}
+	}
+	manifest.Counts[name] = len(records)
+	return nil
+}
+
+func readManifest(file *zip.File) (*ArchiveManifest, error) {
+	if file == nil {
+		return nil, fmt.Errorf("invalid archive: no %s", archiveManifest)
+	}
+	r, err := file.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	manifest := &ArchiveManifest{}
+	if err := json.NewDecoder(r).Decode(manifest); err != nil {
+		return nil, fmt.Errorf("invalid archive manifest: %w", err)
+	}
+	if manifest.Version < 1 || manifest.Version > ArchiveVersion {
+		return nil, fmt.Errorf("unsupported archive version %d, want 1 to %d", manifest.Version, ArchiveVersion)
+	}
+	if len(manifest.UID) == 0 {
+		return nil, errors.New("invalid archive manifest: no uid")
+	}
+	return manifest, nil
+}
+
+// readArchive reads the records of a collection file — a missing file has none.
+func readArchive[T any](file *zip.File) ([]*T, error) {
+	records := []*T{}
+	if file == nil {
+		return records, nil
+	}
+	r, err := file.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		if len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+			continue
+		}
+		vr, err := bson.NewExtJSONValueReader(bytes.NewReader(scanner.Bytes()), false)
+		if err != nil {
+			return nil, fmt.Errorf("%s line %d: %w", file.Name, line, err)
+		}
+		decoder := bson.NewDecoder(vr)
+		decoder.SetRegistry(archiveRegistry)
+		record := new(T)
+		if err := decoder.Decode(record); err != nil {
+			return nil, fmt.Errorf("%s line %d: %w", file.Name, line, err)
+		}
+		records = append(records, record)
+	}
+	return records, scanner.Err()
+}
+
+// importCollection saves the records of a collection one at a time.
+func importCollection[T any](files map[string]*zip.File, restored *ArchiveManifest, name string, save func(*T) error) error {
+	records, err := readArchive[T](files[name+".jsonl"])
+	if err != nil {
+		return err
+	}
+	for _, record := range records {
+		if err := save(record); err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+	}
+	restored.Counts[name] = len(records)
+	return nil
+}
+
+// importBatches saves the records of a collection in bulk writes.
+func importBatches[T any](files map[string]*zip.File, restored *ArchiveManifest, name string, save func([]*T) error) error {
+	const batchSize = 500
+	records, err := readArchive[T](files[name+".jsonl"])
+	if err != nil {
+		return err
+	}
+	for start := 0; start < len(records); start += batchSize {
+		if err := save(records[start:min(start+batchSize, len(records))]); err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+	}
+	restored.Counts[name] = len(records)
+	return nil
+}
@@ -0,0 +1,185 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/rkapps/fin-tracker-backend-go/cmd/common/logger"
+	"github.com/rkapps/fin-tracker-backend-go/internal/domain"
+	"github.com/rkapps/fin-tracker-backend-go/internal/storage"
+	"github.com/rkapps/fin-tracker-backend-go/internal/storage/memory"
+	"github.com/shopspring/decimal"
+)
+
+func archiveFixtures(s storage.FinTrackerStorageService) {
+
+	ctx := context.Background()
+	date := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	s.SaveUser(ctx, &domain.User{ID: "u1", CurrencyCode: "EUR", LotMatchingMethod: domain.LotMatchingFIFO})
+	s.SaveAccount(ctx, &domain.Account{ID: "a1", UID: "u1", Name: "Wallet", Category: domain.CategoryCrypto, Type: domain.TypeHotWallet,
+		Detail: &domain.CryptoDetail{Blockchain: "ethereum", Address: "0xabc"}})
+	s.SaveAccountSyncState(ctx, &domain.AccountSyncState{ID: "a1", UID: "u1", SyncStatus: domain.SyncStatusSuccess})
+	s.SaveAccountCredential(ctx, &domain.AccountCredential{ID: "a1", UID: "u1", Provider: "coinbase", APIKey: "key", APISecret: "secret",
+		KeyVersion: "v1", WrappedKey: "wrapped", Label: "main", Active: true})
+	s.SaveActivityMappingRule(ctx, &domain.ActivityMappingRule{ID: "r1", UID: "u1", AccountID: "a1", Name: "fees", NotesPattern: "fee"})
+	s.SaveImportedActivities(ctx, []*domain.ActivityImport{{ID: "i1", UID: "u1", AccountID: "a1", TxnType: "Buy", Date: &date}})
+	buy := &domain.Activity{ID: "x1", UID: "u1", AccountID: "a1", Date: date, RcvSymbol: "ETH", RcvQuantity: decimal.RequireFromString("1.123456789012345678"),
+		SentAccountID: domain.UnresolvedAccountID("u1")}
+	buy.SetDetail(domain.WalletActivityDetail{Hash: "0xhash", GasPrice: decimal.RequireFromString("0.000000021")})
+	s.SaveActivities(ctx, []*domain.Activity{buy, {ID: "x2", UID: "u1", AccountID: "a1", Date: date, LinkedActivityID: "x1"}})
+	s.SaveActivityLots(ctx, []*domain.ActivityLot{{ID: "l1", UID: "u1", AccountID: "a1", ActivityID: "x1", Qty: decimal.RequireFromString("1.123456789012345678")}})
+	s.SaveAccountSummaries(ctx, []*domain.AccountSummary{{ID: "s1", UID: "u1", AccountID: "a1", MarketValue: decimal.RequireFromString("3000.5")}})
+	s.ImportTransactions(ctx, "u1", date, date.AddDate(0, 1, 0), []*domain.Transaction{
+		{Date: date, Group: "Home", Category: "Groceries", Description: "Market", Amount: 12.5},
+		{Date: date.AddDate(0, 0, 10), Group: "Home", Category: "Rent", Description: "Rent", Amount: 1000},
+	})
+	// another user's records are not exported
+	s.SaveAccount(ctx, &domain.Account{ID: "a2", UID: "u2", Category: domain.CategoryCash, Detail: &domain.BankDetail{}})
+}
+
+func TestArchiveRoundTrip(t *testing.T) {
+
+	ctx := context.Background()
+	source := memory.NewFinTrackerMemoryStorage()
+	archiveFixtures(source)
+
+	var buf bytes.Buffer
+	manifest, err := NewArchiveService(logger.New(), source).Export(ctx, "u1", &buf)
+	if err != nil {
+		t.Fatalf("Export() error = %v", err)
+	}
+	if manifest.Version != ArchiveVersion || manifest.Counts[ArchiveAccounts] != 1 || manifest.Counts[ArchiveActivities] != 2 || manifest.Counts[ArchiveTransactions] != 2 {
+		t.Fatalf("Export() manifest = %+v", manifest)
+	}
+	if bytes.Contains(buf.Bytes(), []byte("secret")) {
+		t.Fatalf("Export() archive contains a credential secret")
+	}
+
+	// restore into an empty store, twice
+	target := memory.NewFinTrackerMemoryStorage()
+	archive := NewArchiveService(logger.New(), target)
+	for i := 0; i < 2; i++ {
+		if _, err := archive.Import(ctx, bytes.NewReader(buf.Bytes()), int64(buf.Len()), ImportOptions{}); err != nil {
+			t.Fatalf("Import() %d error = %v", i, err)
+		}
+	}
+	user, err := target.GetUser(ctx, "u1")
+	if err != nil || user.CurrencyCode != "EUR" {
+		t.Errorf("GetUser() = %+v, %v", user, err)
+	}
+	acct, err := target.GetAccount(ctx, "u1", "a1")
+	if err != nil {
+		t.Fatalf("GetAccount() error = %v", err)
+	}
+	if detail, ok := acct.Detail.(*domain.CryptoDetail); !ok || detail.Address != "0xabc" {
+		t.Errorf("GetAccount().Detail = %T %+v", acct.Detail, acct.Detail)
+	}
+	acred, err := target.GetAccountCredential(ctx, "u1", "a1")
+	if err != nil || acred.Active || len(acred.APIKey) > 0 || len(acred.WrappedKey) > 0 || acred.Label != "main" {
+		t.Errorf("GetAccountCredential() = %+v, %v, want inactive metadata", acred, err)
+	}
+	actvs, _ := target.GetActivities(ctx, "u1")
+	if len(actvs) != 2 {
+		t.Fatalf("GetActivities() = %d, want 2", len(actvs))
+	}
+	for _, actv := range actvs {
+		if actv.ID != "x1" {
+			continue
+		}
+		detail, ok := actv.Detail.(domain.WalletActivityDetail)
+		if !ok || !detail.GasPrice.Equal(decimal.RequireFromString("0.000000021")) || !actv.RcvQuantity.Equal(decimal.RequireFromString("1.123456789012345678")) {
+			t.Errorf("activity x1 = %+v", actv)
+		}
+	}
+	txns, _ := target.SearchTransactions(ctx, "u1", allTransactionsFrom, allTransactionsTo, "")
+	if len(txns) != 2 {
+		t.Errorf("SearchTransactions() after two imports = %d, want 2", len(txns))
+	}
+	if imports, _ := target.GetImortedActivities(ctx, "u1", "a1"); len(imports) != 1 {
+		t.Errorf("GetImortedActivities() = %d, want 1", len(imports))
+	}
+	if accts, _ := target.GetAccounts(ctx, "u2"); len(accts) != 0 {
+		t.Errorf("GetAccounts(u2) = %d, want none", len(accts))
+	}
+}
+
+func TestArchiveClone(t *testing.T) {
+
+	ctx := context.Background()
+	s := memory.NewFinTrackerMemoryStorage()
+	archiveFixtures(s)
+	archive := NewArchiveService(logger.New(), s)
+
+	var buf bytes.Buffer
+	if _, err := archive.Export(ctx, "u1", &buf); err != nil {
+		t.Fatalf("Export() error = %v", err)
+	}
+	for i := 0; i < 2; i++ {
+		if _, err := archive.Import(ctx, bytes.NewReader(buf.Bytes()), int64(buf.Len()), ImportOptions{UID: "test"}); err != nil {
+			t.Fatalf("Import() %d error = %v", i, err)
+		}
+	}
+
+	// the original user is untouched
+	if acct, err := s.GetAccount(ctx, "u1", "a1"); err != nil || acct.UID != "u1" {
+		t.Fatalf("GetAccount(u1, a1) = %+v, %v", acct, err)
+	}
+	if acred, _ := s.GetAccountCredential(ctx, "u1", "a1"); acred == nil || !acred.Active || acred.APIKey != "key" {
+		t.Errorf("original credential = %+v, want it untouched", acred)
+	}
+
+	accts, _ := s.GetAccounts(ctx, "test")
+	if len(accts) != 1 || accts[0].ID == "a1" {
+		t.Fatalf("GetAccounts(test) = %v, want one remapped account", accts)
+	}
+	acctId := accts[0].ID
+	if _, err := s.GetAccountSyncState(ctx, "test", acctId); err != nil {
+		t.Errorf("GetAccountSyncState(test) error = %v", err)
+	}
+	actvs, _ := s.GetActivitiesForAccount(ctx, "test", acctId)
+	if len(actvs) != 2 {
+		t.Fatalf("GetActivitiesForAccount(test) = %d, want 2", len(actvs))
+	}
+	ids := map[string]*domain.Activity{}
+	for _, actv := range actvs {
+		ids[actv.ID] = actv
+	}
+	for _, actv := range actvs {
+		if len(actv.LinkedActivityID) > 0 && ids[actv.LinkedActivityID] == nil {
+			t.Errorf("LinkedActivityID %s does not point at the cloned activity", actv.LinkedActivityID)
+		}
+		if len(actv.SentAccountID) > 0 && actv.SentAccountID != domain.UnresolvedAccountID("test") {
+			t.Errorf("SentAccountID = %s, want the clone's unresolved account", actv.SentAccountID)
+		}
+	}
+	lots, _ := s.GetActivityLotsForAccount(ctx, "test", acctId)
+	if len(lots) != 1 || ids[lots[0].ActivityID] == nil {
+		t.Errorf("GetActivityLotsForAccount(test) = %v, want the lot of the cloned activity", lots)
+	}
+	if rules, _ := s.GetActivityMappingRules(ctx, "test"); len(rules) != 1 || rules[0].AccountID != acctId {
+		t.Errorf("GetActivityMappingRules(test) = %v", rules)
+	}
+	if txns, _ := s.SearchTransactions(ctx, "u1", allTransactionsFrom, allTransactionsTo, ""); len(txns) != 2 {
+		t.Errorf("original transactions = %d, want 2", len(txns))
+	}
+}
+
+func TestArchiveVersion(t *testing.T) {
+
+	ctx := context.Background()
+	archive := NewArchiveService(logger.New(), memory.NewFinTrackerMemoryStorage())
+	if _, err := archive.Import(ctx, bytes.NewReader([]byte("not a zip")), 9, ImportOptions{}); err == nil {
+		t.Errorf("Import(not a zip) error = nil")
+	}
+	var buf bytes.Buffer
+	archive.Export(ctx, "u1", &buf)
+	if _, err := archive.Import(ctx, bytes.NewReader(buf.Bytes()), int64(buf.Len()), ImportOptions{}); err != nil {
+		t.Errorf("Import(empty user) error = %v", err)
+	}
+	if _, err := archive.storage.GetUser(ctx, "u1"); !errors.Is(err, storage.ErrNotFound) {
+		t.Errorf("GetUser() after importing no user error = %v, want ErrNotFound", err)
+	}
+}
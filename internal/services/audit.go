@@ -0,0 +1,120 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/rkapps/fin-tracker-backend-go/cmd/common/logger"
+	"github.com/rkapps/fin-tracker-backend-go/internal/domain"
+	"github.com/rkapps/fin-tracker-backend-go/internal/storage"
+)
+
+const (
+	defaultAuditEntriesLimit = 50
+	maxAuditEntriesLimit     = 500
+
+	auditEntityAccount     = "account"
+	auditEntityUser        = "user"
+	auditEntityTransaction = "transaction"
+)
+
+// fields left out of the diff — they change on every save.
+var auditIgnoredFields = []string{"updatedAt"}
+
+// auditor appends the audit trail of the mutating service calls. The actor and
+// request id come from the context set by the API handlers. Recording is best
+// effort — the change has been made, so a save error is logged and not returned.
+type auditor struct {
+	storage storage.FinTrackerStorageService
+	logger  *logger.Logger
+}
+
+func newAuditor(logConfig *logger.Config, storage storage.FinTrackerStorageService) auditor {
+	return auditor{storage: storage, logger: logConfig.For("audit")}
+}
+
+func (a auditor) record(ctx context.Context, uid string, entityType string, entityId string, acctId string, operation string, changes []domain.AuditChange) {
+	entry := &domain.AuditEntry{
+		ID:         uuid.New().String(),
+		UID:        uid,
+		ActorUID:   domain.ActorFromCtx(ctx),
+		EntityType: entityType,
+		EntityID:   entityId,
+		AccountID:  acctId,
+		Operation:  operation,
+		Changes:    changes,
+		RequestID:  domain.RequestIDFromCtx(ctx),
+		Time:       time.Now().UTC(),
+	}
+	if err := a.storage.SaveAuditEntry(context.WithoutCancel(ctx), entry); err != nil {
+		a.logger.Error("Record", "UID", uid, "Entity", entityType, "EntityId", entityId, "Operation", operation, "Error", err)
+	}
+}
+
+// entries returns the user's most recent entries, for one account when acctId is set.
+func (a auditor) entries(ctx context.Context, uid string, acctId string, limit int64) ([]*domain.AuditEntry, error) {
+	if limit <= 0 {
+		limit = defaultAuditEntriesLimit
+	}
+	limit = min(limit, maxAuditEntriesLimit)
+	return a.storage.GetAuditEntries(ctx, uid, acctId, limit)
+}
+
+// auditChanges returns the top level JSON fields that differ between before and
+// after, sorted by field. A nil before is a create and a nil after a delete.
+func auditChanges(before any, after any) []domain.AuditChange {
+	bfields := auditFields(before)
+	afields := auditFields(after)
+	names := map[string]bool{}
+	for name := range bfields {
+		names[name] = true
+	}
+	for name := range afields {
+		names[name] = true
+	}
+	for _, name := range auditIgnoredFields {
+		delete(names, name)
+	}
+
+	changes := []domain.AuditChange{}
+	for name := range names {
+		if bytes.Equal(bfields[name], afields[name]) {
+			continue
+		}
+		changes = append(changes, domain.AuditChange{Field: name, Before: bfields[name], After: afields[name]})
+	}
+	sort.Slice(changes, func(i, j int) bool {
+		return changes[i].Field < changes[j].Field
+	})
+	return changes
+}
+
+func auditFields(value any) map[string]json.RawMessage {
+	fields := map[string]json.RawMessage{}
+	if value == nil {
+		return fields
+	}
+	data, err := json.Marshal(value)
+	if err != nil {
+		return fields
+	}
+	json.Unmarshal(data, &fields)
+	return fields
+}
+
+// auditChange records a value that is not a field of the entity, e.g the number of
+// records an import replaced. A nil value is left out.
+func auditChange(field string, before any, after any) domain.AuditChange {
+	change := domain.AuditChange{Field: field}
+	if before != nil {
+		change.Before, _ = json.Marshal(before)
+	}
+	if after != nil {
+		change.After, _ = json.Marshal(after)
+	}
+	return change
+}
@@ -0,0 +1,55 @@
+package services
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/rkapps/fin-tracker-backend-go/cmd/common/logger"
+	"github.com/rkapps/fin-tracker-backend-go/internal/domain"
+	"github.com/rkapps/fin-tracker-backend-go/internal/storage/memory"
+)
+
+func TestAccountAudit(t *testing.T) {
+
+	s := memory.NewFinTrackerMemoryStorage()
+	ctx := domain.ContextWithRequestID(domain.ContextWithActor(context.Background(), "u1"), "r1")
+	accounts := NewAccountsService(logger.New(), s)
+
+	acct, err := accounts.CreateAccount(ctx, "u1", &domain.Account{Name: "Brokerage", Category: domain.CategoryCash,
+		Detail: &domain.BankDetail{}, LotMatchingMethod: domain.LotMatchingFIFO})
+	if err != nil {
+		t.Fatalf("CreateAccount() error = %v", err)
+	}
+	acct.LotMatchingMethod = domain.LotMatchingHIFO
+	if err := accounts.UpdateAccount(ctx, "u1", acct.ID, acct); err != nil {
+		t.Fatalf("UpdateAccount() error = %v", err)
+	}
+	date := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	if err := accounts.ImportActivities(ctx, "u1", acct.ID, date, []*domain.ActivityImport{{TxnType: "Buy", Date: &date}}); err != nil {
+		t.Fatalf("ImportActivities() error = %v", err)
+	}
+
+	entries, err := accounts.GetAuditEntries(ctx, "u1", acct.ID, 0)
+	if err != nil || len(entries) != 3 {
+		t.Fatalf("GetAuditEntries() = %d, %v, want 3", len(entries), err)
+	}
+	for i, operation := range []string{domain.AuditImport, domain.AuditUpdate, domain.AuditCreate} {
+		entry := entries[i]
+		if entry.Operation != operation || entry.ActorUID != "u1" || entry.RequestID != "r1" || entry.EntityID != acct.ID {
+			t.Errorf("entry %d = %+v, want %s by u1 in r1", i, entry, operation)
+		}
+	}
+	update := entries[1].Changes
+	if len(update) != 1 || update[0].Field != "lotMatchingMethod" || string(update[0].Before) != `"fifo"` || string(update[0].After) != `"hifo"` {
+		t.Errorf("update changes = %+v, want only lotMatchingMethod fifo to hifo", update)
+	}
+	if imported := entries[0].Changes; len(imported) != 2 || imported[0].Field != "activities" || string(imported[0].After) != "1" {
+		t.Errorf("import changes = %+v, want one imported activity", imported)
+	}
+
+	// another user's entries are not returned
+	if entries, _ := accounts.GetAuditEntries(ctx, "u2", acct.ID, 0); len(entries) != 0 {
+		t.Errorf("GetAuditEntries(u2) = %d, want none", len(entries))
+	}
+}
@@ -0,0 +1,281 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/rkapps/fin-tracker-backend-go/cmd/common/logger"
+	"github.com/rkapps/fin-tracker-backend-go/internal/domain"
+	"github.com/rkapps/fin-tracker-backend-go/internal/dto"
+	"github.com/rkapps/fin-tracker-backend-go/internal/portfolio/refresher"
+	"github.com/rkapps/fin-tracker-backend-go/internal/secrets"
+	"github.com/rkapps/fin-tracker-backend-go/internal/storage"
+)
+
+type CredentialsService struct {
+	storage   storage.FinTrackerStorageService
+	cipher    *secrets.Cipher // nil when no master key is configured
+	logConfig *logger.Config
+	logger    *logger.Logger
+}
+
+func NewCredentialsService(logConfig *logger.Config, storage storage.FinTrackerStorageService, cipher *secrets.Cipher) CredentialsService {
+	clog := logConfig.For("credentials")
+	return CredentialsService{storage: storage, cipher: cipher, logConfig: logConfig, logger: clog}
+}
+
+// GetCredential returns the credential metadata — secrets are never returned.
+func (s CredentialsService) GetCredential(ctx context.Context, uid string, acctId string) (*dto.CredentialView, error) {
+	acred, err := s.storage.GetAccountCredential(ctx, uid, acctId)
+	if err != nil || acred == nil {
+		return nil, lookupError("credential", acctId, err)
+	}
+	return s.view(acred)
+}
+
+// SaveCredential encrypts and saves the account's credential, replacing any existing one.
+func (s CredentialsService) SaveCredential(ctx context.Context, uid string, acctId string, input dto.CredentialInput) (*dto.CredentialView, error) {
+
+	if s.cipher == nil {
+		return nil, secrets.ErrNoMasterKey
+	}
+	acct, err := s.exchangeAccount(ctx, uid, acctId)
+	if err != nil {
+		return nil, err
+	}
+	if len(input.APIKey) == 0 {
+		return nil, fmt.Errorf("apiKey required")
+	}
+	if len(input.Permissions) == 0 {
+		input.Permissions = []string{domain.PermissionRead}
+	}
+	if err := domain.ValidatePermissions(input.Permissions); err != nil {
+		return nil, err
+	}
+	now := time.Now().UTC()
+	if input.ExpiresAt != nil && !input.ExpiresAt.After(now) {
+		return nil, fmt.Errorf("expiresAt must be in the future")
+	}
+
+	acred := &domain.AccountCredential{ID: acctId, UID: uid, APIKey: input.APIKey, APISecret: input.APISecret, Passphrase: input.Passphrase}
+	acred.Provider = acct.Detail.(*domain.CryptoDetail).Exchange
+	acred.Label = input.Label
+	if len(acred.Label) == 0 {
+		acred.Label = acct.Name
+	}
+	acred.Permissions = input.Permissions
+	acred.ExpiresAt = input.ExpiresAt
+	acred.CreatedAt = now
+	acred.Active = true
+	env, err := s.cipher.Seal(acred.ID, acred.SecretFields())
+	if err != nil {
+		return nil, err
+	}
+	acred.KeyVersion = env.KeyVersion
+	acred.WrappedKey = env.WrappedKey
+
+	s.logger.Info("SaveCredential", "AccountId", acctId, "KeyVersion", acred.KeyVersion)
+	if err := s.storage.SaveAccountCredential(ctx, acred); err != nil {
+		return nil, err
+	}
+	return s.view(acred)
+}
+
+// TestCredential decrypts the credential and checks it against the provider.
+func (s CredentialsService) TestCredential(ctx context.Context, uid string, acctId string) (*dto.CredentialTestResult, error) {
+
+	acct, err := s.exchangeAccount(ctx, uid, acctId)
+	if err != nil {
+		return nil, err
+	}
+	creds, err := s.decrypt(ctx, uid, acctId)
+	if err != nil {
+		return nil, err
+	}
+
+	provider := acct.Detail.(*domain.CryptoDetail).Exchange
+	permissions, err := refresher.TestCredentials(ctx, provider, *acct, creds, s.logConfig)
+	if err != nil {
+		s.logger.Info("TestCredential", "AccountId", acctId, "Error", err)
+		return &dto.CredentialTestResult{OK: false, Message: err.Error()}, nil
+	}
+
+	// the provider knows what the key really grants — deactivate keys that can trade
+	if len(permissions) > 0 {
+		acred, err := s.storage.GetAccountCredential(ctx, uid, acctId)
+		if err != nil || acred == nil {
+			return nil, lookupError("credential", acctId, err)
+		}
+		acred.Permissions = permissions
+		perr := domain.ValidatePermissions(permissions)
+		if perr != nil {
+			acred.Active = false
+		}
+		if err := s.storage.SaveAccountCredential(ctx, acred); err != nil {
+			return nil, err
+		}
+		if perr != nil {
+			s.logger.Warn("TestCredential", "AccountId", acctId, "Error", perr)
+			return &dto.CredentialTestResult{OK: false, Message: perr.Error()}, nil
+		}
+	}
+	return &dto.CredentialTestResult{OK: true}, nil
+}
+
+// GetCredentials returns the metadata of all the user's credentials with expiry warnings.
+func (s CredentialsService) GetCredentials(ctx context.Context, uid string) ([]*dto.CredentialView, error) {
+	acreds, err := s.storage.GetAccountCredentials(ctx, uid)
+	if err != nil {
+		return nil, err
+	}
+	views := []*dto.CredentialView{}
+	for _, acred := range acreds {
+		view, err := s.view(acred)
+		if err != nil {
+			return nil, err
+		}
+		views = append(views, view)
+	}
+	return views, nil
+}
+
+// RevokeCredential deactivates the credential and wipes its secrets.
+// The metadata is kept so the user can see what was revoked.
+func (s CredentialsService) RevokeCredential(ctx context.Context, uid string, acctId string) error {
+	acred, err := s.storage.GetAccountCredential(ctx, uid, acctId)
+	if err != nil || acred == nil {
+		return lookupError("credential", acctId, err)
+	}
+	s.logger.Info("RevokeCredential", "AccountId", acctId)
+	acred.Active = false
+	acred.APIKey = ""
+	acred.APISecret = ""
+	acred.Passphrase = ""
+	acred.KeyVersion = ""
+	acred.WrappedKey = ""
+	return s.storage.SaveAccountCredential(ctx, acred)
+}
+
+// ReencryptCredentials rewraps every credential with the current master key
+// version and encrypts credentials saved in plain text.
+func (s CredentialsService) ReencryptCredentials(ctx context.Context) (int, error) {
+
+	if s.cipher == nil {
+		return 0, secrets.ErrNoMasterKey
+	}
+	users, err := s.storage.GetUsers(ctx)
+	if err != nil {
+		return 0, err
+	}
+	count := 0
+	for _, user := range users {
+		if err := ctx.Err(); err != nil {
+			return count, err
+		}
+		acreds, err := s.storage.GetAccountCredentials(ctx, user.ID)
+		if err != nil {
+			return count, err
+		}
+		for _, acred := range acreds {
+			changed := false
+			if len(acred.APIKey) == 0 {
+				// revoked
+				continue
+			}
+			if acred.Encrypted() {
+				env := secrets.Envelope{KeyVersion: acred.KeyVersion, WrappedKey: acred.WrappedKey}
+				env, changed, err = s.cipher.Rewrap(env)
+				if err != nil {
+					return count, fmt.Errorf("credential %s: %v", acred.ID, err)
+				}
+				acred.KeyVersion = env.KeyVersion
+				acred.WrappedKey = env.WrappedKey
+			} else {
+				// saved before encryption was enabled
+				env, err := s.cipher.Seal(acred.ID, acred.SecretFields())
+				if err != nil {
+					return count, fmt.Errorf("credential %s: %v", acred.ID, err)
+				}
+				acred.KeyVersion = env.KeyVersion
+				acred.WrappedKey = env.WrappedKey
+				changed = true
+			}
+			if !changed {
+				continue
+			}
+			if err := s.storage.SaveAccountCredential(ctx, acred); err != nil {
+				return count, err
+			}
+			count++
+		}
+	}
+	s.logger.Info("ReencryptCredentials", "Updated", count, "KeyVersion", s.cipher.CurrentVersion())
+	return count, nil
+}
+
+func (s CredentialsService) decrypt(ctx context.Context, uid string, acctId string) (refresher.Credentials, error) {
+
+	if s.cipher == nil {
+		return refresher.Credentials{}, secrets.ErrNoMasterKey
+	}
+	acred, err := s.storage.GetAccountCredential(ctx, uid, acctId)
+	if err != nil || acred == nil {
+		return refresher.Credentials{}, lookupError("credential", acctId, err)
+	}
+	if !acred.Encrypted() {
+		return refresher.Credentials{}, fmt.Errorf("credential not encrypted: %s", acctId)
+	}
+	env := secrets.Envelope{KeyVersion: acred.KeyVersion, WrappedKey: acred.WrappedKey}
+	if err := s.cipher.Open(env, acred.ID, acred.SecretFields()); err != nil {
+		return refresher.Credentials{}, err
+	}
+	return refresher.Credentials{APIKey: acred.APIKey, APISecret: acred.APISecret, Passphrase: acred.Passphrase}, nil
+}
+
+// exchangeAccount returns the account if it is an exchange account of the user.
+func (s CredentialsService) exchangeAccount(ctx context.Context, uid string, acctId string) (*domain.Account, error) {
+	acct, err := s.storage.GetAccount(ctx, uid, acctId)
+	if err != nil || acct == nil {
+		return nil, lookupError("account", acctId, err)
+	}
+	detail, ok := acct.Detail.(*domain.CryptoDetail)
+	if acct.Type != domain.TypeExchange || !ok || len(detail.Exchange) == 0 {
+		return nil, fmt.Errorf("credentials are only supported for exchange accounts")
+	}
+	return acct, nil
+}
+
+// view describes the credential using the decrypted api key hint.
+func (s CredentialsService) view(acred *domain.AccountCredential) (*dto.CredentialView, error) {
+
+	view := &dto.CredentialView{
+		AccountID:     acred.ID,
+		Provider:      acred.Provider,
+		Label:         acred.Label,
+		HasSecret:     len(acred.APISecret) > 0,
+		HasPassphrase: len(acred.Passphrase) > 0,
+		KeyVersion:    acred.KeyVersion,
+		Permissions:   acred.Permissions,
+		ExpiresAt:     acred.ExpiresAt,
+		CreatedAt:     acred.CreatedAt,
+		LastUsed:      acred.LastUsed,
+		Active:        acred.Active,
+		Warning:       acred.ExpiryWarning(time.Now()),
+	}
+	opened := *acred
+	if acred.Encrypted() {
+		if s.cipher == nil {
+			return view, nil
+		}
+		env := secrets.Envelope{KeyVersion: acred.KeyVersion, WrappedKey: acred.WrappedKey}
+		if err := s.cipher.Open(env, opened.ID, opened.SecretFields()); err != nil {
+			return view, nil
+		}
+	}
+	apiKey := opened.APIKey
+	if len(apiKey) > 4 {
+		view.APIKeyHint = "…" + apiKey[len(apiKey)-4:]
+	}
+	return view, nil
+}
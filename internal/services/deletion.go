@@ -0,0 +1,409 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/rkapps/fin-tracker-backend-go/cmd/common/logger"
+	"github.com/rkapps/fin-tracker-backend-go/internal/domain"
+	"github.com/rkapps/fin-tracker-backend-go/internal/locks"
+	"github.com/rkapps/fin-tracker-backend-go/internal/storage"
+)
+
+// DeletionService deletes an account or a user with everything that belongs to them.
+// A deletion runs in the background holding the user's lock, so it never interleaves
+// with a sync, refresh or import, and its outcome is kept in a receipt. Deleting is
+// idempotent — a deletion that failed or was interrupted completes when requested again.
+// GL entries are deleted with their activities by the stores that keep them (Postgres).
+type DeletionService struct {
+	storage storage.FinTrackerStorageService
+	locker  locks.Locker
+	audit   auditor
+	running *sync.WaitGroup
+	logger  *logger.Logger
+}
+
+func NewDeletionService(logConfig *logger.Config, storage storage.FinTrackerStorageService) DeletionService {
+	locker := locks.NewLocker(storage, locks.NewOwner(), locks.DefaultTTL, logConfig)
+	return DeletionService{storage: storage, locker: locker, audit: newAuditor(logConfig, storage), running: &sync.WaitGroup{}, logger: logConfig.For("deletion")}
+}
+
+// DeleteAccount starts deleting the account, its sync state, credential, mapping rules,
+// imports, activities, lots and summaries. The user's other accounts are flagged for
+// refresh as transfers with the account change. Returns ErrAlreadyRunning when a
+// sync or refresh holds the user's lock.
+func (s DeletionService) DeleteAccount(ctx context.Context, uid string, acctId string) (*domain.DeletionReceipt, error) {
+	acct, err := s.storage.GetAccount(ctx, uid, acctId)
+	if err != nil || acct == nil {
+		return nil, lookupError("account", acctId, err)
+	}
+	receipt := newDeletionReceipt(uid, domain.DeletionScopeAccount, acctId)
+	started, err := s.start(ctx, receipt, func(ctx context.Context, counts map[string]int) error {
+		if err := s.deleteAccount(ctx, uid, acctId, counts); err != nil {
+			return err
+		}
+		return s.flagRefresh(ctx, uid)
+	})
+	if err != nil {
+		return nil, err
+	}
+	// the receipt has the outcome of the deletion
+	changes := append(auditChanges(acct, nil), auditChange("receipt", nil, receipt.ID))
+	s.audit.record(ctx, uid, auditEntityAccount, acctId, acctId, domain.AuditDelete, changes)
+	return started, nil
+}
+
+// DeleteUser starts deleting the user with all their accounts, records and transactions.
+// Returns ErrAlreadyRunning when a sync or refresh holds the user's lock.
+func (s DeletionService) DeleteUser(ctx context.Context, uid string) (*domain.DeletionReceipt, error) {
+	// the previous values are only needed for the audit trail
+	user, _ := s.storage.GetUser(ctx, uid)
+	receipt := newDeletionReceipt(uid, domain.DeletionScopeUser, "")
+	started, err := s.start(ctx, receipt, func(ctx context.Context, counts map[string]int) error {
+		return s.deleteUser(ctx, uid, counts)
+	})
+	if err != nil {
+		return nil, err
+	}
+	// the entry outlives the user, the receipt has the outcome of the deletion
+	changes := append(auditChanges(user, nil), auditChange("receipt", nil, receipt.ID))
+	s.audit.record(ctx, uid, auditEntityUser, uid, "", domain.AuditDelete, changes)
+	return started, nil
+}
+
+// GetReceipt returns the user's deletion receipt — it is kept after the user is deleted.
+func (s DeletionService) GetReceipt(ctx context.Context, uid string, id string) (*domain.DeletionReceipt, error) {
+	receipt, err := s.storage.GetDeletionReceipt(ctx, id)
+	if err != nil || receipt == nil {
+		return nil, lookupError("deletion receipt", id, err)
+	}
+	if receipt.UID != uid {
+		return nil, lookupError("deletion receipt", id, storage.UnauthorizedError(id))
+	}
+	return receipt, nil
+}
+
+// Wait blocks until the running deletions finish e.g before the process exits.
+func (s DeletionService) Wait() {
+	s.running.Wait()
+}
+
+// FailInterrupted marks the running receipts of deletions that no process holds the
+// user's lock for as failed — their process stopped before finishing, e.g it was killed.
+// Requesting the deletion again completes it. Returns the number of receipts failed.
+func (s DeletionService) FailInterrupted(ctx context.Context) (int, error) {
+
+	receipts, err := s.storage.GetDeletionReceiptsByStatus(ctx, domain.DeletionRunning)
+	if err != nil {
+		return 0, err
+	}
+	now := time.Now().UTC()
+	failed := 0
+	for _, receipt := range receipts {
+		// a deletion still running in another replica keeps its lease alive
+		lock, err := s.storage.GetUserLock(ctx, receipt.UID)
+		if err != nil {
+			return failed, err
+		}
+		if lock != nil && !lock.Expired(now) {
+			continue
+		}
+		s.finish(ctx, receipt, receipt.Counts, errors.New("deletion interrupted, request it again"))
+		failed++
+	}
+	return failed, nil
+}
+
+func newDeletionReceipt(uid string, scope string, acctId string) *domain.DeletionReceipt {
+	return &domain.DeletionReceipt{
+		ID:        uuid.New().String(),
+		UID:       uid,
+		Scope:     scope,
+		AccountID: acctId,
+		Requested: time.Now().UTC(),
+		Status:    domain.DeletionRunning,
+		Counts:    map[string]int{},
+	}
+}
+
+// start takes the user's lock, saves the running receipt and runs fn in the background.
+// The deletion outlives the request, so it does not stop when ctx is cancelled.
+func (s DeletionService) start(ctx context.Context, receipt *domain.DeletionReceipt, fn func(ctx context.Context, counts map[string]int) error) (*domain.DeletionReceipt, error) {
+
+	ctx = context.WithoutCancel(ctx)
+	lease, err := s.locker.Acquire(ctx, receipt.UID, "delete")
+	if err != nil {
+		return nil, err
+	}
+	if err := s.storage.SaveDeletionReceipt(ctx, receipt); err != nil {
+		lease.Release()
+		return nil, err
+	}
+	s.logger.Info("Start", "Id", receipt.ID, "UID", receipt.UID, "Scope", receipt.Scope, "AccountId", receipt.AccountID)
+	started := *receipt
+
+	s.running.Add(1)
+	go func() {
+		defer s.running.Done()
+		counts := map[string]int{}
+		err := fn(lease.Context(), counts)
+		if err != nil && lease.Lost() {
+			err = fmt.Errorf("delete lease lost for user %s: %w", receipt.UID, err)
+		}
+		lease.Release()
+		s.finish(ctx, receipt, counts, err)
+	}()
+	return &started, nil
+}
+
+// finish saves the outcome of the deletion — the counts are kept when it failed part way.
+func (s DeletionService) finish(ctx context.Context, receipt *domain.DeletionReceipt, counts map[string]int, err error) {
+	finished := time.Now().UTC()
+	receipt.Finished = &finished
+	receipt.Counts = counts
+	receipt.Status = domain.DeletionSuccess
+	if err != nil {
+		receipt.Status = domain.DeletionFailed
+		receipt.Error = err.Error()
+	}
+	if serr := s.storage.SaveDeletionReceipt(ctx, receipt); serr != nil {
+		s.logger.Error("Finish", "Id", receipt.ID, "UID", receipt.UID, "Error", serr)
+		return
+	}
+	s.logger.Info("Finish", "Id", receipt.ID, "UID", receipt.UID, "Status", receipt.Status, "Counts", receipt.Counts, "Error", receipt.Error)
+}
+
+// deleteAccount deletes the account last, so a failed deletion can be requested again.
+func (s DeletionService) deleteAccount(ctx context.Context, uid string, acctId string, counts map[string]int) error {
+
+	imports, err := s.storage.GetImortedActivities(ctx, uid, acctId)
+	if err != nil {
+		return err
+	}
+	if err := deleteRecords(ctx, imports, s.storage.DeleteImortedActivities, counts, domain.ACTIVITY_IMPORT_COLLECTION_NAME); err != nil {
+		return err
+	}
+	actvs, err := s.storage.GetActivitiesForAccount(ctx, uid, acctId)
+	if err != nil {
+		return err
+	}
+	if err := deleteRecords(ctx, actvs, s.storage.DeleteActivities, counts, domain.ACTIVITY_COLLECTION_NAME); err != nil {
+		return err
+	}
+	lots, err := s.storage.GetActivityLotsForAccount(ctx, uid, acctId)
+	if err != nil {
+		return err
+	}
+	if err := deleteRecords(ctx, lots, s.storage.DeleteActivityLots, counts, domain.ACTIVITY_LOT_COLLECTION_NAME); err != nil {
+		return err
+	}
+	asumys, err := s.storage.GetAccountSummaries(ctx, uid)
+	if err != nil {
+		return err
+	}
+	acctSumys := []*domain.AccountSummary{}
+	for _, asum := range asumys {
+		if asum.AccountID == acctId {
+			acctSumys = append(acctSumys, asum)
+		}
+	}
+	if err := deleteRecords(ctx, acctSumys, s.storage.DeleteAccountSummaries, counts, domain.ACCOUNT_SUMMARY_COLLECTION_NAME); err != nil {
+		return err
+	}
+	values, err := s.storage.GetAccountValues(ctx, uid, time.Time{}, time.Time{})
+	if err != nil {
+		return err
+	}
+	acctValues := []*domain.AccountValue{}
+	for _, value := range values {
+		if value.AccountID == acctId {
+			acctValues = append(acctValues, value)
+		}
+	}
+	if err := deleteRecords(ctx, acctValues, s.storage.DeleteAccountValues, counts, domain.ACCOUNT_VALUE_COLLECTION_NAME); err != nil {
+		return err
+	}
+
+	rules, err := s.storage.GetActivityMappingRules(ctx, uid)
+	if err != nil {
+		return err
+	}
+	for _, rule := range rules {
+		if rule.AccountID != acctId {
+			continue
+		}
+		if err := s.storage.DeleteActivityMappingRule(ctx, uid, rule.ID); err != nil {
+			return err
+		}
+		counts[domain.ACTIVITY_MAPPING_RULE_COLLECTION_NAME]++
+	}
+	if err := s.storage.DeleteAccountCredential(ctx, uid, acctId); err == nil {
+		counts[domain.ACCOUNT_CREDENTIAL_COLLECTION_NAME]++
+	} else if !errors.Is(err, storage.ErrNotFound) {
+		return err
+	}
+	if _, err := s.storage.GetAccountSyncState(ctx, uid, acctId); err == nil {
+		if err := s.storage.DeleteAccountSyncState(ctx, uid, acctId); err != nil {
+			return err
+		}
+		counts[domain.ACCOUNT_SYNC_STATE_COLLECTION_NAME]++
+	} else if !errors.Is(err, storage.ErrNotFound) {
+		return err
+	}
+	if err := s.storage.DeleteAccount(ctx, uid, acctId); err != nil {
+		return err
+	}
+	counts[domain.ACCOUNT_COLLECTION_NAME]++
+	s.logger.Info("DeleteAccount", "UID", uid, "AccountId", acctId)
+	return nil
+}
+
+// deleteUser deletes every account of the user and then the user's remaining records,
+// which includes those left behind by accounts deleted before deletes cascaded.
+func (s DeletionService) deleteUser(ctx context.Context, uid string, counts map[string]int) error {
+
+	accts, err := s.storage.GetAccounts(ctx, uid)
+	if err != nil {
+		return err
+	}
+	for _, acct := range accts {
+		if err := s.deleteAccount(ctx, uid, acct.ID, counts); err != nil {
+			return err
+		}
+	}
+
+	// imports are only found by account — look for them under every account id still referenced
+	acctIds := map[string]bool{}
+	actvs, err := s.storage.GetActivities(ctx, uid)
+	if err != nil {
+		return err
+	}
+	for _, actv := range actvs {
+		acctIds[actv.AccountID] = true
+	}
+	astates, err := s.storage.GetAccountSyncStates(ctx, uid)
+	if err != nil {
+		return err
+	}
+	for _, astate := range astates {
+		acctIds[astate.ID] = true
+	}
+	for acctId := range acctIds {
+		imports, err := s.storage.GetImortedActivities(ctx, uid, acctId)
+		if err != nil {
+			return err
+		}
+		if err := deleteRecords(ctx, imports, s.storage.DeleteImortedActivities, counts, domain.ACTIVITY_IMPORT_COLLECTION_NAME); err != nil {
+			return err
+		}
+	}
+	if err := deleteRecords(ctx, actvs, s.storage.DeleteActivities, counts, domain.ACTIVITY_COLLECTION_NAME); err != nil {
+		return err
+	}
+	lots, err := s.storage.GetActivityLots(ctx, uid)
+	if err != nil {
+		return err
+	}
+	if err := deleteRecords(ctx, lots, s.storage.DeleteActivityLots, counts, domain.ACTIVITY_LOT_COLLECTION_NAME); err != nil {
+		return err
+	}
+	asumys, err := s.storage.GetAccountSummaries(ctx, uid)
+	if err != nil {
+		return err
+	}
+	if err := deleteRecords(ctx, asumys, s.storage.DeleteAccountSummaries, counts, domain.ACCOUNT_SUMMARY_COLLECTION_NAME); err != nil {
+		return err
+	}
+	values, err := s.storage.GetAccountValues(ctx, uid, time.Time{}, time.Time{})
+	if err != nil {
+		return err
+	}
+	if err := deleteRecords(ctx, values, s.storage.DeleteAccountValues, counts, domain.ACCOUNT_VALUE_COLLECTION_NAME); err != nil {
+		return err
+	}
+	for _, astate := range astates {
+		if err := s.storage.DeleteAccountSyncState(ctx, uid, astate.ID); err != nil {
+			return err
+		}
+		counts[domain.ACCOUNT_SYNC_STATE_COLLECTION_NAME]++
+	}
+	acreds, err := s.storage.GetAccountCredentials(ctx, uid)
+	if err != nil {
+		return err
+	}
+	for _, acred := range acreds {
+		if err := s.storage.DeleteAccountCredential(ctx, uid, acred.ID); err != nil {
+			return err
+		}
+		counts[domain.ACCOUNT_CREDENTIAL_COLLECTION_NAME]++
+	}
+	rules, err := s.storage.GetActivityMappingRules(ctx, uid)
+	if err != nil {
+		return err
+	}
+	for _, rule := range rules {
+		if err := s.storage.DeleteActivityMappingRule(ctx, uid, rule.ID); err != nil {
+			return err
+		}
+		counts[domain.ACTIVITY_MAPPING_RULE_COLLECTION_NAME]++
+	}
+
+	txns, err := s.storage.SearchTransactions(ctx, uid, allTransactionsFrom, allTransactionsTo, "")
+	if err != nil {
+		return err
+	}
+	if len(txns) > 0 {
+		if err := s.storage.ImportTransactions(ctx, uid, allTransactionsFrom, allTransactionsTo, nil); err != nil {
+			return err
+		}
+		counts[domain.TRANSACTION_COLLECTION_NAME] += len(txns)
+	}
+
+	// the user record goes last
+	if _, err := s.storage.GetUser(ctx, uid); err == nil {
+		counts[domain.USER_COL]++
+	} else if !errors.Is(err, storage.ErrNotFound) {
+		return err
+	}
+	return s.storage.DeleteUser(ctx, uid)
+}
+
+// flagRefresh marks the user's remaining accounts so the next refresh recomputes them.
+func (s DeletionService) flagRefresh(ctx context.Context, uid string) error {
+	astates, err := s.storage.GetAccountSyncStates(ctx, uid)
+	if err != nil {
+		return err
+	}
+	for _, astate := range astates {
+		if astate.Refresh {
+			continue
+		}
+		astate.Refresh = true
+		if err := s.storage.SaveAccountSyncState(ctx, astate); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// deleteRecords deletes the records by id and counts them under the collection.
+func deleteRecords[T interface{ Id() string }](ctx context.Context, records []T, delete func(ctx context.Context, ids []string) error,
+	counts map[string]int, collection string) error {
+
+	if len(records) == 0 {
+		return nil
+	}
+	ids := make([]string, len(records))
+	for i, record := range records {
+		ids[i] = record.Id()
+	}
+	if err := delete(ctx, ids); err != nil {
+		return err
+	}
+	counts[collection] += len(ids)
+	return nil
+}
@@ -0,0 +1,174 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/rkapps/fin-tracker-backend-go/cmd/common/logger"
+	"github.com/rkapps/fin-tracker-backend-go/internal/domain"
+	"github.com/rkapps/fin-tracker-backend-go/internal/locks"
+	"github.com/rkapps/fin-tracker-backend-go/internal/storage"
+	"github.com/rkapps/fin-tracker-backend-go/internal/storage/memory"
+)
+
+func TestDeleteAccount(t *testing.T) {
+
+	ctx := context.Background()
+	s := memory.NewFinTrackerMemoryStorage()
+	archiveFixtures(s)
+	s.SaveAccount(ctx, &domain.Account{ID: "a3", UID: "u1", Category: domain.CategoryCash, Detail: &domain.BankDetail{}})
+	s.SaveAccountSyncState(ctx, &domain.AccountSyncState{ID: "a3", UID: "u1", SyncStatus: domain.SyncStatusSuccess})
+
+	deletion := NewDeletionService(logger.New(), s)
+	if _, err := deletion.DeleteAccount(ctx, "u2", "a1"); !errors.Is(err, storage.ErrUnauthorized) {
+		t.Fatalf("DeleteAccount() of another user's account error = %v, want ErrUnauthorized", err)
+	}
+	receipt, err := deletion.DeleteAccount(ctx, "u1", "a1")
+	if err != nil {
+		t.Fatalf("DeleteAccount() error = %v", err)
+	}
+	if receipt.Status != domain.DeletionRunning || receipt.Scope != domain.DeletionScopeAccount || receipt.AccountID != "a1" {
+		t.Errorf("DeleteAccount() receipt = %+v, want a running account deletion", receipt)
+	}
+	deletion.Wait()
+
+	receipt, err = deletion.GetReceipt(ctx, "u1", receipt.ID)
+	if err != nil || receipt.Status != domain.DeletionSuccess || receipt.Finished == nil {
+		t.Fatalf("GetReceipt() = %+v, %v, want success", receipt, err)
+	}
+	want := map[string]int{
+		domain.ACCOUNT_COLLECTION_NAME:               1,
+		domain.ACCOUNT_SYNC_STATE_COLLECTION_NAME:    1,
+		domain.ACCOUNT_CREDENTIAL_COLLECTION_NAME:    1,
+		domain.ACCOUNT_SUMMARY_COLLECTION_NAME:       1,
+		domain.ACTIVITY_IMPORT_COLLECTION_NAME:       1,
+		domain.ACTIVITY_COLLECTION_NAME:              2,
+		domain.ACTIVITY_LOT_COLLECTION_NAME:          1,
+		domain.ACTIVITY_MAPPING_RULE_COLLECTION_NAME: 1,
+	}
+	for collection, count := range want {
+		if receipt.Counts[collection] != count {
+			t.Errorf("receipt Counts[%s] = %d, want %d", collection, receipt.Counts[collection], count)
+		}
+	}
+
+	if _, err := s.GetAccount(ctx, "u1", "a1"); !errors.Is(err, storage.ErrNotFound) {
+		t.Errorf("GetAccount() after delete error = %v, want ErrNotFound", err)
+	}
+	if actvs, _ := s.GetActivities(ctx, "u1"); len(actvs) != 0 {
+		t.Errorf("GetActivities() after delete = %d, want 0", len(actvs))
+	}
+	if lots, _ := s.GetActivityLots(ctx, "u1"); len(lots) != 0 {
+		t.Errorf("GetActivityLots() after delete = %d, want 0", len(lots))
+	}
+	if acreds, _ := s.GetAccountCredentials(ctx, "u1"); len(acreds) != 0 {
+		t.Errorf("GetAccountCredentials() after delete = %d, want 0", len(acreds))
+	}
+	// the remaining account is refreshed, the user and their transactions are kept
+	if astate, err := s.GetAccountSyncState(ctx, "u1", "a3"); err != nil || !astate.Refresh {
+		t.Errorf("GetAccountSyncState(a3) = %+v, %v, want flagged for refresh", astate, err)
+	}
+	if txns, _ := s.SearchTransactions(ctx, "u1", allTransactionsFrom, allTransactionsTo, ""); len(txns) != 2 {
+		t.Errorf("SearchTransactions() after account delete = %d, want 2", len(txns))
+	}
+	if _, err := s.GetUser(ctx, "u1"); err != nil {
+		t.Errorf("GetUser() after account delete error = %v", err)
+	}
+}
+
+func TestDeleteUser(t *testing.T) {
+
+	ctx := context.Background()
+	s := memory.NewFinTrackerMemoryStorage()
+	archiveFixtures(s)
+	// left behind by an account deleted before deletes cascaded
+	date := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+	s.SaveActivities(ctx, []*domain.Activity{{ID: "x9", UID: "u1", AccountID: "gone", Date: date}})
+	s.SaveImportedActivities(ctx, []*domain.ActivityImport{{ID: "i9", UID: "u1", AccountID: "gone", Date: &date}})
+
+	deletion := NewDeletionService(logger.New(), s)
+
+	// a running sync holds the user's lock
+	lease, err := locks.NewLocker(s, "sync-owner", locks.DefaultTTL, logger.New()).Acquire(ctx, "u1", "sync")
+	if err != nil {
+		t.Fatalf("Acquire() error = %v", err)
+	}
+	if _, err := deletion.DeleteUser(ctx, "u1"); !errors.Is(err, locks.ErrAlreadyRunning) {
+		t.Fatalf("DeleteUser() while syncing error = %v, want ErrAlreadyRunning", err)
+	}
+	lease.Release()
+
+	receipt, err := deletion.DeleteUser(ctx, "u1")
+	if err != nil {
+		t.Fatalf("DeleteUser() error = %v", err)
+	}
+	deletion.Wait()
+
+	receipt, err = deletion.GetReceipt(ctx, "u1", receipt.ID)
+	if err != nil || receipt.Status != domain.DeletionSuccess {
+		t.Fatalf("GetReceipt() = %+v, %v, want success", receipt, err)
+	}
+	if receipt.Counts[domain.ACTIVITY_COLLECTION_NAME] != 3 || receipt.Counts[domain.ACTIVITY_IMPORT_COLLECTION_NAME] != 2 ||
+		receipt.Counts[domain.TRANSACTION_COLLECTION_NAME] != 2 || receipt.Counts[domain.USER_COL] != 1 {
+		t.Errorf("receipt Counts = %v", receipt.Counts)
+	}
+	if _, err := deletion.GetReceipt(ctx, "u2", receipt.ID); !errors.Is(err, storage.ErrUnauthorized) {
+		t.Errorf("GetReceipt() by another user error = %v, want ErrUnauthorized", err)
+	}
+
+	if _, err := s.GetUser(ctx, "u1"); !errors.Is(err, storage.ErrNotFound) {
+		t.Errorf("GetUser() after delete error = %v, want ErrNotFound", err)
+	}
+	if actvs, _ := s.GetActivities(ctx, "u1"); len(actvs) != 0 {
+		t.Errorf("GetActivities() after delete = %d, want 0", len(actvs))
+	}
+	if imports, _ := s.GetImortedActivities(ctx, "u1", "gone"); len(imports) != 0 {
+		t.Errorf("GetImortedActivities(gone) after delete = %d, want 0", len(imports))
+	}
+	if txns, _ := s.SearchTransactions(ctx, "u1", allTransactionsFrom, allTransactionsTo, ""); len(txns) != 0 {
+		t.Errorf("SearchTransactions() after delete = %d, want 0", len(txns))
+	}
+	if astates, _ := s.GetAccountSyncStates(ctx, "u1"); len(astates) != 0 {
+		t.Errorf("GetAccountSyncStates() after delete = %d, want 0", len(astates))
+	}
+	if lock, _ := s.GetUserLock(ctx, "u1"); lock != nil {
+		t.Errorf("GetUserLock() after delete = %+v, want released", lock)
+	}
+	if accts, _ := s.GetAccounts(ctx, "u2"); len(accts) != 1 {
+		t.Errorf("GetAccounts(u2) = %d, want the other user's account kept", len(accts))
+	}
+	// the deletion is audited and the entry is kept
+	entries, err := s.GetAuditEntries(ctx, "u1", "", 0)
+	if err != nil || len(entries) != 1 {
+		t.Fatalf("GetAuditEntries() = %d, %v, want 1", len(entries), err)
+	}
+	if entry := entries[0]; entry.EntityType != auditEntityUser || entry.EntityID != "u1" || entry.Operation != domain.AuditDelete {
+		t.Errorf("audit entry = %+v, want the user's delete", entry)
+	}
+}
+
+func TestFailInterruptedDeletions(t *testing.T) {
+
+	ctx := context.Background()
+	s := memory.NewFinTrackerMemoryStorage()
+	for _, uid := range []string{"u1", "u2"} {
+		s.SaveDeletionReceipt(ctx, &domain.DeletionReceipt{ID: "d-" + uid, UID: uid, Scope: domain.DeletionScopeUser,
+			Status: domain.DeletionRunning, Counts: map[string]int{domain.ACTIVITY_COLLECTION_NAME: 3}})
+	}
+	// u2 is still being deleted by another replica
+	s.CreateUserLock(ctx, &domain.UserLock{ID: "u2", Operation: "delete", Owner: "replica-2", ExpiresAt: time.Now().Add(time.Minute)})
+
+	failed, err := NewDeletionService(logger.New(), s).FailInterrupted(ctx)
+	if err != nil || failed != 1 {
+		t.Fatalf("FailInterrupted() = %d, %v, want 1", failed, err)
+	}
+	if receipt, _ := s.GetDeletionReceipt(ctx, "d-u1"); receipt.Status != domain.DeletionFailed || receipt.Finished == nil ||
+		len(receipt.Error) == 0 || receipt.Counts[domain.ACTIVITY_COLLECTION_NAME] != 3 {
+		t.Errorf("interrupted receipt = %+v, want failed with its counts", receipt)
+	}
+	if receipt, _ := s.GetDeletionReceipt(ctx, "d-u2"); receipt.Status != domain.DeletionRunning {
+		t.Errorf("locked receipt = %s, want running", receipt.Status)
+	}
+}
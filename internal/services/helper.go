@@ -1,18 +1,52 @@
 package services
 
 import (
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
 	"github.com/rkapps/fin-tracker-backend-go/internal/domain"
+	"github.com/rkapps/fin-tracker-backend-go/internal/storage"
 )
 
-func filterAccount(acctIdsm map[string]string, acct *domain.Account, group string, category string, acctIds []string) bool {
+// transactions are only searched within a range — these bounds cover every transaction.
+var (
+	allTransactionsFrom = time.Date(1900, 1, 1, 0, 0, 0, 0, time.UTC)
+	allTransactionsTo   = time.Date(9999, 12, 31, 0, 0, 0, 0, time.UTC)
+)
 
-	if len(acctIdsm) > 0 {
-		if _, ok := acctIdsm[acct.ID]; !ok {
-			return false
-		}
+// lookupError wraps the storage error of a record lookup so handlers can map
+// it to a status code. A missing record without an error is not found.
+func lookupError(what string, id string, err error) error {
+	if err == nil {
+		err = storage.NotFoundError(id)
 	}
+	return fmt.Errorf("get %s: %w", what, err)
+}
 
-	return true
+// filterAccountIds returns the ids of the accounts in acctIds that match the
+// category and type, for the storage queries. nil means every account and
+// false means no account matches.
+func filterAccountIds(accts []*domain.Account, category string, atype string, acctIds []string) ([]string, bool) {
+
+	if len(category) == 0 && len(atype) == 0 {
+		return acctIds, true
+	}
+	ids := []string{}
+	for _, acct := range accts {
+		if len(category) > 0 && !strings.EqualFold(string(acct.Category), category) {
+			continue
+		}
+		if len(atype) > 0 && !strings.EqualFold(string(acct.Type), atype) {
+			continue
+		}
+		if len(acctIds) > 0 && !slices.Contains(acctIds, acct.ID) {
+			continue
+		}
+		ids = append(ids, acct.ID)
+	}
+	return ids, len(ids) > 0
 }
 
 func filterBankAccount(acctIdsm map[string]*domain.Account, acctId string) bool {
@@ -0,0 +1,93 @@
+package services
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/rkapps/fin-tracker-backend-go/cmd/common/logger"
+	"github.com/rkapps/fin-tracker-backend-go/internal/domain"
+	"github.com/rkapps/fin-tracker-backend-go/internal/storage"
+)
+
+const (
+	defaultPipelineRunsLimit = 20
+	maxPipelineRunsLimit     = 200
+)
+
+type PipelineRunsService struct {
+	storage storage.FinTrackerStorageService
+	logger  *logger.Logger
+}
+
+func NewPipelineRunsService(logConfig *logger.Config, storage storage.FinTrackerStorageService) PipelineRunsService {
+	return PipelineRunsService{storage: storage, logger: logConfig.For("pipeline.runs")}
+}
+
+// StartRun saves a running entry so a run that never finishes (e.g killed) is still visible.
+// The history is best effort — a save error is logged and the run goes ahead.
+func (s PipelineRunsService) StartRun(ctx context.Context, command string, args []string, workers int) *domain.PipelineRun {
+	run := &domain.PipelineRun{
+		ID:      uuid.New().String(),
+		Command: command,
+		Args:    args,
+		Started: time.Now().UTC(),
+		Workers: workers,
+		Status:  domain.PipelineRunRunning,
+	}
+	if err := s.storage.SavePipelineRun(ctx, run); err != nil {
+		s.logger.Warn("StartRun", "Command", command, "Error", err)
+	}
+	return run
+}
+
+// FinishRun saves the final state of the run. err is the run error, if any,
+// when the run did not get as far as a summary. The run is saved even when ctx
+// was cancelled, so a drained or interrupted run is not left running.
+func (s PipelineRunsService) FinishRun(ctx context.Context, run *domain.PipelineRun, err error) {
+	if run.Finished == nil {
+		finished := time.Now().UTC()
+		run.Finished = &finished
+	}
+	if err != nil {
+		run.Error = err.Error()
+		if run.Status == domain.PipelineRunRunning || run.Status == domain.PipelineRunSuccess {
+			run.Status = domain.PipelineRunFailed
+		}
+	}
+	if run.Status == domain.PipelineRunRunning {
+		run.Status = domain.PipelineRunSuccess
+	}
+	if serr := s.storage.SavePipelineRun(context.WithoutCancel(ctx), run); serr != nil {
+		s.logger.Warn("FinishRun", "Id", run.ID, "Command", run.Command, "Error", serr)
+		return
+	}
+	s.logger.Info("FinishRun", "Id", run.ID, "Command", run.Command, "Status", run.Status, "Duration", run.Duration())
+}
+
+// GetRuns returns the most recent runs first, optionally for one command.
+func (s PipelineRunsService) GetRuns(ctx context.Context, command string, limit int64) ([]*domain.PipelineRun, error) {
+	if limit <= 0 {
+		limit = defaultPipelineRunsLimit
+	}
+	if limit > maxPipelineRunsLimit {
+		limit = maxPipelineRunsLimit
+	}
+	runs, err := s.storage.GetPipelineRuns(ctx, command, limit)
+	if err != nil {
+		return nil, err
+	}
+	// the list leaves out the per-user outcomes, GetRun has them
+	for _, run := range runs {
+		run.Outcomes = nil
+	}
+	return runs, nil
+}
+
+func (s PipelineRunsService) GetRun(ctx context.Context, id string) (*domain.PipelineRun, error) {
+	run, err := s.storage.GetPipelineRun(ctx, id)
+	if err != nil || run == nil {
+		return nil, lookupError("pipeline run", id, err)
+	}
+	return run, nil
+}
@@ -3,199 +3,217 @@ package services
 import (
 	"context"
 	"fmt"
+	"slices"
 	"time"
 
 	"github.com/rkapps/fin-tracker-backend-go/cmd/common/logger"
 	"github.com/rkapps/fin-tracker-backend-go/internal/domain"
 	"github.com/rkapps/fin-tracker-backend-go/internal/dto"
+	"github.com/rkapps/fin-tracker-backend-go/internal/locks"
 	"github.com/rkapps/fin-tracker-backend-go/internal/portfolio"
+	"github.com/rkapps/fin-tracker-backend-go/internal/secrets"
 	"github.com/rkapps/fin-tracker-backend-go/internal/storage"
-	"github.com/rkapps/fin-tracker-backend-go/internal/utils"
 	"github.com/shopspring/decimal"
 )
 
 type PortfolioService struct {
 	tickersService TickersService
 	storage        storage.FinTrackerStorageService
+	cipher         *secrets.Cipher
+	locker         locks.Locker // one sync or refresh per user at a time
 	logConfig      *logger.Config
 	logger         *logger.Logger
 }
 
-func NewPortfolioService(logConfig *logger.Config, tickersService TickersService, storage storage.FinTrackerStorageService) PortfolioService {
+func NewPortfolioService(logConfig *logger.Config, tickersService TickersService, storage storage.FinTrackerStorageService, cipher *secrets.Cipher) PortfolioService {
 	plog := logConfig.For("portfolio.service")
-	return PortfolioService{storage: storage, logConfig: logConfig, logger: plog}
+	locker := locks.NewLocker(storage, locks.NewOwner(), locks.DefaultTTL, logConfig)
+	return PortfolioService{tickersService: tickersService, storage: storage, cipher: cipher, locker: locker, logConfig: logConfig, logger: plog}
 }
 
-func (p PortfolioService) GetSummary(uid string) ([]*domain.AccountSummary, error) {
-	return p.storage.GetAccountSummaries(uid)
+// GetSummary returns the account summaries of the most recent refresh.
+func (p PortfolioService) GetSummary(ctx context.Context, uid string) ([]*domain.AccountSummary, error) {
+	return p.storage.GetLatestAccountSummaries(ctx, uid)
 }
 
-func (p PortfolioService) GetHoldings(uid string, category string, atype string, acctIds []string) ([]*domain.HoldingSummary, error) {
+// GetSummaryHistory returns the daily snapshots of the accounts that match the category,
+// type and ids between the dates, per account and in total. The total of a day adds up
+// the snapshots of that day.
+func (p PortfolioService) GetSummaryHistory(ctx context.Context, uid string, category string, atype string,
+	acctIds []string, startDate time.Time, endDate time.Time) (*dto.SummaryHistory, error) {
+
+	history := &dto.SummaryHistory{Total: []dto.SummaryPoint{}, Accounts: []*dto.SummarySeries{}}
+	accts, err := p.storage.GetAccounts(ctx, uid)
+	if err != nil {
+		return nil, fmt.Errorf("error getting accounts: %w", err)
+	}
+	ids, ok := filterAccountIds(accts, category, atype, acctIds)
+	if !ok {
+		return history, nil
+	}
+
+	asumys, err := p.storage.GetAccountSummariesByDate(ctx, uid, startDate, endDate)
+	if err != nil {
+		return nil, fmt.Errorf("error getting account summaries: %w", err)
+	}
+
+	seriesm := make(map[string]*dto.SummarySeries)
+	for _, asum := range asumys {
+		if len(ids) > 0 && !slices.Contains(ids, asum.AccountID) {
+			continue
+		}
+		addHistoryPoint(history, seriesm, asum.AccountID, asum.AccountName, dto.SummaryPoint{
+			Date:        domain.SnapshotDate(asum.Date),
+			MarketValue: asum.MarketValue,
+			CostValue:   asum.CostValue,
+			Cash:        asum.Cash,
+			NetDeposits: asum.NetDeposits,
+			Income:      asum.Income,
+		})
+	}
+	return history, nil
+}
+
+// GetValueHistory returns the backfilled daily values of the accounts that match the
+// category, type and ids between the dates, in the same shape as GetSummaryHistory.
+func (p PortfolioService) GetValueHistory(ctx context.Context, uid string, category string, atype string,
+	acctIds []string, startDate time.Time, endDate time.Time) (*dto.SummaryHistory, error) {
+
+	history := &dto.SummaryHistory{Total: []dto.SummaryPoint{}, Accounts: []*dto.SummarySeries{}}
+	accts, err := p.storage.GetAccounts(ctx, uid)
+	if err != nil {
+		return nil, fmt.Errorf("error getting accounts: %w", err)
+	}
+	ids, ok := filterAccountIds(accts, category, atype, acctIds)
+	if !ok {
+		return history, nil
+	}
+	names := make(map[string]string)
+	for _, acct := range accts {
+		names[acct.ID] = acct.Name
+	}
+
+	values, err := p.storage.GetAccountValues(ctx, uid, startDate, endDate)
+	if err != nil {
+		return nil, fmt.Errorf("error getting account values: %w", err)
+	}
+
+	seriesm := make(map[string]*dto.SummarySeries)
+	for _, value := range values {
+		if len(ids) > 0 && !slices.Contains(ids, value.AccountID) {
+			continue
+		}
+		addHistoryPoint(history, seriesm, value.AccountID, names[value.AccountID], dto.SummaryPoint{
+			Date:        domain.SnapshotDate(value.Date),
+			MarketValue: value.MarketValue,
+			CostValue:   value.CostValue,
+			Cash:        value.Cash,
+			NetDeposits: value.NetDeposits,
+			Income:      value.Income,
+		})
+	}
+	return history, nil
+}
+
+// addHistoryPoint appends the point to the account's series and adds it to the total
+// of its day. The points must come oldest first.
+func addHistoryPoint(history *dto.SummaryHistory, seriesm map[string]*dto.SummarySeries, acctId string, acctName string, point dto.SummaryPoint) {
+	series := seriesm[acctId]
+	if series == nil {
+		series = &dto.SummarySeries{AccountID: acctId, AccountName: acctName}
+		seriesm[acctId] = series
+		history.Accounts = append(history.Accounts, series)
+	}
+	series.Points = append(series.Points, point)
+
+	// a new day is always the last point
+	if n := len(history.Total); n > 0 && history.Total[n-1].Date.Equal(point.Date) {
+		history.Total[n-1].Add(point)
+	} else {
+		history.Total = append(history.Total, point)
+	}
+}
+
+func (p PortfolioService) GetHoldings(ctx context.Context, uid string, category string, atype string, acctIds []string) ([]*domain.HoldingSummary, error) {
 
 	hldgs := []*domain.HoldingSummary{}
-	var err error
-	accts, err := p.storage.GetAccounts(uid)
+	accts, err := p.storage.GetAccounts(ctx, uid)
 	if err != nil {
+		return nil, fmt.Errorf("error getting accounts: %w", err)
+	}
+	ids, ok := filterAccountIds(accts, category, atype, acctIds)
+	if !ok {
 		return hldgs, nil
 	}
 
-	lots, err := p.storage.GetActivityLots(uid)
+	lots, err := p.storage.QueryActivityLots(ctx, uid, domain.LotQuery{AccountIDs: ids, Statuses: []domain.LotStatus{domain.LotStatusOpen}})
 	if err != nil {
-		return hldgs, nil
+		return nil, err
 	}
 
-	return portfolio.GetHoldings(p.tickersService.storage, p.logger, false, accts, acctIds, lots)
-
-	// portfolio := portfolio.NewPortfolio(p.storage, p.logConfig, p.logger)
-
-	// hldgs := []*dto.HoldingSummary{}
-	// hldgsm := make(map[string]*dto.HoldingSummary)
-
-	// acctIdsm := make(map[string]string)
-	// for _, acctId := range acctIds {
-	// 	acctIdsm[acctId] = acctId
-	// }
-
-	// accts, err := p.storage.GetAccounts(uid)
-	// if err != nil {
-	// 	return hldgs, nil
-	// }
-	// acctsm := make(map[string]*domain.Account)
-	// for _, acct := range accts {
-	// 	acctsm[acct.ID] = acct
-	// }
-
-	// lots, err := p.storage.GetActivityLots(uid)
-	// if err != nil {
-	// 	return hldgs, nil
-	// }
-
-	// // get tickermap
-	// tm := GetTickersMapforLots(p.storage, lots)
-
-	// for _, lot := range lots {
-	// 	if lot.Status != domain.LotStatusOpen {
-	// 		continue
-	// 	}
-	// 	acct := acctsm[lot.AccountID]
-	// 	if acct == nil {
-	// 		p.logger.Error("GetHoldings - Account not found", "AccountId", lot.AccountID, "LotId", lot.ID)
-	// 		// log.Println(lot)
-	// 		continue
-	// 	}
-
-	// 	filter := filterBankAccount(acctsm, lot.AccountID)
-	// 	if !filter {
-	// 		continue
-	// 	}
-	// 	filter = filterAccount(acctIdsm, acct, category, atype, acctIds)
-	// 	if !filter {
-	// 		continue
-	// 	}
-
-	// 	key := fmt.Sprintf("%s-%s-%s-%s-%s", acct.Category, acct.Type, acct.Name, lot.AccountID, lot.Symbol)
-	// 	p.logger.Debug("GetHoldings", "Key", key, "Lot", lot.Qty)
-
-	// 	h := hldgsm[key]
-
-	// 	zero := decimal.NewFromFloat(0.0)
-	// 	if h == nil {
-	// 		h = &dto.HoldingSummary{}
-	// 		h.Category = string(acct.Category)
-	// 		h.Type = string(acct.Type)
-	// 		h.AccountName = acct.Name
-	// 		h.Acct_ID = lot.AccountID
-	// 		h.Symbol = lot.Symbol
-	// 		h.Qty = zero
-	// 		h.Cost = zero
-	// 		h.CostValue = zero
-	// 		h.MktValue = zero
-	// 		hldgs = append(hldgs, h)
-	// 		hldgsm[key] = h
-	// 	}
-	// 	h.Cost = lot.Cost
-	// 	h.Qty = h.Qty.Add(lot.Qty)
-	// 	h.CostValue = h.CostValue.Add(lot.CostValue)
-	// 	if !h.Qty.IsZero() {
-	// 		h.Cost = h.CostValue.Div(h.Qty)
-	// 	}
-
-	// 	ticker := tm[lot.Symbol]
-	// 	if len(ticker.Symbol) == 0 {
-	// 		ticker = GetTickerPriceDiff(tm, lot.Symbol)
-	// 		tm[lot.Symbol] = ticker
-	// 	}
-
-	// 	h.PrLast = ticker.PrLast
-	// 	h.PrDiffAmt = ticker.PrDiffAmt
-	// 	h.PrDiffPerc = ticker.PrDiffPerc
-	// 	h.MktValue = h.MktValue.Add(lot.Qty.Mul(ticker.PrLast))
-	// 	h.Dglamount = h.Dglamount.Add(lot.Qty.Mul(ticker.PrDiffAmt))
-	// 	h.Glamount = h.MktValue.Sub(h.CostValue)
-	// 	if !h.CostValue.IsZero() {
-	// 		h.Glperc = h.Glamount.Mul(decimal.NewFromFloat(100.0)).Div(h.CostValue)
-	// 	}
-
-	// 	p.logger.Trace("GetHoldings", "Holding", h.Qty)
-
-	// }
-
-	// p.logger.Info("GetHoldings", "Holdings", len(hldgs))
-
-	// return hldgs, nil
+	return portfolio.GetHoldings(ctx, p.tickersService.storage, p.logger, false, accts, acctIds, lots.Items)
 }
 
-func (p PortfolioService) GetActivities(uid string, category string, atype string,
-	acctIds []string, startDate time.Time, endDate time.Time) ([]dto.ActivityResponse, error) {
+// GetLots returns one page of the lots of the accounts that match the category, type and query.
+func (p PortfolioService) GetLots(ctx context.Context, uid string, category string, atype string, query domain.LotQuery) (domain.Paged[*domain.ActivityLot], error) {
 
-	ractvs := []dto.ActivityResponse{}
-
-	acctIdsm := make(map[string]string)
-	for _, acctId := range acctIds {
-		acctIdsm[acctId] = acctId
+	accts, err := p.storage.GetAccounts(ctx, uid)
+	if err != nil {
+		return domain.Paged[*domain.ActivityLot]{}, fmt.Errorf("error getting accounts: %w", err)
 	}
+	ids, ok := filterAccountIds(accts, category, atype, query.AccountIDs)
+	if !ok {
+		return domain.Paged[*domain.ActivityLot]{Items: []*domain.ActivityLot{}}, nil
+	}
+	query.AccountIDs = ids
+	return p.storage.QueryActivityLots(ctx, uid, query)
+}
+
+// GetActivities returns one page of the activities of the accounts that match the category, type and query.
+func (p PortfolioService) GetActivities(ctx context.Context, uid string, category string, atype string,
+	query domain.ActivityQuery) (domain.Paged[dto.ActivityResponse], error) {
 
-	accts, err := p.storage.GetAccounts(uid)
+	paged := domain.Paged[dto.ActivityResponse]{Items: []dto.ActivityResponse{}}
+
+	accts, err := p.storage.GetAccounts(ctx, uid)
 	if err != nil {
-		return ractvs, nil
+		return paged, fmt.Errorf("error getting accounts: %w", err)
 	}
 	acctsm := make(map[string]*domain.Account)
 	for _, acct := range accts {
 		acctsm[acct.ID] = acct
 	}
+	ids, ok := filterAccountIds(accts, category, atype, query.AccountIDs)
+	if !ok {
+		return paged, nil
+	}
+	query.AccountIDs = ids
 
-	actvs, err := p.storage.GetActivities(uid)
+	actvs, err := p.storage.QueryActivities(ctx, uid, query)
 	if err != nil {
-		return nil, err
+		return paged, err
 	}
+	paged.NextCursor = actvs.NextCursor
 
-	var filter bool
-	for _, actv := range actvs {
+	for _, actv := range actvs.Items {
 
 		acct := acctsm[actv.AccountID]
 		if acct == nil {
-			p.logger.Error("GetHoldings - Account not found", "AccountId", actv.AccountID, "AcvitityId", actv.ID)
-			// log.Println(lot)
+			p.logger.Error("GetActivities - Account not found", "AccountId", actv.AccountID, "AcvitityId", actv.ID)
 			continue
 		}
 		p.logger.Debug("GetActivities", "Actv", actv.Debug(), "Date", actv.Date)
 
-		filter = utils.IsDateBetween(startDate, endDate, actv.Date)
-		if !filter {
-			continue
-		}
-		filter = filterAccount(acctIdsm, acct, category, atype, acctIds)
-		if !filter {
-			continue
-		}
 		ractv := dto.NewActivityResponseFromActivity(*acct, *actv)
 		ractv.Value = actv.Value
 		// ractv.RcvAccount = actv.RcvAccount
 		// ractv.SentAccount = actv.SentAccount
 		ractv.RcvBalance = actv.RcvBalance
 		ractv.SentBalance = actv.SentBalance
-		if actv.TxnType == domain.ActivityTypeDividend || actv.TxnType == domain.ActivityTypeInterest {
-			ractv.Notes = fmt.Sprintf("For %s", actv.SentSymbol)
+		if actv.IsIncome() {
+			ractv.Notes = fmt.Sprintf("For %s", actv.UnderlyingSymbol())
+		} else if actv.IsReinvestment() {
+			ractv.Notes = fmt.Sprintf("Reinvested dividend for %s", actv.RcvSymbol)
 		}
 
 		acct = acctsm[actv.RcvAccountID]
@@ -207,61 +225,47 @@ func (p PortfolioService) GetActivities(uid string, category string, atype strin
 			ractv.SentAccount = acct.Name
 		}
 
-		ractvs = append(ractvs, ractv)
+		paged.Items = append(paged.Items, ractv)
 	}
 
-	p.logger.Debug("GetActivities", "Actvs", len(ractvs))
+	p.logger.Debug("GetActivities", "Actvs", len(paged.Items))
 
-	return ractvs, nil
+	return paged, nil
 }
 
-func (p PortfolioService) GetIncome(uid string, category string, atype string,
+func (p PortfolioService) GetIncome(ctx context.Context, uid string, category string, atype string,
 	acctIds []string, startDate time.Time, endDate time.Time) ([]dto.Income, error) {
 
-	acctIdsm := make(map[string]string)
-	for _, acctId := range acctIds {
-		acctIdsm[acctId] = acctId
-	}
-
-	accts, err := p.storage.GetAccounts(uid)
+	incomes := []dto.Income{}
+	accts, err := p.storage.GetAccounts(ctx, uid)
 	if err != nil {
-		return nil, fmt.Errorf("accounts not found")
+		return nil, fmt.Errorf("error getting accounts: %w", err)
 	}
 	acctsm := make(map[string]*domain.Account)
 	for _, acct := range accts {
 		acctsm[acct.ID] = acct
 	}
+	ids, ok := filterAccountIds(accts, category, atype, acctIds)
+	if !ok {
+		return incomes, nil
+	}
 
-	actvs, err := p.storage.GetActivities(uid)
+	actvs, err := p.storage.QueryActivities(ctx, uid, domain.ActivityQuery{
+		AccountIDs: ids,
+		StartDate:  startDate,
+		EndDate:    endDate,
+		TxnTypes:   []domain.ActivityType{domain.ActivityTypeDividend, domain.ActivityTypeInterest},
+		Page:       domain.Page{Sort: domain.SortDate},
+	})
 	if err != nil {
-		return nil, fmt.Errorf("activites error")
+		return nil, fmt.Errorf("error getting income activities: %w", err)
 	}
-	var filter bool
-	incomes := []dto.Income{}
-
-	for _, actv := range actvs {
 
-		if !actv.IsIncome() {
-			continue
-		}
+	for _, actv := range actvs.Items {
 
 		acct := acctsm[actv.RcvAccountID]
 		if acct == nil {
-			p.logger.Error("GetHoldings - Account not found", "AccountId", actv.AccountID, "AcvitityId", actv.ID)
-			// log.Println(lot)
-			continue
-		}
-
-		filter = utils.IsDateBetween(startDate, endDate, actv.Date)
-		if !filter {
-			continue
-		}
-		filter = filterAccount(acctIdsm, acct, category, atype, acctIds)
-		if !filter {
-			continue
-		}
-		filter = filterAccount(acctIdsm, acct, category, atype, acctIds)
-		if !filter {
+			p.logger.Error("GetIncome - Account not found", "AccountId", actv.AccountID, "AcvitityId", actv.ID)
 			continue
 		}
 
@@ -275,7 +279,7 @@ func (p PortfolioService) GetIncome(uid string, category string, atype string,
 		income.CostValue = actv.SentAmount
 		income.Cost = income.CostValue.Div(income.Qty)
 		if actv.TxnType == domain.ActivityTypeDividend || actv.TxnType == domain.ActivityTypeInterest {
-			income.Symbol = actv.SentSymbol
+			income.Symbol = actv.UnderlyingSymbol()
 			income.Qty = decimal.NewFromFloat(1.0)
 			income.Cost = actv.RcvAmount
 			income.CostValue = actv.RcvAmount
@@ -288,11 +292,50 @@ func (p PortfolioService) GetIncome(uid string, category string, atype string,
 
 func (p PortfolioService) RefreshUserAccounts(ctx context.Context, uid string, simulate bool) error {
 	p.logger.Info("RefreshAccounts", "UID", uid, "Simulate", simulate)
-	portfolio := portfolio.NewPortfolio(p.storage, p.tickersService.storage, p.logConfig, p.logger)
-	return portfolio.RefreshUserAccounts(ctx, uid, simulate)
+	portfolio := portfolio.NewPortfolio(p.storage, p.tickersService.storage, p.cipher, p.logConfig, p.logger)
+	return p.locker.WithLock(ctx, uid, "refresh", func(ctx context.Context) error {
+		return portfolio.RefreshUserAccounts(ctx, uid, simulate)
+	})
 }
-func (p PortfolioService) SyncUserAccounts(ctx context.Context, uid string) error {
+
+// HasAccounts returns true when the user has an account in one of the categories.
+func (p PortfolioService) HasAccounts(ctx context.Context, uid string, categories []domain.AccountCategory) (bool, error) {
+	accts, err := p.storage.GetAccounts(ctx, uid)
+	if err != nil {
+		return false, err
+	}
+	for _, acct := range accts {
+		if slices.Contains(categories, acct.Category) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// NeedsRefresh returns true when a sync or import flagged one of the user's accounts.
+func (p PortfolioService) NeedsRefresh(ctx context.Context, uid string) (bool, error) {
+	portfolio := portfolio.NewPortfolio(p.storage, p.tickersService.storage, p.cipher, p.logConfig, p.logger)
+	return portfolio.NeedsRefresh(ctx, uid)
+}
+
+// BackfillValues rebuilds the user's daily account values from their activities and the
+// ticker history. Returns the number of values saved.
+func (p PortfolioService) BackfillValues(ctx context.Context, uid string) (int, error) {
+	p.logger.Info("BackfillValues", "UID", uid)
+	portfolio := portfolio.NewPortfolio(p.storage, p.tickersService.storage, p.cipher, p.logConfig, p.logger)
+	count := 0
+	err := p.locker.WithLock(ctx, uid, "backfill", func(ctx context.Context) error {
+		var err error
+		count, err = portfolio.BackfillValues(ctx, uid)
+		return err
+	})
+	return count, err
+}
+
+func (p PortfolioService) SyncUserAccounts(ctx context.Context, uid string, opts portfolio.SyncOptions) error {
 	p.logger.Trace("RefreshAccounts", "UID", uid)
-	portfolio := portfolio.NewPortfolio(p.storage, p.tickersService.storage, p.logConfig, p.logger)
-	return portfolio.SyncUserAccounts(ctx, uid)
+	portfolio := portfolio.NewPortfolio(p.storage, p.tickersService.storage, p.cipher, p.logConfig, p.logger)
+	return p.locker.WithLock(ctx, uid, "sync", func(ctx context.Context) error {
+		return portfolio.SyncUserAccounts(ctx, uid, opts)
+	})
 }
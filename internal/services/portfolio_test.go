@@ -0,0 +1,54 @@
+package services
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/rkapps/fin-tracker-backend-go/cmd/common/logger"
+	"github.com/rkapps/fin-tracker-backend-go/internal/domain"
+	"github.com/rkapps/fin-tracker-backend-go/internal/storage/memory"
+	"github.com/shopspring/decimal"
+)
+
+func TestGetSummaryHistory(t *testing.T) {
+
+	ctx := context.Background()
+	s := memory.NewFinTrackerMemoryStorage()
+	s.SaveAccount(ctx, &domain.Account{ID: "a1", UID: "u1", Name: "Brokerage", Category: domain.CategoryCash, Detail: &domain.BankDetail{}})
+	s.SaveAccount(ctx, &domain.Account{ID: "a2", UID: "u1", Name: "Wallet", Category: domain.CategoryCrypto, Detail: &domain.CryptoDetail{}})
+	asumys := []*domain.AccountSummary{}
+	for day := 1; day <= 3; day++ {
+		for i, acctId := range []string{"a1", "a2"} {
+			asum := &domain.AccountSummary{UID: "u1", AccountID: acctId, MarketValue: decimal.NewFromInt(int64(day * (i + 1))),
+				Cash: decimal.NewFromInt(1)}
+			asum.SetSnapshot(time.Date(2024, 1, day, 18, 0, 0, 0, time.UTC))
+			asumys = append(asumys, asum)
+		}
+	}
+	s.SaveAccountSummaries(ctx, asumys)
+
+	service := NewPortfolioService(logger.New(), NewStocksService(memory.NewTickerMemoryStorage()), s, nil)
+	history, err := service.GetSummaryHistory(ctx, "u1", "", "", nil, time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC), time.Time{})
+	if err != nil {
+		t.Fatalf("GetSummaryHistory() error = %v", err)
+	}
+	if len(history.Accounts) != 2 || len(history.Total) != 2 {
+		t.Fatalf("GetSummaryHistory() = %d accounts, %d days, want 2 and 2", len(history.Accounts), len(history.Total))
+	}
+	// day 3 is 3 + 6 market value and 2 cash
+	last := history.Total[1]
+	if !last.Date.Equal(time.Date(2024, 1, 3, 0, 0, 0, 0, time.UTC)) || !last.MarketValue.Equal(decimal.NewFromInt(9)) || !last.Cash.Equal(decimal.NewFromInt(2)) {
+		t.Errorf("GetSummaryHistory() total day 3 = %+v", last)
+	}
+
+	history, _ = service.GetSummaryHistory(ctx, "u1", string(domain.CategoryCrypto), "", nil, time.Time{}, time.Time{})
+	if len(history.Accounts) != 1 || history.Accounts[0].AccountID != "a2" || len(history.Total) != 3 || !history.Total[0].MarketValue.Equal(decimal.NewFromInt(2)) {
+		t.Errorf("GetSummaryHistory(crypto) = %+v, want the a2 series only", history)
+	}
+
+	latest, err := service.GetSummary(ctx, "u1")
+	if err != nil || len(latest) != 2 || !latest[0].Date.Equal(time.Date(2024, 1, 3, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("GetSummary() = %v, %v, want the 2 snapshots of day 3", latest, err)
+	}
+}
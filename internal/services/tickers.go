@@ -19,7 +19,7 @@ func NewStocksService(storage storage.TickerStorageService) TickersService {
 
 // DeleteTicker returns the ticker for the exchange:symbol
 func (t TickersService) DeleteTicker(ctx context.Context, id string) error {
-	return t.storage.DeleteTicker(id)
+	return t.storage.DeleteTicker(ctx, id)
 }
 
 // // getTickersByFilter returns all the tickers
@@ -30,34 +30,34 @@ func (t TickersService) DeleteTicker(ctx context.Context, id string) error {
 
 // GetTicker returns the ticker for the exchange:symbol
 func (t TickersService) GetTicker(ctx context.Context, id string) (*domain.Ticker, error) {
-	return t.storage.GetTicker(id)
+	return t.storage.GetTicker(ctx, id)
 }
 
 func (t TickersService) GetTickerGroups(ctx context.Context) (domain.TickerGroups, error) {
-	return t.storage.GetTickerGroups()
+	return t.storage.GetTickerGroups(ctx)
 }
 
 // GetTickerHistory returns the ticker history for the symbol
 func (t TickersService) GetTickerHistory(ctx context.Context, symbol string) ([]*domain.TickerHistory, error) {
-	return t.storage.GetTickerHistory(symbol)
+	return t.storage.GetTickerHistory(ctx, symbol)
 }
 
 // GetTickerSentiments returns the ticker sentiments for the symbol
 func (t TickersService) GetTickerSentiments(ctx context.Context, symbol string) ([]*domain.TickerSentiment, error) {
-	return t.storage.GetTickerSentiments(symbol)
+	return t.storage.GetTickerSentiments(ctx, symbol)
 }
 
 // GetTickerEmbeddings returns the ticker embeddings for the symbol
 func (t TickersService) GetTickerEmbeddings(ctx context.Context, symbol string) ([]*domain.TickerEmbedding, error) {
-	return t.storage.GetTickerEmbeddings(symbol)
+	return t.storage.GetTickerEmbeddings(ctx, symbol)
 }
 
 // GetTickers returns the tickers for the symbols
 func (t TickersService) GetTickers(ctx context.Context, symbols []string) (domain.Tickers, error) {
-	return t.storage.GetTickers(symbols)
+	return t.storage.GetTickers(ctx, symbols)
 }
 
 // SearchTicker search tickers based on input fields
 func (t TickersService) SearchTicker(ctx context.Context, ts domain.TickerSearch) (domain.Tickers, error) {
-	return t.storage.SearchTicker(ts)
+	return t.storage.SearchTicker(ctx, ts)
 }
@@ -1,28 +1,42 @@
 package services
 
 import (
+	"context"
 	"time"
 
+	"github.com/rkapps/fin-tracker-backend-go/cmd/common/logger"
 	"github.com/rkapps/fin-tracker-backend-go/internal/domain"
 	"github.com/rkapps/fin-tracker-backend-go/internal/storage"
 )
 
 type TransactionsService struct {
 	storage storage.FinTrackerStorageService
+	audit   auditor
 }
 
-func NewTransactionsService(storage storage.FinTrackerStorageService) TransactionsService {
-	return TransactionsService{storage: storage}
+func NewTransactionsService(logConfig *logger.Config, storage storage.FinTrackerStorageService) TransactionsService {
+	return TransactionsService{storage: storage, audit: newAuditor(logConfig, storage)}
 }
 
-func (s TransactionsService) SearchTransactions(uid string, startDate time.Time, endDate time.Time, searchText string) (domain.Transactions, error) {
-	return s.storage.SearchTransactions(uid, startDate, endDate, searchText)
+func (s TransactionsService) SearchTransactions(ctx context.Context, uid string, startDate time.Time, endDate time.Time, searchText string) (domain.Transactions, error) {
+	return s.storage.SearchTransactions(ctx, uid, startDate, endDate, searchText)
 }
 
-func (s TransactionsService) ImportTransactions(uid string, startDate time.Time, endDate time.Time, txns []*domain.Transaction) error {
-	return s.storage.ImportTransactions(uid, startDate, endDate, txns)
+// ImportTransactions replaces the user's transactions between startDate and endDate.
+func (s TransactionsService) ImportTransactions(ctx context.Context, uid string, startDate time.Time, endDate time.Time, txns []*domain.Transaction) error {
+	// the replaced count is only needed for the audit trail
+	replaced, _ := s.storage.SearchTransactions(ctx, uid, startDate, endDate, "")
+	if err := s.storage.ImportTransactions(ctx, uid, startDate, endDate, txns); err != nil {
+		return err
+	}
+	s.audit.record(ctx, uid, auditEntityTransaction, uid, "", domain.AuditImport, []domain.AuditChange{
+		auditChange("transactions", len(replaced), len(txns)),
+		auditChange("startDate", nil, startDate),
+		auditChange("endDate", nil, endDate),
+	})
+	return nil
 }
 
-func (s TransactionsService) SummaryTransactions(uid string, startDate time.Time, endDate time.Time) ([]domain.TransactionAgg, error) {
-	return s.storage.SummaryTransactions(uid, startDate, endDate)
+func (s TransactionsService) SummaryTransactions(ctx context.Context, uid string, startDate time.Time, endDate time.Time) ([]domain.TransactionAgg, error) {
+	return s.storage.SummaryTransactions(ctx, uid, startDate, endDate)
 }
@@ -1,27 +1,30 @@
 package services
 
 import (
+	"context"
+	"github.com/rkapps/fin-tracker-backend-go/cmd/common/logger"
 	"github.com/rkapps/fin-tracker-backend-go/internal/domain"
 	"github.com/rkapps/fin-tracker-backend-go/internal/storage"
 )
 
 type UserService struct {
 	storage storage.FinTrackerStorageService
+	audit   auditor
 }
 
-func NewUserService(storage storage.FinTrackerStorageService) UserService {
-	return UserService{storage: storage}
+func NewUserService(logConfig *logger.Config, storage storage.FinTrackerStorageService) UserService {
+	return UserService{storage: storage, audit: newAuditor(logConfig, storage)}
 }
 
-func (s UserService) GetUsers() []*domain.User {
-	return s.storage.GetUsers()
+func (s UserService) GetUsers(ctx context.Context) ([]*domain.User, error) {
+	return s.storage.GetUsers(ctx)
 }
 
-func (s UserService) GetUser(id string) (*domain.User, error) {
-	return s.storage.GetUser(id)
+func (s UserService) GetUser(ctx context.Context, id string) (*domain.User, error) {
+	return s.storage.GetUser(ctx, id)
 }
 
-func (s UserService) SaveUser(user *domain.User) error {
+func (s UserService) SaveUser(ctx context.Context, user *domain.User) error {
 
 	// apply defaults if not set
 	if user.LotMatchingMethod == "" {
@@ -30,5 +33,15 @@ func (s UserService) SaveUser(user *domain.User) error {
 	if user.CurrencyCode == "" {
 		user.CurrencyCode = "USD"
 	}
-	return s.storage.SaveUser(user)
+	// the previous values are only needed for the audit trail
+	before, _ := s.storage.GetUser(ctx, user.ID)
+	if err := s.storage.SaveUser(ctx, user); err != nil {
+		return err
+	}
+	operation := domain.AuditUpdate
+	if before == nil {
+		operation = domain.AuditCreate
+	}
+	s.audit.record(ctx, user.ID, auditEntityUser, user.ID, "", operation, auditChanges(before, user))
+	return nil
 }
@@ -0,0 +1,54 @@
+package storage
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"time"
+)
+
+// ErrInvalidCursor is returned when a page cursor was not issued by a previous page.
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// Cursor is the position after the last item of a page — its sort value and id.
+// Ties on the sort value are broken by id so pages neither skip nor repeat items.
+type Cursor struct {
+	Value string `json:"v"`
+	ID    string `json:"id"`
+}
+
+// DateCursor returns the cursor after an item sorted by date.
+func DateCursor(date time.Time, id string) string {
+	return Cursor{Value: date.UTC().Format(time.RFC3339Nano), ID: id}.Encode()
+}
+
+// Encode returns the opaque form handed to clients.
+func (c Cursor) Encode() string {
+	b, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// Date returns the sort value of a date cursor.
+func (c Cursor) Date() (time.Time, error) {
+	date, err := time.Parse(time.RFC3339Nano, c.Value)
+	if err != nil {
+		return time.Time{}, ErrInvalidCursor
+	}
+	return date, nil
+}
+
+// DecodeCursor parses a cursor from Encode. An empty cursor is the first page.
+func DecodeCursor(cursor string) (*Cursor, error) {
+	if len(cursor) == 0 {
+		return nil, nil
+	}
+	b, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	var c Cursor
+	if err := json.Unmarshal(b, &c); err != nil || len(c.ID) == 0 {
+		return nil, ErrInvalidCursor
+	}
+	return &c, nil
+}
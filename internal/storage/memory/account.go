@@ -0,0 +1,184 @@
+package memory
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/rkapps/fin-tracker-backend-go/internal/domain"
+	"github.com/rkapps/fin-tracker-backend-go/internal/storage"
+)
+
+func (s *FinTrackerMemoryStorage) GetAccount(ctx context.Context, uid string, id string) (*domain.Account, error) {
+	return read(ctx, &s.mu, func() (*domain.Account, error) {
+		acct, ok := s.accounts.get(id)
+		if !ok {
+			return nil, storage.NotFoundError(id)
+		}
+		if acct.UID != uid {
+			return nil, storage.UnauthorizedError(id)
+		}
+		return acct, nil
+	})
+}
+
+func (s *FinTrackerMemoryStorage) GetAccountSyncState(ctx context.Context, uid string, id string) (*domain.AccountSyncState, error) {
+	return read(ctx, &s.mu, func() (*domain.AccountSyncState, error) {
+		astate, ok := s.accountSyncStates.get(id)
+		if !ok {
+			return nil, storage.NotFoundError(id)
+		}
+		if astate.UID != uid {
+			return nil, storage.UnauthorizedError(id)
+		}
+		return astate, nil
+	})
+}
+
+func (s *FinTrackerMemoryStorage) GetAccountSyncStates(ctx context.Context, uid string) ([]*domain.AccountSyncState, error) {
+	return read(ctx, &s.mu, func() ([]*domain.AccountSyncState, error) {
+		return s.accountSyncStates.find(func(a *domain.AccountSyncState) bool { return a.UID == uid }), nil
+	})
+}
+
+func (s *FinTrackerMemoryStorage) GetAccountCredential(ctx context.Context, uid string, id string) (*domain.AccountCredential, error) {
+	return read(ctx, &s.mu, func() (*domain.AccountCredential, error) {
+		acred, ok := s.accountCredentials.get(id)
+		if !ok {
+			return nil, storage.NotFoundError(id)
+		}
+		if acred.UID != uid {
+			return nil, storage.UnauthorizedError(id)
+		}
+		return acred, nil
+	})
+}
+
+func (s *FinTrackerMemoryStorage) GetAccountCredentials(ctx context.Context, uid string) ([]*domain.AccountCredential, error) {
+	return read(ctx, &s.mu, func() ([]*domain.AccountCredential, error) {
+		return s.accountCredentials.find(func(a *domain.AccountCredential) bool { return a.UID == uid }), nil
+	})
+}
+
+func (s *FinTrackerMemoryStorage) GetAccounts(ctx context.Context, uid string) (domain.Accounts, error) {
+	return read(ctx, &s.mu, func() (domain.Accounts, error) {
+		return s.accounts.find(func(a *domain.Account) bool { return a.UID == uid }), nil
+	})
+}
+
+func (s *FinTrackerMemoryStorage) GetAccountSummaries(ctx context.Context, uid string) ([]*domain.AccountSummary, error) {
+	return read(ctx, &s.mu, func() ([]*domain.AccountSummary, error) {
+		return s.accountSummaries.find(func(a *domain.AccountSummary) bool { return a.UID == uid }), nil
+	})
+}
+
+func (s *FinTrackerMemoryStorage) GetAccountSummariesByDate(ctx context.Context, uid string, startDate time.Time, endDate time.Time) ([]*domain.AccountSummary, error) {
+	return read(ctx, &s.mu, func() ([]*domain.AccountSummary, error) {
+		asumys := s.accountSummaries.find(func(a *domain.AccountSummary) bool {
+			return a.UID == uid && inDateRange(a.Date, startDate, endDate)
+		})
+		sortSummaries(asumys)
+		return asumys, nil
+	})
+}
+
+func (s *FinTrackerMemoryStorage) GetLatestAccountSummaries(ctx context.Context, uid string) ([]*domain.AccountSummary, error) {
+	return read(ctx, &s.mu, func() ([]*domain.AccountSummary, error) {
+		latest := time.Time{}
+		for _, a := range s.accountSummaries.find(func(a *domain.AccountSummary) bool { return a.UID == uid }) {
+			if a.Date.After(latest) {
+				latest = a.Date
+			}
+		}
+		asumys := s.accountSummaries.find(func(a *domain.AccountSummary) bool {
+			return a.UID == uid && a.Date.Equal(latest)
+		})
+		sortSummaries(asumys)
+		return asumys, nil
+	})
+}
+
+// sortSummaries orders the snapshots by date then account, like the stores' indexes.
+func sortSummaries(asumys []*domain.AccountSummary) {
+	sort.SliceStable(asumys, func(i, j int) bool {
+		if !asumys[i].Date.Equal(asumys[j].Date) {
+			return asumys[i].Date.Before(asumys[j].Date)
+		}
+		return asumys[i].AccountID < asumys[j].AccountID
+	})
+}
+
+func (s *FinTrackerMemoryStorage) DeleteAccount(ctx context.Context, uid string, id string) error {
+	return write(ctx, &s.mu, func() error {
+		acct, ok := s.accounts.get(id)
+		if ok && acct.UID != uid {
+			return storage.UnauthorizedError(id)
+		}
+		s.accounts.delete(id)
+		return nil
+	})
+}
+
+func (s *FinTrackerMemoryStorage) DeleteAccountCredential(ctx context.Context, uid string, id string) error {
+	return write(ctx, &s.mu, func() error {
+		acred, ok := s.accountCredentials.get(id)
+		if !ok {
+			return storage.NotFoundError(id)
+		}
+		if acred.UID != uid {
+			return storage.UnauthorizedError(id)
+		}
+		s.accountCredentials.delete(id)
+		return nil
+	})
+}
+
+// DeleteAccountSyncState deletes the account's sync state — deleting a missing one is not an error.
+func (s *FinTrackerMemoryStorage) DeleteAccountSyncState(ctx context.Context, uid string, id string) error {
+	return write(ctx, &s.mu, func() error {
+		astate, ok := s.accountSyncStates.get(id)
+		if ok && astate.UID != uid {
+			return storage.UnauthorizedError(id)
+		}
+		s.accountSyncStates.delete(id)
+		return nil
+	})
+}
+
+// DeleteAccountSummaries deletes nothing when ids is empty.
+func (s *FinTrackerMemoryStorage) DeleteAccountSummaries(ctx context.Context, ids []string) error {
+	return write(ctx, &s.mu, func() error {
+		s.accountSummaries.delete(ids...)
+		return nil
+	})
+}
+
+func (s *FinTrackerMemoryStorage) SaveAccount(ctx context.Context, data *domain.Account) error {
+	return write(ctx, &s.mu, func() error {
+		s.accounts.put(data.ID, data)
+		return nil
+	})
+}
+
+func (s *FinTrackerMemoryStorage) SaveAccountSyncState(ctx context.Context, data *domain.AccountSyncState) error {
+	return write(ctx, &s.mu, func() error {
+		s.accountSyncStates.put(data.ID, data)
+		return nil
+	})
+}
+
+func (s *FinTrackerMemoryStorage) SaveAccountCredential(ctx context.Context, data *domain.AccountCredential) error {
+	return write(ctx, &s.mu, func() error {
+		s.accountCredentials.put(data.ID, data)
+		return nil
+	})
+}
+
+func (s *FinTrackerMemoryStorage) SaveAccountSummaries(ctx context.Context, asumys []*domain.AccountSummary) error {
+	return write(ctx, &s.mu, func() error {
+		for _, asum := range asumys {
+			s.accountSummaries.put(asum.ID, asum)
+		}
+		return nil
+	})
+}
@@ -0,0 +1,41 @@
+package memory
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/rkapps/fin-tracker-backend-go/internal/domain"
+)
+
+func (s *FinTrackerMemoryStorage) GetAccountValues(ctx context.Context, uid string, startDate time.Time, endDate time.Time) ([]*domain.AccountValue, error) {
+	return read(ctx, &s.mu, func() ([]*domain.AccountValue, error) {
+		values := s.accountValues.find(func(v *domain.AccountValue) bool {
+			return v.UID == uid && inDateRange(v.Date, startDate, endDate)
+		})
+		sort.SliceStable(values, func(i, j int) bool {
+			if !values[i].Date.Equal(values[j].Date) {
+				return values[i].Date.Before(values[j].Date)
+			}
+			return values[i].AccountID < values[j].AccountID
+		})
+		return values, nil
+	})
+}
+
+// DeleteAccountValues deletes nothing when ids is empty.
+func (s *FinTrackerMemoryStorage) DeleteAccountValues(ctx context.Context, ids []string) error {
+	return write(ctx, &s.mu, func() error {
+		s.accountValues.delete(ids...)
+		return nil
+	})
+}
+
+func (s *FinTrackerMemoryStorage) SaveAccountValues(ctx context.Context, values []*domain.AccountValue) error {
+	return write(ctx, &s.mu, func() error {
+		for _, v := range values {
+			s.accountValues.put(v.ID, v)
+		}
+		return nil
+	})
+}
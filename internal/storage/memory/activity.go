@@ -0,0 +1,114 @@
+package memory
+
+import (
+	"context"
+
+	"github.com/rkapps/fin-tracker-backend-go/internal/domain"
+)
+
+// DeleteImortedActivities deletes nothing when ids is empty.
+func (s *FinTrackerMemoryStorage) DeleteImortedActivities(ctx context.Context, ids []string) error {
+	return write(ctx, &s.mu, func() error {
+		s.activityImports.delete(ids...)
+		return nil
+	})
+}
+
+// DeleteActivities deletes nothing when ids is empty.
+func (s *FinTrackerMemoryStorage) DeleteActivities(ctx context.Context, ids []string) error {
+	return write(ctx, &s.mu, func() error {
+		s.activities.delete(ids...)
+		return nil
+	})
+}
+
+// DeleteActivityLots deletes nothing when ids is empty.
+func (s *FinTrackerMemoryStorage) DeleteActivityLots(ctx context.Context, ids []string) error {
+	return write(ctx, &s.mu, func() error {
+		s.activityLots.delete(ids...)
+		return nil
+	})
+}
+
+func (s *FinTrackerMemoryStorage) GetImortedActivities(ctx context.Context, uid string, acctId string) ([]*domain.ActivityImport, error) {
+	return read(ctx, &s.mu, func() ([]*domain.ActivityImport, error) {
+		return s.activityImports.find(func(a *domain.ActivityImport) bool {
+			return a.UID == uid && a.AccountID == acctId
+		}), nil
+	})
+}
+
+func (s *FinTrackerMemoryStorage) GetActivities(ctx context.Context, uid string) ([]*domain.Activity, error) {
+	return read(ctx, &s.mu, func() ([]*domain.Activity, error) {
+		return s.activities.find(func(a *domain.Activity) bool { return a.UID == uid }), nil
+	})
+}
+
+func (s *FinTrackerMemoryStorage) GetActivitiesForAccount(ctx context.Context, uid string, acctId string) ([]*domain.Activity, error) {
+	return read(ctx, &s.mu, func() ([]*domain.Activity, error) {
+		return s.activities.find(func(a *domain.Activity) bool {
+			return a.UID == uid && a.AccountID == acctId
+		}), nil
+	})
+}
+
+func (s *FinTrackerMemoryStorage) GetActivityLots(ctx context.Context, uid string) ([]*domain.ActivityLot, error) {
+	return read(ctx, &s.mu, func() ([]*domain.ActivityLot, error) {
+		return s.activityLots.find(func(l *domain.ActivityLot) bool { return l.UID == uid }), nil
+	})
+}
+
+func (s *FinTrackerMemoryStorage) GetActivityLotsForAccount(ctx context.Context, uid string, acctId string) ([]*domain.ActivityLot, error) {
+	return read(ctx, &s.mu, func() ([]*domain.ActivityLot, error) {
+		return s.activityLots.find(func(l *domain.ActivityLot) bool {
+			return l.UID == uid && l.AccountID == acctId
+		}), nil
+	})
+}
+
+func (s *FinTrackerMemoryStorage) SaveImportedActivities(ctx context.Context, actvs []*domain.ActivityImport) error {
+	return write(ctx, &s.mu, func() error {
+		for _, actv := range actvs {
+			s.activityImports.put(actv.ID, actv)
+		}
+		return nil
+	})
+}
+
+func (s *FinTrackerMemoryStorage) SaveActivities(ctx context.Context, actvs []*domain.Activity) error {
+	return write(ctx, &s.mu, func() error {
+		for _, actv := range actvs {
+			s.activities.put(actv.ID, actv)
+		}
+		return nil
+	})
+}
+
+func (s *FinTrackerMemoryStorage) SaveActivityLots(ctx context.Context, lots []*domain.ActivityLot) error {
+	return write(ctx, &s.mu, func() error {
+		for _, lot := range lots {
+			s.activityLots.put(lot.ID, lot)
+		}
+		return nil
+	})
+}
+
+// QueryActivities returns one page of the user's activities that match the query.
+func (s *FinTrackerMemoryStorage) QueryActivities(ctx context.Context, uid string, query domain.ActivityQuery) (domain.Paged[*domain.Activity], error) {
+	return read(ctx, &s.mu, func() (domain.Paged[*domain.Activity], error) {
+		actvs := s.activities.find(func(a *domain.Activity) bool {
+			return a.UID == uid && matchesActivity(query, a)
+		})
+		return paginate(actvs, query.Page, activityKey)
+	})
+}
+
+// QueryActivityLots returns one page of the user's lots that match the query.
+func (s *FinTrackerMemoryStorage) QueryActivityLots(ctx context.Context, uid string, query domain.LotQuery) (domain.Paged[*domain.ActivityLot], error) {
+	return read(ctx, &s.mu, func() (domain.Paged[*domain.ActivityLot], error) {
+		lots := s.activityLots.find(func(l *domain.ActivityLot) bool {
+			return l.UID == uid && matchesLot(query, l)
+		})
+		return paginate(lots, query.Page, lotKey)
+	})
+}
@@ -0,0 +1,45 @@
+package memory
+
+import (
+	"context"
+	"sort"
+
+	"github.com/rkapps/fin-tracker-backend-go/internal/domain"
+	"github.com/rkapps/fin-tracker-backend-go/internal/storage"
+)
+
+// GetActivityMappingRules returns the user's mapping rules ordered by priority
+func (s *FinTrackerMemoryStorage) GetActivityMappingRules(ctx context.Context, uid string) (domain.ActivityMappingRules, error) {
+	return read(ctx, &s.mu, func() (domain.ActivityMappingRules, error) {
+		rules := s.activityMappingRules.find(func(r *domain.ActivityMappingRule) bool { return r.UID == uid })
+		sort.SliceStable(rules, func(i, j int) bool {
+			return rules[i].Priority < rules[j].Priority
+		})
+		return rules, nil
+	})
+}
+
+// SaveActivityMappingRule adds or replaces the rule. Another user's rule is not replaced.
+func (s *FinTrackerMemoryStorage) SaveActivityMappingRule(ctx context.Context, data *domain.ActivityMappingRule) error {
+	return write(ctx, &s.mu, func() error {
+		if rule, ok := s.activityMappingRules.get(data.ID); ok && rule.UID != data.UID {
+			return storage.UnauthorizedError(data.ID)
+		}
+		s.activityMappingRules.put(data.ID, data)
+		return nil
+	})
+}
+
+func (s *FinTrackerMemoryStorage) DeleteActivityMappingRule(ctx context.Context, uid string, id string) error {
+	return write(ctx, &s.mu, func() error {
+		rule, ok := s.activityMappingRules.get(id)
+		if !ok {
+			return storage.NotFoundError(id)
+		}
+		if rule.UID != uid {
+			return storage.UnauthorizedError(id)
+		}
+		s.activityMappingRules.delete(id)
+		return nil
+	})
+}
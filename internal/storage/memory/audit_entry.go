@@ -0,0 +1,36 @@
+package memory
+
+import (
+	"context"
+	"sort"
+
+	"github.com/rkapps/fin-tracker-backend-go/internal/domain"
+	"github.com/rkapps/fin-tracker-backend-go/internal/storage"
+)
+
+// SaveAuditEntry fails when the entry exists, like the unique id index.
+func (s *FinTrackerMemoryStorage) SaveAuditEntry(ctx context.Context, entry *domain.AuditEntry) error {
+	return write(ctx, &s.mu, func() error {
+		if _, ok := s.auditEntries.get(entry.ID); ok {
+			return storage.ErrAlreadyExists
+		}
+		s.auditEntries.put(entry.ID, entry)
+		return nil
+	})
+}
+
+// GetAuditEntries returns the user's most recent entries first, optionally for one account.
+func (s *FinTrackerMemoryStorage) GetAuditEntries(ctx context.Context, uid string, acctId string, limit int64) ([]*domain.AuditEntry, error) {
+	return read(ctx, &s.mu, func() ([]*domain.AuditEntry, error) {
+		entries := s.auditEntries.find(func(e *domain.AuditEntry) bool {
+			return e.UID == uid && (len(acctId) == 0 || e.AccountID == acctId)
+		})
+		sort.SliceStable(entries, func(i, j int) bool {
+			return entries[i].Time.After(entries[j].Time)
+		})
+		if limit > 0 && int64(len(entries)) > limit {
+			entries = entries[:limit]
+		}
+		return entries, nil
+	})
+}
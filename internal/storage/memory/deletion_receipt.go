@@ -0,0 +1,33 @@
+package memory
+
+import (
+	"context"
+
+	"github.com/rkapps/fin-tracker-backend-go/internal/domain"
+	"github.com/rkapps/fin-tracker-backend-go/internal/storage"
+)
+
+func (s *FinTrackerMemoryStorage) GetDeletionReceipt(ctx context.Context, id string) (*domain.DeletionReceipt, error) {
+	return read(ctx, &s.mu, func() (*domain.DeletionReceipt, error) {
+		receipt, ok := s.deletionReceipts.get(id)
+		if !ok {
+			return nil, storage.NotFoundError(id)
+		}
+		return receipt, nil
+	})
+}
+
+func (s *FinTrackerMemoryStorage) GetDeletionReceiptsByStatus(ctx context.Context, status string) ([]*domain.DeletionReceipt, error) {
+	return read(ctx, &s.mu, func() ([]*domain.DeletionReceipt, error) {
+		return s.deletionReceipts.find(func(r *domain.DeletionReceipt) bool {
+			return r.Status == status
+		}), nil
+	})
+}
+
+func (s *FinTrackerMemoryStorage) SaveDeletionReceipt(ctx context.Context, receipt *domain.DeletionReceipt) error {
+	return write(ctx, &s.mu, func() error {
+		s.deletionReceipts.put(receipt.ID, receipt)
+		return nil
+	})
+}
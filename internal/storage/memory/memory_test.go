@@ -0,0 +1,283 @@
+package memory
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/rkapps/fin-tracker-backend-go/internal/domain"
+	"github.com/rkapps/fin-tracker-backend-go/internal/storage"
+	"github.com/rkapps/fin-tracker-backend-go/internal/storage/storagetest"
+	"github.com/shopspring/decimal"
+)
+
+func date(year int, month time.Month, day int) time.Time {
+	return time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+}
+
+func TestConformance(t *testing.T) {
+	storagetest.RunFinTracker(t, func(t *testing.T) storage.FinTrackerStorageService {
+		return NewFinTrackerMemoryStorage()
+	})
+}
+
+func TestTickerConformance(t *testing.T) {
+	storagetest.RunTicker(t, func(t *testing.T, fixtures storagetest.TickerFixtures) storage.TickerStorageService {
+		s := NewTickerMemoryStorage()
+		ctx := context.Background()
+		for _, ticker := range fixtures.Tickers {
+			s.SaveTicker(ctx, ticker)
+		}
+		s.SaveTickerHistory(ctx, fixtures.History)
+		s.SaveTickerSentiments(ctx, fixtures.Sentiments)
+		s.SaveTickerEmbeddings(ctx, fixtures.Embeddings)
+		return s
+	})
+}
+
+func TestAccountOwnership(t *testing.T) {
+
+	s := NewFinTrackerMemoryStorage()
+	ctx := context.Background()
+	s.SaveAccount(ctx, &domain.Account{ID: "a1", UID: "u1"})
+
+	if _, err := s.GetAccount(ctx, "u1", "a1"); err != nil {
+		t.Fatalf("GetAccount() error = %v", err)
+	}
+	if _, err := s.GetAccount(ctx, "u2", "a1"); !errors.Is(err, storage.ErrUnauthorized) {
+		t.Fatalf("GetAccount() by another user error = %v, want ErrUnauthorized", err)
+	}
+	if err := s.DeleteAccount(ctx, "u2", "a1"); !errors.Is(err, storage.ErrUnauthorized) {
+		t.Fatalf("DeleteAccount() by another user error = %v, want ErrUnauthorized", err)
+	}
+	if _, err := s.GetAccount(ctx, "u1", "missing"); !errors.Is(err, storage.ErrNotFound) {
+		t.Fatalf("GetAccount(missing) error = %v, want ErrNotFound", err)
+	}
+
+	// a cancelled request does not touch the store
+	cctx, cancel := context.WithCancel(ctx)
+	cancel()
+	if _, err := s.GetAccount(cctx, "u1", "a1"); !errors.Is(err, context.Canceled) {
+		t.Fatalf("GetAccount() with cancelled context error = %v, want context.Canceled", err)
+	}
+
+	// callers get copies
+	acct, _ := s.GetAccount(ctx, "u1", "a1")
+	acct.UID = "u2"
+	if accts, _ := s.GetAccounts(ctx, "u1"); len(accts) != 1 {
+		t.Fatalf("GetAccounts() = %d accounts after changing a copy, want 1", len(accts))
+	}
+}
+
+func TestDeleteEmptyIds(t *testing.T) {
+
+	s := NewFinTrackerMemoryStorage()
+	ctx := context.Background()
+	s.SaveActivities(ctx, []*domain.Activity{{ID: "x1", UID: "u1"}, {ID: "x2", UID: "u1"}})
+	s.DeleteActivities(ctx, nil)
+	if actvs, _ := s.GetActivities(ctx, "u1"); len(actvs) != 2 {
+		t.Fatalf("DeleteActivities(nil) left %d activities, want 2", len(actvs))
+	}
+	s.DeleteActivities(ctx, []string{"x1"})
+	actvs, _ := s.GetActivities(ctx, "u1")
+	if len(actvs) != 1 || actvs[0].ID != "x2" {
+		t.Fatalf("DeleteActivities(x1) left %v", actvs)
+	}
+}
+
+func TestQueryActivities(t *testing.T) {
+
+	s := NewFinTrackerMemoryStorage()
+	ctx := context.Background()
+	s.SaveActivities(ctx, []*domain.Activity{
+		{ID: "x1", UID: "u1", AccountID: "a1", Date: date(2024, 1, 1), TxnType: domain.ActivityTypeDividend, RcvSymbol: "USD"},
+		{ID: "x2", UID: "u1", AccountID: "a1", Date: date(2024, 2, 1), TxnType: domain.ActivityTypeBuy, RcvSymbol: "AAPL", SentSymbol: "USD"},
+		{ID: "x3", UID: "u1", AccountID: "a2", Date: date(2024, 2, 1), TxnType: domain.ActivityTypeBuy, RcvSymbol: "MSFT", SentSymbol: "USD"},
+		{ID: "x4", UID: "u1", AccountID: "a2", Date: date(2024, 3, 1), TxnType: domain.ActivityTypeSell, RcvSymbol: "USD", SentSymbol: "MSFT"},
+		{ID: "x5", UID: "u2", AccountID: "a3", Date: date(2024, 3, 1), TxnType: domain.ActivityTypeBuy, RcvSymbol: "AAPL"},
+	})
+	ids := func(actvs []*domain.Activity) string {
+		ss := []string{}
+		for _, actv := range actvs {
+			ss = append(ss, actv.ID)
+		}
+		return fmt.Sprint(ss)
+	}
+
+	tests := []struct {
+		name  string
+		query domain.ActivityQuery
+		want  string
+	}{
+		{"newest first", domain.ActivityQuery{}, "[x4 x3 x2 x1]"},
+		{"accounts", domain.ActivityQuery{AccountIDs: []string{"a2"}}, "[x4 x3]"},
+		{"dates", domain.ActivityQuery{StartDate: date(2024, 2, 1), EndDate: date(2024, 2, 1)}, "[x3 x2]"},
+		{"rcv or sent symbol", domain.ActivityQuery{Symbols: []string{"MSFT"}}, "[x4 x3]"},
+		{"txn types", domain.ActivityQuery{TxnTypes: []domain.ActivityType{domain.ActivityTypeBuy}}, "[x3 x2]"},
+		{"by symbol", domain.ActivityQuery{Page: domain.Page{Sort: "symbol"}}, "[x2 x3 x1 x4]"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			paged, err := s.QueryActivities(ctx, "u1", tt.query)
+			if err != nil {
+				t.Fatalf("QueryActivities() error = %v", err)
+			}
+			if got := ids(paged.Items); got != tt.want || len(paged.NextCursor) > 0 {
+				t.Errorf("QueryActivities() = %s next %q, want %s", got, paged.NextCursor, tt.want)
+			}
+		})
+	}
+
+	// pages neither skip nor repeat activities with the same date
+	query := domain.ActivityQuery{Page: domain.Page{Limit: 2, Sort: "date"}}
+	got := []*domain.Activity{}
+	for pages := 0; pages < 3; pages++ {
+		paged, err := s.QueryActivities(ctx, "u1", query)
+		if err != nil {
+			t.Fatalf("QueryActivities() page %d error = %v", pages, err)
+		}
+		got = append(got, paged.Items...)
+		if len(paged.NextCursor) == 0 {
+			break
+		}
+		query.Cursor = paged.NextCursor
+	}
+	if ids(got) != "[x1 x2 x3 x4]" {
+		t.Errorf("paged QueryActivities() = %s, want [x1 x2 x3 x4]", ids(got))
+	}
+
+	if _, err := s.QueryActivities(ctx, "u1", domain.ActivityQuery{Page: domain.Page{Cursor: "bogus"}}); !errors.Is(err, storage.ErrInvalidCursor) {
+		t.Errorf("QueryActivities(bogus cursor) error = %v, want ErrInvalidCursor", err)
+	}
+}
+
+func TestTransactions(t *testing.T) {
+
+	s := NewFinTrackerMemoryStorage()
+	ctx := context.Background()
+	start, end := date(2024, 1, 1), date(2024, 12, 31)
+	err := s.ImportTransactions(ctx, "u1", start, end, []*domain.Transaction{
+		{Date: date(2024, 1, 5), Group: "Home", Category: "Groceries", Account: "Checking", Description: "Whole Foods Market", Dbcr: "debit", Amount: 100},
+		{Date: date(2024, 1, 20), Group: "Home", Category: "Groceries", Account: "Checking", Description: "Safeway", Dbcr: "debit", Amount: 50},
+		{Date: date(2024, 1, 25), Group: "Home", Category: "Groceries", Account: "Checking", Description: "Refund", Dbcr: "credit", Amount: 20},
+		{Date: date(2024, 2, 1), Group: "Income", Category: "Paycheck", Account: "Checking", Description: "Salary", Dbcr: "credit", Amount: 5000},
+	})
+	if err != nil {
+		t.Fatalf("ImportTransactions() error = %v", err)
+	}
+
+	txns, _ := s.SearchTransactions(ctx, "u1", start, end, "whole")
+	if len(txns) != 1 || txns[0].Description != "Whole Foods Market" || txns[0].UID != "u1" || len(txns[0].ID) == 0 {
+		t.Fatalf("SearchTransactions(whole) = %v", txns)
+	}
+	if txns, _ := s.SearchTransactions(ctx, "u1", start, end, "groc check"); len(txns) != 3 {
+		t.Fatalf("SearchTransactions(groc check) = %d, want 3", len(txns))
+	}
+	if txns, _ := s.SearchTransactions(ctx, "u2", start, end, ""); len(txns) != 0 {
+		t.Fatalf("SearchTransactions(u2) = %d, want 0", len(txns))
+	}
+
+	aggs, _ := s.SummaryTransactions(ctx, "u1", time.Time{}, time.Time{})
+	if len(aggs) != 1 {
+		t.Fatalf("SummaryTransactions() = %v, want the groceries of january only", aggs)
+	}
+	if aggs[0].ID.Year != 2024 || aggs[0].ID.Month != 1 || aggs[0].Amount != -130 {
+		t.Fatalf("SummaryTransactions() = %+v, want 2024/1 -130", aggs[0])
+	}
+
+	// a re-import replaces the transactions in the range
+	s.ImportTransactions(ctx, "u1", date(2024, 1, 1), date(2024, 1, 31), []*domain.Transaction{
+		{Date: date(2024, 1, 10), Group: "Home", Category: "Groceries", Account: "Checking", Dbcr: "debit", Amount: 10},
+	})
+	if txns, _ := s.SearchTransactions(ctx, "u1", start, end, ""); len(txns) != 2 {
+		t.Fatalf("after re-import = %d transactions, want 2", len(txns))
+	}
+}
+
+func TestSearchTicker(t *testing.T) {
+
+	s := NewTickerMemoryStorage()
+	ctx := context.Background()
+	for _, ticker := range []*domain.Ticker{
+		{ID: "AAPL", Symbol: "AAPL", Name: "Apple Inc", Sector: "Technology", Industry: "Consumer Electronics", Active: true, TotalAssets: 300, PrDiffPercSearch: 1.5, Yield: 0.5},
+		{ID: "MSFT", Symbol: "MSFT", Name: "Microsoft Corp", Sector: "Technology", Industry: "Software", Active: true, TotalAssets: 400, PrDiffPercSearch: -0.5, Yield: 0.8},
+		{ID: "KO", Symbol: "KO", Name: "Coca-Cola Co", Sector: "Consumer Defensive", Industry: "Beverages", Active: true, TotalAssets: 100, PrDiffPercSearch: 0.2, Yield: 3.1,
+			PerformanceSearch: map[string]map[string]float64{"YTD": {domain.FIELD_DIFF: 4}}},
+		{ID: "OLD", Symbol: "OLD", Name: "Delisted", Sector: "Technology", Active: false, TotalAssets: 900, PrDiffPercSearch: 9},
+	} {
+		s.SaveTicker(ctx, ticker)
+	}
+
+	symbols := func(tks domain.Tickers) string {
+		return fmt.Sprint(func() []string {
+			ss := []string{}
+			for _, tk := range tks {
+				ss = append(ss, tk.Symbol)
+			}
+			return ss
+		}())
+	}
+
+	tests := []struct {
+		name string
+		ts   domain.TickerSearch
+		want string
+	}{
+		{"all active by total assets", domain.TickerSearch{}, "[MSFT AAPL KO]"},
+		{"text", domain.TickerSearch{SearchText: "micro"}, "[MSFT]"},
+		{"sector", domain.TickerSearch{Sectors: []string{"Technology"}}, "[MSFT AAPL]"},
+		{"yield", domain.TickerSearch{FromYield: 1}, "[KO]"},
+		{"1D performance", domain.TickerSearch{PerfPeriod: "1D", FromPerfPerc: 0, ToPerfPerc: 2}, "[AAPL KO]"},
+		{"YTD performance", domain.TickerSearch{PerfPeriod: "YTD", FromPerfPerc: 0, ToPerfPerc: 10}, "[KO]"},
+		{"top gainers", domain.TickerSearch{Function: "Top Gainers"}, "[AAPL KO]"},
+		{"top losers", domain.TickerSearch{Function: "Top Losers"}, "[MSFT]"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tks, err := s.SearchTicker(ctx, tt.ts)
+			if err != nil {
+				t.Fatalf("SearchTicker() error = %v", err)
+			}
+			if got := symbols(tks); got != tt.want {
+				t.Errorf("SearchTicker() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+
+	if tgs, _ := s.GetTickerGroups(ctx); len(tgs) != 4 {
+		t.Errorf("GetTickerGroups() = %d groups, want 4", len(tgs))
+	}
+	if tks, _ := s.GetTickers(ctx, []string{"aapl", ""}); symbols(tks) != "[AAPL]" {
+		t.Errorf("GetTickers(aapl) = %s", symbols(tks))
+	}
+	if price, _ := s.GetTickerPrice(ctx, "USDC"); !price.Equal(decimal.NewFromInt(1)) {
+		t.Errorf("GetTickerPrice(USDC) = %v, want 1", price)
+	}
+}
+
+func TestConcurrentAccess(t *testing.T) {
+
+	s := NewFinTrackerMemoryStorage()
+	ctx := context.Background()
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			id := fmt.Sprintf("x%d", i)
+			s.SaveActivities(ctx, []*domain.Activity{{ID: id, UID: "u1"}})
+			s.GetActivities(ctx, "u1")
+			s.CreateUserLock(ctx, &domain.UserLock{ID: "u1", Owner: id})
+		}(i)
+	}
+	wg.Wait()
+	if actvs, _ := s.GetActivities(ctx, "u1"); len(actvs) != 20 {
+		t.Fatalf("GetActivities() = %d, want 20", len(actvs))
+	}
+	if err := s.CreateUserLock(ctx, &domain.UserLock{ID: "u1"}); !errors.Is(err, storage.ErrAlreadyExists) {
+		t.Fatalf("CreateUserLock() error = %v, want ErrAlreadyExists", err)
+	}
+}
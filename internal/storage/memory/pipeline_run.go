@@ -0,0 +1,42 @@
+package memory
+
+import (
+	"context"
+	"sort"
+
+	"github.com/rkapps/fin-tracker-backend-go/internal/domain"
+	"github.com/rkapps/fin-tracker-backend-go/internal/storage"
+)
+
+func (s *FinTrackerMemoryStorage) GetPipelineRun(ctx context.Context, id string) (*domain.PipelineRun, error) {
+	return read(ctx, &s.mu, func() (*domain.PipelineRun, error) {
+		run, ok := s.pipelineRuns.get(id)
+		if !ok {
+			return nil, storage.NotFoundError(id)
+		}
+		return run, nil
+	})
+}
+
+// GetPipelineRuns returns the most recent runs first, optionally for one command.
+func (s *FinTrackerMemoryStorage) GetPipelineRuns(ctx context.Context, command string, limit int64) ([]*domain.PipelineRun, error) {
+	return read(ctx, &s.mu, func() ([]*domain.PipelineRun, error) {
+		runs := s.pipelineRuns.find(func(r *domain.PipelineRun) bool {
+			return len(command) == 0 || r.Command == command
+		})
+		sort.SliceStable(runs, func(i, j int) bool {
+			return runs[i].Started.After(runs[j].Started)
+		})
+		if limit > 0 && int64(len(runs)) > limit {
+			runs = runs[:limit]
+		}
+		return runs, nil
+	})
+}
+
+func (s *FinTrackerMemoryStorage) SavePipelineRun(ctx context.Context, run *domain.PipelineRun) error {
+	return write(ctx, &s.mu, func() error {
+		s.pipelineRuns.put(run.ID, run)
+		return nil
+	})
+}
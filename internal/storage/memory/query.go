@@ -0,0 +1,131 @@
+package memory
+
+import (
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/rkapps/fin-tracker-backend-go/internal/domain"
+	"github.com/rkapps/fin-tracker-backend-go/internal/storage"
+)
+
+// sortKey is what a record is sorted and paged on.
+type sortKey struct {
+	date   time.Time
+	symbol string
+	id     string
+}
+
+// compare orders by the sort field, then by id like the Mongo sort.
+func (k sortKey) compare(o sortKey, field string) int {
+	c := 0
+	if field == domain.SortSymbol {
+		c = strings.Compare(k.symbol, o.symbol)
+	} else {
+		c = k.date.Compare(o.date)
+	}
+	if c == 0 {
+		c = strings.Compare(k.id, o.id)
+	}
+	return c
+}
+
+// paginate sorts the matched records and returns the page after the cursor.
+func paginate[T any](rows []*T, page domain.Page, key func(*T) sortKey) (domain.Paged[*T], error) {
+
+	field, desc, err := page.SortField()
+	if err != nil {
+		return domain.Paged[*T]{}, err
+	}
+	cursor, err := storage.DecodeCursor(page.Cursor)
+	if err != nil {
+		return domain.Paged[*T]{}, err
+	}
+	cmp := func(a, b sortKey) int {
+		if desc {
+			return b.compare(a, field)
+		}
+		return a.compare(b, field)
+	}
+	sort.SliceStable(rows, func(i, j int) bool {
+		return cmp(key(rows[i]), key(rows[j])) < 0
+	})
+
+	if cursor != nil {
+		after := sortKey{symbol: cursor.Value, id: cursor.ID}
+		if field == domain.SortDate {
+			if after.date, err = cursor.Date(); err != nil {
+				return domain.Paged[*T]{}, err
+			}
+		}
+		start := len(rows)
+		for i, row := range rows {
+			if cmp(key(row), after) > 0 {
+				start = i
+				break
+			}
+		}
+		rows = rows[start:]
+	}
+
+	paged := domain.Paged[*T]{Items: rows}
+	if limit := page.PageLimit(); limit > 0 && len(rows) > limit {
+		paged.Items = rows[:limit]
+		last := key(rows[limit-1])
+		if field == domain.SortSymbol {
+			paged.NextCursor = storage.Cursor{Value: last.symbol, ID: last.id}.Encode()
+		} else {
+			paged.NextCursor = storage.DateCursor(last.date, last.id)
+		}
+	}
+	return paged, nil
+}
+
+// inDateRange matches dates between start and end inclusive, a zero bound is open.
+func inDateRange(date time.Time, start time.Time, end time.Time) bool {
+	if !start.IsZero() && date.Before(start) {
+		return false
+	}
+	if !end.IsZero() && date.After(end) {
+		return false
+	}
+	return true
+}
+
+// matchesAny matches when values is empty or contains value.
+func matchesAny[V comparable](values []V, value V) bool {
+	return len(values) == 0 || slices.Contains(values, value)
+}
+
+func matchesActivity(q domain.ActivityQuery, actv *domain.Activity) bool {
+	if !matchesAny(q.AccountIDs, actv.AccountID) || !matchesAny(q.TxnTypes, actv.TxnType) || !matchesAny(q.Statuses, actv.Status) {
+		return false
+	}
+	if len(q.Symbols) > 0 && !slices.Contains(q.Symbols, actv.RcvSymbol) && !slices.Contains(q.Symbols, actv.SentSymbol) {
+		return false
+	}
+	return inDateRange(actv.Date, q.StartDate, q.EndDate)
+}
+
+func matchesLot(q domain.LotQuery, lot *domain.ActivityLot) bool {
+	if !matchesAny(q.AccountIDs, lot.AccountID) || !matchesAny(q.Symbols, lot.Symbol) || !matchesAny(q.Statuses, lot.Status) {
+		return false
+	}
+	if q.StartDate.IsZero() && q.EndDate.IsZero() {
+		return true
+	}
+	return lot.Date != nil && inDateRange(*lot.Date, q.StartDate, q.EndDate)
+}
+
+func activityKey(actv *domain.Activity) sortKey {
+	return sortKey{date: actv.Date, symbol: actv.RcvSymbol, id: actv.ID}
+}
+
+func lotKey(lot *domain.ActivityLot) sortKey {
+	key := sortKey{symbol: lot.Symbol, id: lot.ID}
+	if lot.Date != nil {
+		key.date = *lot.Date
+	}
+	return key
+}
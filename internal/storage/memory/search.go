@@ -0,0 +1,40 @@
+package memory
+
+import (
+	"strings"
+	"unicode"
+)
+
+// matchesText approximates the Atlas autocomplete used by the search indexes:
+// every word of the query must be the prefix of a word in one of the fields,
+// ignoring case. An empty query matches everything.
+func matchesText(query string, fields ...string) bool {
+
+	terms := words(query)
+	if len(terms) == 0 {
+		return true
+	}
+	var fieldWords []string
+	for _, field := range fields {
+		fieldWords = append(fieldWords, words(field)...)
+	}
+	for _, term := range terms {
+		found := false
+		for _, word := range fieldWords {
+			if strings.HasPrefix(word, term) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+func words(value string) []string {
+	return strings.FieldsFunc(strings.ToLower(value), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+}
@@ -0,0 +1,284 @@
+package memory
+
+import (
+	"context"
+	"sort"
+	"strings"
+
+	"github.com/rkapps/fin-tracker-backend-go/internal/domain"
+	"github.com/rkapps/fin-tracker-backend-go/internal/storage"
+	"github.com/rkapps/fin-tracker-backend-go/internal/utils"
+	"github.com/shopspring/decimal"
+)
+
+// DeleteTicker deletes the ticker for the exchange:symbol
+func (s *TickerMemoryStorage) DeleteTicker(ctx context.Context, id string) error {
+	return write(ctx, &s.mu, func() error {
+		s.tickers.delete(id)
+		return nil
+	})
+}
+
+func (s *TickerMemoryStorage) GetTicker(ctx context.Context, id string) (*domain.Ticker, error) {
+	return read(ctx, &s.mu, func() (*domain.Ticker, error) {
+		ticker, ok := s.tickers.get(id)
+		if !ok {
+			return nil, storage.NotFoundError(id)
+		}
+		return ticker, nil
+	})
+}
+
+func (s *TickerMemoryStorage) GetTickerPrice(ctx context.Context, symbol string) (decimal.Decimal, error) {
+	if strings.Compare(symbol, "USD") == 0 || strings.Compare(symbol, "USDC") == 0 || strings.Compare(symbol, "USDT") == 0 {
+		return decimal.NewFromFloat(1.0), nil
+	}
+	ticker, err := s.GetTicker(ctx, symbol)
+	if err != nil {
+		return decimal.Zero, err
+	}
+	return ticker.PrLast, nil
+}
+
+// GetTickerGroups returns the distinct sector and industry pairs.
+func (s *TickerMemoryStorage) GetTickerGroups(ctx context.Context) (domain.TickerGroups, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	tgs := domain.TickerGroups{}
+	seen := map[domain.TickerGroup]bool{}
+	for _, ticker := range s.tickers.find(nil) {
+		tg := domain.TickerGroup{Sector: ticker.Sector, Industry: ticker.Industry}
+		if seen[tg] {
+			continue
+		}
+		seen[tg] = true
+		tgs = append(tgs, &tg)
+	}
+	return tgs, nil
+}
+
+func (s *TickerMemoryStorage) GetTickerHistory(ctx context.Context, symbol string) ([]*domain.TickerHistory, error) {
+	return read(ctx, &s.mu, func() ([]*domain.TickerHistory, error) {
+		return s.tickerHistory.find(func(th *domain.TickerHistory) bool { return th.Metadata.Symbol == symbol }), nil
+	})
+}
+
+func (s *TickerMemoryStorage) GetTickerSentiments(ctx context.Context, symbol string) ([]*domain.TickerSentiment, error) {
+	return read(ctx, &s.mu, func() ([]*domain.TickerSentiment, error) {
+		return s.tickerSentiments.find(func(ts *domain.TickerSentiment) bool { return ts.Symbol == symbol }), nil
+	})
+}
+
+func (s *TickerMemoryStorage) GetTickerEmbeddings(ctx context.Context, symbol string) ([]*domain.TickerEmbedding, error) {
+	return read(ctx, &s.mu, func() ([]*domain.TickerEmbedding, error) {
+		return s.tickerEmbeddings.find(func(te *domain.TickerEmbedding) bool { return te.Symbol == symbol }), nil
+	})
+}
+
+// GetTickers returns the tickers for the symbols (upper cased), all tickers when there are none.
+func (s *TickerMemoryStorage) GetTickers(ctx context.Context, symbols []string) (domain.Tickers, error) {
+	qs := map[string]bool{}
+	for _, symbol := range symbols {
+		if len(symbol) == 0 {
+			continue
+		}
+		qs[strings.ToUpper(symbol)] = true
+	}
+	return read(ctx, &s.mu, func() (domain.Tickers, error) {
+		return s.tickers.find(func(t *domain.Ticker) bool {
+			return len(qs) == 0 || qs[t.Symbol]
+		}), nil
+	})
+}
+
+// tickerField reads a numeric search field. ok is false when the ticker does not have it
+// e.g no performance for the period — such tickers never match a range.
+type tickerField func(t *domain.Ticker) (value float64, ok bool)
+
+type tickerRange struct {
+	field tickerField
+	op    string // gt, gte, lt, lte
+	value float64
+}
+
+func (r tickerRange) matches(t *domain.Ticker) bool {
+	value, ok := r.field(t)
+	if !ok {
+		return false
+	}
+	switch r.op {
+	case "gt":
+		return value > r.value
+	case "gte":
+		return value >= r.value
+	case "lt":
+		return value < r.value
+	default:
+		return value <= r.value
+	}
+}
+
+type tickerSort struct {
+	field tickerField
+	dir   int // 1 ascending, -1 descending
+}
+
+func prDiffPercField(t *domain.Ticker) (float64, bool) {
+	return t.PrDiffPercSearch, true
+}
+
+func yieldField(t *domain.Ticker) (float64, bool) {
+	return t.Yield, true
+}
+
+func totalAssetsField(t *domain.Ticker) (float64, bool) {
+	return float64(t.TotalAssets), true
+}
+
+func performanceField(period string) tickerField {
+	return func(t *domain.Ticker) (float64, bool) {
+		value, ok := t.PerformanceSearch[period][domain.FIELD_DIFF]
+		return value, ok
+	}
+}
+
+// SearchTicker applies the same criteria as the Atlas search index — text, functions
+// (top gainers/losers, limited to 50), sectors, industries, yield and performance ranges.
+// Only active tickers are returned, largest total assets first.
+func (s *TickerMemoryStorage) SearchTicker(ctx context.Context, ts domain.TickerSearch) (domain.Tickers, error) {
+
+	ranges := []tickerRange{}
+	sorts := []tickerSort{{totalAssetsField, -1}}
+	limit := 0
+	sectors := map[string]bool{}
+	industries := map[string]bool{}
+
+	if len(ts.Function) > 0 {
+
+		limit = 50
+		if strings.Compare(ts.Function, "Top Gainers") == 0 {
+			ranges = append(ranges, tickerRange{prDiffPercField, "gt", 0})
+			sorts = append(sorts, tickerSort{prDiffPercField, -1})
+		} else if strings.Compare(ts.Function, "Top Losers") == 0 {
+			ranges = append(ranges, tickerRange{prDiffPercField, "lt", 0})
+			sorts = append(sorts, tickerSort{prDiffPercField, -1})
+		} else if strings.Compare(ts.Function, "Top Gainers (Ytd)") == 0 {
+			field := performanceField(ts.PerfPeriod)
+			ranges = append(ranges, tickerRange{field, "gt", 0})
+			sorts = append(sorts, tickerSort{field, -1})
+		} else if strings.Compare(ts.Function, "Top Losers (Ytd)") == 0 {
+			field := performanceField(ts.PerfPeriod)
+			ranges = append(ranges, tickerRange{field, "lt", 0})
+			sorts = append(sorts, tickerSort{field, 1})
+		}
+
+	} else {
+
+		for _, sector := range ts.Sectors {
+			sectors[sector] = true
+		}
+		for _, industry := range ts.Industries {
+			industries[industry] = true
+		}
+		if len(ts.Strategies) > 0 {
+			// tickers have no strategies field, so the index never matches one
+			return domain.Tickers{}, nil
+		}
+		if ts.FromYield > 0 {
+			ranges = append(ranges, tickerRange{yieldField, "gte", ts.FromYield})
+		}
+		if ts.ToYield > 0 {
+			ranges = append(ranges, tickerRange{yieldField, "lte", ts.ToYield})
+		}
+		if len(ts.PerfPeriod) > 0 {
+			var field tickerField
+			if strings.Compare(ts.PerfPeriod, "1D") == 0 || strings.Compare(ts.PerfPeriod, "N") == 0 {
+				field = prDiffPercField
+			} else if utils.CheckStringInArray(ts.PerfPeriod, domain.PerfPeriods) {
+				field = performanceField(ts.PerfPeriod)
+			}
+			if field != nil {
+				ranges = append(ranges, tickerRange{field, "gte", ts.FromPerfPerc}, tickerRange{field, "lte", ts.ToPerfPerc})
+			}
+		}
+	}
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	s.mu.RLock()
+	tks := s.tickers.find(func(t *domain.Ticker) bool {
+		if !t.Active {
+			return false
+		}
+		if len(sectors) > 0 && !sectors[t.Sector] {
+			return false
+		}
+		if len(industries) > 0 && !industries[t.Industry] {
+			return false
+		}
+		for _, r := range ranges {
+			if !r.matches(t) {
+				return false
+			}
+		}
+		return matchesText(ts.SearchText, t.Symbol, t.Exchange, t.Name, t.Overview)
+	})
+	s.mu.RUnlock()
+
+	sort.SliceStable(tks, func(i, j int) bool {
+		for _, ts := range sorts {
+			a, _ := ts.field(tks[i])
+			b, _ := ts.field(tks[j])
+			if a != b {
+				return (a < b) == (ts.dir > 0)
+			}
+		}
+		return false
+	})
+	if limit > 0 && len(tks) > limit {
+		tks = tks[:limit]
+	}
+	return tks, nil
+}
+
+// SaveTicker adds or replaces the ticker.
+func (s *TickerMemoryStorage) SaveTicker(ctx context.Context, ticker *domain.Ticker) error {
+	return write(ctx, &s.mu, func() error {
+		s.tickers.put(ticker.ID, ticker)
+		return nil
+	})
+}
+
+// SaveTickerHistory adds or replaces the history records.
+func (s *TickerMemoryStorage) SaveTickerHistory(ctx context.Context, history []*domain.TickerHistory) error {
+	return write(ctx, &s.mu, func() error {
+		for _, th := range history {
+			s.tickerHistory.put(th.ID, th)
+		}
+		return nil
+	})
+}
+
+// SaveTickerSentiments adds or replaces the sentiments.
+func (s *TickerMemoryStorage) SaveTickerSentiments(ctx context.Context, sentiments []*domain.TickerSentiment) error {
+	return write(ctx, &s.mu, func() error {
+		for _, ts := range sentiments {
+			s.tickerSentiments.put(ts.ID, ts)
+		}
+		return nil
+	})
+}
+
+// SaveTickerEmbeddings adds or replaces the embeddings.
+func (s *TickerMemoryStorage) SaveTickerEmbeddings(ctx context.Context, embeddings []*domain.TickerEmbedding) error {
+	return write(ctx, &s.mu, func() error {
+		for _, te := range embeddings {
+			s.tickerEmbeddings.put(te.ID, te)
+		}
+		return nil
+	})
+}
@@ -0,0 +1,114 @@
+package memory
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/rkapps/fin-tracker-backend-go/internal/domain"
+)
+
+// SearchTransactions returns the user's transactions between the dates (inclusive)
+// whose account, category, group, description or tag match the search text.
+func (s *FinTrackerMemoryStorage) SearchTransactions(ctx context.Context, uid string, startDate time.Time, endDate time.Time, searchText string) (domain.Transactions, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.searchTransactions(uid, startDate, endDate, searchText), nil
+}
+
+func (s *FinTrackerMemoryStorage) searchTransactions(uid string, startDate time.Time, endDate time.Time, searchText string) domain.Transactions {
+	return s.transactions.find(func(t *domain.Transaction) bool {
+		return t.UID == uid &&
+			!t.Date.Before(startDate) && !t.Date.After(endDate) &&
+			matchesText(searchText, t.Account, t.Category, t.Group, t.Description, t.Tag)
+	})
+}
+
+// ImportTransactions replaces the user's transactions between the dates.
+func (s *FinTrackerMemoryStorage) ImportTransactions(ctx context.Context, uid string, startDate time.Time, endDate time.Time, txns []*domain.Transaction) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	ids := []string{}
+	for _, txn := range s.searchTransactions(uid, startDate, endDate, "") {
+		ids = append(ids, txn.ID)
+	}
+	s.transactions.delete(ids...)
+	for _, txn := range txns {
+		txn.UID = uid
+		txn.ID = uuid.New().String()
+		s.transactions.put(txn.ID, txn)
+	}
+	return nil
+}
+
+// SummaryTransactions sums the user's transactions by year, month, group, category and account —
+// debits negative, credits positive. Paychecks, the Others group and interest payments are left out.
+func (s *FinTrackerMemoryStorage) SummaryTransactions(ctx context.Context, uid string, startDate time.Time, endDate time.Time) ([]domain.TransactionAgg, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	txns := s.transactions.find(func(t *domain.Transaction) bool {
+		if t.UID != uid || t.Category == "Paycheck" || t.Group == "Others" || t.Account == "Interest Payment" {
+			return false
+		}
+		if !startDate.IsZero() && t.Date.Before(startDate) {
+			return false
+		}
+		if !endDate.IsZero() && t.Date.After(endDate) {
+			return false
+		}
+		return true
+	})
+
+	aggs := map[any]*domain.TransactionAgg{}
+	results := []domain.TransactionAgg{}
+	for _, txn := range txns {
+		agg := domain.TransactionAgg{}
+		date := txn.Date.UTC()
+		agg.ID.Year = int32(date.Year())
+		agg.ID.Month = int32(date.Month())
+		agg.ID.Group = txn.Group
+		agg.ID.Category = txn.Category
+		agg.ID.Account = txn.Account
+		if _, ok := aggs[agg.ID]; !ok {
+			aggs[agg.ID] = &agg
+		}
+		amount := txn.Amount
+		if txn.Dbcr != "credit" {
+			amount = -amount
+		}
+		aggs[agg.ID].Amount += amount
+	}
+	for _, agg := range aggs {
+		results = append(results, *agg)
+	}
+	// $group has no order — sort so that results are stable
+	sort.Slice(results, func(i, j int) bool {
+		a, b := results[i].ID, results[j].ID
+		if a.Year != b.Year {
+			return a.Year < b.Year
+		}
+		if a.Month != b.Month {
+			return a.Month < b.Month
+		}
+		if a.Group != b.Group {
+			return a.Group < b.Group
+		}
+		if a.Category != b.Category {
+			return a.Category < b.Category
+		}
+		return a.Account < b.Account
+	})
+	return results, nil
+}
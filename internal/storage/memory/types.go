@@ -0,0 +1,161 @@
+// Package memory keeps the storage in process — for unit tests and for running
+// the API locally without Mongo (STORAGE=memory). Nothing is persisted.
+package memory
+
+import (
+	"context"
+	"sync"
+
+	"github.com/rkapps/fin-tracker-backend-go/internal/domain"
+	"github.com/rkapps/fin-tracker-backend-go/internal/storage"
+)
+
+var (
+	_ storage.FinTrackerStorageService = (*FinTrackerMemoryStorage)(nil)
+	_ storage.TickerStorageService     = (*TickerMemoryStorage)(nil)
+)
+
+// table holds one collection in insertion order, like Mongo's natural order.
+// Records are copied in and out so callers cannot change the stored values
+// (the copy is shallow — nested maps and slices are shared).
+type table[T any] struct {
+	rows  map[string]*T
+	order []string
+}
+
+func newTable[T any]() *table[T] {
+	return &table[T]{rows: make(map[string]*T)}
+}
+
+func clone[T any](v *T) *T {
+	c := *v
+	return &c
+}
+
+func (t *table[T]) get(id string) (*T, bool) {
+	row, ok := t.rows[id]
+	if !ok {
+		return nil, false
+	}
+	return clone(row), true
+}
+
+// put inserts or replaces the record — the upsert of UpdateOne/BulkWrite.
+func (t *table[T]) put(id string, v *T) {
+	if _, ok := t.rows[id]; !ok {
+		t.order = append(t.order, id)
+	}
+	t.rows[id] = clone(v)
+}
+
+func (t *table[T]) delete(ids ...string) {
+	removed := false
+	for _, id := range ids {
+		if _, ok := t.rows[id]; ok {
+			delete(t.rows, id)
+			removed = true
+		}
+	}
+	if !removed {
+		return
+	}
+	order := t.order[:0]
+	for _, id := range t.order {
+		if _, ok := t.rows[id]; ok {
+			order = append(order, id)
+		}
+	}
+	t.order = order
+}
+
+// find returns copies of the records that match, in insertion order.
+func (t *table[T]) find(match func(v *T) bool) []*T {
+	rows := []*T{}
+	for _, id := range t.order {
+		row := t.rows[id]
+		if match == nil || match(row) {
+			rows = append(rows, clone(row))
+		}
+	}
+	return rows
+}
+
+// read runs fn under the read lock unless ctx is already done.
+func read[T any](ctx context.Context, mu *sync.RWMutex, fn func() (T, error)) (T, error) {
+	if err := ctx.Err(); err != nil {
+		var zero T
+		return zero, err
+	}
+	mu.RLock()
+	defer mu.RUnlock()
+	return fn()
+}
+
+// write runs fn under the write lock unless ctx is already done.
+func write(ctx context.Context, mu *sync.RWMutex, fn func() error) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	return fn()
+}
+
+// FinTracker Memory Storage
+type FinTrackerMemoryStorage struct {
+	mu                   sync.RWMutex
+	accounts             *table[domain.Account]
+	accountCredentials   *table[domain.AccountCredential]
+	accountSyncStates    *table[domain.AccountSyncState]
+	accountSummaries     *table[domain.AccountSummary]
+	accountValues        *table[domain.AccountValue]
+	activityImports      *table[domain.ActivityImport]
+	activities           *table[domain.Activity]
+	activityLots         *table[domain.ActivityLot]
+	activityMappingRules *table[domain.ActivityMappingRule]
+	auditEntries         *table[domain.AuditEntry]
+	deletionReceipts     *table[domain.DeletionReceipt]
+	pipelineRuns         *table[domain.PipelineRun]
+	transactions         *table[domain.Transaction]
+	userLocks            *table[domain.UserLock]
+	users                *table[domain.User]
+}
+
+func NewFinTrackerMemoryStorage() *FinTrackerMemoryStorage {
+	return &FinTrackerMemoryStorage{
+		accounts:             newTable[domain.Account](),
+		accountCredentials:   newTable[domain.AccountCredential](),
+		accountSyncStates:    newTable[domain.AccountSyncState](),
+		accountSummaries:     newTable[domain.AccountSummary](),
+		accountValues:        newTable[domain.AccountValue](),
+		activityImports:      newTable[domain.ActivityImport](),
+		activities:           newTable[domain.Activity](),
+		activityLots:         newTable[domain.ActivityLot](),
+		activityMappingRules: newTable[domain.ActivityMappingRule](),
+		auditEntries:         newTable[domain.AuditEntry](),
+		deletionReceipts:     newTable[domain.DeletionReceipt](),
+		pipelineRuns:         newTable[domain.PipelineRun](),
+		transactions:         newTable[domain.Transaction](),
+		userLocks:            newTable[domain.UserLock](),
+		users:                newTable[domain.User](),
+	}
+}
+
+// Ticker Memory Storage
+// The app only reads tickers — tests and local runs load them with the Save methods.
+type TickerMemoryStorage struct {
+	mu               sync.RWMutex
+	tickers          *table[domain.Ticker]
+	tickerHistory    *table[domain.TickerHistory]
+	tickerSentiments *table[domain.TickerSentiment]
+	tickerEmbeddings *table[domain.TickerEmbedding]
+}
+
+func NewTickerMemoryStorage() *TickerMemoryStorage {
+	return &TickerMemoryStorage{
+		tickers:          newTable[domain.Ticker](),
+		tickerHistory:    newTable[domain.TickerHistory](),
+		tickerSentiments: newTable[domain.TickerSentiment](),
+		tickerEmbeddings: newTable[domain.TickerEmbedding](),
+	}
+}
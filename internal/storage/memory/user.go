@@ -0,0 +1,39 @@
+package memory
+
+import (
+	"context"
+
+	"github.com/rkapps/fin-tracker-backend-go/internal/domain"
+	"github.com/rkapps/fin-tracker-backend-go/internal/storage"
+)
+
+func (s *FinTrackerMemoryStorage) GetUsers(ctx context.Context) ([]*domain.User, error) {
+	return read(ctx, &s.mu, func() ([]*domain.User, error) {
+		return s.users.find(nil), nil
+	})
+}
+
+func (s *FinTrackerMemoryStorage) GetUser(ctx context.Context, id string) (*domain.User, error) {
+	return read(ctx, &s.mu, func() (*domain.User, error) {
+		user, ok := s.users.get(id)
+		if !ok {
+			return nil, storage.NotFoundError(id)
+		}
+		return user, nil
+	})
+}
+
+func (s *FinTrackerMemoryStorage) SaveUser(ctx context.Context, user *domain.User) error {
+	return write(ctx, &s.mu, func() error {
+		s.users.put(user.ID, user)
+		return nil
+	})
+}
+
+// DeleteUser deletes the user record only, like Mongo — the other records are deleted by the caller.
+func (s *FinTrackerMemoryStorage) DeleteUser(ctx context.Context, id string) error {
+	return write(ctx, &s.mu, func() error {
+		s.users.delete(id)
+		return nil
+	})
+}
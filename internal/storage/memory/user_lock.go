@@ -0,0 +1,49 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/rkapps/fin-tracker-backend-go/internal/domain"
+	"github.com/rkapps/fin-tracker-backend-go/internal/storage"
+)
+
+// CreateUserLock fails when the user is locked, like the unique id index.
+func (s *FinTrackerMemoryStorage) CreateUserLock(ctx context.Context, lock *domain.UserLock) error {
+	return write(ctx, &s.mu, func() error {
+		if _, ok := s.userLocks.get(lock.ID); ok {
+			return storage.ErrAlreadyExists
+		}
+		s.userLocks.put(lock.ID, lock)
+		return nil
+	})
+}
+
+func (s *FinTrackerMemoryStorage) GetUserLock(ctx context.Context, uid string) (*domain.UserLock, error) {
+	return read(ctx, &s.mu, func() (*domain.UserLock, error) {
+		lock, ok := s.userLocks.get(uid)
+		if !ok {
+			return nil, nil
+		}
+		return lock, nil
+	})
+}
+
+// ReplaceUserLock compares and swaps under the store mutex.
+func (s *FinTrackerMemoryStorage) ReplaceUserLock(ctx context.Context, prev *domain.UserLock, lock *domain.UserLock) error {
+	return write(ctx, &s.mu, func() error {
+		existing, ok := s.userLocks.get(prev.ID)
+		if !ok || existing.Owner != prev.Owner || !existing.ExpiresAt.Equal(prev.ExpiresAt) {
+			return fmt.Errorf("%w: lock of %s changed", storage.ErrConflict, prev.ID)
+		}
+		s.userLocks.put(lock.ID, lock)
+		return nil
+	})
+}
+
+func (s *FinTrackerMemoryStorage) DeleteUserLock(ctx context.Context, uid string) error {
+	return write(ctx, &s.mu, func() error {
+		s.userLocks.delete(uid)
+		return nil
+	})
+}
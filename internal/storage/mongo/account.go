@@ -1,109 +1,180 @@
 package mongo
 
 import (
-	"fmt"
-	"log"
+	"context"
+	"errors"
 	"log/slog"
+	"time"
 
 	"github.com/rkapps/fin-tracker-backend-go/internal/domain"
+	"github.com/rkapps/fin-tracker-backend-go/internal/storage"
 	"go.mongodb.org/mongo-driver/v2/bson"
 )
 
-func (s FinTrackerMongoStorage) GetAccount(uid string, id string) (*domain.Account, error) {
-	// filter := bson.M{domain.FIELD_UID: uid, domain.FIELD_ID: id}
-	acct, err := s.accounts().FindByID(s.context(), id)
+func (s FinTrackerMongoStorage) GetAccount(ctx context.Context, uid string, id string) (*domain.Account, error) {
+	acct, err := s.accounts().FindByID(ctx, id)
 	if err != nil {
 		slog.Debug("Get Account", "Error", err)
+		return nil, storageError(err, id)
 	}
-	if acct != nil && acct.UID != uid {
-		return nil, fmt.Errorf("Not authorized: %s", id)
+	if acct.UID != uid {
+		return nil, storage.UnauthorizedError(id)
 	}
-	return acct, err
-
+	return acct, nil
 }
 
-func (s FinTrackerMongoStorage) GetAccountSyncState(uid string, id string) (*domain.AccountSyncState, error) {
-	// filter := bson.M{domain.FIELD_UID: uid, domain.FIELD_ID: id}
-	acct, err := s.accountSyncStates().FindByID(s.context(), id)
+func (s FinTrackerMongoStorage) GetAccountSyncState(ctx context.Context, uid string, id string) (*domain.AccountSyncState, error) {
+	astate, err := s.accountSyncStates().FindByID(ctx, id)
 	if err != nil {
-		slog.Debug("Get Account", "Error", err)
+		slog.Debug("Get AccountSyncState", "Error", err)
+		return nil, storageError(err, id)
 	}
-	if acct.UID != uid {
-		return nil, fmt.Errorf("Not authorized: %s", id)
+	if astate.UID != uid {
+		return nil, storage.UnauthorizedError(id)
 	}
-	return acct, err
+	return astate, nil
+}
 
+func (s FinTrackerMongoStorage) GetAccountSyncStates(ctx context.Context, uid string) ([]*domain.AccountSyncState, error) {
+	filter := bson.M{domain.FIELD_UID: uid}
+	astates, err := s.accountSyncStates().Find(ctx, filter, bson.D{}, 0, 0)
+	if err != nil {
+		slog.Debug("Get AccountSyncStates", "Error", err)
+		return nil, storageError(err, uid)
+	}
+	return astates, nil
 }
 
-func (s FinTrackerMongoStorage) GetAccountCredential(uid string, id string) (*domain.AccountCredential, error) {
-	// filter := bson.M{domain.FIELD_UID: uid, domain.FIELD_ID: id}
-	acct, err := s.accountCredentials().FindByID(s.context(), id)
+func (s FinTrackerMongoStorage) GetAccountCredential(ctx context.Context, uid string, id string) (*domain.AccountCredential, error) {
+	acred, err := s.accountCredentials().FindByID(ctx, id)
 	if err != nil {
-		slog.Debug("Get Account", "Error", err)
+		slog.Debug("Get AccountCredential", "Error", err)
+		return nil, storageError(err, id)
 	}
-	if acct.UID != uid {
-		return nil, fmt.Errorf("Not authorized: %s", id)
+	if acred.UID != uid {
+		return nil, storage.UnauthorizedError(id)
 	}
-	return acct, err
+	return acred, nil
+}
 
+func (s FinTrackerMongoStorage) GetAccountCredentials(ctx context.Context, uid string) ([]*domain.AccountCredential, error) {
+	filter := bson.M{domain.FIELD_UID: uid}
+	acreds, err := s.accountCredentials().Find(ctx, filter, bson.D{}, 0, 0)
+	if err != nil {
+		slog.Debug("Get AccountCredentials", "Error", err)
+		return nil, storageError(err, uid)
+	}
+	return acreds, nil
 }
 
-func (s FinTrackerMongoStorage) GetAccounts(uid string) (domain.Accounts, error) {
+func (s FinTrackerMongoStorage) GetAccounts(ctx context.Context, uid string) (domain.Accounts, error) {
 	filter := bson.M{domain.FIELD_UID: uid}
-	accts, err := s.accounts().Find(s.context(), filter, bson.D{}, 0, 0)
+	accts, err := s.accounts().Find(ctx, filter, bson.D{}, 0, 0)
 	if err != nil {
 		slog.Debug("Get Accounts", "Error", err)
+		return nil, storageError(err, uid)
 	}
 	slog.Debug("Get Accounts", "Filter", filter, "Count", len(accts))
-	return accts, err
-
+	return accts, nil
 }
 
-func (s FinTrackerMongoStorage) GetAccountSummaries(uid string) ([]*domain.AccountSummary, error) {
+func (s FinTrackerMongoStorage) GetAccountSummaries(ctx context.Context, uid string) ([]*domain.AccountSummary, error) {
 	filter := bson.M{domain.FIELD_UID: uid}
-	accts, err := s.accountSummaries().Find(s.context(), filter, bson.D{}, 0, 0)
+	asumys, err := s.accountSummaries().Find(ctx, filter, bson.D{}, 0, 0)
 	if err != nil {
 		slog.Debug("Get AccountSummaries", "Error", err)
+		return nil, storageError(err, uid)
+	}
+	slog.Debug("Get AccountSummaries", "Filter", filter, "Count", len(asumys))
+	return asumys, nil
+}
+
+// summaries have no bson tags — the fields are stored lower cased.
+var summarySort = bson.D{{Key: domain.FIELD_DATE, Value: 1}, {Key: "accountid", Value: 1}}
+
+func (s FinTrackerMongoStorage) GetAccountSummariesByDate(ctx context.Context, uid string, startDate time.Time, endDate time.Time) ([]*domain.AccountSummary, error) {
+	conds := append(bson.A{bson.M{domain.FIELD_UID: uid}}, dateRange(domain.FIELD_DATE, startDate, endDate)...)
+	asumys, err := s.accountSummaries().Find(ctx, bson.M{"$and": conds}, summarySort, 0, 0)
+	if err != nil {
+		slog.Debug("Get AccountSummariesByDate", "Error", err)
+		return nil, storageError(err, uid)
 	}
-	slog.Debug("Get AccountSummaries", "Filter", filter, "Count", len(accts))
-	return accts, err
+	return asumys, nil
+}
 
+func (s FinTrackerMongoStorage) GetLatestAccountSummaries(ctx context.Context, uid string) ([]*domain.AccountSummary, error) {
+	filter := bson.M{domain.FIELD_UID: uid}
+	latest, err := s.accountSummaries().Find(ctx, filter, bson.D{{Key: domain.FIELD_DATE, Value: -1}}, 1, 0)
+	if err != nil || len(latest) == 0 {
+		return []*domain.AccountSummary{}, storageError(err, uid)
+	}
+	filter[domain.FIELD_DATE] = latest[0].Date
+	asumys, err := s.accountSummaries().Find(ctx, filter, summarySort, 0, 0)
+	if err != nil {
+		slog.Debug("Get LatestAccountSummaries", "Error", err)
+		return nil, storageError(err, uid)
+	}
+	return asumys, nil
 }
 
-func (s FinTrackerMongoStorage) DeleteAccount(uid string, id string) error {
-	// filter := bson.M{domain.FIELD_UID: uid, domain.FIELD_ID: id}
-	return s.accounts().DeleteByID(s.context(), id)
+// DeleteAccount deletes the user's account — deleting a missing account is not an error.
+func (s FinTrackerMongoStorage) DeleteAccount(ctx context.Context, uid string, id string) error {
+	_, err := s.GetAccount(ctx, uid, id)
+	if errors.Is(err, storage.ErrNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return storageError(s.accounts().DeleteByID(ctx, id), id)
 }
 
-// DeleteAccountSummary
-func (s FinTrackerMongoStorage) DeleteAccountSummaries(ids []string) error {
+func (s FinTrackerMongoStorage) DeleteAccountCredential(ctx context.Context, uid string, id string) error {
+	if _, err := s.GetAccountCredential(ctx, uid, id); err != nil {
+		return err
+	}
+	return storageError(s.accountCredentials().DeleteByID(ctx, id), id)
+}
 
-	err := s.accountSummaries().DeleteMany(s.context(), ids)
+// DeleteAccountSyncState deletes the account's sync state — deleting a missing one is not an error.
+func (s FinTrackerMongoStorage) DeleteAccountSyncState(ctx context.Context, uid string, id string) error {
+	_, err := s.GetAccountSyncState(ctx, uid, id)
+	if errors.Is(err, storage.ErrNotFound) {
+		return nil
+	}
 	if err != nil {
-		log.Printf("Delete AccountSummary error: %v", err)
+		return err
+	}
+	return storageError(s.accountSyncStates().DeleteByID(ctx, id), id)
+}
+
+// DeleteAccountSummaries deletes nothing when ids is empty.
+func (s FinTrackerMongoStorage) DeleteAccountSummaries(ctx context.Context, ids []string) error {
+	if len(ids) == 0 {
 		return nil
 	}
-	return err
+	return storageError(s.accountSummaries().DeleteMany(ctx, ids), "")
 }
 
-func (s FinTrackerMongoStorage) SaveAccount(data *domain.Account) error {
-	return s.accounts().UpdateOne(s.context(), data)
+func (s FinTrackerMongoStorage) SaveAccount(ctx context.Context, data *domain.Account) error {
+	return storageError(s.accounts().UpdateOne(ctx, data), data.ID)
 }
 
-func (s FinTrackerMongoStorage) SaveAccountSyncState(data *domain.AccountSyncState) error {
-	return s.accountSyncStates().UpdateOne(s.context(), data)
+func (s FinTrackerMongoStorage) SaveAccountSyncState(ctx context.Context, data *domain.AccountSyncState) error {
+	return storageError(s.accountSyncStates().UpdateOne(ctx, data), data.ID)
 }
 
-func (s FinTrackerMongoStorage) SaveAccountCredential(data *domain.AccountCredential) error {
-	return s.accountCredentials().UpdateOne(s.context(), data)
+func (s FinTrackerMongoStorage) SaveAccountCredential(ctx context.Context, data *domain.AccountCredential) error {
+	return storageError(s.accountCredentials().UpdateOne(ctx, data), data.ID)
 }
 
-// Save AccountSummaries
-func (s FinTrackerMongoStorage) SaveAccountSummaries(asumys []*domain.AccountSummary) error {
+func (s FinTrackerMongoStorage) SaveAccountSummaries(ctx context.Context, asumys []*domain.AccountSummary) error {
+	if len(asumys) == 0 {
+		return nil
+	}
 	ids := []string{}
 	for _, asum := range asumys {
 		ids = append(ids, asum.ID)
 	}
-	s.accountSummaries().BulkWrite(s.context(), ids, asumys)
-	return nil
+	return storageError(s.accountSummaries().BulkWrite(ctx, ids, asumys), "")
 }
@@ -0,0 +1,40 @@
+package mongo
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/rkapps/fin-tracker-backend-go/internal/domain"
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+func (s FinTrackerMongoStorage) GetAccountValues(ctx context.Context, uid string, startDate time.Time, endDate time.Time) ([]*domain.AccountValue, error) {
+	conds := append(bson.A{bson.M{domain.FIELD_UID: uid}}, dateRange(domain.FIELD_DATE, startDate, endDate)...)
+	sort := bson.D{{Key: domain.FIELD_DATE, Value: 1}, {Key: domain.FIELD_ACTIVITY_ACCOUNT_ID, Value: 1}}
+	values, err := s.accountValues().Find(ctx, bson.M{"$and": conds}, sort, 0, 0)
+	if err != nil {
+		slog.Debug("Get AccountValues", "Error", err)
+		return nil, storageError(err, uid)
+	}
+	return values, nil
+}
+
+// DeleteAccountValues deletes nothing when ids is empty.
+func (s FinTrackerMongoStorage) DeleteAccountValues(ctx context.Context, ids []string) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	return storageError(s.accountValues().DeleteMany(ctx, ids), "")
+}
+
+func (s FinTrackerMongoStorage) SaveAccountValues(ctx context.Context, values []*domain.AccountValue) error {
+	if len(values) == 0 {
+		return nil
+	}
+	ids := []string{}
+	for _, v := range values {
+		ids = append(ids, v.ID)
+	}
+	return storageError(s.accountValues().BulkWrite(ctx, ids, values), "")
+}
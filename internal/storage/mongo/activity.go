@@ -1,129 +1,186 @@
 package mongo
 
 import (
-	"log"
+	"context"
+	"log/slog"
+	"time"
 
 	"github.com/rkapps/fin-tracker-backend-go/internal/domain"
+	"github.com/rkapps/fin-tracker-backend-go/internal/storage"
 	"go.mongodb.org/mongo-driver/v2/bson"
 )
 
-// DeleteImortedActivities implements Repo.
-func (s FinTrackerMongoStorage) DeleteImortedActivities(ids []string) error {
-
+// DeleteImortedActivities deletes nothing when ids is empty.
+func (s FinTrackerMongoStorage) DeleteImortedActivities(ctx context.Context, ids []string) error {
 	if len(ids) == 0 {
 		return nil
 	}
-	err := s.acitivyImports().DeleteMany(s.context(), ids)
-	if err != nil {
-		log.Printf("Delete Imported Activities error: %v", err)
-		return nil
-	}
-	return err
+	return storageError(s.acitivyImports().DeleteMany(ctx, ids), "")
 }
 
-// DeleteActivities implements Repo.
-func (s FinTrackerMongoStorage) DeleteActivities(ids []string) error {
-
-	err := s.acitivities().DeleteMany(s.context(), ids)
-	if err != nil {
-		log.Printf("Delete Activities error: %v", err)
+// DeleteActivities deletes nothing when ids is empty.
+func (s FinTrackerMongoStorage) DeleteActivities(ctx context.Context, ids []string) error {
+	if len(ids) == 0 {
 		return nil
 	}
-	return err
+	return storageError(s.acitivities().DeleteMany(ctx, ids), "")
 }
 
-// DeleteActivityLots implements Repo.
-func (s FinTrackerMongoStorage) DeleteActivityLots(ids []string) error {
-
-	err := s.acitivityLots().DeleteMany(s.context(), ids)
-	if err != nil {
-		log.Printf("Delete ActivityLot error: %v", err)
+// DeleteActivityLots deletes nothing when ids is empty.
+func (s FinTrackerMongoStorage) DeleteActivityLots(ctx context.Context, ids []string) error {
+	if len(ids) == 0 {
 		return nil
 	}
-	return err
+	return storageError(s.acitivityLots().DeleteMany(ctx, ids), "")
 }
 
 // GetImortedActivities
-func (s FinTrackerMongoStorage) GetImortedActivities(uid string, acctId string) ([]*domain.ActivityImport, error) {
+func (s FinTrackerMongoStorage) GetImortedActivities(ctx context.Context, uid string, acctId string) ([]*domain.ActivityImport, error) {
 	filter := bson.M{"uid": uid, "accountId": acctId}
-	actvs, err := s.acitivyImports().Find(s.context(), filter, bson.D{}, 0, 0)
+	actvs, err := s.acitivyImports().Find(ctx, filter, bson.D{}, 0, 0)
 	if err != nil {
-		log.Printf("Delete Imported Activities error: %v", err)
-		return nil, err
+		slog.Debug("Get ImportedActivities", "Error", err)
+		return nil, storageError(err, acctId)
 	}
-	return actvs, err
+	return actvs, nil
 }
 
 // GetActivities
-func (s FinTrackerMongoStorage) GetActivities(uid string) ([]*domain.Activity, error) {
+func (s FinTrackerMongoStorage) GetActivities(ctx context.Context, uid string) ([]*domain.Activity, error) {
 	filter := bson.M{"uid": uid}
-	actvs, err := s.acitivities().Find(s.context(), filter, bson.D{}, 0, 0)
+	actvs, err := s.acitivities().Find(ctx, filter, bson.D{}, 0, 0)
 	if err != nil {
-		log.Printf("Delete Imported Activities error: %v", err)
-		return nil, err
+		slog.Debug("Get Activities", "Error", err)
+		return nil, storageError(err, uid)
 	}
-	return actvs, err
+	return actvs, nil
 }
 
 // GetActivitiesforAccount
-func (s FinTrackerMongoStorage) GetActivitiesForAccount(uid string, acctId string) ([]*domain.Activity, error) {
+func (s FinTrackerMongoStorage) GetActivitiesForAccount(ctx context.Context, uid string, acctId string) ([]*domain.Activity, error) {
 	filter := bson.M{"uid": uid, "accountId": acctId}
-	actvs, err := s.acitivities().Find(s.context(), filter, bson.D{}, 0, 0)
+	actvs, err := s.acitivities().Find(ctx, filter, bson.D{}, 0, 0)
 	if err != nil {
-		log.Printf("Delete Imported Activities error: %v", err)
-		return nil, err
+		slog.Debug("Get Activities", "Error", err)
+		return nil, storageError(err, acctId)
 	}
-	return actvs, err
+	return actvs, nil
 }
 
 // GetActivityLots
-func (s FinTrackerMongoStorage) GetActivityLots(uid string) ([]*domain.ActivityLot, error) {
+func (s FinTrackerMongoStorage) GetActivityLots(ctx context.Context, uid string) ([]*domain.ActivityLot, error) {
 	filter := bson.M{"uid": uid}
-	lots, err := s.acitivityLots().Find(s.context(), filter, bson.D{}, 0, 0)
+	lots, err := s.acitivityLots().Find(ctx, filter, bson.D{}, 0, 0)
 	if err != nil {
-		log.Printf("Delete Imported Activities error: %v", err)
-		return nil, err
+		slog.Debug("Get ActivityLots", "Error", err)
+		return nil, storageError(err, uid)
 	}
-	return lots, err
+	return lots, nil
 }
 
-// GetActivityLots
-func (s FinTrackerMongoStorage) GetActivityLotsForAccount(uid string, acctId string) ([]*domain.ActivityLot, error) {
+// GetActivityLotsForAccount
+func (s FinTrackerMongoStorage) GetActivityLotsForAccount(ctx context.Context, uid string, acctId string) ([]*domain.ActivityLot, error) {
 	filter := bson.M{"uid": uid, "accountId": acctId}
-	lots, err := s.acitivityLots().Find(s.context(), filter, bson.D{}, 0, 0)
+	lots, err := s.acitivityLots().Find(ctx, filter, bson.D{}, 0, 0)
 	if err != nil {
-		log.Printf("Delete Imported Activities error: %v", err)
-		return nil, err
+		slog.Debug("Get ActivityLots", "Error", err)
+		return nil, storageError(err, acctId)
 	}
-	return lots, err
+	return lots, nil
 }
 
 // Save imported activities
-func (s FinTrackerMongoStorage) SaveImportedActivities(actvs []*domain.ActivityImport) error {
+func (s FinTrackerMongoStorage) SaveImportedActivities(ctx context.Context, actvs []*domain.ActivityImport) error {
+	if len(actvs) == 0 {
+		return nil
+	}
 	ids := []string{}
 	for _, actv := range actvs {
 		ids = append(ids, actv.ID)
 	}
-	s.acitivyImports().BulkWrite(s.context(), ids, actvs)
-	return nil
+	return storageError(s.acitivyImports().BulkWrite(ctx, ids, actvs), "")
 }
 
 // Save activities
-func (s FinTrackerMongoStorage) SaveActivities(actvs []*domain.Activity) error {
+func (s FinTrackerMongoStorage) SaveActivities(ctx context.Context, actvs []*domain.Activity) error {
+	if len(actvs) == 0 {
+		return nil
+	}
 	ids := []string{}
 	for _, actv := range actvs {
 		ids = append(ids, actv.ID)
 	}
-	s.acitivities().BulkWrite(s.context(), ids, actvs)
-	return nil
+	return storageError(s.acitivities().BulkWrite(ctx, ids, actvs), "")
 }
 
 // Save activity lots
-func (s FinTrackerMongoStorage) SaveActivityLots(lots []*domain.ActivityLot) error {
+func (s FinTrackerMongoStorage) SaveActivityLots(ctx context.Context, lots []*domain.ActivityLot) error {
+	if len(lots) == 0 {
+		return nil
+	}
 	ids := []string{}
 	for _, lot := range lots {
 		ids = append(ids, lot.ID)
 	}
-	s.acitivityLots().BulkWrite(s.context(), ids, lots)
-	return nil
+	return storageError(s.acitivityLots().BulkWrite(ctx, ids, lots), "")
+}
+
+// QueryActivities returns one page of the user's activities that match the query.
+func (s FinTrackerMongoStorage) QueryActivities(ctx context.Context, uid string, query domain.ActivityQuery) (domain.Paged[*domain.Activity], error) {
+
+	pq, err := newPageQuery(query.Page, domain.FIELD_DATE, domain.FIELD_ACTIVITY_RCV_SYMBOL)
+	if err != nil {
+		return domain.Paged[*domain.Activity]{}, err
+	}
+	conds := bson.A{bson.M{domain.FIELD_UID: uid}}
+	conds = in(conds, domain.FIELD_ACTIVITY_ACCOUNT_ID, query.AccountIDs)
+	conds = in(conds, domain.FIELD_ACTIVITY_TXN_TYPE, query.TxnTypes)
+	conds = in(conds, domain.FIELD_ACTIVITY_STATUS, query.Statuses)
+	if len(query.Symbols) > 0 {
+		conds = append(conds, bson.M{"$or": bson.A{
+			bson.M{domain.FIELD_ACTIVITY_RCV_SYMBOL: bson.M{"$in": query.Symbols}},
+			bson.M{domain.FIELD_ACTIVITY_SENT_SYMBOL: bson.M{"$in": query.Symbols}},
+		}})
+	}
+	conds = append(conds, dateRange(domain.FIELD_DATE, query.StartDate, query.EndDate)...)
+
+	actvs, err := s.acitivities().Find(ctx, pq.filter(conds), pq.sort(), pq.fetchLimit(), 0)
+	if err != nil {
+		return domain.Paged[*domain.Activity]{}, storageError(err, uid)
+	}
+	return page(pq, actvs, func(actv *domain.Activity) string {
+		if pq.field == domain.FIELD_DATE {
+			return storage.DateCursor(actv.Date, actv.ID)
+		}
+		return storage.Cursor{Value: actv.RcvSymbol, ID: actv.ID}.Encode()
+	}), nil
+}
+
+// QueryActivityLots returns one page of the user's lots that match the query.
+func (s FinTrackerMongoStorage) QueryActivityLots(ctx context.Context, uid string, query domain.LotQuery) (domain.Paged[*domain.ActivityLot], error) {
+
+	pq, err := newPageQuery(query.Page, domain.FIELD_DATE, domain.FIELD_SYMBOL)
+	if err != nil {
+		return domain.Paged[*domain.ActivityLot]{}, err
+	}
+	conds := bson.A{bson.M{domain.FIELD_UID: uid}}
+	conds = in(conds, domain.FIELD_ACTIVITY_ACCOUNT_ID, query.AccountIDs)
+	conds = in(conds, domain.FIELD_SYMBOL, query.Symbols)
+	conds = in(conds, domain.FIELD_ACTIVITY_STATUS, query.Statuses)
+	conds = append(conds, dateRange(domain.FIELD_DATE, query.StartDate, query.EndDate)...)
+
+	lots, err := s.acitivityLots().Find(ctx, pq.filter(conds), pq.sort(), pq.fetchLimit(), 0)
+	if err != nil {
+		return domain.Paged[*domain.ActivityLot]{}, storageError(err, uid)
+	}
+	return page(pq, lots, func(lot *domain.ActivityLot) string {
+		if pq.field == domain.FIELD_SYMBOL {
+			return storage.Cursor{Value: lot.Symbol, ID: lot.ID}.Encode()
+		}
+		var date time.Time
+		if lot.Date != nil {
+			date = *lot.Date
+		}
+		return storage.DateCursor(date, lot.ID)
+	}), nil
 }
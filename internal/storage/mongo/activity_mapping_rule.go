@@ -0,0 +1,50 @@
+package mongo
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"sort"
+
+	"github.com/rkapps/fin-tracker-backend-go/internal/domain"
+	"github.com/rkapps/fin-tracker-backend-go/internal/storage"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+)
+
+// GetActivityMappingRules returns the user's mapping rules ordered by priority
+func (s FinTrackerMongoStorage) GetActivityMappingRules(ctx context.Context, uid string) (domain.ActivityMappingRules, error) {
+	filter := bson.M{domain.FIELD_UID: uid}
+	rules, err := s.activityMappingRules().Find(ctx, filter, bson.D{}, 0, 0)
+	if err != nil {
+		slog.Debug("Get ActivityMappingRules", "Error", err)
+		return nil, storageError(err, uid)
+	}
+	sort.SliceStable(rules, func(i, j int) bool {
+		return rules[i].Priority < rules[j].Priority
+	})
+	return rules, nil
+}
+
+// SaveActivityMappingRule adds or replaces the rule. Another user's rule is not replaced.
+func (s FinTrackerMongoStorage) SaveActivityMappingRule(ctx context.Context, data *domain.ActivityMappingRule) error {
+	rule, err := s.activityMappingRules().FindByID(ctx, data.ID)
+	if err != nil && !errors.Is(err, mongo.ErrNoDocuments) {
+		return storageError(err, data.ID)
+	}
+	if err == nil && rule.UID != data.UID {
+		return storage.UnauthorizedError(data.ID)
+	}
+	return storageError(s.activityMappingRules().UpdateOne(ctx, data), data.ID)
+}
+
+func (s FinTrackerMongoStorage) DeleteActivityMappingRule(ctx context.Context, uid string, id string) error {
+	rule, err := s.activityMappingRules().FindByID(ctx, id)
+	if err != nil {
+		return storageError(err, id)
+	}
+	if rule.UID != uid {
+		return storage.UnauthorizedError(id)
+	}
+	return storageError(s.activityMappingRules().DeleteByID(ctx, id), id)
+}
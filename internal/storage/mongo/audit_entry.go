@@ -0,0 +1,29 @@
+package mongo
+
+import (
+	"context"
+	"log/slog"
+
+	"github.com/rkapps/fin-tracker-backend-go/internal/domain"
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+// SaveAuditEntry inserts the entry — the unique id index refuses to replace one.
+func (s FinTrackerMongoStorage) SaveAuditEntry(ctx context.Context, entry *domain.AuditEntry) error {
+	return storageError(s.auditEntries().InsertOne(ctx, entry), entry.ID)
+}
+
+// GetAuditEntries returns the user's most recent entries first, optionally for one account.
+func (s FinTrackerMongoStorage) GetAuditEntries(ctx context.Context, uid string, acctId string, limit int64) ([]*domain.AuditEntry, error) {
+	filter := bson.M{domain.FIELD_UID: uid}
+	if len(acctId) > 0 {
+		filter[domain.FIELD_ACTIVITY_ACCOUNT_ID] = acctId
+	}
+	sort := bson.D{{Key: domain.FIELD_TIME, Value: -1}}
+	entries, err := s.auditEntries().Find(ctx, filter, sort, limit, 0)
+	if err != nil {
+		slog.Debug("Get AuditEntries", "Error", err)
+		return nil, storageError(err, uid)
+	}
+	return entries, nil
+}
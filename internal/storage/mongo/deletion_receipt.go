@@ -0,0 +1,28 @@
+package mongo
+
+import (
+	"context"
+
+	"github.com/rkapps/fin-tracker-backend-go/internal/domain"
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+func (s FinTrackerMongoStorage) GetDeletionReceipt(ctx context.Context, id string) (*domain.DeletionReceipt, error) {
+	receipt, err := s.deletionReceipts().FindByID(ctx, id)
+	if err != nil {
+		return nil, storageError(err, id)
+	}
+	return receipt, nil
+}
+
+func (s FinTrackerMongoStorage) GetDeletionReceiptsByStatus(ctx context.Context, status string) ([]*domain.DeletionReceipt, error) {
+	receipts, err := s.deletionReceipts().Find(ctx, bson.M{domain.FIELD_STATUS: status}, nil, 0, 0)
+	if err != nil {
+		return nil, storageError(err, status)
+	}
+	return receipts, nil
+}
+
+func (s FinTrackerMongoStorage) SaveDeletionReceipt(ctx context.Context, receipt *domain.DeletionReceipt) error {
+	return storageError(s.deletionReceipts().UpdateOne(ctx, receipt), receipt.ID)
+}
@@ -0,0 +1,85 @@
+package mongo
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"github.com/rkapps/fin-tracker-backend-go/internal/domain"
+	"github.com/rkapps/fin-tracker-backend-go/internal/storage"
+	"github.com/rkapps/fin-tracker-backend-go/internal/storage/storagetest"
+	"github.com/rkapps/storage-backend-go/core"
+	"github.com/rkapps/storage-backend-go/mongodb"
+)
+
+// testDatabase connects to FINTRACKER_TEST_MONGO_URI. The database must already be
+// migrated — transaction search needs the Atlas idx_search index — and is emptied
+// by the tests, never point it at real data.
+func testDatabase(t *testing.T, nameKey string) *mongodb.MongoDatabase {
+	uri, name := os.Getenv("FINTRACKER_TEST_MONGO_URI"), os.Getenv(nameKey)
+	if len(uri) == 0 || len(name) == 0 {
+		t.Skipf("set FINTRACKER_TEST_MONGO_URI and %s to run against Mongo", nameKey)
+	}
+	database, err := mongodb.NewMongoDatabaseWithRegistry(uri, name, mongodb.GetBsonRegistryForDecimal())
+	if err != nil {
+		t.Fatalf("connect: %v", err)
+	}
+	return database
+}
+
+// empty deletes every record of the collection.
+func empty[M core.RepoModel[string]](t *testing.T, database *mongodb.MongoDatabase) {
+	if err := mongodb.GetMongoRepository[string, M](database).DeleteMany(context.Background(), nil); err != nil {
+		t.Fatalf("empty: %v", err)
+	}
+}
+
+func TestConformance(t *testing.T) {
+	database := testDatabase(t, "FINTRACKER_TEST_MONGO_DB")
+	db, err := NewDriverDatabase(os.Getenv("FINTRACKER_TEST_MONGO_URI"), os.Getenv("FINTRACKER_TEST_MONGO_DB"))
+	if err != nil {
+		t.Fatalf("connect: %v", err)
+	}
+	storagetest.RunFinTracker(t, func(t *testing.T) storage.FinTrackerStorageService {
+		empty[*domain.Account](t, database)
+		empty[*domain.AccountCredential](t, database)
+		empty[*domain.AccountSyncState](t, database)
+		empty[*domain.AccountSummary](t, database)
+		empty[*domain.AccountValue](t, database)
+		empty[*domain.ActivityImport](t, database)
+		empty[*domain.Activity](t, database)
+		empty[*domain.ActivityLot](t, database)
+		empty[*domain.ActivityMappingRule](t, database)
+		empty[*domain.AuditEntry](t, database)
+		empty[*domain.DeletionReceipt](t, database)
+		empty[*domain.PipelineRun](t, database)
+		empty[*domain.Transaction](t, database)
+		empty[*domain.UserLock](t, database)
+		empty[*domain.User](t, database)
+		return NewFinTrackerMongoStorage(database, db)
+	})
+}
+
+func TestTickerConformance(t *testing.T) {
+	database := testDatabase(t, "FINTRACKER_TEST_MONGO_FINANCE_DB")
+	storagetest.RunTicker(t, func(t *testing.T, fixtures storagetest.TickerFixtures) storage.TickerStorageService {
+		empty[*domain.Ticker](t, database)
+		empty[*domain.TickerHistory](t, database)
+		empty[*domain.TickerSentiment](t, database)
+		empty[*domain.TickerEmbedding](t, database)
+		ctx := context.Background()
+		if err := mongodb.GetMongoRepository[string, *domain.Ticker](database).InsertMany(ctx, fixtures.Tickers); err != nil {
+			t.Fatalf("insert tickers: %v", err)
+		}
+		if err := mongodb.GetMongoRepository[string, *domain.TickerHistory](database).InsertMany(ctx, fixtures.History); err != nil {
+			t.Fatalf("insert history: %v", err)
+		}
+		if err := mongodb.GetMongoRepository[string, *domain.TickerSentiment](database).InsertMany(ctx, fixtures.Sentiments); err != nil {
+			t.Fatalf("insert sentiments: %v", err)
+		}
+		if err := mongodb.GetMongoRepository[string, *domain.TickerEmbedding](database).InsertMany(ctx, fixtures.Embeddings); err != nil {
+			t.Fatalf("insert embeddings: %v", err)
+		}
+		return NewTickerMongoStorage(database)
+	})
+}
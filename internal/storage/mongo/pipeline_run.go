@@ -0,0 +1,36 @@
+package mongo
+
+import (
+	"context"
+	"log/slog"
+
+	"github.com/rkapps/fin-tracker-backend-go/internal/domain"
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+func (s FinTrackerMongoStorage) GetPipelineRun(ctx context.Context, id string) (*domain.PipelineRun, error) {
+	run, err := s.pipelineRuns().FindByID(ctx, id)
+	if err != nil {
+		return nil, storageError(err, id)
+	}
+	return run, nil
+}
+
+// GetPipelineRuns returns the most recent runs first, optionally for one command.
+func (s FinTrackerMongoStorage) GetPipelineRuns(ctx context.Context, command string, limit int64) ([]*domain.PipelineRun, error) {
+	filter := bson.M{}
+	if len(command) > 0 {
+		filter[domain.FIELD_COMMAND] = command
+	}
+	sort := bson.D{{Key: domain.FIELD_STARTED, Value: -1}}
+	runs, err := s.pipelineRuns().Find(ctx, filter, sort, limit, 0)
+	if err != nil {
+		slog.Debug("Get PipelineRuns", "Error", err)
+		return nil, storageError(err, command)
+	}
+	return runs, nil
+}
+
+func (s FinTrackerMongoStorage) SavePipelineRun(ctx context.Context, run *domain.PipelineRun) error {
+	return storageError(s.pipelineRuns().UpdateOne(ctx, run), run.ID)
+}
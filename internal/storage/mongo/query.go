@@ -0,0 +1,104 @@
+package mongo
+
+import (
+	"time"
+
+	"github.com/rkapps/fin-tracker-backend-go/internal/domain"
+	"github.com/rkapps/fin-tracker-backend-go/internal/storage"
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+// pageQuery is the keyset filter and sort of one page sorted on the given field.
+type pageQuery struct {
+	field string // bson field of the sort
+	desc  bool
+	after bson.M // items after the cursor, nil on the first page
+	limit int
+}
+
+// newPageQuery maps the page sort to the bson fields of the collection.
+func newPageQuery(page domain.Page, dateField string, symbolField string) (pageQuery, error) {
+
+	sortField, desc, err := page.SortField()
+	if err != nil {
+		return pageQuery{}, err
+	}
+	q := pageQuery{field: dateField, desc: desc, limit: page.PageLimit()}
+	if sortField == domain.SortSymbol {
+		q.field = symbolField
+	}
+
+	cursor, err := storage.DecodeCursor(page.Cursor)
+	if err != nil || cursor == nil {
+		return q, err
+	}
+	var value any = cursor.Value
+	if sortField == domain.SortDate {
+		if value, err = cursor.Date(); err != nil {
+			return q, err
+		}
+	}
+	op := "$gt"
+	if desc {
+		op = "$lt"
+	}
+	q.after = bson.M{"$or": bson.A{
+		bson.M{q.field: bson.M{op: value}},
+		bson.M{q.field: value, domain.FIELD_ACTIVITY_ID: bson.M{op: cursor.ID}},
+	}}
+	return q, nil
+}
+
+// sort orders on the field with the id as tie breaker.
+func (q pageQuery) sort() bson.D {
+	dir := 1
+	if q.desc {
+		dir = -1
+	}
+	return bson.D{{Key: q.field, Value: dir}, {Key: domain.FIELD_ACTIVITY_ID, Value: dir}}
+}
+
+// fetchLimit asks for one more than the page to know if there is a next one.
+func (q pageQuery) fetchLimit() int64 {
+	if q.limit == 0 {
+		return 0
+	}
+	return int64(q.limit + 1)
+}
+
+// filter ands the query conditions with the cursor.
+func (q pageQuery) filter(conds bson.A) bson.M {
+	if q.after != nil {
+		conds = append(conds, q.after)
+	}
+	return bson.M{"$and": conds}
+}
+
+// page trims the fetched items to the limit and sets the cursor after the last one.
+func page[T any](q pageQuery, items []T, cursor func(T) string) domain.Paged[T] {
+	if q.limit == 0 || len(items) <= q.limit {
+		return domain.Paged[T]{Items: items}
+	}
+	items = items[:q.limit]
+	return domain.Paged[T]{Items: items, NextCursor: cursor(items[len(items)-1])}
+}
+
+// dateRange matches the field between start and end inclusive, a zero bound is open.
+func dateRange(field string, start time.Time, end time.Time) bson.A {
+	conds := bson.A{}
+	if !start.IsZero() {
+		conds = append(conds, bson.M{field: bson.M{"$gte": start}})
+	}
+	if !end.IsZero() {
+		conds = append(conds, bson.M{field: bson.M{"$lte": end}})
+	}
+	return conds
+}
+
+// in matches the field against the values, nothing when values is empty.
+func in[V any](conds bson.A, field string, values []V) bson.A {
+	if len(values) == 0 {
+		return conds
+	}
+	return append(conds, bson.M{field: bson.M{"$in": values}})
+}
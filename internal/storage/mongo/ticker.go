@@ -1,6 +1,7 @@
 package mongo
 
 import (
+	"context"
 	"fmt"
 	"log/slog"
 	"strings"
@@ -13,26 +14,32 @@ import (
 )
 
 // DeleteTicker returns the ticker for the exchange:symbol
-func (s TickerMongoStorage) DeleteTicker(id string) error {
-	return s.tickers().DeleteByID(s.context(), id)
+func (s TickerMongoStorage) DeleteTicker(ctx context.Context, id string) error {
+	return storageError(s.tickers().DeleteByID(ctx, id), id)
 }
 
-func (s TickerMongoStorage) GetTicker(id string) (*domain.Ticker, error) {
-	// log.Println(s.tickers().Count(s.context()))
-	ticker, err := s.tickers().FindByID(s.context(), id)
-	return ticker, err
+func (s TickerMongoStorage) GetTicker(ctx context.Context, id string) (*domain.Ticker, error) {
+	// log.Println(s.tickers().Count(ctx))
+	ticker, err := s.tickers().FindByID(ctx, id)
+	if err != nil {
+		return nil, storageError(err, id)
+	}
+	return ticker, nil
 }
 
-func (s TickerMongoStorage) GetTickerPrice(symbol string) (decimal.Decimal, error) {
+func (s TickerMongoStorage) GetTickerPrice(ctx context.Context, symbol string) (decimal.Decimal, error) {
 	if strings.Compare(symbol, "USD") == 0 || strings.Compare(symbol, "USDC") == 0 || strings.Compare(symbol, "USDT") == 0 {
 		return decimal.NewFromFloat(1.0), nil
 	}
-	// log.Println(s.tickers().Count(s.context()))
-	ticker, err := s.tickers().FindByID(s.context(), symbol)
-	return ticker.PrLast, err
+	// log.Println(s.tickers().Count(ctx))
+	ticker, err := s.GetTicker(ctx, symbol)
+	if err != nil {
+		return decimal.Zero, err
+	}
+	return ticker.PrLast, nil
 }
 
-func (s TickerMongoStorage) GetTickerGroups() (domain.TickerGroups, error) {
+func (s TickerMongoStorage) GetTickerGroups(ctx context.Context) (domain.TickerGroups, error) {
 
 	query := bson.M{
 		"_id": bson.M{
@@ -49,34 +56,46 @@ func (s TickerMongoStorage) GetTickerGroups() (domain.TickerGroups, error) {
 	tgs := domain.TickerGroups{}
 	results := []domain.TickerGroupsAggregateResult{}
 
-	err := s.tickers().Aggregate(s.context(), pipeline, &results)
+	err := s.tickers().Aggregate(ctx, pipeline, &results)
+	if err != nil {
+		return nil, storageError(err, "")
+	}
 	for _, result := range results {
 		tgs = append(tgs, &result.ID)
 	}
-	if err != nil {
-		return nil, err
-	}
 	return tgs, nil
-	// return s.tickers().Find(s.context(), id)
+	// return s.tickers().Find(ctx, id)
 }
 
-func (s TickerMongoStorage) GetTickerHistory(symbol string) ([]*domain.TickerHistory, error) {
+func (s TickerMongoStorage) GetTickerHistory(ctx context.Context, symbol string) ([]*domain.TickerHistory, error) {
 	filter := bson.D{{Key: domain.FIELD_HISTORY_SYMBOL, Value: symbol}}
 	slog.Debug("GetTickerHistory", "filter", filter)
-	return s.tickerHistory().Find(s.context(), filter, bson.D{}, 0, 0)
+	results, err := s.tickerHistory().Find(ctx, filter, bson.D{}, 0, 0)
+	if err != nil {
+		return nil, storageError(err, symbol)
+	}
+	return results, nil
 }
 
-func (s TickerMongoStorage) GetTickerSentiments(symbol string) ([]*domain.TickerSentiment, error) {
+func (s TickerMongoStorage) GetTickerSentiments(ctx context.Context, symbol string) ([]*domain.TickerSentiment, error) {
 	filter := bson.D{{Key: domain.FIELD_SYMBOL, Value: symbol}}
-	return s.tickerSentiment().Find(s.context(), filter, bson.D{}, 0, 0)
+	results, err := s.tickerSentiment().Find(ctx, filter, bson.D{}, 0, 0)
+	if err != nil {
+		return nil, storageError(err, symbol)
+	}
+	return results, nil
 }
 
-func (s TickerMongoStorage) GetTickerEmbeddings(symbol string) ([]*domain.TickerEmbedding, error) {
+func (s TickerMongoStorage) GetTickerEmbeddings(ctx context.Context, symbol string) ([]*domain.TickerEmbedding, error) {
 	filter := bson.D{{Key: domain.FIELD_SYMBOL, Value: symbol}}
-	return s.tickerEmbedding().Find(s.context(), filter, bson.D{}, 0, 0)
+	results, err := s.tickerEmbedding().Find(ctx, filter, bson.D{}, 0, 0)
+	if err != nil {
+		return nil, storageError(err, symbol)
+	}
+	return results, nil
 }
 
-func (s TickerMongoStorage) GetTickers(symbols []string) (domain.Tickers, error) {
+func (s TickerMongoStorage) GetTickers(ctx context.Context, symbols []string) (domain.Tickers, error) {
 	var qs []string
 	for _, symbol := range symbols {
 		if len(symbol) == 0 {
@@ -92,13 +111,16 @@ func (s TickerMongoStorage) GetTickers(symbols []string) (domain.Tickers, error)
 	} else {
 		filter = bson.M{}
 	}
-	ts, err := s.tickers().Find(s.context(), filter, bson.D{}, 0, 0)
+	ts, err := s.tickers().Find(ctx, filter, bson.D{}, 0, 0)
+	if err != nil {
+		return nil, storageError(err, "")
+	}
 	slog.Debug("Get Tickers", "Symbols", symbols, "Filter", filter, "Count", len(ts))
-	return ts, err
+	return ts, nil
 
 }
 
-func (s TickerMongoStorage) SearchTicker(ts domain.TickerSearch) (domain.Tickers, error) {
+func (s TickerMongoStorage) SearchTicker(ctx context.Context, ts domain.TickerSearch) (domain.Tickers, error) {
 
 	var tks domain.Tickers
 	criteria := core.SearchCriteria{}
@@ -187,10 +209,10 @@ func (s TickerMongoStorage) SearchTicker(ts domain.TickerSearch) (domain.Tickers
 
 	//sort
 	// criteria.SortFields = append(criteria.SortFields, sortField)
-	tks, err := s.tickers().Search(s.context(), criteria)
+	tks, err := s.tickers().Search(ctx, criteria)
 	if err != nil {
 		slog.Error("SearchTicker", "ERROR", err)
-		return tks, err
+		return nil, storageError(err, "")
 	}
 	if tks == nil {
 		tks = domain.Tickers{}
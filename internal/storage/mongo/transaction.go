@@ -1,6 +1,7 @@
 package mongo
 
 import (
+	"context"
 	"log/slog"
 	"time"
 
@@ -11,7 +12,7 @@ import (
 )
 
 // SearchTransactions implements Repo.
-func (s FinTrackerMongoStorage) SearchTransactions(uid string, startDate time.Time, endDate time.Time, searchText string) (domain.Transactions, error) {
+func (s FinTrackerMongoStorage) SearchTransactions(ctx context.Context, uid string, startDate time.Time, endDate time.Time, searchText string) (domain.Transactions, error) {
 
 	criteria := core.SearchCriteria{}
 	criteria.IndexName = "idx_search"
@@ -23,10 +24,10 @@ func (s FinTrackerMongoStorage) SearchTransactions(uid string, startDate time.Ti
 	criteria.AddSearchDateRangeField(domain.FIELD_DATE, "gte", startDate)
 	criteria.AddSearchDateRangeField(domain.FIELD_DATE, "lte", endDate)
 
-	txns, err := s.transaction().Search(s.context(), criteria)
+	txns, err := s.transaction().Search(ctx, criteria)
 	if err != nil {
 		slog.Error("SearchTransaction", "ERROR", err)
-		return txns, err
+		return nil, storageError(err, uid)
 	}
 	if txns == nil {
 		txns = domain.Transactions{}
@@ -34,9 +35,12 @@ func (s FinTrackerMongoStorage) SearchTransactions(uid string, startDate time.Ti
 	return txns, nil
 }
 
-func (s FinTrackerMongoStorage) ImportTransactions(uid string, startDate time.Time, endDate time.Time, txns []*domain.Transaction) error {
+func (s FinTrackerMongoStorage) ImportTransactions(ctx context.Context, uid string, startDate time.Time, endDate time.Time, txns []*domain.Transaction) error {
 
-	ctxns, _ := s.SearchTransactions(uid, startDate, endDate, "")
+	ctxns, err := s.SearchTransactions(ctx, uid, startDate, endDate, "")
+	if err != nil {
+		return err
+	}
 	ids := []string{}
 	for _, txn := range ctxns {
 		ids = append(ids, txn.ID)
@@ -49,14 +53,19 @@ func (s FinTrackerMongoStorage) ImportTransactions(uid string, startDate time.Ti
 
 	}
 
-	err := s.transaction().DeleteMany(s.context(), ids)
-	if err != nil {
-		return err
+	// an empty DeleteMany would delete every user's transactions
+	if len(ids) > 0 {
+		if err := s.transaction().DeleteMany(ctx, ids); err != nil {
+			return storageError(err, uid)
+		}
+	}
+	if len(txns) == 0 {
+		return nil
 	}
-	return s.transaction().InsertMany(s.context(), txns)
+	return storageError(s.transaction().InsertMany(ctx, txns), uid)
 }
 
-func (s FinTrackerMongoStorage) SummaryTransactions(uid string, startDate time.Time, endDate time.Time) ([]domain.TransactionAgg, error) {
+func (s FinTrackerMongoStorage) SummaryTransactions(ctx context.Context, uid string, startDate time.Time, endDate time.Time) ([]domain.TransactionAgg, error) {
 
 	var pipeline []interface{}
 	var match map[string]interface{}
@@ -78,7 +87,7 @@ func (s FinTrackerMongoStorage) SummaryTransactions(uid string, startDate time.T
 		}
 	}
 
-	tf1c := bson.M{"$cond": []interface{}{bson.M{"$eq": []interface{}{"$dbcr", "credit"}}, "$amount", bson.D{{"$multiply", bson.A{"$amount", -1}}}}}
+	tf1c := bson.M{"$cond": []interface{}{bson.M{"$eq": []interface{}{"$dbcr", "credit"}}, "$amount", bson.D{{Key: "$multiply", Value: bson.A{"$amount", -1}}}}}
 
 	query := bson.M{
 		"_id": bson.M{
@@ -101,9 +110,10 @@ func (s FinTrackerMongoStorage) SummaryTransactions(uid string, startDate time.T
 	pipeline = append(pipeline, matchStage, queryStage)
 	// var results []map[string]interface{}
 	var results []domain.TransactionAgg
-	err := s.transaction().Aggregate(s.context(), pipeline, &results)
+	err := s.transaction().Aggregate(ctx, pipeline, &results)
 	if err != nil {
-		println(err)
+		slog.Error("SummaryTransactions", "ERROR", err)
+		return nil, storageError(err, uid)
 	}
 	return results, nil
 	// taggs := convertToTransactionAgg(results)
@@ -2,24 +2,53 @@ package mongo
 
 import (
 	"context"
+	"errors"
+	"fmt"
 
 	"github.com/rkapps/fin-tracker-backend-go/internal/domain"
 	"github.com/rkapps/fin-tracker-backend-go/internal/storage"
 	"github.com/rkapps/storage-backend-go/core"
 	"github.com/rkapps/storage-backend-go/mongodb"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
 )
 
+// storageError maps a driver error to the storage errors — id names the record
+// for not found and conflicts. Context errors are returned as is.
+func storageError(err error, id string) error {
+	switch {
+	case err == nil:
+		return nil
+	case errors.Is(err, context.Canceled), errors.Is(err, context.DeadlineExceeded):
+		return err
+	case errors.Is(err, mongo.ErrNoDocuments):
+		return storage.NotFoundError(id)
+	case mongo.IsDuplicateKeyError(err):
+		return fmt.Errorf("%w: %s", storage.ErrAlreadyExists, id)
+	case mongo.IsNetworkError(err), mongo.IsTimeout(err):
+		return fmt.Errorf("%w: %w", storage.ErrTransient, err)
+	}
+	return err
+}
+
 // FinTracker Mongo Storage
 type FinTrackerMongoStorage struct {
 	database *mongodb.MongoDatabase
+	db       *mongo.Database // for the conditional updates the repositories do not offer
 }
 
-func NewFinTrackerMongoStorage(database *mongodb.MongoDatabase) storage.FinTrackerStorageService {
-	return FinTrackerMongoStorage{database}
+func NewFinTrackerMongoStorage(database *mongodb.MongoDatabase, db *mongo.Database) storage.FinTrackerStorageService {
+	return FinTrackerMongoStorage{database, db}
 }
 
-func (s FinTrackerMongoStorage) context() context.Context {
-	return context.Background()
+// NewDriverDatabase connects the driver database of NewFinTrackerMongoStorage with the
+// registry of the repositories.
+func NewDriverDatabase(uri string, name string) (*mongo.Database, error) {
+	client, err := mongo.Connect(options.Client().ApplyURI(uri).SetRegistry(mongodb.GetBsonRegistryForDecimal()))
+	if err != nil {
+		return nil, err
+	}
+	return client.Database(name), nil
 }
 
 func (s FinTrackerMongoStorage) accounts() core.Repository[string, *domain.Account] {
@@ -49,10 +78,34 @@ func (s FinTrackerMongoStorage) acitivityLots() core.Repository[string, *domain.
 	return mongodb.GetMongoRepository[string, *domain.ActivityLot](s.database)
 }
 
+func (s FinTrackerMongoStorage) activityMappingRules() core.Repository[string, *domain.ActivityMappingRule] {
+	return mongodb.GetMongoRepository[string, *domain.ActivityMappingRule](s.database)
+}
+
+func (s FinTrackerMongoStorage) accountValues() core.Repository[string, *domain.AccountValue] {
+	return mongodb.GetMongoRepository[string, *domain.AccountValue](s.database)
+}
+
+func (s FinTrackerMongoStorage) auditEntries() core.Repository[string, *domain.AuditEntry] {
+	return mongodb.GetMongoRepository[string, *domain.AuditEntry](s.database)
+}
+
+func (s FinTrackerMongoStorage) deletionReceipts() core.Repository[string, *domain.DeletionReceipt] {
+	return mongodb.GetMongoRepository[string, *domain.DeletionReceipt](s.database)
+}
+
+func (s FinTrackerMongoStorage) pipelineRuns() core.Repository[string, *domain.PipelineRun] {
+	return mongodb.GetMongoRepository[string, *domain.PipelineRun](s.database)
+}
+
 func (s FinTrackerMongoStorage) transaction() core.Repository[string, *domain.Transaction] {
 	return mongodb.GetMongoRepository[string, *domain.Transaction](s.database)
 }
 
+func (s FinTrackerMongoStorage) userLocks() core.Repository[string, *domain.UserLock] {
+	return mongodb.GetMongoRepository[string, *domain.UserLock](s.database)
+}
+
 func (s FinTrackerMongoStorage) users() core.Repository[string, *domain.User] {
 	return mongodb.GetMongoRepository[string, *domain.User](s.database)
 }
@@ -65,9 +118,6 @@ type TickerMongoStorage struct {
 func NewTickerMongoStorage(database *mongodb.MongoDatabase) storage.TickerStorageService {
 	return TickerMongoStorage{database}
 }
-func (s TickerMongoStorage) context() context.Context {
-	return context.Background()
-}
 func (s TickerMongoStorage) tickers() core.Repository[string, *domain.Ticker] {
 	return mongodb.GetMongoRepository[string, *domain.Ticker](s.database)
 }
@@ -1,27 +1,34 @@
 package mongo
 
 import (
+	"context"
+
 	"github.com/rkapps/fin-tracker-backend-go/internal/domain"
 	"go.mongodb.org/mongo-driver/v2/bson"
 )
 
-func (s FinTrackerMongoStorage) GetUsers() []*domain.User {
-	users, err := s.users().Find(s.context(), bson.M{}, bson.D{}, 0, 0)
+func (s FinTrackerMongoStorage) GetUsers(ctx context.Context) ([]*domain.User, error) {
+	users, err := s.users().Find(ctx, bson.M{}, bson.D{}, 0, 0)
 	if err != nil {
-		return []*domain.User{}
-	} else {
-		return users
+		return nil, storageError(err, "")
 	}
+	return users, nil
 }
 
-func (s FinTrackerMongoStorage) GetUser(id string) (*domain.User, error) {
-	return s.users().FindByID(s.context(), id)
-}
-
-func (s FinTrackerMongoStorage) SaveUser(user *domain.User) error {
-	err := s.users().UpdateOne(s.context(), user)
+func (s FinTrackerMongoStorage) GetUser(ctx context.Context, id string) (*domain.User, error) {
+	user, err := s.users().FindByID(ctx, id)
 	if err != nil {
-		err = s.users().InsertOne(s.context(), user)
+		return nil, storageError(err, id)
 	}
-	return err
+	return user, nil
+}
+
+// SaveUser upserts the user.
+func (s FinTrackerMongoStorage) SaveUser(ctx context.Context, user *domain.User) error {
+	return storageError(s.users().UpdateOne(ctx, user), user.ID)
+}
+
+// DeleteUser deletes the user record only — the other records are deleted by the caller.
+func (s FinTrackerMongoStorage) DeleteUser(ctx context.Context, id string) error {
+	return storageError(s.users().DeleteByID(ctx, id), id)
 }
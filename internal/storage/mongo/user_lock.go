@@ -0,0 +1,48 @@
+package mongo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/rkapps/fin-tracker-backend-go/internal/domain"
+	"github.com/rkapps/fin-tracker-backend-go/internal/storage"
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+// CreateUserLock relies on the unique id index — the insert is the atomic acquire.
+func (s FinTrackerMongoStorage) CreateUserLock(ctx context.Context, lock *domain.UserLock) error {
+	err := storageError(s.userLocks().InsertOne(ctx, lock), lock.ID)
+	if errors.Is(err, storage.ErrConflict) {
+		return storage.ErrAlreadyExists
+	}
+	return err
+}
+
+func (s FinTrackerMongoStorage) GetUserLock(ctx context.Context, uid string) (*domain.UserLock, error) {
+	lock, err := s.userLocks().FindByID(ctx, uid)
+	err = storageError(err, uid)
+	if errors.Is(err, storage.ErrNotFound) {
+		return nil, nil
+	}
+	return lock, err
+}
+
+// ReplaceUserLock replaces the lock only while it still has the previous owner and
+// expiry — the filtered replace of a single document is the compare-and-swap.
+func (s FinTrackerMongoStorage) ReplaceUserLock(ctx context.Context, prev *domain.UserLock, lock *domain.UserLock) error {
+
+	filter := bson.M{domain.FIELD_ID: prev.ID, "owner": prev.Owner, "expiresAt": prev.ExpiresAt}
+	result, err := s.db.Collection(domain.USER_LOCK_COLLECTION_NAME).ReplaceOne(ctx, filter, lock)
+	if err != nil {
+		return storageError(err, lock.ID)
+	}
+	if result.MatchedCount == 0 {
+		return fmt.Errorf("%w: lock of %s changed", storage.ErrConflict, prev.ID)
+	}
+	return nil
+}
+
+func (s FinTrackerMongoStorage) DeleteUserLock(ctx context.Context, uid string) error {
+	return storageError(s.userLocks().DeleteByID(ctx, uid), uid)
+}
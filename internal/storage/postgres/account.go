@@ -0,0 +1,232 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/rkapps/fin-tracker-backend-go/internal/domain"
+	"github.com/rkapps/fin-tracker-backend-go/internal/storage"
+)
+
+var accounts = table[domain.Account]{
+	name: "accounts",
+	columns: []string{"id", "uid", "name", "active", "category", "type", "alternate_names", "alias_patterns",
+		"detail", "tax_status", "lot_matching_method", "created_at", "updated_at"},
+	values: func(a *domain.Account) []any {
+		return []any{a.ID, a.UID, a.Name, a.Active, a.Category, a.Type, jsonb{a.AlternateNames}, jsonb{a.AliasPatterns},
+			jsonb{a.Detail}, a.TaxStatus, a.LotMatchingMethod, a.CreatedAt, a.UpdatedAt}
+	},
+	scan: func(row scanner) (*domain.Account, error) {
+		a := &domain.Account{}
+		var detail rawJSON
+		err := row.Scan(&a.ID, &a.UID, &a.Name, &a.Active, &a.Category, &a.Type, jsonb{&a.AlternateNames}, jsonb{&a.AliasPatterns},
+			&detail, &a.TaxStatus, &a.LotMatchingMethod, &a.CreatedAt, &a.UpdatedAt)
+		if err != nil {
+			return nil, err
+		}
+		// the detail type is kept by the category
+		if a.Detail, err = domain.NewAccountDetail(a.Category); err != nil {
+			return nil, err
+		}
+		if len(detail) > 0 {
+			if err := json.Unmarshal(detail, a.Detail); err != nil {
+				return nil, err
+			}
+		}
+		return a, nil
+	},
+}
+
+var accountCredentials = table[domain.AccountCredential]{
+	name: "account_credentials",
+	columns: []string{"id", "uid", "provider", "api_key", "api_secret", "passphrase", "key_version", "wrapped_key",
+		"label", "permissions", "expires_at", "created_at", "last_used", "active"},
+	values: func(a *domain.AccountCredential) []any {
+		return []any{a.ID, a.UID, a.Provider, a.APIKey, a.APISecret, a.Passphrase, a.KeyVersion, a.WrappedKey,
+			a.Label, jsonb{a.Permissions}, a.ExpiresAt, a.CreatedAt, a.LastUsed, a.Active}
+	},
+	scan: func(row scanner) (*domain.AccountCredential, error) {
+		a := &domain.AccountCredential{}
+		return a, row.Scan(&a.ID, &a.UID, &a.Provider, &a.APIKey, &a.APISecret, &a.Passphrase, &a.KeyVersion, &a.WrappedKey,
+			&a.Label, jsonb{&a.Permissions}, &a.ExpiresAt, &a.CreatedAt, &a.LastUsed, &a.Active)
+	},
+}
+
+var accountSyncStates = table[domain.AccountSyncState]{
+	name: "account_sync_states",
+	columns: []string{"id", "uid", "last_sync_date", "resync", "refresh", "sync_status", "error_message", "warning",
+		"sync_start_date", "new_activities"},
+	values: func(a *domain.AccountSyncState) []any {
+		return []any{a.ID, a.UID, a.LastSyncDate, a.Resync, a.Refresh, a.SyncStatus, a.ErrorMessage, a.Warning,
+			a.SyncStartDate, a.NewActivities}
+	},
+	scan: func(row scanner) (*domain.AccountSyncState, error) {
+		a := &domain.AccountSyncState{}
+		return a, row.Scan(&a.ID, &a.UID, &a.LastSyncDate, &a.Resync, &a.Refresh, &a.SyncStatus, &a.ErrorMessage, &a.Warning,
+			&a.SyncStartDate, &a.NewActivities)
+	},
+}
+
+var accountSummaries = table[domain.AccountSummary]{
+	name: "account_summaries",
+	columns: []string{"id", "uid", "account_id", "date", "account_name", "category", "type", "parent_account_name",
+		"deposits", "withdrawals", "net_deposits", "income", "realized_gl", "cash", "sector_hldgs", "asset_type_hldgs",
+		"cost_value", "market_value"},
+	values: func(a *domain.AccountSummary) []any {
+		return []any{a.ID, a.UID, a.AccountID, a.Date, a.AccountName, a.Category, a.Type, a.ParentAccountName,
+			a.Deposits, a.Withdrawals, a.NetDeposits, a.Income, a.Realizedgl, a.Cash, jsonb{a.SectorHldgs}, jsonb{a.AssetTypeHlgds},
+			a.CostValue, a.MarketValue}
+	},
+	scan: func(row scanner) (*domain.AccountSummary, error) {
+		a := &domain.AccountSummary{}
+		return a, row.Scan(&a.ID, &a.UID, &a.AccountID, &a.Date, &a.AccountName, &a.Category, &a.Type, &a.ParentAccountName,
+			&a.Deposits, &a.Withdrawals, &a.NetDeposits, &a.Income, &a.Realizedgl, &a.Cash, jsonb{&a.SectorHldgs}, jsonb{&a.AssetTypeHlgds},
+			&a.CostValue, &a.MarketValue)
+	},
+}
+
+func (s FinTrackerPostgresStorage) GetAccount(ctx context.Context, uid string, id string) (*domain.Account, error) {
+	acct, err := accounts.get(ctx, s.db, id)
+	if err != nil {
+		return nil, err
+	}
+	if acct.UID != uid {
+		return nil, storage.UnauthorizedError(id)
+	}
+	return acct, nil
+}
+
+func (s FinTrackerPostgresStorage) GetAccountSyncState(ctx context.Context, uid string, id string) (*domain.AccountSyncState, error) {
+	astate, err := accountSyncStates.get(ctx, s.db, id)
+	if err != nil {
+		return nil, err
+	}
+	if astate.UID != uid {
+		return nil, storage.UnauthorizedError(id)
+	}
+	return astate, nil
+}
+
+func (s FinTrackerPostgresStorage) GetAccountSyncStates(ctx context.Context, uid string) ([]*domain.AccountSyncState, error) {
+	astates, err := accountSyncStates.find(ctx, s.db, "uid = $1", uid)
+	return astates, storageError(err, uid)
+}
+
+func (s FinTrackerPostgresStorage) GetAccountCredential(ctx context.Context, uid string, id string) (*domain.AccountCredential, error) {
+	acred, err := accountCredentials.get(ctx, s.db, id)
+	if err != nil {
+		return nil, err
+	}
+	if acred.UID != uid {
+		return nil, storage.UnauthorizedError(id)
+	}
+	return acred, nil
+}
+
+func (s FinTrackerPostgresStorage) GetAccountCredentials(ctx context.Context, uid string) ([]*domain.AccountCredential, error) {
+	acreds, err := accountCredentials.find(ctx, s.db, "uid = $1", uid)
+	return acreds, storageError(err, uid)
+}
+
+func (s FinTrackerPostgresStorage) GetAccounts(ctx context.Context, uid string) (domain.Accounts, error) {
+	accts, err := accounts.find(ctx, s.db, "uid = $1", uid)
+	return accts, storageError(err, uid)
+}
+
+func (s FinTrackerPostgresStorage) GetAccountSummaries(ctx context.Context, uid string) ([]*domain.AccountSummary, error) {
+	asumys, err := accountSummaries.find(ctx, s.db, "uid = $1", uid)
+	return asumys, storageError(err, uid)
+}
+
+func (s FinTrackerPostgresStorage) GetAccountSummariesByDate(ctx context.Context, uid string, startDate time.Time, endDate time.Time) ([]*domain.AccountSummary, error) {
+	w := &where{}
+	w.add("uid = " + w.arg(uid))
+	dateRange(w, "date", startDate, endDate)
+	asumys, err := accountSummaries.find(ctx, s.db, w.sql()+" ORDER BY date, account_id", w.args...)
+	return asumys, storageError(err, uid)
+}
+
+func (s FinTrackerPostgresStorage) GetLatestAccountSummaries(ctx context.Context, uid string) ([]*domain.AccountSummary, error) {
+	asumys, err := accountSummaries.find(ctx, s.db,
+		"uid = $1 AND date = (SELECT max(date) FROM account_summaries WHERE uid = $1) ORDER BY account_id", uid)
+	return asumys, storageError(err, uid)
+}
+
+// DeleteAccount deletes the user's account — deleting a missing account is not an error.
+func (s FinTrackerPostgresStorage) DeleteAccount(ctx context.Context, uid string, id string) error {
+	_, err := s.GetAccount(ctx, uid, id)
+	if errors.Is(err, storage.ErrNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return storageError(accounts.delete(ctx, s.db, id), id)
+}
+
+func (s FinTrackerPostgresStorage) DeleteAccountCredential(ctx context.Context, uid string, id string) error {
+	if _, err := s.GetAccountCredential(ctx, uid, id); err != nil {
+		return err
+	}
+	return storageError(accountCredentials.delete(ctx, s.db, id), id)
+}
+
+// DeleteAccountSyncState deletes the account's sync state — deleting a missing one is not an error.
+func (s FinTrackerPostgresStorage) DeleteAccountSyncState(ctx context.Context, uid string, id string) error {
+	_, err := s.GetAccountSyncState(ctx, uid, id)
+	if errors.Is(err, storage.ErrNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return storageError(accountSyncStates.delete(ctx, s.db, id), id)
+}
+
+// DeleteAccountSummaries deletes nothing when ids is empty.
+func (s FinTrackerPostgresStorage) DeleteAccountSummaries(ctx context.Context, ids []string) error {
+	return storageError(accountSummaries.delete(ctx, s.db, ids...), "")
+}
+
+func (s FinTrackerPostgresStorage) SaveAccount(ctx context.Context, data *domain.Account) error {
+	return storageError(s.inTx(ctx, func(tx *sql.Tx) error {
+		if err := ensureUsers(ctx, tx, data.UID); err != nil {
+			return err
+		}
+		return accounts.save(ctx, tx, data)
+	}), data.ID)
+}
+
+func (s FinTrackerPostgresStorage) SaveAccountSyncState(ctx context.Context, data *domain.AccountSyncState) error {
+	return storageError(s.inTx(ctx, func(tx *sql.Tx) error {
+		if err := ensureUsers(ctx, tx, data.UID); err != nil {
+			return err
+		}
+		return accountSyncStates.save(ctx, tx, data)
+	}), data.ID)
+}
+
+func (s FinTrackerPostgresStorage) SaveAccountCredential(ctx context.Context, data *domain.AccountCredential) error {
+	return storageError(s.inTx(ctx, func(tx *sql.Tx) error {
+		if err := ensureUsers(ctx, tx, data.UID); err != nil {
+			return err
+		}
+		return accountCredentials.save(ctx, tx, data)
+	}), data.ID)
+}
+
+func (s FinTrackerPostgresStorage) SaveAccountSummaries(ctx context.Context, asumys []*domain.AccountSummary) error {
+	uids := []string{}
+	for _, asum := range asumys {
+		uids = append(uids, asum.UID)
+	}
+	return storageError(s.inTx(ctx, func(tx *sql.Tx) error {
+		if err := ensureUsers(ctx, tx, uids...); err != nil {
+			return err
+		}
+		return accountSummaries.save(ctx, tx, asumys...)
+	}), "")
+}
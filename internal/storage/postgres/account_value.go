@@ -0,0 +1,50 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/rkapps/fin-tracker-backend-go/internal/domain"
+)
+
+var accountValues = table[domain.AccountValue]{
+	name: "account_values",
+	columns: []string{"id", "uid", "account_id", "date", "market_value", "cost_value", "cash", "net_deposits", "income",
+		"unpriced"},
+	values: func(v *domain.AccountValue) []any {
+		return []any{v.ID, v.UID, v.AccountID, v.Date, v.MarketValue, v.CostValue, v.Cash, v.NetDeposits, v.Income,
+			jsonb{v.Unpriced}}
+	},
+	scan: func(row scanner) (*domain.AccountValue, error) {
+		v := &domain.AccountValue{}
+		return v, row.Scan(&v.ID, &v.UID, &v.AccountID, &v.Date, &v.MarketValue, &v.CostValue, &v.Cash, &v.NetDeposits, &v.Income,
+			jsonb{&v.Unpriced})
+	},
+}
+
+func (s FinTrackerPostgresStorage) GetAccountValues(ctx context.Context, uid string, startDate time.Time, endDate time.Time) ([]*domain.AccountValue, error) {
+	w := &where{}
+	w.add("uid = " + w.arg(uid))
+	dateRange(w, "date", startDate, endDate)
+	values, err := accountValues.find(ctx, s.db, w.sql()+" ORDER BY date, account_id", w.args...)
+	return values, storageError(err, uid)
+}
+
+// DeleteAccountValues deletes nothing when ids is empty.
+func (s FinTrackerPostgresStorage) DeleteAccountValues(ctx context.Context, ids []string) error {
+	return storageError(accountValues.delete(ctx, s.db, ids...), "")
+}
+
+func (s FinTrackerPostgresStorage) SaveAccountValues(ctx context.Context, values []*domain.AccountValue) error {
+	uids := []string{}
+	for _, v := range values {
+		uids = append(uids, v.UID)
+	}
+	return storageError(s.inTx(ctx, func(tx *sql.Tx) error {
+		if err := ensureUsers(ctx, tx, uids...); err != nil {
+			return err
+		}
+		return accountValues.save(ctx, tx, values...)
+	}), "")
+}
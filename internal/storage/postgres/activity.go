@@ -0,0 +1,221 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/rkapps/fin-tracker-backend-go/internal/domain"
+	"github.com/rkapps/fin-tracker-backend-go/internal/storage"
+)
+
+var activityImports = table[domain.ActivityImport]{
+	name: "activity_imports",
+	columns: []string{"id", "uid", "account_id", "hash", "txn_wallet", "txn_type", "date",
+		"rcv_account", "rcv_address", "rcv_currency", "rcv_amount",
+		"sent_account", "sent_address", "sent_currency", "sent_amount", "sent_price", "sent_balance",
+		"gl_amount", "fee", "fee_currency", "notes", "process_status", "error_message"},
+	values: func(a *domain.ActivityImport) []any {
+		return []any{a.ID, a.UID, a.AccountID, a.Hash, a.TxnWallet, a.TxnType, a.Date,
+			a.RcvAccount, a.RcvAddress, a.RcvCurrency, a.RcvAmount,
+			a.SentAccount, a.SentAddress, a.SentCurrency, a.SentAmount, a.SentPrice, a.SentBalance,
+			a.GlAmount, a.Fee, a.FeeCurrency, a.Notes, a.ProcessStatus, a.ErrorMessage}
+	},
+	scan: func(row scanner) (*domain.ActivityImport, error) {
+		a := &domain.ActivityImport{}
+		return a, row.Scan(&a.ID, &a.UID, &a.AccountID, &a.Hash, &a.TxnWallet, &a.TxnType, &a.Date,
+			&a.RcvAccount, &a.RcvAddress, &a.RcvCurrency, &a.RcvAmount,
+			&a.SentAccount, &a.SentAddress, &a.SentCurrency, &a.SentAmount, &a.SentPrice, &a.SentBalance,
+			&a.GlAmount, &a.Fee, &a.FeeCurrency, &a.Notes, &a.ProcessStatus, &a.ErrorMessage)
+	},
+}
+
+var activities = table[domain.Activity]{
+	name: "activities",
+	columns: []string{"id", "uid", "account_id", "txn_type", "date", "status", "source_id", "source_type",
+		"rcv_symbol", "rcv_quantity", "rcv_price", "rcv_amount", "rcv_account", "rcv_balance",
+		"sent_symbol", "sent_quantity", "sent_price", "sent_amount", "sent_balance", "sent_account",
+		"value", "fee", "fee_currency", "commission", "tax", "tax_currency",
+		"rcv_account_id", "sent_account_id", "income_symbol", "linked_activity_id", "detail", "detail_type", "notes"},
+	values: func(a *domain.Activity) []any {
+		return []any{a.ID, a.UID, a.AccountID, a.TxnType, a.Date, a.Status, a.SourceID, a.SourceType,
+			a.RcvSymbol, a.RcvQuantity, a.RcvPrice, a.RcvAmount, a.RcvAccount, a.RcvBalance,
+			a.SentSymbol, a.SentQuantity, a.SentPrice, a.SentAmount, a.SentBalance, a.SentAccount,
+			a.Value, a.Fee, a.FeeCurrency, a.Commission, a.Tax, a.TaxCurrency,
+			a.RcvAccountID, a.SentAccountID, a.IncomeSymbol, a.LinkedActivityID, jsonb{a.Detail}, a.DetailType, a.Notes}
+	},
+	scan: func(row scanner) (*domain.Activity, error) {
+		a := &domain.Activity{}
+		var detail rawJSON
+		err := row.Scan(&a.ID, &a.UID, &a.AccountID, &a.TxnType, &a.Date, &a.Status, &a.SourceID, &a.SourceType,
+			&a.RcvSymbol, &a.RcvQuantity, &a.RcvPrice, &a.RcvAmount, &a.RcvAccount, &a.RcvBalance,
+			&a.SentSymbol, &a.SentQuantity, &a.SentPrice, &a.SentAmount, &a.SentBalance, &a.SentAccount,
+			&a.Value, &a.Fee, &a.FeeCurrency, &a.Commission, &a.Tax, &a.TaxCurrency,
+			&a.RcvAccountID, &a.SentAccountID, &a.IncomeSymbol, &a.LinkedActivityID, &detail, &a.DetailType, &a.Notes)
+		if err != nil || len(detail) == 0 {
+			return a, err
+		}
+		// the detail type is kept alongside the detail
+		a.Detail, err = domain.UnmarshalActivityDetailJSON(a.DetailType, detail)
+		return a, err
+	},
+}
+
+var activityLots = table[domain.ActivityLot]{
+	name: "activity_lots",
+	columns: []string{"id", "uid", "account_id", "actv_id", "sell_actv_id", "lot_seq", "symbol", "date",
+		"orig_qty", "qty", "cost", "cost_value", "fee", "send_qty", "send_date",
+		"sale_qty", "sale_date", "sale_price", "sale_fee", "status"},
+	values: func(l *domain.ActivityLot) []any {
+		return []any{l.ID, l.UID, l.AccountID, l.ActivityID, l.SellActivityID, l.LotSeq, l.Symbol, l.Date,
+			l.OrigQty, l.Qty, l.Cost, l.CostValue, l.Fee, l.SendQty, l.SendDate,
+			l.SaleQty, l.SaleDate, l.SalePrice, l.SaleFee, l.Status}
+	},
+	scan: func(row scanner) (*domain.ActivityLot, error) {
+		l := &domain.ActivityLot{}
+		return l, row.Scan(&l.ID, &l.UID, &l.AccountID, &l.ActivityID, &l.SellActivityID, &l.LotSeq, &l.Symbol, &l.Date,
+			&l.OrigQty, &l.Qty, &l.Cost, &l.CostValue, &l.Fee, &l.SendQty, &l.SendDate,
+			&l.SaleQty, &l.SaleDate, &l.SalePrice, &l.SaleFee, &l.Status)
+	},
+}
+
+// DeleteImortedActivities deletes nothing when ids is empty.
+func (s FinTrackerPostgresStorage) DeleteImortedActivities(ctx context.Context, ids []string) error {
+	return storageError(activityImports.delete(ctx, s.db, ids...), "")
+}
+
+// DeleteActivities deletes nothing when ids is empty. Their GL entries are deleted with them.
+func (s FinTrackerPostgresStorage) DeleteActivities(ctx context.Context, ids []string) error {
+	return storageError(activities.delete(ctx, s.db, ids...), "")
+}
+
+// DeleteActivityLots deletes nothing when ids is empty.
+func (s FinTrackerPostgresStorage) DeleteActivityLots(ctx context.Context, ids []string) error {
+	return storageError(activityLots.delete(ctx, s.db, ids...), "")
+}
+
+func (s FinTrackerPostgresStorage) GetImortedActivities(ctx context.Context, uid string, acctId string) ([]*domain.ActivityImport, error) {
+	actvs, err := activityImports.find(ctx, s.db, "uid = $1 AND account_id = $2", uid, acctId)
+	return actvs, storageError(err, acctId)
+}
+
+func (s FinTrackerPostgresStorage) GetActivities(ctx context.Context, uid string) ([]*domain.Activity, error) {
+	actvs, err := activities.find(ctx, s.db, "uid = $1", uid)
+	return actvs, storageError(err, uid)
+}
+
+func (s FinTrackerPostgresStorage) GetActivitiesForAccount(ctx context.Context, uid string, acctId string) ([]*domain.Activity, error) {
+	actvs, err := activities.find(ctx, s.db, "uid = $1 AND account_id = $2", uid, acctId)
+	return actvs, storageError(err, acctId)
+}
+
+func (s FinTrackerPostgresStorage) GetActivityLots(ctx context.Context, uid string) ([]*domain.ActivityLot, error) {
+	lots, err := activityLots.find(ctx, s.db, "uid = $1", uid)
+	return lots, storageError(err, uid)
+}
+
+func (s FinTrackerPostgresStorage) GetActivityLotsForAccount(ctx context.Context, uid string, acctId string) ([]*domain.ActivityLot, error) {
+	lots, err := activityLots.find(ctx, s.db, "uid = $1 AND account_id = $2", uid, acctId)
+	return lots, storageError(err, acctId)
+}
+
+func (s FinTrackerPostgresStorage) SaveImportedActivities(ctx context.Context, actvs []*domain.ActivityImport) error {
+	uids := []string{}
+	for _, actv := range actvs {
+		uids = append(uids, actv.UID)
+	}
+	return storageError(s.inTx(ctx, func(tx *sql.Tx) error {
+		if err := ensureUsers(ctx, tx, uids...); err != nil {
+			return err
+		}
+		return activityImports.save(ctx, tx, actvs...)
+	}), "")
+}
+
+func (s FinTrackerPostgresStorage) SaveActivities(ctx context.Context, actvs []*domain.Activity) error {
+	uids := []string{}
+	for _, actv := range actvs {
+		uids = append(uids, actv.UID)
+	}
+	return storageError(s.inTx(ctx, func(tx *sql.Tx) error {
+		if err := ensureUsers(ctx, tx, uids...); err != nil {
+			return err
+		}
+		return activities.save(ctx, tx, actvs...)
+	}), "")
+}
+
+func (s FinTrackerPostgresStorage) SaveActivityLots(ctx context.Context, lots []*domain.ActivityLot) error {
+	uids := []string{}
+	for _, lot := range lots {
+		uids = append(uids, lot.UID)
+	}
+	return storageError(s.inTx(ctx, func(tx *sql.Tx) error {
+		if err := ensureUsers(ctx, tx, uids...); err != nil {
+			return err
+		}
+		return activityLots.save(ctx, tx, lots...)
+	}), "")
+}
+
+// QueryActivities returns one page of the user's activities that match the query.
+func (s FinTrackerPostgresStorage) QueryActivities(ctx context.Context, uid string, query domain.ActivityQuery) (domain.Paged[*domain.Activity], error) {
+
+	pq, err := newPageQuery(query.Page, "date", "rcv_symbol")
+	if err != nil {
+		return domain.Paged[*domain.Activity]{}, err
+	}
+	w := &where{}
+	w.add("uid = " + w.arg(uid))
+	in(w, "account_id", query.AccountIDs)
+	in(w, "txn_type", query.TxnTypes)
+	in(w, "status", query.Statuses)
+	if len(query.Symbols) > 0 {
+		symbols := params(w, query.Symbols)
+		w.add(fmt.Sprintf("(rcv_symbol IN (%s) OR sent_symbol IN (%s))", symbols, symbols))
+	}
+	dateRange(w, "date", query.StartDate, query.EndDate)
+
+	actvs, err := activities.find(ctx, s.db, pq.where(w), w.args...)
+	if err != nil {
+		return domain.Paged[*domain.Activity]{}, storageError(err, uid)
+	}
+	return page(pq, actvs, func(actv *domain.Activity) string {
+		if pq.sort == domain.SortDate {
+			return storage.DateCursor(actv.Date, actv.ID)
+		}
+		return storage.Cursor{Value: actv.RcvSymbol, ID: actv.ID}.Encode()
+	}), nil
+}
+
+// QueryActivityLots returns one page of the user's lots that match the query.
+func (s FinTrackerPostgresStorage) QueryActivityLots(ctx context.Context, uid string, query domain.LotQuery) (domain.Paged[*domain.ActivityLot], error) {
+
+	// lots without a date sort first like the zero date of their cursor
+	pq, err := newPageQuery(query.Page, "COALESCE(date, '0001-01-01T00:00:00Z')", "symbol")
+	if err != nil {
+		return domain.Paged[*domain.ActivityLot]{}, err
+	}
+	w := &where{}
+	w.add("uid = " + w.arg(uid))
+	in(w, "account_id", query.AccountIDs)
+	in(w, "symbol", query.Symbols)
+	in(w, "status", query.Statuses)
+	dateRange(w, "date", query.StartDate, query.EndDate)
+
+	lots, err := activityLots.find(ctx, s.db, pq.where(w), w.args...)
+	if err != nil {
+		return domain.Paged[*domain.ActivityLot]{}, storageError(err, uid)
+	}
+	return page(pq, lots, func(lot *domain.ActivityLot) string {
+		if pq.sort == domain.SortSymbol {
+			return storage.Cursor{Value: lot.Symbol, ID: lot.ID}.Encode()
+		}
+		var date time.Time
+		if lot.Date != nil {
+			date = *lot.Date
+		}
+		return storage.DateCursor(date, lot.ID)
+	}), nil
+}
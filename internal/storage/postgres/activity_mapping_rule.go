@@ -0,0 +1,60 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"github.com/rkapps/fin-tracker-backend-go/internal/domain"
+	"github.com/rkapps/fin-tracker-backend-go/internal/storage"
+)
+
+var activityMappingRules = table[domain.ActivityMappingRule]{
+	name: "activity_mapping_rules",
+	columns: []string{"id", "uid", "account_id", "name", "priority", "txn_type_pattern", "notes_pattern",
+		"activity_type", "skip", "field_mapping"},
+	values: func(r *domain.ActivityMappingRule) []any {
+		return []any{r.ID, r.UID, nullText{&r.AccountID}, r.Name, r.Priority, r.TxnTypePattern, r.NotesPattern,
+			r.ActivityType, r.Skip, jsonb{r.FieldMapping}}
+	},
+	scan: func(row scanner) (*domain.ActivityMappingRule, error) {
+		r := &domain.ActivityMappingRule{}
+		return r, row.Scan(&r.ID, &r.UID, nullText{&r.AccountID}, &r.Name, &r.Priority, &r.TxnTypePattern, &r.NotesPattern,
+			&r.ActivityType, &r.Skip, jsonb{&r.FieldMapping})
+	},
+}
+
+// GetActivityMappingRules returns the user's mapping rules ordered by priority
+func (s FinTrackerPostgresStorage) GetActivityMappingRules(ctx context.Context, uid string) (domain.ActivityMappingRules, error) {
+	rules, err := activityMappingRules.find(ctx, s.db, "uid = $1 ORDER BY priority, id", uid)
+	return rules, storageError(err, uid)
+}
+
+// SaveActivityMappingRule adds or replaces the rule. Another user's rule is not replaced.
+func (s FinTrackerPostgresStorage) SaveActivityMappingRule(ctx context.Context, data *domain.ActivityMappingRule) error {
+	return storageError(s.inTx(ctx, func(tx *sql.Tx) error {
+		if err := ensureUsers(ctx, tx, data.UID); err != nil {
+			return err
+		}
+		var uid string
+		err := tx.QueryRowContext(ctx, `SELECT uid FROM activity_mapping_rules WHERE id = $1 FOR UPDATE`, data.ID).Scan(&uid)
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			return err
+		}
+		if err == nil && uid != data.UID {
+			return storage.UnauthorizedError(data.ID)
+		}
+		return activityMappingRules.save(ctx, tx, data)
+	}), data.ID)
+}
+
+func (s FinTrackerPostgresStorage) DeleteActivityMappingRule(ctx context.Context, uid string, id string) error {
+	rule, err := activityMappingRules.get(ctx, s.db, id)
+	if err != nil {
+		return err
+	}
+	if rule.UID != uid {
+		return storage.UnauthorizedError(id)
+	}
+	return storageError(activityMappingRules.delete(ctx, s.db, id), id)
+}
@@ -0,0 +1,45 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/rkapps/fin-tracker-backend-go/internal/domain"
+)
+
+var auditEntries = table[domain.AuditEntry]{
+	name: "audit_entries",
+	columns: []string{"id", "uid", "actor_uid", "entity_type", "entity_id", "account_id", "operation",
+		"changes", "request_id", "time"},
+	values: func(e *domain.AuditEntry) []any {
+		return []any{e.ID, e.UID, e.ActorUID, e.EntityType, e.EntityID, e.AccountID, e.Operation,
+			jsonb{e.Changes}, e.RequestID, e.Time}
+	},
+	scan: func(row scanner) (*domain.AuditEntry, error) {
+		e := &domain.AuditEntry{}
+		return e, row.Scan(&e.ID, &e.UID, &e.ActorUID, &e.EntityType, &e.EntityID, &e.AccountID, &e.Operation,
+			jsonb{&e.Changes}, &e.RequestID, &e.Time)
+	},
+}
+
+// SaveAuditEntry inserts the entry — the primary key refuses to replace one.
+func (s FinTrackerPostgresStorage) SaveAuditEntry(ctx context.Context, entry *domain.AuditEntry) error {
+	_, err := s.db.ExecContext(ctx, `INSERT INTO audit_entries (id, uid, actor_uid, entity_type, entity_id, account_id, operation,
+		changes, request_id, time) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)`, auditEntries.values(entry)...)
+	return storageError(err, entry.ID)
+}
+
+// GetAuditEntries returns the user's most recent entries first, optionally for one account.
+func (s FinTrackerPostgresStorage) GetAuditEntries(ctx context.Context, uid string, acctId string, limit int64) ([]*domain.AuditEntry, error) {
+	w := &where{}
+	w.add("uid = " + w.arg(uid))
+	if len(acctId) > 0 {
+		w.add("account_id = " + w.arg(acctId))
+	}
+	cond := w.sql() + " ORDER BY time DESC"
+	if limit > 0 {
+		cond += fmt.Sprintf(" LIMIT %d", limit)
+	}
+	entries, err := auditEntries.find(ctx, s.db, cond, w.args...)
+	return entries, storageError(err, uid)
+}
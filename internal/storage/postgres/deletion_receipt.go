@@ -0,0 +1,35 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/rkapps/fin-tracker-backend-go/internal/domain"
+)
+
+var deletionReceipts = table[domain.DeletionReceipt]{
+	name:    "deletion_receipts",
+	columns: []string{"id", "uid", "scope", "account_id", "requested", "finished", "status", "counts", "error"},
+	values: func(r *domain.DeletionReceipt) []any {
+		return []any{r.ID, r.UID, r.Scope, r.AccountID, r.Requested, r.Finished, r.Status, jsonb{r.Counts}, r.Error}
+	},
+	scan: func(row scanner) (*domain.DeletionReceipt, error) {
+		r := &domain.DeletionReceipt{}
+		return r, row.Scan(&r.ID, &r.UID, &r.Scope, &r.AccountID, &r.Requested, &r.Finished, &r.Status, jsonb{&r.Counts}, &r.Error)
+	},
+}
+
+func (s FinTrackerPostgresStorage) GetDeletionReceipt(ctx context.Context, id string) (*domain.DeletionReceipt, error) {
+	return deletionReceipts.get(ctx, s.db, id)
+}
+
+func (s FinTrackerPostgresStorage) GetDeletionReceiptsByStatus(ctx context.Context, status string) ([]*domain.DeletionReceipt, error) {
+	receipts, err := deletionReceipts.find(ctx, s.db, "status = $1", status)
+	return receipts, storageError(err, status)
+}
+
+func (s FinTrackerPostgresStorage) SaveDeletionReceipt(ctx context.Context, receipt *domain.DeletionReceipt) error {
+	return storageError(s.inTx(ctx, func(tx *sql.Tx) error {
+		return deletionReceipts.save(ctx, tx, receipt)
+	}), receipt.ID)
+}
@@ -0,0 +1,7 @@
+//go:build postgres
+
+package postgres
+
+// The pgx driver registers itself as "pgx". It is left out of the default build
+// so that the Mongo deployment does not need it — build with -tags postgres.
+import _ "github.com/jackc/pgx/v5/stdlib"
@@ -0,0 +1,372 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log/slog"
+)
+
+// migrationLock is the advisory lock held while migrating so that
+// instances starting together do not apply the same migration twice.
+const migrationLock = 727274
+
+// migration is one schema version. Applied versions are recorded in schema_migrations.
+type migration struct {
+	version    int
+	name       string
+	statements []string
+}
+
+// numeric columns keep the exact decimal — no precision or scale is imposed.
+var migrations = []migration{
+	{1, "schema", []string{
+		`CREATE TABLE users (
+			id                  TEXT COLLATE "C" PRIMARY KEY,
+			currency_code       TEXT NOT NULL DEFAULT '',
+			country             TEXT NOT NULL DEFAULT '',
+			lot_matching_method TEXT NOT NULL DEFAULT ''
+		)`,
+		`CREATE TABLE accounts (
+			id                  TEXT COLLATE "C" PRIMARY KEY,
+			uid                 TEXT COLLATE "C" NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+			name                TEXT NOT NULL,
+			active              BOOLEAN NOT NULL,
+			category            TEXT NOT NULL,
+			type                TEXT NOT NULL,
+			alternate_names     JSONB,
+			alias_patterns      JSONB,
+			detail              JSONB,
+			tax_status          TEXT NOT NULL,
+			lot_matching_method TEXT NOT NULL,
+			created_at          TIMESTAMPTZ NOT NULL,
+			updated_at          TIMESTAMPTZ NOT NULL
+		)`,
+		`CREATE INDEX idx_accounts_uid ON accounts (uid)`,
+		`CREATE TABLE account_credentials (
+			id          TEXT COLLATE "C" PRIMARY KEY,
+			uid         TEXT COLLATE "C" NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+			provider    TEXT NOT NULL,
+			api_key     TEXT NOT NULL,
+			api_secret  TEXT NOT NULL,
+			passphrase  TEXT NOT NULL,
+			key_version TEXT NOT NULL,
+			wrapped_key TEXT NOT NULL,
+			label       TEXT NOT NULL,
+			permissions JSONB,
+			expires_at  TIMESTAMPTZ,
+			created_at  TIMESTAMPTZ NOT NULL,
+			last_used   TIMESTAMPTZ,
+			active      BOOLEAN NOT NULL
+		)`,
+		`CREATE INDEX idx_account_credentials_uid ON account_credentials (uid)`,
+		`CREATE TABLE account_sync_states (
+			id              TEXT COLLATE "C" PRIMARY KEY,
+			uid             TEXT COLLATE "C" NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+			last_sync_date  TIMESTAMPTZ,
+			resync          BOOLEAN NOT NULL,
+			refresh         BOOLEAN NOT NULL,
+			sync_status     TEXT NOT NULL,
+			error_message   TEXT NOT NULL,
+			warning         TEXT NOT NULL,
+			sync_start_date TIMESTAMPTZ,
+			new_activities  INTEGER NOT NULL
+		)`,
+		`CREATE INDEX idx_account_sync_states_uid ON account_sync_states (uid)`,
+		`CREATE TABLE account_summaries (
+			id                  TEXT COLLATE "C" PRIMARY KEY,
+			uid                 TEXT COLLATE "C" NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+			account_id          TEXT COLLATE "C" NOT NULL,
+			date                TIMESTAMPTZ NOT NULL,
+			account_name        TEXT NOT NULL,
+			category            TEXT NOT NULL,
+			type                TEXT NOT NULL,
+			parent_account_name TEXT NOT NULL,
+			deposits            NUMERIC NOT NULL,
+			withdrawals         NUMERIC NOT NULL,
+			net_deposits        NUMERIC NOT NULL,
+			income              NUMERIC NOT NULL,
+			realized_gl         NUMERIC NOT NULL,
+			cash                NUMERIC NOT NULL,
+			sector_hldgs        JSONB,
+			asset_type_hldgs    JSONB,
+			cost_value          NUMERIC NOT NULL,
+			market_value        NUMERIC NOT NULL
+		)`,
+		`CREATE INDEX idx_account_summaries_uid ON account_summaries (uid)`,
+		`CREATE TABLE activity_imports (
+			id             TEXT COLLATE "C" PRIMARY KEY,
+			uid            TEXT COLLATE "C" NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+			account_id     TEXT COLLATE "C" NOT NULL,
+			hash           TEXT NOT NULL,
+			txn_wallet     TEXT NOT NULL,
+			txn_type       TEXT NOT NULL,
+			date           TIMESTAMPTZ,
+			rcv_account    TEXT NOT NULL,
+			rcv_address    TEXT NOT NULL,
+			rcv_currency   TEXT NOT NULL,
+			rcv_amount     NUMERIC NOT NULL,
+			sent_account   TEXT NOT NULL,
+			sent_address   TEXT NOT NULL,
+			sent_currency  TEXT NOT NULL,
+			sent_amount    NUMERIC NOT NULL,
+			sent_price     NUMERIC NOT NULL,
+			sent_balance   NUMERIC NOT NULL,
+			gl_amount      NUMERIC NOT NULL,
+			fee            NUMERIC NOT NULL,
+			fee_currency   TEXT NOT NULL,
+			notes          TEXT NOT NULL,
+			process_status TEXT NOT NULL,
+			error_message  TEXT NOT NULL
+		)`,
+		`CREATE INDEX idx_activity_imports_uid_account_id ON activity_imports (uid, account_id)`,
+		`CREATE TABLE activities (
+			id                 TEXT COLLATE "C" PRIMARY KEY,
+			uid                TEXT COLLATE "C" NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+			account_id         TEXT COLLATE "C" NOT NULL,
+			txn_type           TEXT NOT NULL,
+			date               TIMESTAMPTZ NOT NULL,
+			status             TEXT NOT NULL,
+			source_id          TEXT NOT NULL,
+			source_type        TEXT NOT NULL,
+			rcv_symbol         TEXT COLLATE "C" NOT NULL,
+			rcv_quantity       NUMERIC NOT NULL,
+			rcv_price          NUMERIC NOT NULL,
+			rcv_amount         NUMERIC NOT NULL,
+			rcv_account        TEXT NOT NULL,
+			rcv_balance        NUMERIC NOT NULL,
+			sent_symbol        TEXT COLLATE "C" NOT NULL,
+			sent_quantity      NUMERIC NOT NULL,
+			sent_price         NUMERIC NOT NULL,
+			sent_amount        NUMERIC NOT NULL,
+			sent_balance       NUMERIC NOT NULL,
+			sent_account       TEXT NOT NULL,
+			value              NUMERIC NOT NULL,
+			fee                NUMERIC NOT NULL,
+			fee_currency       TEXT NOT NULL,
+			commission         NUMERIC NOT NULL,
+			tax                NUMERIC NOT NULL,
+			tax_currency       TEXT NOT NULL,
+			rcv_account_id     TEXT COLLATE "C" NOT NULL,
+			sent_account_id    TEXT COLLATE "C" NOT NULL,
+			income_symbol      TEXT NOT NULL,
+			linked_activity_id TEXT COLLATE "C" NOT NULL,
+			detail             JSONB,
+			detail_type        TEXT NOT NULL,
+			notes              TEXT NOT NULL
+		)`,
+		`CREATE INDEX idx_activities_uid_date_id ON activities (uid, date, id)`,
+		`CREATE INDEX idx_activities_uid_account_id_date_id ON activities (uid, account_id, date, id)`,
+		`CREATE INDEX idx_activities_uid_txn_type_date ON activities (uid, txn_type, date)`,
+		`CREATE INDEX idx_activities_uid_rcv_symbol_id ON activities (uid, rcv_symbol, id)`,
+		`CREATE TABLE activity_lots (
+			id           TEXT COLLATE "C" PRIMARY KEY,
+			uid          TEXT COLLATE "C" NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+			account_id   TEXT COLLATE "C" NOT NULL,
+			actv_id      TEXT COLLATE "C" NOT NULL,
+			sell_actv_id TEXT COLLATE "C" NOT NULL,
+			lot_seq      INTEGER NOT NULL,
+			symbol       TEXT COLLATE "C" NOT NULL,
+			date         TIMESTAMPTZ,
+			orig_qty     NUMERIC NOT NULL,
+			qty          NUMERIC NOT NULL,
+			cost         NUMERIC NOT NULL,
+			cost_value   NUMERIC NOT NULL,
+			fee          NUMERIC NOT NULL,
+			send_qty     NUMERIC NOT NULL,
+			send_date    TIMESTAMPTZ,
+			sale_qty     NUMERIC NOT NULL,
+			sale_date    TIMESTAMPTZ,
+			sale_price   NUMERIC NOT NULL,
+			sale_fee     NUMERIC NOT NULL,
+			status       TEXT NOT NULL
+		)`,
+		`CREATE INDEX idx_activity_lots_uid_status_account_id_symbol ON activity_lots (uid, status, account_id, symbol)`,
+		`CREATE INDEX idx_activity_lots_uid_date_id ON activity_lots (uid, date, id)`,
+		`CREATE TABLE activity_mapping_rules (
+			id               TEXT COLLATE "C" PRIMARY KEY,
+			uid              TEXT COLLATE "C" NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+			account_id       TEXT COLLATE "C" NOT NULL,
+			name             TEXT NOT NULL,
+			priority         INTEGER NOT NULL,
+			txn_type_pattern TEXT NOT NULL,
+			notes_pattern    TEXT NOT NULL,
+			activity_type    TEXT NOT NULL,
+			skip             BOOLEAN NOT NULL,
+			field_mapping    JSONB
+		)`,
+		`CREATE INDEX idx_activity_mapping_rules_uid ON activity_mapping_rules (uid)`,
+		`CREATE TABLE gl_entries (
+			id                  TEXT COLLATE "C" PRIMARY KEY,
+			uid                 TEXT COLLATE "C" NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+			account_id          TEXT COLLATE "C" NOT NULL,
+			activity_id         TEXT COLLATE "C" NOT NULL REFERENCES activities (id) ON DELETE CASCADE,
+			txn_type            TEXT NOT NULL,
+			gl_type             TEXT NOT NULL,
+			currency            TEXT NOT NULL,
+			quantity            NUMERIC NOT NULL,
+			cost_basis          NUMERIC NOT NULL,
+			cost_basis_per_unit NUMERIC NOT NULL,
+			proceeds            NUMERIC NOT NULL,
+			proceeds_per_unit   NUMERIC NOT NULL,
+			gain_loss           NUMERIC NOT NULL,
+			is_short_term       BOOLEAN NOT NULL,
+			holding_period      INTEGER NOT NULL,
+			acquired_date       TIMESTAMPTZ NOT NULL,
+			disposed_date       TIMESTAMPTZ NOT NULL,
+			fee                 NUMERIC NOT NULL,
+			fee_currency        TEXT NOT NULL,
+			notes               TEXT NOT NULL
+		)`,
+		`CREATE INDEX idx_gl_entries_uid_activity_id ON gl_entries (uid, activity_id)`,
+		`CREATE TABLE pipeline_runs (
+			id        TEXT COLLATE "C" PRIMARY KEY,
+			command   TEXT NOT NULL,
+			args      JSONB,
+			started   TIMESTAMPTZ NOT NULL,
+			finished  TIMESTAMPTZ,
+			workers   INTEGER NOT NULL,
+			status    TEXT NOT NULL,
+			jobs      INTEGER NOT NULL,
+			succeeded INTEGER NOT NULL,
+			failed    INTEGER NOT NULL,
+			skipped   INTEGER NOT NULL,
+			retries   INTEGER NOT NULL,
+			error     TEXT NOT NULL,
+			outcomes  JSONB
+		)`,
+		`CREATE INDEX idx_pipeline_runs_command_started ON pipeline_runs (command, started DESC)`,
+		`CREATE TABLE transactions (
+			id          TEXT COLLATE "C" PRIMARY KEY,
+			uid         TEXT COLLATE "C" NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+			date        TIMESTAMPTZ NOT NULL,
+			txn_group   TEXT NOT NULL,
+			category    TEXT NOT NULL,
+			account     TEXT NOT NULL,
+			description TEXT NOT NULL,
+			dbcr        TEXT NOT NULL,
+			amount      DOUBLE PRECISION NOT NULL,
+			damount     DOUBLE PRECISION NOT NULL,
+			camount     DOUBLE PRECISION NOT NULL,
+			tag         TEXT NOT NULL
+		)`,
+		`CREATE INDEX idx_transactions_uid_date ON transactions (uid, date)`,
+		`CREATE TABLE user_locks (
+			id           TEXT COLLATE "C" PRIMARY KEY REFERENCES users (id) ON DELETE CASCADE,
+			operation    TEXT NOT NULL,
+			owner        TEXT NOT NULL,
+			acquired_at  TIMESTAMPTZ NOT NULL,
+			heartbeat_at TIMESTAMPTZ NOT NULL,
+			expires_at   TIMESTAMPTZ NOT NULL
+		)`,
+	}},
+	// receipts outlive the user — no foreign key to users
+	{2, "deletion receipts", []string{
+		`CREATE TABLE deletion_receipts (
+			id         TEXT COLLATE "C" PRIMARY KEY,
+			uid        TEXT COLLATE "C" NOT NULL,
+			scope      TEXT NOT NULL,
+			account_id TEXT COLLATE "C" NOT NULL,
+			requested  TIMESTAMPTZ NOT NULL,
+			finished   TIMESTAMPTZ,
+			status     TEXT NOT NULL,
+			counts     JSONB,
+			error      TEXT NOT NULL
+		)`,
+	}},
+	// the audit trail is append only and also outlives the user
+	{3, "audit entries", []string{
+		`CREATE TABLE audit_entries (
+			id          TEXT COLLATE "C" PRIMARY KEY,
+			uid         TEXT COLLATE "C" NOT NULL,
+			actor_uid   TEXT COLLATE "C" NOT NULL,
+			entity_type TEXT NOT NULL,
+			entity_id   TEXT COLLATE "C" NOT NULL,
+			account_id  TEXT COLLATE "C" NOT NULL,
+			operation   TEXT NOT NULL,
+			changes     JSONB,
+			request_id  TEXT NOT NULL,
+			time        TIMESTAMPTZ NOT NULL
+		)`,
+		`CREATE INDEX idx_audit_entries_uid_time ON audit_entries (uid, time DESC)`,
+		`CREATE INDEX idx_audit_entries_uid_account_id_time ON audit_entries (uid, account_id, time DESC)`,
+	}},
+	// summaries are kept as daily snapshots
+	{4, "account summary snapshots", []string{
+		`CREATE INDEX idx_account_summaries_uid_date ON account_summaries (uid, date)`,
+	}},
+	{5, "account values", []string{
+		`CREATE TABLE account_values (
+			id           TEXT COLLATE "C" PRIMARY KEY,
+			uid          TEXT COLLATE "C" NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+			account_id   TEXT COLLATE "C" NOT NULL,
+			date         TIMESTAMPTZ NOT NULL,
+			market_value NUMERIC NOT NULL,
+			cost_value   NUMERIC NOT NULL,
+			cash         NUMERIC NOT NULL,
+			net_deposits NUMERIC NOT NULL,
+			income       NUMERIC NOT NULL,
+			unpriced     JSONB
+		)`,
+		`CREATE INDEX idx_account_values_uid_date ON account_values (uid, date)`,
+	}},
+	// records of an account go with it. Left out: the counterparty ids of activities,
+	// which can be an unresolved placeholder, and the receipts and audit trail that
+	// outlive the account. NOT VALID keeps the rows left behind by accounts deleted
+	// before deletes cascaded — deleting the user removes them.
+	{6, "account foreign keys", []string{
+		`ALTER TABLE account_credentials ADD FOREIGN KEY (id) REFERENCES accounts (id) ON DELETE CASCADE NOT VALID`,
+		`ALTER TABLE account_sync_states ADD FOREIGN KEY (id) REFERENCES accounts (id) ON DELETE CASCADE NOT VALID`,
+		`ALTER TABLE account_summaries ADD FOREIGN KEY (account_id) REFERENCES accounts (id) ON DELETE CASCADE NOT VALID`,
+		`ALTER TABLE account_values ADD FOREIGN KEY (account_id) REFERENCES accounts (id) ON DELETE CASCADE NOT VALID`,
+		`ALTER TABLE activity_imports ADD FOREIGN KEY (account_id) REFERENCES accounts (id) ON DELETE CASCADE NOT VALID`,
+		`ALTER TABLE activities ADD FOREIGN KEY (account_id) REFERENCES accounts (id) ON DELETE CASCADE NOT VALID`,
+		`ALTER TABLE activity_lots ADD FOREIGN KEY (account_id) REFERENCES accounts (id) ON DELETE CASCADE NOT VALID`,
+		`ALTER TABLE gl_entries ADD FOREIGN KEY (account_id) REFERENCES accounts (id) ON DELETE CASCADE NOT VALID`,
+		// user wide rules have no account
+		`ALTER TABLE activity_mapping_rules ALTER COLUMN account_id DROP NOT NULL`,
+		`UPDATE activity_mapping_rules SET account_id = NULL WHERE account_id = ''`,
+		`ALTER TABLE activity_mapping_rules ADD FOREIGN KEY (account_id) REFERENCES accounts (id) ON DELETE CASCADE NOT VALID`,
+	}},
+}
+
+// Migrate applies the migrations newer than the database's schema version.
+func Migrate(ctx context.Context, db *sql.DB) error {
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return storageError(err, "")
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, `SELECT pg_advisory_xact_lock($1)`, migrationLock); err != nil {
+		return storageError(err, "")
+	}
+	if _, err := tx.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS schema_migrations (
+		version    INTEGER PRIMARY KEY,
+		name       TEXT NOT NULL,
+		applied_at TIMESTAMPTZ NOT NULL DEFAULT now()
+	)`); err != nil {
+		return storageError(err, "")
+	}
+	var current int
+	if err := tx.QueryRowContext(ctx, `SELECT COALESCE(MAX(version), 0) FROM schema_migrations`).Scan(&current); err != nil {
+		return storageError(err, "")
+	}
+
+	for _, m := range migrations {
+		if m.version <= current {
+			continue
+		}
+		slog.Info("Migrate", "Version", m.version, "Name", m.name)
+		for _, stmt := range m.statements {
+			if _, err := tx.ExecContext(ctx, stmt); err != nil {
+				return fmt.Errorf("migration %d %s: %w", m.version, m.name, storageError(err, ""))
+			}
+		}
+		if _, err := tx.ExecContext(ctx, `INSERT INTO schema_migrations (version, name) VALUES ($1, $2)`, m.version, m.name); err != nil {
+			return storageError(err, "")
+		}
+	}
+	return storageError(tx.Commit(), "")
+}
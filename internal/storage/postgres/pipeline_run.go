@@ -0,0 +1,48 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/rkapps/fin-tracker-backend-go/internal/domain"
+)
+
+var pipelineRuns = table[domain.PipelineRun]{
+	name: "pipeline_runs",
+	columns: []string{"id", "command", "args", "started", "finished", "workers", "status",
+		"jobs", "succeeded", "failed", "skipped", "retries", "error", "outcomes"},
+	values: func(r *domain.PipelineRun) []any {
+		return []any{r.ID, r.Command, jsonb{r.Args}, r.Started, r.Finished, r.Workers, r.Status,
+			r.Jobs, r.Succeeded, r.Failed, r.Skipped, r.Retries, r.Error, jsonb{r.Outcomes}}
+	},
+	scan: func(row scanner) (*domain.PipelineRun, error) {
+		r := &domain.PipelineRun{}
+		return r, row.Scan(&r.ID, &r.Command, jsonb{&r.Args}, &r.Started, &r.Finished, &r.Workers, &r.Status,
+			&r.Jobs, &r.Succeeded, &r.Failed, &r.Skipped, &r.Retries, &r.Error, jsonb{&r.Outcomes})
+	},
+}
+
+func (s FinTrackerPostgresStorage) GetPipelineRun(ctx context.Context, id string) (*domain.PipelineRun, error) {
+	return pipelineRuns.get(ctx, s.db, id)
+}
+
+// GetPipelineRuns returns the most recent runs first, optionally for one command.
+func (s FinTrackerPostgresStorage) GetPipelineRuns(ctx context.Context, command string, limit int64) ([]*domain.PipelineRun, error) {
+	w := &where{}
+	if len(command) > 0 {
+		w.add("command = " + w.arg(command))
+	}
+	cond := w.sql() + " ORDER BY started DESC"
+	if limit > 0 {
+		cond += fmt.Sprintf(" LIMIT %d", limit)
+	}
+	runs, err := pipelineRuns.find(ctx, s.db, cond, w.args...)
+	return runs, storageError(err, command)
+}
+
+func (s FinTrackerPostgresStorage) SavePipelineRun(ctx context.Context, run *domain.PipelineRun) error {
+	return storageError(s.inTx(ctx, func(tx *sql.Tx) error {
+		return pipelineRuns.save(ctx, tx, run)
+	}), run.ID)
+}
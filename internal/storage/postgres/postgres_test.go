@@ -0,0 +1,84 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"os"
+	"testing"
+
+	"github.com/rkapps/fin-tracker-backend-go/internal/domain"
+	"github.com/rkapps/fin-tracker-backend-go/internal/storage"
+	"github.com/rkapps/fin-tracker-backend-go/internal/storage/storagetest"
+)
+
+// testDB connects to FINTRACKER_TEST_POSTGRES_DSN, a database the tests migrate
+// and empty — run with -tags postgres so the driver is linked.
+func testDB(t *testing.T) *sql.DB {
+	dsn := os.Getenv("FINTRACKER_TEST_POSTGRES_DSN")
+	if len(dsn) == 0 {
+		t.Skip("set FINTRACKER_TEST_POSTGRES_DSN to run against Postgres")
+	}
+	ctx := context.Background()
+	db, err := Open(ctx, dsn)
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	if err := Migrate(ctx, db); err != nil {
+		t.Fatalf("Migrate() error = %v", err)
+	}
+	return db
+}
+
+// truncate empties every table.
+func truncate(t *testing.T, db *sql.DB) {
+	_, err := db.ExecContext(context.Background(), `TRUNCATE users, audit_entries, accounts, account_credentials, account_sync_states, account_summaries, account_values,
+		activity_imports, activities, activity_lots, activity_mapping_rules, deletion_receipts, gl_entries, pipeline_runs, transactions, user_locks CASCADE`)
+	if err != nil {
+		t.Fatalf("truncate: %v", err)
+	}
+}
+
+func TestConformance(t *testing.T) {
+	db := testDB(t)
+	storagetest.RunFinTracker(t, func(t *testing.T) storage.FinTrackerStorageService {
+		truncate(t, db)
+		return NewFinTrackerPostgresStorage(db)
+	})
+}
+
+// TestAccountForeignKeys covers what only Postgres enforces: records need their
+// account and go with it.
+func TestAccountForeignKeys(t *testing.T) {
+	db := testDB(t)
+	truncate(t, db)
+	ctx := context.Background()
+	s := NewFinTrackerPostgresStorage(db)
+
+	orphan := []*domain.Activity{{ID: "x1", UID: "u1", AccountID: "missing"}}
+	if err := s.SaveActivities(ctx, orphan); !errors.Is(err, storage.ErrConflict) {
+		t.Fatalf("SaveActivities() of a missing account error = %v, want ErrConflict", err)
+	}
+
+	if err := s.SaveAccount(ctx, &domain.Account{ID: "a1", UID: "u1", Name: "Brokerage", Active: true}); err != nil {
+		t.Fatalf("SaveAccount() error = %v", err)
+	}
+	s.SaveAccountSyncState(ctx, &domain.AccountSyncState{ID: "a1", UID: "u1", SyncStatus: domain.SyncStatusPending})
+	s.SaveActivities(ctx, []*domain.Activity{{ID: "x1", UID: "u1", AccountID: "a1"}})
+	s.SaveActivityMappingRule(ctx, &domain.ActivityMappingRule{ID: "r1", UID: "u1", AccountID: "a1", Name: "rule", NotesPattern: "x", Skip: true})
+	s.SaveActivityMappingRule(ctx, &domain.ActivityMappingRule{ID: "r2", UID: "u1", Name: "user wide", NotesPattern: "y", Skip: true})
+
+	if err := s.DeleteAccount(ctx, "u1", "a1"); err != nil {
+		t.Fatalf("DeleteAccount() error = %v", err)
+	}
+	if actvs, _ := s.GetActivities(ctx, "u1"); len(actvs) != 0 {
+		t.Errorf("GetActivities() after account delete = %d, want 0", len(actvs))
+	}
+	if _, err := s.GetAccountSyncState(ctx, "u1", "a1"); !errors.Is(err, storage.ErrNotFound) {
+		t.Errorf("GetAccountSyncState() after account delete error = %v, want ErrNotFound", err)
+	}
+	if rules, _ := s.GetActivityMappingRules(ctx, "u1"); len(rules) != 1 || rules[0].ID != "r2" {
+		t.Errorf("GetActivityMappingRules() after account delete = %v, want the user wide rule", rules)
+	}
+}
@@ -0,0 +1,80 @@
+package postgres
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/rkapps/fin-tracker-backend-go/internal/domain"
+	"github.com/rkapps/fin-tracker-backend-go/internal/storage"
+)
+
+// pageQuery is the keyset condition and order of one page sorted on the given column.
+type pageQuery struct {
+	sort   string // date or symbol
+	column string // sql expression of the sort
+	desc   bool
+	after  *storage.Cursor // nil on the first page
+	value  any             // sort value of the cursor
+	limit  int
+}
+
+// newPageQuery maps the page sort to the columns of the table.
+func newPageQuery(page domain.Page, dateColumn string, symbolColumn string) (pageQuery, error) {
+
+	sortField, desc, err := page.SortField()
+	if err != nil {
+		return pageQuery{}, err
+	}
+	q := pageQuery{sort: sortField, column: dateColumn, desc: desc, limit: page.PageLimit()}
+	if sortField == domain.SortSymbol {
+		q.column = symbolColumn
+	}
+
+	cursor, err := storage.DecodeCursor(page.Cursor)
+	if err != nil || cursor == nil {
+		return q, err
+	}
+	q.after, q.value = cursor, cursor.Value
+	if sortField == domain.SortDate {
+		if q.value, err = cursor.Date(); err != nil {
+			return q, err
+		}
+	}
+	return q, nil
+}
+
+// where ands the query conditions with the cursor, then orders on the column
+// with the id as tie breaker and asks for one more than the page to know if there is a next one.
+func (q pageQuery) where(w *where) string {
+	op, dir := ">", "ASC"
+	if q.desc {
+		op, dir = "<", "DESC"
+	}
+	if q.after != nil {
+		w.add(fmt.Sprintf("(%s, id) %s (%s, %s)", q.column, op, w.arg(q.value), w.arg(q.after.ID)))
+	}
+	sql := fmt.Sprintf("%s ORDER BY %s %s, id %s", w.sql(), q.column, dir, dir)
+	if q.limit > 0 {
+		sql += fmt.Sprintf(" LIMIT %d", q.limit+1)
+	}
+	return sql
+}
+
+// page trims the fetched items to the limit and sets the cursor after the last one.
+func page[T any](q pageQuery, items []T, cursor func(T) string) domain.Paged[T] {
+	if q.limit == 0 || len(items) <= q.limit {
+		return domain.Paged[T]{Items: items}
+	}
+	items = items[:q.limit]
+	return domain.Paged[T]{Items: items, NextCursor: cursor(items[len(items)-1])}
+}
+
+// dateRange matches the column between start and end inclusive, a zero bound is open.
+func dateRange(w *where, column string, start time.Time, end time.Time) {
+	if !start.IsZero() {
+		w.add(column + " >= " + w.arg(start))
+	}
+	if !end.IsZero() {
+		w.add(column + " <= " + w.arg(end))
+	}
+}
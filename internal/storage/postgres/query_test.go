@@ -0,0 +1,69 @@
+package postgres
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/rkapps/fin-tracker-backend-go/internal/domain"
+	"github.com/rkapps/fin-tracker-backend-go/internal/storage"
+)
+
+func TestPageQueryWhere(t *testing.T) {
+
+	date := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name string
+		page domain.Page
+		sql  string
+		args int
+	}{
+		{"first page", domain.Page{Limit: 10},
+			"uid = $1 AND symbol IN ($2, $3) ORDER BY date DESC, id DESC LIMIT 11", 3},
+		{"everything", domain.Page{Sort: "symbol"},
+			"uid = $1 AND symbol IN ($2, $3) ORDER BY symbol ASC, id ASC", 3},
+		{"after date", domain.Page{Limit: 5, Cursor: storage.DateCursor(date, "a1")},
+			"uid = $1 AND symbol IN ($2, $3) AND (date, id) < ($4, $5) ORDER BY date DESC, id DESC LIMIT 6", 5},
+		{"after symbol", domain.Page{Sort: "-symbol", Cursor: storage.Cursor{Value: "VTI", ID: "a2"}.Encode()},
+			"uid = $1 AND symbol IN ($2, $3) AND (symbol, id) < ($4, $5) ORDER BY symbol DESC, id DESC", 5},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pq, err := newPageQuery(tt.page, "date", "symbol")
+			if err != nil {
+				t.Fatal(err)
+			}
+			w := &where{}
+			w.add("uid = " + w.arg("u1"))
+			in(w, "symbol", []string{"VTI", "BND"})
+			in(w, "status", []domain.LotStatus{})
+			if got := pq.where(w); got != tt.sql {
+				t.Errorf("where = %q, want %q", got, tt.sql)
+			}
+			if len(w.args) != tt.args {
+				t.Errorf("args = %d, want %d", len(w.args), tt.args)
+			}
+		})
+	}
+
+	if _, err := newPageQuery(domain.Page{Cursor: "bogus"}, "date", "symbol"); !errors.Is(err, storage.ErrInvalidCursor) {
+		t.Errorf("newPageQuery(bogus cursor) error = %v, want ErrInvalidCursor", err)
+	}
+}
+
+func TestUpsertSQL(t *testing.T) {
+	want := "INSERT INTO users (id, currency_code, country, lot_matching_method) VALUES ($1, $2, $3, $4) " +
+		"ON CONFLICT (id) DO UPDATE SET currency_code = EXCLUDED.currency_code, country = EXCLUDED.country, " +
+		"lot_matching_method = EXCLUDED.lot_matching_method"
+	if got := users.upsertSQL(); got != want {
+		t.Errorf("upsertSQL = %q, want %q", got, want)
+	}
+}
+
+func TestMigrationVersions(t *testing.T) {
+	for i, m := range migrations {
+		if m.version != i+1 {
+			t.Errorf("migration %s: version = %d, want %d", m.name, m.version, i+1)
+		}
+	}
+}
@@ -0,0 +1,109 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/google/uuid"
+	"github.com/rkapps/fin-tracker-backend-go/internal/domain"
+)
+
+var transactions = table[domain.Transaction]{
+	name: "transactions",
+	columns: []string{"id", "uid", "date", "txn_group", "category", "account", "description",
+		"dbcr", "amount", "damount", "camount", "tag"},
+	values: func(t *domain.Transaction) []any {
+		return []any{t.ID, t.UID, t.Date, t.Group, t.Category, t.Account, t.Description,
+			t.Dbcr, t.Amount, t.DAmount, t.CAmount, t.Tag}
+	},
+	scan: func(row scanner) (*domain.Transaction, error) {
+		t := &domain.Transaction{}
+		return t, row.Scan(&t.ID, &t.UID, &t.Date, &t.Group, &t.Category, &t.Account, &t.Description,
+			&t.Dbcr, &t.Amount, &t.DAmount, &t.CAmount, &t.Tag)
+	},
+}
+
+// searchWhere matches the user's transactions between the dates (inclusive) like the Atlas
+// autocomplete: every word of the search text starts a word of the account, category,
+// group, description or tag, ignoring case.
+func searchWhere(uid string, startDate time.Time, endDate time.Time, searchText string) *where {
+	w := &where{}
+	w.add("uid = " + w.arg(uid))
+	dateRange(w, "date", startDate, endDate)
+	for _, term := range words(searchText) {
+		// words are letters and numbers only — nothing to escape in the pattern
+		w.add("concat_ws(' ', account, category, txn_group, description, tag) ~* " + w.arg(`(^|[^[:alnum:]])`+term))
+	}
+	return w
+}
+
+func words(value string) []string {
+	return strings.FieldsFunc(strings.ToLower(value), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+}
+
+func (s FinTrackerPostgresStorage) SearchTransactions(ctx context.Context, uid string, startDate time.Time, endDate time.Time, searchText string) (domain.Transactions, error) {
+	w := searchWhere(uid, startDate, endDate, searchText)
+	txns, err := transactions.find(ctx, s.db, w.sql()+" ORDER BY date, id", w.args...)
+	if err != nil {
+		return nil, storageError(err, uid)
+	}
+	return txns, nil
+}
+
+// ImportTransactions replaces the user's transactions between the dates.
+func (s FinTrackerPostgresStorage) ImportTransactions(ctx context.Context, uid string, startDate time.Time, endDate time.Time, txns []*domain.Transaction) error {
+	for _, txn := range txns {
+		txn.UID = uid
+		txn.ID = uuid.New().String()
+	}
+	return storageError(s.inTx(ctx, func(tx *sql.Tx) error {
+		if err := ensureUsers(ctx, tx, uid); err != nil {
+			return err
+		}
+		w := searchWhere(uid, startDate, endDate, "")
+		if _, err := tx.ExecContext(ctx, "DELETE FROM transactions WHERE "+w.sql(), w.args...); err != nil {
+			return err
+		}
+		return transactions.save(ctx, tx, txns...)
+	}), uid)
+}
+
+// SummaryTransactions sums the user's transactions by year, month, group, category and account —
+// debits negative, credits positive. Paychecks, the Others group and interest payments are left out.
+func (s FinTrackerPostgresStorage) SummaryTransactions(ctx context.Context, uid string, startDate time.Time, endDate time.Time) ([]domain.TransactionAgg, error) {
+
+	w := &where{}
+	w.add("uid = " + w.arg(uid))
+	w.add("category <> 'Paycheck' AND txn_group <> 'Others' AND account <> 'Interest Payment'")
+	dateRange(w, "date", startDate, endDate)
+
+	query := fmt.Sprintf(`SELECT
+			EXTRACT(YEAR FROM date AT TIME ZONE 'UTC')::int AS year,
+			EXTRACT(MONTH FROM date AT TIME ZONE 'UTC')::int AS month,
+			txn_group, category, account,
+			SUM(CASE WHEN dbcr = 'credit' THEN amount ELSE -amount END)
+		FROM transactions WHERE %s
+		GROUP BY 1, 2, 3, 4, 5
+		ORDER BY year, month, txn_group COLLATE "C", category COLLATE "C", account COLLATE "C"`, w.sql())
+
+	rows, err := s.db.QueryContext(ctx, query, w.args...)
+	if err != nil {
+		return nil, storageError(err, uid)
+	}
+	defer rows.Close()
+	results := []domain.TransactionAgg{}
+	for rows.Next() {
+		agg := domain.TransactionAgg{}
+		if err := rows.Scan(&agg.ID.Year, &agg.ID.Month, &agg.ID.Group, &agg.ID.Category, &agg.ID.Account, &agg.Amount); err != nil {
+			return nil, storageError(err, uid)
+		}
+		results = append(results, agg)
+	}
+	return results, storageError(rows.Err(), uid)
+}
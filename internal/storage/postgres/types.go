@@ -0,0 +1,319 @@
+// Package postgres keeps the tracker data in PostgreSQL — decimals are NUMERIC,
+// nested values and polymorphic details are JSONB. Open connects and Migrate
+// creates the schema. Selected with STORAGE=postgres.
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"slices"
+	"strings"
+
+	"github.com/rkapps/fin-tracker-backend-go/internal/storage"
+)
+
+// DriverName is the database/sql driver used by Open. The pgx driver is only
+// linked into builds with the postgres tag — see driver.go.
+const DriverName = "pgx"
+
+var _ storage.FinTrackerStorageService = FinTrackerPostgresStorage{}
+
+// Open connects to the database and checks it can be reached.
+func Open(ctx context.Context, dsn string) (*sql.DB, error) {
+	if !slices.Contains(sql.Drivers(), DriverName) {
+		return nil, fmt.Errorf("postgres driver %q is not registered, build with -tags postgres", DriverName)
+	}
+	db, err := sql.Open(DriverName, dsn)
+	if err != nil {
+		return nil, err
+	}
+	if err := db.PingContext(ctx); err != nil {
+		db.Close()
+		return nil, storageError(err, "")
+	}
+	return db, nil
+}
+
+// sqlState is implemented by the driver errors (pgconn.PgError, pq.Error).
+type sqlState interface {
+	SQLState() string
+}
+
+// storageError maps a driver error to the storage errors — id names the record
+// for not found and conflicts. Context errors are returned as is.
+func storageError(err error, id string) error {
+	switch {
+	case err == nil:
+		return nil
+	case errors.Is(err, context.Canceled), errors.Is(err, context.DeadlineExceeded):
+		return err
+	case errors.Is(err, sql.ErrNoRows):
+		return storage.NotFoundError(id)
+	case errors.Is(err, driver.ErrBadConn), errors.Is(err, sql.ErrConnDone):
+		return fmt.Errorf("%w: %w", storage.ErrTransient, err)
+	}
+	var state sqlState
+	if errors.As(err, &state) {
+		code := state.SQLState()
+		switch {
+		case code == "23505": // unique_violation
+			return fmt.Errorf("%w: %s", storage.ErrAlreadyExists, id)
+		case code == "23503": // foreign_key_violation
+			return fmt.Errorf("%w: %s: %w", storage.ErrConflict, id, err)
+		case strings.HasPrefix(code, "08"), // connection exception
+			code == "40001", code == "40P01", // serialization failure, deadlock
+			code == "57P01", code == "57P03", // admin shutdown, cannot connect now
+			strings.HasPrefix(code, "53"): // insufficient resources
+			return fmt.Errorf("%w: %w", storage.ErrTransient, err)
+		}
+	}
+	var nerr net.Error
+	if errors.As(err, &nerr) {
+		return fmt.Errorf("%w: %w", storage.ErrTransient, err)
+	}
+	return err
+}
+
+// FinTracker Postgres Storage
+type FinTrackerPostgresStorage struct {
+	db *sql.DB
+}
+
+func NewFinTrackerPostgresStorage(db *sql.DB) storage.FinTrackerStorageService {
+	return FinTrackerPostgresStorage{db}
+}
+
+// inTx runs fn in a transaction, committed when fn returns nil.
+func (s FinTrackerPostgresStorage) inTx(ctx context.Context, fn func(tx *sql.Tx) error) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	if err := fn(tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+// ensureUsers adds the users the records belong to — every table references users,
+// and a user is only saved explicitly once they change their settings.
+func ensureUsers(ctx context.Context, tx *sql.Tx, uids ...string) error {
+	seen := map[string]bool{}
+	for _, uid := range uids {
+		if seen[uid] {
+			continue
+		}
+		seen[uid] = true
+		if _, err := tx.ExecContext(ctx, `INSERT INTO users (id) VALUES ($1) ON CONFLICT (id) DO NOTHING`, uid); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// jsonb reads and writes a JSONB column — ptr points at the value. nil is NULL.
+type jsonb struct {
+	ptr any
+}
+
+func (j jsonb) Value() (driver.Value, error) {
+	b, err := json.Marshal(j.ptr)
+	if err != nil || string(b) == "null" {
+		return nil, err
+	}
+	return string(b), nil
+}
+
+func (j jsonb) Scan(src any) error {
+	switch v := src.(type) {
+	case nil:
+		return nil
+	case []byte:
+		return json.Unmarshal(v, j.ptr)
+	case string:
+		return json.Unmarshal([]byte(v), j.ptr)
+	}
+	return fmt.Errorf("jsonb: cannot scan %T", src)
+}
+
+// nullText reads and writes a TEXT column that is NULL for "" e.g an optional
+// foreign key — ptr points at the value.
+type nullText struct {
+	ptr *string
+}
+
+func (n nullText) Value() (driver.Value, error) {
+	if len(*n.ptr) == 0 {
+		return nil, nil
+	}
+	return *n.ptr, nil
+}
+
+func (n nullText) Scan(src any) error {
+	switch v := src.(type) {
+	case nil:
+		*n.ptr = ""
+	case []byte:
+		*n.ptr = string(v)
+	case string:
+		*n.ptr = v
+	default:
+		return fmt.Errorf("text: cannot scan %T", src)
+	}
+	return nil
+}
+
+// rawJSON reads a JSONB column that is decoded later e.g a polymorphic detail.
+type rawJSON []byte
+
+func (r *rawJSON) Scan(src any) error {
+	switch v := src.(type) {
+	case nil:
+		*r = nil
+	case []byte:
+		*r = slices.Clone(v)
+	case string:
+		*r = []byte(v)
+	default:
+		return fmt.Errorf("jsonb: cannot scan %T", src)
+	}
+	return nil
+}
+
+// scanner is a *sql.Row or *sql.Rows.
+type scanner interface {
+	Scan(dest ...any) error
+}
+
+// table maps a domain record to its table. The first column is the primary key.
+type table[T any] struct {
+	name    string
+	columns []string
+	values  func(v *T) []any
+	scan    func(row scanner) (*T, error)
+}
+
+func (t table[T]) selectSQL() string {
+	return fmt.Sprintf("SELECT %s FROM %s", strings.Join(t.columns, ", "), t.name)
+}
+
+// upsertSQL inserts the record or replaces every column of the existing one.
+func (t table[T]) upsertSQL() string {
+	placeholders := make([]string, len(t.columns))
+	sets := []string{}
+	for i, column := range t.columns {
+		placeholders[i] = fmt.Sprintf("$%d", i+1)
+		if i > 0 {
+			sets = append(sets, fmt.Sprintf("%s = EXCLUDED.%s", column, column))
+		}
+	}
+	return fmt.Sprintf("INSERT INTO %s (%s) VALUES (%s) ON CONFLICT (%s) DO UPDATE SET %s",
+		t.name, strings.Join(t.columns, ", "), strings.Join(placeholders, ", "), t.columns[0], strings.Join(sets, ", "))
+}
+
+// get returns the record with the id.
+func (t table[T]) get(ctx context.Context, db *sql.DB, id string) (*T, error) {
+	row := db.QueryRowContext(ctx, t.selectSQL()+" WHERE "+t.columns[0]+" = $1", id)
+	v, err := t.scan(row)
+	if err != nil {
+		return nil, storageError(err, id)
+	}
+	return v, nil
+}
+
+// find returns the records that match the where clause e.g "uid = $1 ORDER BY date".
+func (t table[T]) find(ctx context.Context, db *sql.DB, cond string, args ...any) ([]*T, error) {
+	query := t.selectSQL()
+	if len(cond) > 0 {
+		query += " WHERE " + cond
+	}
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	results := []*T{}
+	for rows.Next() {
+		v, err := t.scan(rows)
+		if err != nil {
+			return nil, err
+		}
+		results = append(results, v)
+	}
+	return results, rows.Err()
+}
+
+// save upserts the records in one statement each, in the transaction.
+func (t table[T]) save(ctx context.Context, tx *sql.Tx, records ...*T) error {
+	if len(records) == 0 {
+		return nil
+	}
+	stmt, err := tx.PrepareContext(ctx, t.upsertSQL())
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+	for _, v := range records {
+		if _, err := stmt.ExecContext(ctx, t.values(v)...); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// delete deletes the records with the ids — nothing when ids is empty.
+func (t table[T]) delete(ctx context.Context, db *sql.DB, ids ...string) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	w := &where{}
+	in(w, t.columns[0], ids)
+	_, err := db.ExecContext(ctx, "DELETE FROM "+t.name+" WHERE "+w.sql(), w.args...)
+	return err
+}
+
+// where builds a parameterised where clause — conditions are ANDed.
+type where struct {
+	conds []string
+	args  []any
+}
+
+// arg adds a parameter and returns its placeholder.
+func (w *where) arg(v any) string {
+	w.args = append(w.args, v)
+	return fmt.Sprintf("$%d", len(w.args))
+}
+
+func (w *where) add(cond string) {
+	w.conds = append(w.conds, cond)
+}
+
+// params adds the values as parameters and returns their placeholders e.g "$2, $3".
+func params[V any](w *where, values []V) string {
+	placeholders := make([]string, len(values))
+	for i, v := range values {
+		placeholders[i] = w.arg(v)
+	}
+	return strings.Join(placeholders, ", ")
+}
+
+// in matches the column to any of the values — nothing is added when values is empty.
+func in[V any](w *where, column string, values []V) {
+	if len(values) == 0 {
+		return
+	}
+	w.add(fmt.Sprintf("%s IN (%s)", column, params(w, values)))
+}
+
+func (w *where) sql() string {
+	if len(w.conds) == 0 {
+		return "TRUE"
+	}
+	return strings.Join(w.conds, " AND ")
+}
@@ -0,0 +1,42 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/rkapps/fin-tracker-backend-go/internal/domain"
+)
+
+var users = table[domain.User]{
+	name:    "users",
+	columns: []string{"id", "currency_code", "country", "lot_matching_method"},
+	values: func(u *domain.User) []any {
+		return []any{u.ID, u.CurrencyCode, u.Country, u.LotMatchingMethod}
+	},
+	scan: func(row scanner) (*domain.User, error) {
+		u := &domain.User{}
+		return u, row.Scan(&u.ID, &u.CurrencyCode, &u.Country, &u.LotMatchingMethod)
+	},
+}
+
+func (s FinTrackerPostgresStorage) GetUsers(ctx context.Context) ([]*domain.User, error) {
+	results, err := users.find(ctx, s.db, "")
+	return results, storageError(err, "")
+}
+
+func (s FinTrackerPostgresStorage) GetUser(ctx context.Context, id string) (*domain.User, error) {
+	return users.get(ctx, s.db, id)
+}
+
+// SaveUser upserts the user.
+func (s FinTrackerPostgresStorage) SaveUser(ctx context.Context, user *domain.User) error {
+	return storageError(s.inTx(ctx, func(tx *sql.Tx) error {
+		return users.save(ctx, tx, user)
+	}), user.ID)
+}
+
+// DeleteUser deletes the user and, through the foreign keys, every record of theirs
+// including the GL entries and the user's lock.
+func (s FinTrackerPostgresStorage) DeleteUser(ctx context.Context, id string) error {
+	return storageError(users.delete(ctx, s.db, id), id)
+}
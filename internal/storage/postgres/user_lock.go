@@ -0,0 +1,69 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/rkapps/fin-tracker-backend-go/internal/domain"
+	"github.com/rkapps/fin-tracker-backend-go/internal/storage"
+)
+
+var userLocks = table[domain.UserLock]{
+	name:    "user_locks",
+	columns: []string{"id", "operation", "owner", "acquired_at", "heartbeat_at", "expires_at"},
+	values: func(l *domain.UserLock) []any {
+		return []any{l.ID, l.Operation, l.Owner, l.AcquiredAt, l.HeartbeatAt, l.ExpiresAt}
+	},
+	scan: func(row scanner) (*domain.UserLock, error) {
+		l := &domain.UserLock{}
+		return l, row.Scan(&l.ID, &l.Operation, &l.Owner, &l.AcquiredAt, &l.HeartbeatAt, &l.ExpiresAt)
+	},
+}
+
+// CreateUserLock relies on the primary key — the insert is the atomic acquire.
+func (s FinTrackerPostgresStorage) CreateUserLock(ctx context.Context, lock *domain.UserLock) error {
+	err := storageError(s.inTx(ctx, func(tx *sql.Tx) error {
+		if err := ensureUsers(ctx, tx, lock.ID); err != nil {
+			return err
+		}
+		_, err := tx.ExecContext(ctx, `INSERT INTO user_locks (id, operation, owner, acquired_at, heartbeat_at, expires_at)
+			VALUES ($1, $2, $3, $4, $5, $6)`, userLocks.values(lock)...)
+		return err
+	}), lock.ID)
+	if errors.Is(err, storage.ErrConflict) {
+		return storage.ErrAlreadyExists
+	}
+	return err
+}
+
+// GetUserLock returns nil when the user is not locked.
+func (s FinTrackerPostgresStorage) GetUserLock(ctx context.Context, uid string) (*domain.UserLock, error) {
+	lock, err := userLocks.get(ctx, s.db, uid)
+	if errors.Is(err, storage.ErrNotFound) {
+		return nil, nil
+	}
+	return lock, err
+}
+
+// ReplaceUserLock matches the owner and expiry in the update — the row lock makes it a compare-and-swap.
+func (s FinTrackerPostgresStorage) ReplaceUserLock(ctx context.Context, prev *domain.UserLock, lock *domain.UserLock) error {
+	result, err := s.db.ExecContext(ctx, `UPDATE user_locks SET operation = $2, owner = $3, acquired_at = $4, heartbeat_at = $5, expires_at = $6
+		WHERE id = $1 AND owner = $7 AND expires_at = $8`, append(userLocks.values(lock), prev.Owner, prev.ExpiresAt)...)
+	if err != nil {
+		return storageError(err, lock.ID)
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return storageError(err, lock.ID)
+	}
+	if n == 0 {
+		return fmt.Errorf("%w: lock of %s changed", storage.ErrConflict, prev.ID)
+	}
+	return nil
+}
+
+func (s FinTrackerPostgresStorage) DeleteUserLock(ctx context.Context, uid string) error {
+	return storageError(userLocks.delete(ctx, s.db, uid), uid)
+}
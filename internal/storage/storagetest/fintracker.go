@@ -0,0 +1,1064 @@
+// Package storagetest is the conformance suite of the storage services. Every
+// backend runs it from its own tests so that the services behave the same
+// whichever store the app is deployed on.
+package storagetest
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/rkapps/fin-tracker-backend-go/internal/domain"
+	"github.com/rkapps/fin-tracker-backend-go/internal/storage"
+	"github.com/shopspring/decimal"
+)
+
+// OpenFinTracker returns a new, empty store — it is called once for every test.
+type OpenFinTracker func(t *testing.T) storage.FinTrackerStorageService
+
+// RunFinTracker runs the conformance tests of FinTrackerStorageService.
+func RunFinTracker(t *testing.T, open OpenFinTracker) {
+	tests := []struct {
+		name string
+		test func(t *testing.T, s storage.FinTrackerStorageService)
+	}{
+		{"AccountRoundTrip", testAccountRoundTrip},
+		{"AccountDetail", testAccountDetail},
+		{"UIDIsolation", testUIDIsolation},
+		{"NotFound", testNotFound},
+		{"DecimalPrecision", testDecimalPrecision},
+		{"ActivityDetail", testActivityDetail},
+		{"BulkWriteAndDelete", testBulkWriteAndDelete},
+		{"QueryActivities", testQueryActivities},
+		{"QueryActivityLots", testQueryActivityLots},
+		{"SummarySnapshots", testSummarySnapshots},
+		{"AccountValues", testAccountValues},
+		{"MappingRules", testMappingRules},
+		{"PipelineRuns", testPipelineRuns},
+		{"DeletionReceipts", testDeletionReceipts},
+		{"AuditEntries", testAuditEntries},
+		{"Users", testUsers},
+		{"UserLocks", testUserLocks},
+		{"Transactions", testTransactions},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.test(t, open(t))
+		})
+	}
+}
+
+// date returns midnight UTC — stores keep milliseconds at best.
+func date(year int, month time.Month, day int) time.Time {
+	return time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+}
+
+func dec(value string) decimal.Decimal {
+	return decimal.RequireFromString(value)
+}
+
+// ids returns the sorted ids of the records, for stores without a natural order.
+func ids[T interface{ Id() string }](records []T) string {
+	ss := []string{}
+	for _, r := range records {
+		ss = append(ss, r.Id())
+	}
+	sort.Strings(ss)
+	return fmt.Sprint(ss)
+}
+
+// ordered returns the ids of the records in the order returned.
+func ordered[T interface{ Id() string }](records []T) string {
+	ss := []string{}
+	for _, r := range records {
+		ss = append(ss, r.Id())
+	}
+	return fmt.Sprint(ss)
+}
+
+func brokerage(id string, uid string) *domain.Account {
+	return &domain.Account{
+		ID: id, UID: uid, Name: "Brokerage " + id, Active: true,
+		Category: domain.CategoryBrokerage, Type: domain.TypeRegular,
+		Detail:    &domain.BrokerageDetail{Institution: "Fidelity", AccountNumber: "X-" + id},
+		TaxStatus: domain.TaxStatusTaxable, CreatedAt: date(2024, 1, 1), UpdatedAt: date(2024, 1, 2),
+	}
+}
+
+// saveAccounts saves the accounts the records of a test belong to — Postgres
+// enforces them with foreign keys.
+func saveAccounts(t *testing.T, s storage.FinTrackerStorageService, uid string, acctIds ...string) {
+	for _, acctId := range acctIds {
+		if err := s.SaveAccount(context.Background(), brokerage(acctId, uid)); err != nil {
+			t.Fatalf("SaveAccount(%s) error = %v", acctId, err)
+		}
+	}
+}
+
+func testAccountRoundTrip(t *testing.T, s storage.FinTrackerStorageService) {
+
+	ctx := context.Background()
+	acct := brokerage("a1", "u1")
+	acct.AlternateNames = []string{"Fidelity Brokerage"}
+	acct.AliasPatterns = []string{"^fid.*"}
+	acct.LotMatchingMethod = domain.LotMatchingFIFO
+	if err := s.SaveAccount(ctx, acct); err != nil {
+		t.Fatalf("SaveAccount() error = %v", err)
+	}
+
+	got, err := s.GetAccount(ctx, "u1", "a1")
+	if err != nil {
+		t.Fatalf("GetAccount() error = %v", err)
+	}
+	if got.ID != "a1" || got.UID != "u1" || got.Name != acct.Name || !got.Active || got.Category != domain.CategoryBrokerage ||
+		got.Type != domain.TypeRegular || got.TaxStatus != domain.TaxStatusTaxable || got.LotMatchingMethod != domain.LotMatchingFIFO {
+		t.Errorf("GetAccount() = %+v, want %+v", got, acct)
+	}
+	if !slices.Equal(got.AlternateNames, acct.AlternateNames) || !slices.Equal(got.AliasPatterns, acct.AliasPatterns) {
+		t.Errorf("GetAccount() names = %v %v, want %v %v", got.AlternateNames, got.AliasPatterns, acct.AlternateNames, acct.AliasPatterns)
+	}
+	if !got.CreatedAt.Equal(acct.CreatedAt) || !got.UpdatedAt.Equal(acct.UpdatedAt) {
+		t.Errorf("GetAccount() dates = %v %v, want %v %v", got.CreatedAt, got.UpdatedAt, acct.CreatedAt, acct.UpdatedAt)
+	}
+
+	// saving again replaces the account
+	acct.Name = "Renamed"
+	acct.Active = false
+	if err := s.SaveAccount(ctx, acct); err != nil {
+		t.Fatalf("SaveAccount() again error = %v", err)
+	}
+	accts, err := s.GetAccounts(ctx, "u1")
+	if err != nil {
+		t.Fatalf("GetAccounts() error = %v", err)
+	}
+	if len(accts) != 1 || accts[0].Name != "Renamed" || accts[0].Active {
+		t.Errorf("GetAccounts() after update = %v, want the renamed account only", accts)
+	}
+
+	// sync state and credential
+	last := date(2024, 5, 1)
+	astate := &domain.AccountSyncState{ID: "a1", UID: "u1", LastSyncDate: &last, Refresh: true, SyncStatus: domain.SyncStatusSuccess, NewActivities: 3}
+	if err := s.SaveAccountSyncState(ctx, astate); err != nil {
+		t.Fatalf("SaveAccountSyncState() error = %v", err)
+	}
+	gstate, err := s.GetAccountSyncState(ctx, "u1", "a1")
+	if err != nil {
+		t.Fatalf("GetAccountSyncState() error = %v", err)
+	}
+	if gstate.LastSyncDate == nil || !gstate.LastSyncDate.Equal(last) || gstate.SyncStartDate != nil ||
+		!gstate.Refresh || gstate.SyncStatus != domain.SyncStatusSuccess || gstate.NewActivities != 3 {
+		t.Errorf("GetAccountSyncState() = %+v, want %+v", gstate, astate)
+	}
+
+	acred := &domain.AccountCredential{ID: "a1", UID: "u1", Provider: "coinbase", APIKey: "enc-key", APISecret: "enc-secret",
+		KeyVersion: "v1", WrappedKey: "wrapped", Label: "main", Permissions: []string{domain.PermissionRead}, CreatedAt: date(2024, 1, 1), Active: true}
+	if err := s.SaveAccountCredential(ctx, acred); err != nil {
+		t.Fatalf("SaveAccountCredential() error = %v", err)
+	}
+	gcred, err := s.GetAccountCredential(ctx, "u1", "a1")
+	if err != nil {
+		t.Fatalf("GetAccountCredential() error = %v", err)
+	}
+	if gcred.APIKey != "enc-key" || gcred.APISecret != "enc-secret" || gcred.KeyVersion != "v1" || gcred.WrappedKey != "wrapped" ||
+		!slices.Equal(gcred.Permissions, acred.Permissions) || gcred.LastUsed != nil || gcred.ExpiresAt != nil || !gcred.Active {
+		t.Errorf("GetAccountCredential() = %+v, want %+v", gcred, acred)
+	}
+	if err := s.DeleteAccountCredential(ctx, "u1", "a1"); err != nil {
+		t.Fatalf("DeleteAccountCredential() error = %v", err)
+	}
+	if acreds, _ := s.GetAccountCredentials(ctx, "u1"); len(acreds) != 0 {
+		t.Errorf("GetAccountCredentials() after delete = %d, want 0", len(acreds))
+	}
+	if err := s.DeleteAccountSyncState(ctx, "u1", "a1"); err != nil {
+		t.Fatalf("DeleteAccountSyncState() error = %v", err)
+	}
+	if _, err := s.GetAccountSyncState(ctx, "u1", "a1"); !errors.Is(err, storage.ErrNotFound) {
+		t.Errorf("GetAccountSyncState() after delete error = %v, want ErrNotFound", err)
+	}
+	if err := s.DeleteAccountSyncState(ctx, "u1", "a1"); err != nil {
+		t.Errorf("DeleteAccountSyncState() again error = %v, want nil", err)
+	}
+
+	if err := s.DeleteAccount(ctx, "u1", "a1"); err != nil {
+		t.Fatalf("DeleteAccount() error = %v", err)
+	}
+	if _, err := s.GetAccount(ctx, "u1", "a1"); !errors.Is(err, storage.ErrNotFound) {
+		t.Errorf("GetAccount() after delete error = %v, want ErrNotFound", err)
+	}
+	// deleting a missing account is not an error
+	if err := s.DeleteAccount(ctx, "u1", "a1"); err != nil {
+		t.Errorf("DeleteAccount() again error = %v, want nil", err)
+	}
+}
+
+func testAccountDetail(t *testing.T, s storage.FinTrackerStorageService) {
+
+	ctx := context.Background()
+	accts := []*domain.Account{
+		{ID: "bank", UID: "u1", Category: domain.CategoryCash, Type: domain.TypeChecking,
+			Detail: &domain.BankDetail{Institution: "Chase", RoutingNumber: "021000021", AccountNumber: "123"}},
+		{ID: "brokerage", UID: "u1", Category: domain.CategoryBrokerage, Type: domain.TypeRegular,
+			Detail: &domain.BrokerageDetail{Institution: "Fidelity", AccountNumber: "456"}},
+		{ID: "crypto", UID: "u1", Category: domain.CategoryCrypto, Type: domain.TypeHotWallet,
+			Detail: &domain.CryptoDetail{Blockchain: "ethereum", Address: "0xabc", AccountNumber: "789"}},
+		{ID: "529", UID: "u1", Category: domain.Category529, Type: domain.TypeRegular,
+			Detail: &domain.EducationDetail{Institution: "Vanguard", AccountNumber: "012", BeneficiaryName: "Sam", StateProgram: "NV"}},
+	}
+	for _, acct := range accts {
+		if err := s.SaveAccount(ctx, acct); err != nil {
+			t.Fatalf("SaveAccount(%s) error = %v", acct.ID, err)
+		}
+	}
+	for _, acct := range accts {
+		got, err := s.GetAccount(ctx, "u1", acct.ID)
+		if err != nil {
+			t.Fatalf("GetAccount(%s) error = %v", acct.ID, err)
+		}
+		if fmt.Sprintf("%T %+v", got.Detail, got.Detail) != fmt.Sprintf("%T %+v", acct.Detail, acct.Detail) {
+			t.Errorf("GetAccount(%s).Detail = %T %+v, want %T %+v", acct.ID, got.Detail, got.Detail, acct.Detail, acct.Detail)
+		}
+		if got.AccountNumber() != acct.AccountNumber() {
+			t.Errorf("GetAccount(%s).AccountNumber() = %q, want %q", acct.ID, got.AccountNumber(), acct.AccountNumber())
+		}
+	}
+}
+
+func testUIDIsolation(t *testing.T, s storage.FinTrackerStorageService) {
+
+	ctx := context.Background()
+	s.SaveAccount(ctx, brokerage("a1", "u1"))
+	s.SaveAccount(ctx, brokerage("a2", "u2"))
+	s.SaveAccountSyncState(ctx, &domain.AccountSyncState{ID: "a1", UID: "u1", SyncStatus: domain.SyncStatusPending})
+	s.SaveAccountCredential(ctx, &domain.AccountCredential{ID: "a1", UID: "u1", Provider: "coinbase", CreatedAt: date(2024, 1, 1)})
+	s.SaveActivityMappingRule(ctx, &domain.ActivityMappingRule{ID: "r1", UID: "u1", Name: "rule", NotesPattern: "x", Skip: true})
+	s.SaveActivities(ctx, []*domain.Activity{
+		{ID: "x1", UID: "u1", AccountID: "a1", Date: date(2024, 1, 1)},
+		{ID: "x2", UID: "u2", AccountID: "a2", Date: date(2024, 1, 1)},
+	})
+	s.SaveActivityLots(ctx, []*domain.ActivityLot{{ID: "l1", UID: "u1", AccountID: "a1"}, {ID: "l2", UID: "u2", AccountID: "a2"}})
+	s.SaveAccountSummaries(ctx, []*domain.AccountSummary{{ID: "s1", UID: "u1", AccountID: "a1"}, {ID: "s2", UID: "u2", AccountID: "a2"}})
+
+	unauthorized := map[string]error{}
+	_, unauthorized["GetAccount"] = s.GetAccount(ctx, "u2", "a1")
+	_, unauthorized["GetAccountSyncState"] = s.GetAccountSyncState(ctx, "u2", "a1")
+	_, unauthorized["GetAccountCredential"] = s.GetAccountCredential(ctx, "u2", "a1")
+	unauthorized["DeleteAccount"] = s.DeleteAccount(ctx, "u2", "a1")
+	unauthorized["DeleteAccountCredential"] = s.DeleteAccountCredential(ctx, "u2", "a1")
+	unauthorized["DeleteAccountSyncState"] = s.DeleteAccountSyncState(ctx, "u2", "a1")
+	unauthorized["DeleteActivityMappingRule"] = s.DeleteActivityMappingRule(ctx, "u2", "r1")
+	unauthorized["SaveActivityMappingRule"] = s.SaveActivityMappingRule(ctx, &domain.ActivityMappingRule{ID: "r1", UID: "u2", Name: "taken"})
+	for name, err := range unauthorized {
+		if !errors.Is(err, storage.ErrUnauthorized) {
+			t.Errorf("%s() by another user error = %v, want ErrUnauthorized", name, err)
+		}
+	}
+	// refused deletes leave the records
+	if _, err := s.GetAccount(ctx, "u1", "a1"); err != nil {
+		t.Errorf("GetAccount() after refused delete error = %v", err)
+	}
+	if rules, _ := s.GetActivityMappingRules(ctx, "u1"); len(rules) != 1 {
+		t.Errorf("GetActivityMappingRules() after refused delete = %d, want 1", len(rules))
+	} else if rules[0].Name != "rule" {
+		t.Errorf("GetActivityMappingRules() after refused save = %s, want rule", rules[0].Name)
+	}
+
+	// lists only return the user's records
+	if accts, _ := s.GetAccounts(ctx, "u1"); ids(accts) != "[a1]" {
+		t.Errorf("GetAccounts(u1) = %s, want [a1]", ids(accts))
+	}
+	if astates, _ := s.GetAccountSyncStates(ctx, "u2"); len(astates) != 0 {
+		t.Errorf("GetAccountSyncStates(u2) = %d, want 0", len(astates))
+	}
+	if acreds, _ := s.GetAccountCredentials(ctx, "u2"); len(acreds) != 0 {
+		t.Errorf("GetAccountCredentials(u2) = %d, want 0", len(acreds))
+	}
+	if rules, _ := s.GetActivityMappingRules(ctx, "u2"); len(rules) != 0 {
+		t.Errorf("GetActivityMappingRules(u2) = %d, want 0", len(rules))
+	}
+	if actvs, _ := s.GetActivities(ctx, "u1"); ids(actvs) != "[x1]" {
+		t.Errorf("GetActivities(u1) = %s, want [x1]", ids(actvs))
+	}
+	if actvs, _ := s.GetActivitiesForAccount(ctx, "u1", "a2"); len(actvs) != 0 {
+		t.Errorf("GetActivitiesForAccount(u1, a2) = %s, want none", ids(actvs))
+	}
+	if paged, _ := s.QueryActivities(ctx, "u1", domain.ActivityQuery{}); ids(paged.Items) != "[x1]" {
+		t.Errorf("QueryActivities(u1) = %s, want [x1]", ids(paged.Items))
+	}
+	if lots, _ := s.GetActivityLots(ctx, "u2"); ids(lots) != "[l2]" {
+		t.Errorf("GetActivityLots(u2) = %s, want [l2]", ids(lots))
+	}
+	if lots, _ := s.GetActivityLotsForAccount(ctx, "u2", "a1"); len(lots) != 0 {
+		t.Errorf("GetActivityLotsForAccount(u2, a1) = %s, want none", ids(lots))
+	}
+	if paged, _ := s.QueryActivityLots(ctx, "u2", domain.LotQuery{}); ids(paged.Items) != "[l2]" {
+		t.Errorf("QueryActivityLots(u2) = %s, want [l2]", ids(paged.Items))
+	}
+	if asumys, _ := s.GetAccountSummaries(ctx, "u1"); ids(asumys) != "[s1]" {
+		t.Errorf("GetAccountSummaries(u1) = %s, want [s1]", ids(asumys))
+	}
+}
+
+func testNotFound(t *testing.T, s storage.FinTrackerStorageService) {
+
+	ctx := context.Background()
+	notFound := map[string]error{}
+	_, notFound["GetAccount"] = s.GetAccount(ctx, "u1", "missing")
+	_, notFound["GetAccountSyncState"] = s.GetAccountSyncState(ctx, "u1", "missing")
+	_, notFound["GetAccountCredential"] = s.GetAccountCredential(ctx, "u1", "missing")
+	_, notFound["GetUser"] = s.GetUser(ctx, "missing")
+	_, notFound["GetPipelineRun"] = s.GetPipelineRun(ctx, "missing")
+	_, notFound["GetDeletionReceipt"] = s.GetDeletionReceipt(ctx, "missing")
+	notFound["DeleteAccountCredential"] = s.DeleteAccountCredential(ctx, "u1", "missing")
+	notFound["DeleteActivityMappingRule"] = s.DeleteActivityMappingRule(ctx, "u1", "missing")
+	for name, err := range notFound {
+		if !errors.Is(err, storage.ErrNotFound) {
+			t.Errorf("%s(missing) error = %v, want ErrNotFound", name, err)
+		}
+	}
+	if lock, err := s.GetUserLock(ctx, "missing"); lock != nil || err != nil {
+		t.Errorf("GetUserLock(missing) = %v, %v, want nil, nil", lock, err)
+	}
+
+	// lists are empty, not errors
+	if accts, err := s.GetAccounts(ctx, "u1"); err != nil || len(accts) != 0 {
+		t.Errorf("GetAccounts() = %v, %v, want none", accts, err)
+	}
+	if actvs, err := s.GetActivities(ctx, "u1"); err != nil || len(actvs) != 0 {
+		t.Errorf("GetActivities() = %v, %v, want none", actvs, err)
+	}
+	if paged, err := s.QueryActivities(ctx, "u1", domain.ActivityQuery{}); err != nil || len(paged.Items) != 0 || len(paged.NextCursor) > 0 {
+		t.Errorf("QueryActivities() = %v, %v, want an empty page", paged, err)
+	}
+}
+
+func testDecimalPrecision(t *testing.T, s storage.FinTrackerStorageService) {
+
+	ctx := context.Background()
+	// more digits than a float64 keeps, and satoshi sized quantities
+	qty := dec("12345678901234.123456789012")
+	price := dec("0.00000001")
+	amount := dec("-98765.4321")
+	saveAccounts(t, s, "u1", "a1")
+
+	s.SaveActivities(ctx, []*domain.Activity{{ID: "x1", UID: "u1", AccountID: "a1", Date: date(2024, 1, 1),
+		RcvSymbol: "BTC", RcvQuantity: qty, RcvPrice: price, RcvAmount: qty.Mul(price), SentAmount: amount, Fee: dec("0.1")}})
+	actvs, err := s.GetActivities(ctx, "u1")
+	if err != nil || len(actvs) != 1 {
+		t.Fatalf("GetActivities() = %v, %v", actvs, err)
+	}
+	actv := actvs[0]
+	for name, pair := range map[string][2]decimal.Decimal{
+		"RcvQuantity": {actv.RcvQuantity, qty},
+		"RcvPrice":    {actv.RcvPrice, price},
+		"RcvAmount":   {actv.RcvAmount, qty.Mul(price)},
+		"SentAmount":  {actv.SentAmount, amount},
+		"Fee":         {actv.Fee, dec("0.1")},
+		"Tax":         {actv.Tax, decimal.Zero},
+	} {
+		if !pair[0].Equal(pair[1]) {
+			t.Errorf("Activity.%s = %s, want %s", name, pair[0], pair[1])
+		}
+	}
+
+	s.SaveActivityLots(ctx, []*domain.ActivityLot{{ID: "l1", UID: "u1", AccountID: "a1", Symbol: "BTC",
+		OrigQty: qty, Qty: dec("0.00000001"), Cost: price, CostValue: qty.Mul(price), Status: domain.LotStatusOpen}})
+	lots, err := s.GetActivityLots(ctx, "u1")
+	if err != nil || len(lots) != 1 {
+		t.Fatalf("GetActivityLots() = %v, %v", lots, err)
+	}
+	if !lots[0].OrigQty.Equal(qty) || !lots[0].Qty.Equal(dec("0.00000001")) || !lots[0].CostValue.Equal(qty.Mul(price)) {
+		t.Errorf("GetActivityLots() = %s %s %s, want %s 0.00000001 %s", lots[0].OrigQty, lots[0].Qty, lots[0].CostValue, qty, qty.Mul(price))
+	}
+
+	s.SaveAccountSummaries(ctx, []*domain.AccountSummary{{ID: "s1", UID: "u1", AccountID: "a1", Date: date(2024, 1, 1),
+		MarketValue: qty, SectorHldgs: map[string]*domain.AccountSummaryValue{"Technology": {CostValue: price, MktValue: qty}}}})
+	asumys, err := s.GetAccountSummaries(ctx, "u1")
+	if err != nil || len(asumys) != 1 {
+		t.Fatalf("GetAccountSummaries() = %v, %v", asumys, err)
+	}
+	tech := asumys[0].SectorHldgs["Technology"]
+	if !asumys[0].MarketValue.Equal(qty) || tech == nil || !tech.CostValue.Equal(price) || !tech.MktValue.Equal(qty) {
+		t.Errorf("GetAccountSummaries() = %s %+v, want %s with the Technology holding", asumys[0].MarketValue, tech, qty)
+	}
+}
+
+func testActivityDetail(t *testing.T, s storage.FinTrackerStorageService) {
+
+	ctx := context.Background()
+	saveAccounts(t, s, "u1", "a1")
+	wallet := &domain.Activity{ID: "x1", UID: "u1", AccountID: "a1", Date: date(2024, 1, 1)}
+	wallet.SetDetail(domain.WalletActivityDetail{Hash: "0xhash", Blockchain: "ethereum", GasPrice: dec("0.000000021"), GasUsed: dec("21000"), GasTotal: dec("0.000441")})
+	split := &domain.Activity{ID: "x2", UID: "u1", AccountID: "a1", Date: date(2024, 1, 2)}
+	split.SetDetail(domain.CorporateActionDetail{Description: "2:1", Ratio: dec("2"), OldSymbol: "A", NewSymbol: "A", EffectiveDate: date(2024, 1, 2)})
+	plain := &domain.Activity{ID: "x3", UID: "u1", AccountID: "a1", Date: date(2024, 1, 3)}
+	if err := s.SaveActivities(ctx, []*domain.Activity{wallet, split, plain}); err != nil {
+		t.Fatalf("SaveActivities() error = %v", err)
+	}
+
+	actvs, err := s.GetActivitiesForAccount(ctx, "u1", "a1")
+	if err != nil {
+		t.Fatalf("GetActivitiesForAccount() error = %v", err)
+	}
+	byId := map[string]*domain.Activity{}
+	for _, actv := range actvs {
+		byId[actv.ID] = actv
+	}
+	if detail, ok := byId["x1"].Detail.(domain.WalletActivityDetail); !ok || detail.Hash != "0xhash" || !detail.GasPrice.Equal(dec("0.000000021")) || !detail.GasTotal.Equal(dec("0.000441")) {
+		t.Errorf("wallet Detail = %T %+v", byId["x1"].Detail, byId["x1"].Detail)
+	}
+	if detail, ok := byId["x2"].Detail.(domain.CorporateActionDetail); !ok || !detail.Ratio.Equal(dec("2")) || !detail.EffectiveDate.Equal(date(2024, 1, 2)) {
+		t.Errorf("corporate action Detail = %T %+v", byId["x2"].Detail, byId["x2"].Detail)
+	}
+	if byId["x3"].Detail != nil || len(byId["x3"].DetailType) > 0 {
+		t.Errorf("plain Detail = %T %+v, want none", byId["x3"].Detail, byId["x3"].Detail)
+	}
+}
+
+func testBulkWriteAndDelete(t *testing.T, s storage.FinTrackerStorageService) {
+
+	ctx := context.Background()
+	saveAccounts(t, s, "u1", "a1")
+	actvs := []*domain.Activity{}
+	lots := []*domain.ActivityLot{}
+	imports := []*domain.ActivityImport{}
+	asumys := []*domain.AccountSummary{}
+	for i := 0; i < 50; i++ {
+		id := fmt.Sprintf("%02d", i)
+		actvs = append(actvs, &domain.Activity{ID: "x" + id, UID: "u1", AccountID: "a1", Date: date(2024, 1, 1).AddDate(0, 0, i), RcvQuantity: decimal.NewFromInt(int64(i))})
+		lots = append(lots, &domain.ActivityLot{ID: "l" + id, UID: "u1", AccountID: "a1", Qty: decimal.NewFromInt(int64(i))})
+		imports = append(imports, &domain.ActivityImport{ID: "i" + id, UID: "u1", AccountID: "a1", TxnType: "Buy"})
+		asumys = append(asumys, &domain.AccountSummary{ID: "s" + id, UID: "u1", AccountID: "a1"})
+	}
+
+	// empty writes are not errors
+	if err := s.SaveActivities(ctx, nil); err != nil {
+		t.Errorf("SaveActivities(nil) error = %v", err)
+	}
+	if err := s.SaveActivityLots(ctx, nil); err != nil {
+		t.Errorf("SaveActivityLots(nil) error = %v", err)
+	}
+	if err := s.SaveImportedActivities(ctx, nil); err != nil {
+		t.Errorf("SaveImportedActivities(nil) error = %v", err)
+	}
+	if err := s.SaveAccountSummaries(ctx, nil); err != nil {
+		t.Errorf("SaveAccountSummaries(nil) error = %v", err)
+	}
+
+	if err := s.SaveActivities(ctx, actvs); err != nil {
+		t.Fatalf("SaveActivities() error = %v", err)
+	}
+	if err := s.SaveActivityLots(ctx, lots); err != nil {
+		t.Fatalf("SaveActivityLots() error = %v", err)
+	}
+	if err := s.SaveImportedActivities(ctx, imports); err != nil {
+		t.Fatalf("SaveImportedActivities() error = %v", err)
+	}
+	if err := s.SaveAccountSummaries(ctx, asumys); err != nil {
+		t.Fatalf("SaveAccountSummaries() error = %v", err)
+	}
+
+	// a second bulk write upserts — replaced and new records
+	actvs[0].RcvQuantity = decimal.NewFromInt(100)
+	if err := s.SaveActivities(ctx, []*domain.Activity{actvs[0], {ID: "x50", UID: "u1", AccountID: "a1", Date: date(2025, 1, 1)}}); err != nil {
+		t.Fatalf("SaveActivities() upsert error = %v", err)
+	}
+	got, _ := s.GetActivities(ctx, "u1")
+	if len(got) != 51 {
+		t.Fatalf("GetActivities() = %d after upsert, want 51", len(got))
+	}
+	for _, actv := range got {
+		if actv.ID == "x00" && !actv.RcvQuantity.Equal(decimal.NewFromInt(100)) {
+			t.Errorf("upserted x00 RcvQuantity = %s, want 100", actv.RcvQuantity)
+		}
+	}
+
+	// deleting no ids deletes nothing
+	s.DeleteActivities(ctx, nil)
+	s.DeleteActivityLots(ctx, []string{})
+	s.DeleteImortedActivities(ctx, nil)
+	s.DeleteAccountSummaries(ctx, nil)
+	if got, _ := s.GetActivities(ctx, "u1"); len(got) != 51 {
+		t.Errorf("DeleteActivities(nil) left %d activities, want 51", len(got))
+	}
+	if got, _ := s.GetActivityLots(ctx, "u1"); len(got) != 50 {
+		t.Errorf("DeleteActivityLots([]) left %d lots, want 50", len(got))
+	}
+	if got, _ := s.GetImortedActivities(ctx, "u1", "a1"); len(got) != 50 {
+		t.Errorf("DeleteImortedActivities(nil) left %d imports, want 50", len(got))
+	}
+	if got, _ := s.GetAccountSummaries(ctx, "u1"); len(got) != 50 {
+		t.Errorf("DeleteAccountSummaries(nil) left %d summaries, want 50", len(got))
+	}
+
+	// deleting ids deletes only those, unknown ids are ignored
+	if err := s.DeleteActivities(ctx, []string{"x00", "x01", "missing"}); err != nil {
+		t.Fatalf("DeleteActivities() error = %v", err)
+	}
+	if err := s.DeleteActivityLots(ctx, []string{"l00"}); err != nil {
+		t.Fatalf("DeleteActivityLots() error = %v", err)
+	}
+	if err := s.DeleteImortedActivities(ctx, []string{"i00", "i01", "i02"}); err != nil {
+		t.Fatalf("DeleteImortedActivities() error = %v", err)
+	}
+	if err := s.DeleteAccountSummaries(ctx, []string{"s49"}); err != nil {
+		t.Fatalf("DeleteAccountSummaries() error = %v", err)
+	}
+	if got, _ := s.GetActivities(ctx, "u1"); len(got) != 49 || slices.ContainsFunc(got, func(a *domain.Activity) bool { return a.ID == "x00" }) {
+		t.Errorf("GetActivities() after delete = %d, want 49 without x00", len(got))
+	}
+	if got, _ := s.GetActivityLots(ctx, "u1"); len(got) != 49 {
+		t.Errorf("GetActivityLots() after delete = %d, want 49", len(got))
+	}
+	if got, _ := s.GetImortedActivities(ctx, "u1", "a1"); len(got) != 47 {
+		t.Errorf("GetImortedActivities() after delete = %d, want 47", len(got))
+	}
+	if got, _ := s.GetAccountSummaries(ctx, "u1"); len(got) != 49 {
+		t.Errorf("GetAccountSummaries() after delete = %d, want 49", len(got))
+	}
+}
+
+func testQueryActivities(t *testing.T, s storage.FinTrackerStorageService) {
+
+	ctx := context.Background()
+	saveAccounts(t, s, "u1", "a1", "a2")
+	saveAccounts(t, s, "u2", "a3")
+	s.SaveActivities(ctx, []*domain.Activity{
+		{ID: "x1", UID: "u1", AccountID: "a1", Date: date(2024, 1, 1), TxnType: domain.ActivityTypeDividend, Status: domain.ActivityStatusSettled, RcvSymbol: "USD"},
+		{ID: "x2", UID: "u1", AccountID: "a1", Date: date(2024, 2, 1), TxnType: domain.ActivityTypeBuy, Status: domain.ActivityStatusSettled, RcvSymbol: "AAPL", SentSymbol: "USD"},
+		{ID: "x3", UID: "u1", AccountID: "a2", Date: date(2024, 2, 1), TxnType: domain.ActivityTypeBuy, Status: domain.ActivityStatusPending, RcvSymbol: "MSFT", SentSymbol: "USD"},
+		{ID: "x4", UID: "u1", AccountID: "a2", Date: date(2024, 3, 1), TxnType: domain.ActivityTypeSell, Status: domain.ActivityStatusSettled, RcvSymbol: "USD", SentSymbol: "MSFT"},
+		{ID: "x5", UID: "u2", AccountID: "a3", Date: date(2024, 3, 1), TxnType: domain.ActivityTypeBuy, RcvSymbol: "AAPL"},
+	})
+
+	tests := []struct {
+		name  string
+		query domain.ActivityQuery
+		want  string
+	}{
+		{"newest first", domain.ActivityQuery{}, "[x4 x3 x2 x1]"},
+		{"oldest first", domain.ActivityQuery{Page: domain.Page{Sort: "date"}}, "[x1 x2 x3 x4]"},
+		{"accounts", domain.ActivityQuery{AccountIDs: []string{"a2"}}, "[x4 x3]"},
+		{"dates inclusive", domain.ActivityQuery{StartDate: date(2024, 2, 1), EndDate: date(2024, 2, 1)}, "[x3 x2]"},
+		{"open start", domain.ActivityQuery{EndDate: date(2024, 1, 31)}, "[x1]"},
+		{"rcv or sent symbol", domain.ActivityQuery{Symbols: []string{"MSFT"}}, "[x4 x3]"},
+		{"txn types", domain.ActivityQuery{TxnTypes: []domain.ActivityType{domain.ActivityTypeBuy, domain.ActivityTypeSell}}, "[x4 x3 x2]"},
+		{"statuses", domain.ActivityQuery{Statuses: []domain.ActivityStatus{domain.ActivityStatusPending}}, "[x3]"},
+		{"by symbol", domain.ActivityQuery{Page: domain.Page{Sort: "symbol"}}, "[x2 x3 x1 x4]"},
+		{"by symbol descending", domain.ActivityQuery{Page: domain.Page{Sort: "-symbol"}}, "[x4 x1 x3 x2]"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			paged, err := s.QueryActivities(ctx, "u1", tt.query)
+			if err != nil {
+				t.Fatalf("QueryActivities() error = %v", err)
+			}
+			if got := ordered(paged.Items); got != tt.want || len(paged.NextCursor) > 0 {
+				t.Errorf("QueryActivities() = %s next %q, want %s", got, paged.NextCursor, tt.want)
+			}
+		})
+	}
+
+	// pages neither skip nor repeat activities with the same sort value
+	for _, sort := range []string{"date", "-date", "symbol", "-symbol"} {
+		all, _ := s.QueryActivities(ctx, "u1", domain.ActivityQuery{Page: domain.Page{Sort: sort}})
+		query := domain.ActivityQuery{Page: domain.Page{Limit: 1, Sort: sort}}
+		got := []*domain.Activity{}
+		for pages := 0; pages < 10; pages++ {
+			paged, err := s.QueryActivities(ctx, "u1", query)
+			if err != nil {
+				t.Fatalf("QueryActivities(%s) page %d error = %v", sort, pages, err)
+			}
+			got = append(got, paged.Items...)
+			if len(paged.NextCursor) == 0 {
+				break
+			}
+			query.Cursor = paged.NextCursor
+		}
+		if ordered(got) != ordered(all.Items) {
+			t.Errorf("paged QueryActivities(%s) = %s, want %s", sort, ordered(got), ordered(all.Items))
+		}
+	}
+
+	if _, err := s.QueryActivities(ctx, "u1", domain.ActivityQuery{Page: domain.Page{Cursor: "bogus"}}); !errors.Is(err, storage.ErrInvalidCursor) {
+		t.Errorf("QueryActivities(bogus cursor) error = %v, want ErrInvalidCursor", err)
+	}
+	if _, err := s.QueryActivities(ctx, "u1", domain.ActivityQuery{Page: domain.Page{Sort: "amount"}}); err == nil {
+		t.Errorf("QueryActivities(sort amount) error = nil, want invalid sort")
+	}
+}
+
+func testQueryActivityLots(t *testing.T, s storage.FinTrackerStorageService) {
+
+	ctx := context.Background()
+	d1, d2 := date(2024, 1, 1), date(2024, 2, 1)
+	saveAccounts(t, s, "u1", "a1", "a2")
+	s.SaveActivityLots(ctx, []*domain.ActivityLot{
+		{ID: "l1", UID: "u1", AccountID: "a1", Symbol: "VTI", Date: &d1, Status: domain.LotStatusOpen},
+		{ID: "l2", UID: "u1", AccountID: "a1", Symbol: "BND", Date: &d2, Status: domain.LotStatusClosed},
+		{ID: "l3", UID: "u1", AccountID: "a2", Symbol: "VTI", Date: &d2, Status: domain.LotStatusOpen},
+		{ID: "l4", UID: "u1", AccountID: "a2", Symbol: "AAPL", Status: domain.LotStatusOpen},
+	})
+
+	tests := []struct {
+		name  string
+		query domain.LotQuery
+		want  string
+	}{
+		{"newest first", domain.LotQuery{}, "[l3 l2 l1 l4]"},
+		{"open", domain.LotQuery{Statuses: []domain.LotStatus{domain.LotStatusOpen}}, "[l3 l1 l4]"},
+		{"symbols", domain.LotQuery{Symbols: []string{"VTI"}}, "[l3 l1]"},
+		{"accounts", domain.LotQuery{AccountIDs: []string{"a1"}}, "[l2 l1]"},
+		{"acquired", domain.LotQuery{StartDate: d2}, "[l3 l2]"},
+		{"by symbol", domain.LotQuery{Page: domain.Page{Sort: "symbol"}}, "[l4 l2 l1 l3]"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			paged, err := s.QueryActivityLots(ctx, "u1", tt.query)
+			if err != nil {
+				t.Fatalf("QueryActivityLots() error = %v", err)
+			}
+			if got := ordered(paged.Items); got != tt.want || len(paged.NextCursor) > 0 {
+				t.Errorf("QueryActivityLots() = %s next %q, want %s", got, paged.NextCursor, tt.want)
+			}
+		})
+	}
+
+	// the lot without a date pages like the others
+	query := domain.LotQuery{Page: domain.Page{Limit: 3}}
+	first, _ := s.QueryActivityLots(ctx, "u1", query)
+	query.Cursor = first.NextCursor
+	second, err := s.QueryActivityLots(ctx, "u1", query)
+	if err != nil {
+		t.Fatalf("QueryActivityLots() second page error = %v", err)
+	}
+	if ordered(first.Items) != "[l3 l2 l1]" || ordered(second.Items) != "[l4]" || len(second.NextCursor) > 0 {
+		t.Errorf("paged QueryActivityLots() = %s %s, want [l3 l2 l1] [l4]", ordered(first.Items), ordered(second.Items))
+	}
+}
+
+func testSummarySnapshots(t *testing.T, s storage.FinTrackerStorageService) {
+
+	ctx := context.Background()
+	if latest, err := s.GetLatestAccountSummaries(ctx, "u1"); err != nil || len(latest) != 0 {
+		t.Fatalf("GetLatestAccountSummaries() without snapshots = %v, %v, want none", latest, err)
+	}
+
+	snapshot := func(uid string, acctId string, day time.Time, value string) *domain.AccountSummary {
+		asum := &domain.AccountSummary{UID: uid, AccountID: acctId, MarketValue: dec(value)}
+		asum.SetSnapshot(day.Add(15 * time.Hour))
+		return asum
+	}
+	saveAccounts(t, s, "u1", "a1", "a2")
+	saveAccounts(t, s, "u2", "a9")
+	s.SaveAccountSummaries(ctx, []*domain.AccountSummary{
+		snapshot("u1", "a2", date(2024, 1, 2), "20"),
+		snapshot("u1", "a1", date(2024, 1, 2), "10"),
+		snapshot("u1", "a1", date(2024, 1, 1), "5"),
+		snapshot("u1", "a1", date(2024, 1, 3), "1"),
+		snapshot("u2", "a9", date(2024, 2, 1), "1"),
+	})
+	// a second refresh on the same day replaces the snapshot
+	if err := s.SaveAccountSummaries(ctx, []*domain.AccountSummary{snapshot("u1", "a1", date(2024, 1, 3), "12")}); err != nil {
+		t.Fatalf("SaveAccountSummaries() again error = %v", err)
+	}
+
+	tests := []struct {
+		name  string
+		start time.Time
+		end   time.Time
+		want  string
+	}{
+		{"all", time.Time{}, time.Time{}, "[a1-2024-01-01 a1-2024-01-02 a2-2024-01-02 a1-2024-01-03]"},
+		{"inclusive", date(2024, 1, 2), date(2024, 1, 2), "[a1-2024-01-02 a2-2024-01-02]"},
+		{"open end", date(2024, 1, 3), time.Time{}, "[a1-2024-01-03]"},
+	}
+	for _, tt := range tests {
+		asumys, err := s.GetAccountSummariesByDate(ctx, "u1", tt.start, tt.end)
+		if err != nil {
+			t.Fatalf("GetAccountSummariesByDate(%s) error = %v", tt.name, err)
+		}
+		if got := ordered(asumys); got != tt.want {
+			t.Errorf("GetAccountSummariesByDate(%s) = %s, want %s", tt.name, got, tt.want)
+		}
+	}
+
+	latest, err := s.GetLatestAccountSummaries(ctx, "u1")
+	if err != nil || len(latest) != 1 || !latest[0].MarketValue.Equal(dec("12")) || !latest[0].Date.Equal(date(2024, 1, 3)) {
+		t.Errorf("GetLatestAccountSummaries() = %v, %v, want the replaced a1 snapshot of 2024-01-03", latest, err)
+	}
+}
+
+func testAccountValues(t *testing.T, s storage.FinTrackerStorageService) {
+
+	ctx := context.Background()
+	value := func(uid string, acctId string, day time.Time, market string) *domain.AccountValue {
+		v := &domain.AccountValue{UID: uid, AccountID: acctId, MarketValue: dec(market), CostValue: dec("100.123456789")}
+		v.SetDate(day)
+		return v
+	}
+	saveAccounts(t, s, "u1", "a1", "a2")
+	saveAccounts(t, s, "u2", "a9")
+	unpriced := value("u1", "a1", date(2024, 1, 2), "0")
+	unpriced.Unpriced = []string{"XYZ"}
+	if err := s.SaveAccountValues(ctx, []*domain.AccountValue{
+		value("u1", "a2", date(2024, 1, 1), "20"),
+		value("u1", "a1", date(2024, 1, 1), "10"),
+		unpriced,
+		value("u2", "a9", date(2024, 1, 1), "1"),
+	}); err != nil {
+		t.Fatalf("SaveAccountValues() error = %v", err)
+	}
+	// values are upserted by account and day
+	s.SaveAccountValues(ctx, []*domain.AccountValue{value("u1", "a1", date(2024, 1, 1), "11")})
+
+	values, err := s.GetAccountValues(ctx, "u1", time.Time{}, time.Time{})
+	if err != nil {
+		t.Fatalf("GetAccountValues() error = %v", err)
+	}
+	if got := ordered(values); got != "[a1-2024-01-01 a2-2024-01-01 a1-2024-01-02]" {
+		t.Fatalf("GetAccountValues() = %s", got)
+	}
+	if !values[0].MarketValue.Equal(dec("11")) || !values[0].CostValue.Equal(dec("100.123456789")) || !values[0].Date.Equal(date(2024, 1, 1)) {
+		t.Errorf("GetAccountValues()[0] = %+v, want the upserted a1 value", values[0])
+	}
+	if !slices.Equal(values[2].Unpriced, []string{"XYZ"}) {
+		t.Errorf("GetAccountValues()[2].Unpriced = %v", values[2].Unpriced)
+	}
+	if values, _ := s.GetAccountValues(ctx, "u1", date(2024, 1, 2), date(2024, 1, 2)); ordered(values) != "[a1-2024-01-02]" {
+		t.Errorf("GetAccountValues(2024-01-02) = %s", ordered(values))
+	}
+
+	if err := s.DeleteAccountValues(ctx, []string{"a1-2024-01-01", "a1-2024-01-02"}); err != nil {
+		t.Fatalf("DeleteAccountValues() error = %v", err)
+	}
+	if values, _ := s.GetAccountValues(ctx, "u1", time.Time{}, time.Time{}); ordered(values) != "[a2-2024-01-01]" {
+		t.Errorf("GetAccountValues() after delete = %s", ordered(values))
+	}
+}
+
+func testMappingRules(t *testing.T, s storage.FinTrackerStorageService) {
+
+	ctx := context.Background()
+	saveAccounts(t, s, "u1", "a1")
+	for _, rule := range []*domain.ActivityMappingRule{
+		{ID: "r1", UID: "u1", AccountID: "a1", Name: "late", Priority: 20, TxnTypePattern: "^div", ActivityType: domain.ActivityTypeDividend},
+		{ID: "r2", UID: "u1", Name: "early", Priority: 10, NotesPattern: "fee", ActivityType: domain.ActivityTypeFee,
+			FieldMapping: map[string]string{"fee": "sentAmount"}},
+		{ID: "r3", UID: "u1", Name: "skip", Priority: 15, NotesPattern: "ignore", Skip: true},
+	} {
+		if err := s.SaveActivityMappingRule(ctx, rule); err != nil {
+			t.Fatalf("SaveActivityMappingRule(%s) error = %v", rule.ID, err)
+		}
+	}
+	rules, err := s.GetActivityMappingRules(ctx, "u1")
+	if err != nil {
+		t.Fatalf("GetActivityMappingRules() error = %v", err)
+	}
+	if ordered(rules) != "[r2 r3 r1]" {
+		t.Fatalf("GetActivityMappingRules() = %s, want by priority [r2 r3 r1]", ordered(rules))
+	}
+	if rules[0].FieldMapping["fee"] != "sentAmount" || !rules[1].Skip || rules[2].TxnTypePattern != "^div" ||
+		rules[0].AccountID != "" || rules[2].AccountID != "a1" {
+		t.Errorf("GetActivityMappingRules() = %+v %+v %+v", rules[0], rules[1], rules[2])
+	}
+
+	if err := s.DeleteActivityMappingRule(ctx, "u1", "r3"); err != nil {
+		t.Fatalf("DeleteActivityMappingRule() error = %v", err)
+	}
+	if rules, _ := s.GetActivityMappingRules(ctx, "u1"); ordered(rules) != "[r2 r1]" {
+		t.Errorf("GetActivityMappingRules() after delete = %s, want [r2 r1]", ordered(rules))
+	}
+}
+
+func testPipelineRuns(t *testing.T, s storage.FinTrackerStorageService) {
+
+	ctx := context.Background()
+	finished := date(2024, 1, 1).Add(time.Minute)
+	for i, run := range []*domain.PipelineRun{
+		{ID: "p1", Command: "sync", Started: date(2024, 1, 1), Finished: &finished, Status: domain.PipelineRunSuccess, Jobs: 2, Succeeded: 2,
+			Args: []string{"-workers", "4"}, Outcomes: []domain.PipelineJobOutcome{{UserID: "u1", Job: "sync", Status: "success", Attempts: 1, DurationMs: 1200}}},
+		{ID: "p2", Command: "refresh", Started: date(2024, 1, 2), Status: domain.PipelineRunRunning},
+		{ID: "p3", Command: "sync", Started: date(2024, 1, 3), Status: domain.PipelineRunFailed, Error: "boom"},
+	} {
+		if err := s.SavePipelineRun(ctx, run); err != nil {
+			t.Fatalf("SavePipelineRun(%d) error = %v", i, err)
+		}
+	}
+
+	run, err := s.GetPipelineRun(ctx, "p1")
+	if err != nil {
+		t.Fatalf("GetPipelineRun() error = %v", err)
+	}
+	if run.Duration() != time.Minute || !slices.Equal(run.Args, []string{"-workers", "4"}) || len(run.Outcomes) != 1 || run.Outcomes[0].DurationMs != 1200 {
+		t.Errorf("GetPipelineRun() = %+v", run)
+	}
+
+	if runs, _ := s.GetPipelineRuns(ctx, "", 0); ordered(runs) != "[p3 p2 p1]" {
+		t.Errorf("GetPipelineRuns() = %s, want most recent first [p3 p2 p1]", ordered(runs))
+	}
+	if runs, _ := s.GetPipelineRuns(ctx, "sync", 1); ordered(runs) != "[p3]" {
+		t.Errorf("GetPipelineRuns(sync, 1) = %s, want [p3]", ordered(runs))
+	}
+
+	// finishing a run replaces it
+	finished = date(2024, 1, 2).Add(time.Hour)
+	s.SavePipelineRun(ctx, &domain.PipelineRun{ID: "p2", Command: "refresh", Started: date(2024, 1, 2), Finished: &finished, Status: domain.PipelineRunSuccess})
+	if run, _ := s.GetPipelineRun(ctx, "p2"); run == nil || run.Status != domain.PipelineRunSuccess || run.Duration() != time.Hour {
+		t.Errorf("GetPipelineRun(p2) after finish = %+v", run)
+	}
+}
+
+func testUsers(t *testing.T, s storage.FinTrackerStorageService) {
+
+	ctx := context.Background()
+	s.SaveUser(ctx, &domain.User{ID: "u1", CurrencyCode: "USD", Country: "US", LotMatchingMethod: domain.LotMatchingHIFO})
+	s.SaveUser(ctx, &domain.User{ID: "u2", CurrencyCode: "EUR", Country: "DE", LotMatchingMethod: domain.LotMatchingFIFO})
+	s.SaveUser(ctx, &domain.User{ID: "u1", CurrencyCode: "CAD", Country: "CA", LotMatchingMethod: domain.LotMatchingLIFO})
+
+	user, err := s.GetUser(ctx, "u1")
+	if err != nil {
+		t.Fatalf("GetUser() error = %v", err)
+	}
+	if user.CurrencyCode != "CAD" || user.Country != "CA" || user.LotMatchingMethod != domain.LotMatchingLIFO {
+		t.Errorf("GetUser() = %+v, want the saved settings", user)
+	}
+	if users, err := s.GetUsers(ctx); err != nil || ids(users) != "[u1 u2]" {
+		t.Errorf("GetUsers() = %s, %v, want [u1 u2]", ids(users), err)
+	}
+
+	if err := s.DeleteUser(ctx, "u1"); err != nil {
+		t.Fatalf("DeleteUser() error = %v", err)
+	}
+	if _, err := s.GetUser(ctx, "u1"); !errors.Is(err, storage.ErrNotFound) {
+		t.Errorf("GetUser() after delete error = %v, want ErrNotFound", err)
+	}
+	if users, _ := s.GetUsers(ctx); ids(users) != "[u2]" {
+		t.Errorf("GetUsers() after delete = %s, want [u2]", ids(users))
+	}
+	// deleting a missing user is not an error
+	if err := s.DeleteUser(ctx, "u1"); err != nil {
+		t.Errorf("DeleteUser() again error = %v, want nil", err)
+	}
+}
+
+func testDeletionReceipts(t *testing.T, s storage.FinTrackerStorageService) {
+
+	ctx := context.Background()
+	receipt := &domain.DeletionReceipt{ID: "d1", UID: "u1", Scope: domain.DeletionScopeAccount, AccountID: "a1",
+		Requested: date(2024, 1, 1), Status: domain.DeletionRunning, Counts: map[string]int{}}
+	if err := s.SaveDeletionReceipt(ctx, receipt); err != nil {
+		t.Fatalf("SaveDeletionReceipt() error = %v", err)
+	}
+
+	// finishing the deletion replaces the receipt
+	finished := date(2024, 1, 1).Add(time.Minute)
+	receipt.Finished = &finished
+	receipt.Status = domain.DeletionSuccess
+	receipt.Counts = map[string]int{domain.ACTIVITY_COLLECTION_NAME: 12, domain.ACCOUNT_COLLECTION_NAME: 1}
+	if err := s.SaveDeletionReceipt(ctx, receipt); err != nil {
+		t.Fatalf("SaveDeletionReceipt() again error = %v", err)
+	}
+	got, err := s.GetDeletionReceipt(ctx, "d1")
+	if err != nil {
+		t.Fatalf("GetDeletionReceipt() error = %v", err)
+	}
+
+	// running receipts of every user are found e.g to fail those of a crashed process
+	running := &domain.DeletionReceipt{ID: "d2", UID: "u2", Scope: domain.DeletionScopeUser, Requested: date(2024, 1, 2),
+		Status: domain.DeletionRunning, Counts: map[string]int{}}
+	if err := s.SaveDeletionReceipt(ctx, running); err != nil {
+		t.Fatalf("SaveDeletionReceipt(d2) error = %v", err)
+	}
+	if receipts, err := s.GetDeletionReceiptsByStatus(ctx, domain.DeletionRunning); err != nil || len(receipts) != 1 || receipts[0].ID != "d2" {
+		t.Errorf("GetDeletionReceiptsByStatus(running) = %v, %v, want [d2]", receipts, err)
+	}
+	if got.UID != "u1" || got.Scope != domain.DeletionScopeAccount || got.AccountID != "a1" || got.Status != domain.DeletionSuccess ||
+		got.Finished == nil || !got.Finished.Equal(finished) || got.Counts[domain.ACTIVITY_COLLECTION_NAME] != 12 || len(got.Counts) != 2 {
+		t.Errorf("GetDeletionReceipt() = %+v, want %+v", got, receipt)
+	}
+
+	// receipts outlive the user
+	s.SaveUser(ctx, &domain.User{ID: "u1"})
+	if err := s.DeleteUser(ctx, "u1"); err != nil {
+		t.Fatalf("DeleteUser() error = %v", err)
+	}
+	if _, err := s.GetDeletionReceipt(ctx, "d1"); err != nil {
+		t.Errorf("GetDeletionReceipt() after DeleteUser error = %v", err)
+	}
+}
+
+func testAuditEntries(t *testing.T, s storage.FinTrackerStorageService) {
+
+	ctx := context.Background()
+	entry := func(id string, uid string, acctId string, minutes int) *domain.AuditEntry {
+		return &domain.AuditEntry{ID: id, UID: uid, ActorUID: uid, EntityType: "account", EntityID: acctId, AccountID: acctId,
+			Operation: domain.AuditUpdate, RequestID: "r-" + id, Time: date(2024, 1, 1).Add(time.Duration(minutes) * time.Minute)}
+	}
+	e1 := entry("e1", "u1", "a1", 1)
+	e1.Changes = []domain.AuditChange{{Field: "name", Before: json.RawMessage(`"Old"`), After: json.RawMessage(`"New"`)},
+		{Field: "tags", After: json.RawMessage(`["a","b"]`)}}
+	for _, e := range []*domain.AuditEntry{e1, entry("e3", "u1", "a1", 3), entry("e2", "u1", "a2", 2), entry("e4", "u2", "a1", 4)} {
+		if err := s.SaveAuditEntry(ctx, e); err != nil {
+			t.Fatalf("SaveAuditEntry(%s) error = %v", e.ID, err)
+		}
+	}
+
+	// entries are append only
+	if err := s.SaveAuditEntry(ctx, entry("e1", "u1", "a1", 9)); !errors.Is(err, storage.ErrAlreadyExists) {
+		t.Errorf("SaveAuditEntry() existing id error = %v, want ErrAlreadyExists", err)
+	}
+
+	tests := []struct {
+		acctId string
+		limit  int64
+		want   string
+	}{
+		{"", 0, "[e3 e2 e1]"},
+		{"a1", 0, "[e3 e1]"},
+		{"", 2, "[e3 e2]"},
+		{"a3", 0, "[]"},
+	}
+	for _, tt := range tests {
+		entries, err := s.GetAuditEntries(ctx, "u1", tt.acctId, tt.limit)
+		if err != nil {
+			t.Fatalf("GetAuditEntries(%q, %d) error = %v", tt.acctId, tt.limit, err)
+		}
+		if got := ordered(entries); got != tt.want {
+			t.Errorf("GetAuditEntries(%q, %d) = %s, want %s", tt.acctId, tt.limit, got, tt.want)
+		}
+	}
+
+	entries, _ := s.GetAuditEntries(ctx, "u1", "a1", 0)
+	got := entries[len(entries)-1]
+	if got.ActorUID != "u1" || got.EntityID != "a1" || got.RequestID != "r-e1" || !got.Time.Equal(e1.Time) || len(got.Changes) != 2 ||
+		string(got.Changes[0].Before) != `"Old"` || got.Changes[1].Before != nil || !json.Valid(got.Changes[1].After) {
+		t.Errorf("GetAuditEntries() e1 = %+v, want %+v", got, e1)
+	}
+}
+
+func testUserLocks(t *testing.T, s storage.FinTrackerStorageService) {
+
+	ctx := context.Background()
+	now := date(2024, 1, 1)
+	lock := &domain.UserLock{ID: "u1", Operation: "sync", Owner: "host-1", AcquiredAt: now, HeartbeatAt: now, ExpiresAt: now.Add(time.Minute)}
+	if err := s.CreateUserLock(ctx, lock); err != nil {
+		t.Fatalf("CreateUserLock() error = %v", err)
+	}
+	if err := s.CreateUserLock(ctx, &domain.UserLock{ID: "u1", Owner: "host-2", ExpiresAt: now}); !errors.Is(err, storage.ErrAlreadyExists) {
+		t.Fatalf("CreateUserLock() when locked error = %v, want ErrAlreadyExists", err)
+	}
+	got, err := s.GetUserLock(ctx, "u1")
+	if err != nil || got == nil || got.Owner != "host-1" || !got.ExpiresAt.Equal(lock.ExpiresAt) {
+		t.Fatalf("GetUserLock() = %+v, %v, want the host-1 lock", got, err)
+	}
+
+	extended := *lock
+	extended.HeartbeatAt = now.Add(30 * time.Second)
+	extended.ExpiresAt = now.Add(2 * time.Minute)
+	if err := s.ReplaceUserLock(ctx, lock, &extended); err != nil {
+		t.Fatalf("ReplaceUserLock() error = %v", err)
+	}
+	if got, _ := s.GetUserLock(ctx, "u1"); got == nil || !got.ExpiresAt.Equal(extended.ExpiresAt) {
+		t.Errorf("GetUserLock() after heartbeat = %+v, want expiry %v", got, extended.ExpiresAt)
+	}
+
+	// a swap against the lock before the heartbeat, or of another owner, changes nothing
+	for _, prev := range []*domain.UserLock{lock, {ID: "u1", Owner: "host-2", ExpiresAt: extended.ExpiresAt}} {
+		taken := &domain.UserLock{ID: "u1", Operation: "refresh", Owner: "host-3", AcquiredAt: now, HeartbeatAt: now, ExpiresAt: now.Add(time.Hour)}
+		if err := s.ReplaceUserLock(ctx, prev, taken); !errors.Is(err, storage.ErrConflict) {
+			t.Errorf("ReplaceUserLock(%s, %s) error = %v, want ErrConflict", prev.Owner, prev.ExpiresAt, err)
+		}
+	}
+	if got, _ := s.GetUserLock(ctx, "u1"); got == nil || got.Owner != "host-1" || !got.ExpiresAt.Equal(extended.ExpiresAt) {
+		t.Errorf("GetUserLock() after stale swaps = %+v, want the extended host-1 lock", got)
+	}
+
+	if err := s.DeleteUserLock(ctx, "u1"); err != nil {
+		t.Fatalf("DeleteUserLock() error = %v", err)
+	}
+	if err := s.CreateUserLock(ctx, &domain.UserLock{ID: "u1", Owner: "host-2", ExpiresAt: now}); err != nil {
+		t.Errorf("CreateUserLock() after release error = %v", err)
+	}
+	if err := s.ReplaceUserLock(ctx, lock, &extended); !errors.Is(err, storage.ErrConflict) {
+		t.Errorf("ReplaceUserLock() of a released lock error = %v, want ErrConflict", err)
+	}
+}
+
+func testTransactions(t *testing.T, s storage.FinTrackerStorageService) {
+
+	ctx := context.Background()
+	start, end := date(2024, 1, 1), date(2024, 12, 31)
+	err := s.ImportTransactions(ctx, "u1", start, end, []*domain.Transaction{
+		{Date: date(2024, 1, 5), Group: "Home", Category: "Groceries", Account: "Checking", Description: "Whole Foods Market", Dbcr: "debit", Amount: 100, DAmount: 100},
+		{Date: date(2024, 1, 20), Group: "Home", Category: "Groceries", Account: "Checking", Description: "Safeway", Dbcr: "debit", Amount: 50, DAmount: 50},
+		{Date: date(2024, 1, 25), Group: "Home", Category: "Groceries", Account: "Checking", Description: "Refund", Dbcr: "credit", Amount: 20, CAmount: 20, Tag: "returns"},
+		{Date: date(2024, 2, 1), Group: "Income", Category: "Paycheck", Account: "Checking", Description: "Salary", Dbcr: "credit", Amount: 5000},
+		{Date: date(2024, 2, 3), Group: "Travel", Category: "Flights", Account: "Credit Card", Description: "United Airlines", Dbcr: "debit", Amount: 400},
+	})
+	if err != nil {
+		t.Fatalf("ImportTransactions() error = %v", err)
+	}
+	s.ImportTransactions(ctx, "u2", start, end, []*domain.Transaction{
+		{Date: date(2024, 1, 5), Group: "Home", Category: "Groceries", Account: "Checking", Description: "Whole Foods Market", Dbcr: "debit", Amount: 1},
+	})
+
+	txns, err := s.SearchTransactions(ctx, "u1", start, end, "whole")
+	if err != nil {
+		t.Fatalf("SearchTransactions() error = %v", err)
+	}
+	if len(txns) != 1 || txns[0].Description != "Whole Foods Market" || txns[0].UID != "u1" || len(txns[0].ID) == 0 || txns[0].Amount != 100 {
+		t.Fatalf("SearchTransactions(whole) = %v", txns)
+	}
+
+	// every word must start a word of one of the fields, ignoring case
+	tests := []struct {
+		text string
+		want int
+	}{
+		{"", 5},
+		{"FOODS", 1},
+		{"groc check", 3},
+		{"groc credit", 0},
+		{"oods", 0},
+		{"ret", 1},
+		{"credit card united", 1},
+	}
+	for _, tt := range tests {
+		if txns, err := s.SearchTransactions(ctx, "u1", start, end, tt.text); err != nil || len(txns) != tt.want {
+			t.Errorf("SearchTransactions(%q) = %d, %v, want %d", tt.text, len(txns), err, tt.want)
+		}
+	}
+	if txns, _ := s.SearchTransactions(ctx, "u1", date(2024, 1, 20), date(2024, 1, 25), ""); len(txns) != 2 {
+		t.Errorf("SearchTransactions(dates inclusive) = %d, want 2", len(txns))
+	}
+
+	aggs, err := s.SummaryTransactions(ctx, "u1", time.Time{}, time.Time{})
+	if err != nil {
+		t.Fatalf("SummaryTransactions() error = %v", err)
+	}
+	// paychecks are left out, debits are negative
+	want := "[{2024 1 Home Groceries Checking -130} {2024 2 Travel Flights Credit Card -400}]"
+	got := []string{}
+	for _, agg := range aggs {
+		got = append(got, fmt.Sprintf("{%d %d %s %s %s %v}", agg.ID.Year, agg.ID.Month, agg.ID.Group, agg.ID.Category, agg.ID.Account, agg.Amount))
+	}
+	if fmt.Sprint(got) != want {
+		t.Errorf("SummaryTransactions() = %v, want %s", got, want)
+	}
+
+	// a re-import replaces the user's transactions in the range only
+	s.ImportTransactions(ctx, "u1", date(2024, 1, 1), date(2024, 1, 31), []*domain.Transaction{
+		{Date: date(2024, 1, 10), Group: "Home", Category: "Groceries", Account: "Checking", Dbcr: "debit", Amount: 10},
+	})
+	if txns, _ := s.SearchTransactions(ctx, "u1", start, end, ""); len(txns) != 3 {
+		t.Errorf("after re-import = %d transactions, want 3", len(txns))
+	}
+	if txns, _ := s.SearchTransactions(ctx, "u2", start, end, ""); len(txns) != 1 {
+		t.Errorf("other user after re-import = %d transactions, want 1", len(txns))
+	}
+}
@@ -0,0 +1,224 @@
+package storagetest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"testing"
+
+	"github.com/rkapps/fin-tracker-backend-go/internal/domain"
+	"github.com/rkapps/fin-tracker-backend-go/internal/storage"
+	"github.com/shopspring/decimal"
+)
+
+// TickerFixtures are the records a ticker store is opened with — the service is
+// read only, the tickers are loaded by the market data jobs.
+type TickerFixtures struct {
+	Tickers    []*domain.Ticker
+	History    []*domain.TickerHistory
+	Sentiments []*domain.TickerSentiment
+	Embeddings []*domain.TickerEmbedding
+}
+
+// OpenTicker returns a new store holding only the fixtures — it is called once for every test.
+type OpenTicker func(t *testing.T, fixtures TickerFixtures) storage.TickerStorageService
+
+// RunTicker runs the conformance tests of TickerStorageService. SearchTicker is
+// left to the backends, the Mongo one needs an Atlas search index.
+func RunTicker(t *testing.T, open OpenTicker) {
+	tests := []struct {
+		name string
+		test func(t *testing.T, s storage.TickerStorageService)
+	}{
+		{"Tickers", testTickers},
+		{"TickerPrice", testTickerPrice},
+		{"TickerGroups", testTickerGroups},
+		{"TickerSeries", testTickerSeries},
+		{"DeleteTicker", testDeleteTicker},
+	}
+	fixtures := tickerFixtures()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.test(t, open(t, fixtures))
+		})
+	}
+}
+
+func tickerFixtures() TickerFixtures {
+
+	fixtures := TickerFixtures{
+		Tickers: []*domain.Ticker{
+			{ID: "AAPL", Symbol: "AAPL", Name: "Apple Inc", Sector: "Technology", Industry: "Consumer Electronics", Active: true, PrLast: dec("189.123456789")},
+			{ID: "MSFT", Symbol: "MSFT", Name: "Microsoft Corp", Sector: "Technology", Industry: "Software", Active: true, PrLast: dec("410.5")},
+			{ID: "KO", Symbol: "KO", Name: "Coca-Cola Co", Sector: "Consumer Defensive", Industry: "Beverages", Active: true, PrLast: dec("60")},
+			{ID: "BTC", Symbol: "BTC", Name: "Bitcoin", Sector: "Crypto", Industry: "Crypto", Active: true, PrLast: dec("67000.00000001")},
+		},
+		Sentiments: []*domain.TickerSentiment{
+			{ID: "s1", Symbol: "AAPL", Date: date(2024, 1, 1), Title: "Apple beats", RelevanceScore: dec("0.912345"), Score: dec("-0.25"), Label: "Neutral"},
+			{ID: "s2", Symbol: "AAPL", Date: date(2024, 1, 2), Title: "Apple misses", Score: dec("-0.5"), Label: "Bearish"},
+			{ID: "s3", Symbol: "MSFT", Date: date(2024, 1, 1), Title: "Microsoft", Score: dec("0.5"), Label: "Bullish"},
+		},
+		Embeddings: []*domain.TickerEmbedding{
+			{ID: "e1", Symbol: "AAPL", SentimentId: "s1", EmbeddingText: "Apple beats", Vector: []float64{0.1, -0.2, 0.3}},
+			{ID: "e2", Symbol: "MSFT", SentimentId: "s3", EmbeddingText: "Microsoft", Vector: []float64{0.4}},
+		},
+	}
+	for i, close := range []string{"185.5", "187.25", "189.123456789"} {
+		th := &domain.TickerHistory{ID: fmt.Sprintf("AAPL-%d", i), Date: date(2024, 1, 1+i), Close: dec(close), Volume: dec("51234567")}
+		th.Metadata.Symbol, th.Metadata.Exchange, th.Metadata.Granularity = "AAPL", "NASDAQ", "1d"
+		fixtures.History = append(fixtures.History, th)
+	}
+	th := &domain.TickerHistory{ID: "MSFT-0", Date: date(2024, 1, 1), Close: dec("410.5")}
+	th.Metadata.Symbol = "MSFT"
+	fixtures.History = append(fixtures.History, th)
+	return fixtures
+}
+
+func symbols(tks domain.Tickers) string {
+	ss := []string{}
+	for _, tk := range tks {
+		ss = append(ss, tk.Symbol)
+	}
+	sort.Strings(ss)
+	return fmt.Sprint(ss)
+}
+
+func testTickers(t *testing.T, s storage.TickerStorageService) {
+
+	ctx := context.Background()
+	ticker, err := s.GetTicker(ctx, "AAPL")
+	if err != nil {
+		t.Fatalf("GetTicker() error = %v", err)
+	}
+	if ticker.Name != "Apple Inc" || ticker.Sector != "Technology" || !ticker.Active || !ticker.PrLast.Equal(dec("189.123456789")) {
+		t.Errorf("GetTicker() = %+v", ticker)
+	}
+	if _, err := s.GetTicker(ctx, "MISSING"); !errors.Is(err, storage.ErrNotFound) {
+		t.Errorf("GetTicker(missing) error = %v, want ErrNotFound", err)
+	}
+
+	tests := []struct {
+		symbols []string
+		want    string
+	}{
+		{nil, "[AAPL BTC KO MSFT]"},
+		{[]string{"aapl", "Ko"}, "[AAPL KO]"},
+		{[]string{"", "msft"}, "[MSFT]"},
+		{[]string{"missing"}, "[]"},
+	}
+	for _, tt := range tests {
+		tks, err := s.GetTickers(ctx, tt.symbols)
+		if err != nil {
+			t.Fatalf("GetTickers(%v) error = %v", tt.symbols, err)
+		}
+		if got := symbols(tks); got != tt.want {
+			t.Errorf("GetTickers(%v) = %s, want %s", tt.symbols, got, tt.want)
+		}
+	}
+}
+
+func testTickerPrice(t *testing.T, s storage.TickerStorageService) {
+
+	ctx := context.Background()
+	tests := []struct {
+		symbol string
+		want   decimal.Decimal
+	}{
+		{"USD", decimal.NewFromInt(1)},
+		{"USDC", decimal.NewFromInt(1)},
+		{"USDT", decimal.NewFromInt(1)},
+		{"AAPL", dec("189.123456789")},
+		{"BTC", dec("67000.00000001")},
+	}
+	for _, tt := range tests {
+		price, err := s.GetTickerPrice(ctx, tt.symbol)
+		if err != nil {
+			t.Fatalf("GetTickerPrice(%s) error = %v", tt.symbol, err)
+		}
+		if !price.Equal(tt.want) {
+			t.Errorf("GetTickerPrice(%s) = %s, want %s", tt.symbol, price, tt.want)
+		}
+	}
+	if _, err := s.GetTickerPrice(ctx, "MISSING"); !errors.Is(err, storage.ErrNotFound) {
+		t.Errorf("GetTickerPrice(missing) error = %v, want ErrNotFound", err)
+	}
+}
+
+func testTickerGroups(t *testing.T, s storage.TickerStorageService) {
+
+	tgs, err := s.GetTickerGroups(context.Background())
+	if err != nil {
+		t.Fatalf("GetTickerGroups() error = %v", err)
+	}
+	got := []string{}
+	for _, tg := range tgs {
+		got = append(got, tg.Sector+"/"+tg.Industry)
+	}
+	sort.Strings(got)
+	want := "[Consumer Defensive/Beverages Crypto/Crypto Technology/Consumer Electronics Technology/Software]"
+	if fmt.Sprint(got) != want {
+		t.Errorf("GetTickerGroups() = %v, want %s", got, want)
+	}
+}
+
+func testTickerSeries(t *testing.T, s storage.TickerStorageService) {
+
+	ctx := context.Background()
+	history, err := s.GetTickerHistory(ctx, "AAPL")
+	if err != nil {
+		t.Fatalf("GetTickerHistory() error = %v", err)
+	}
+	if ids(history) != "[AAPL-0 AAPL-1 AAPL-2]" {
+		t.Fatalf("GetTickerHistory(AAPL) = %s, want [AAPL-0 AAPL-1 AAPL-2]", ids(history))
+	}
+	for _, th := range history {
+		if th.ID == "AAPL-2" && (!th.Close.Equal(dec("189.123456789")) || !th.Volume.Equal(dec("51234567")) ||
+			!th.Date.Equal(date(2024, 1, 3)) || th.Metadata.Exchange != "NASDAQ" || th.Metadata.Granularity != "1d") {
+			t.Errorf("GetTickerHistory(AAPL) AAPL-2 = %+v", th)
+		}
+	}
+
+	sentiments, err := s.GetTickerSentiments(ctx, "AAPL")
+	if err != nil {
+		t.Fatalf("GetTickerSentiments() error = %v", err)
+	}
+	if ids(sentiments) != "[s1 s2]" {
+		t.Fatalf("GetTickerSentiments(AAPL) = %s, want [s1 s2]", ids(sentiments))
+	}
+	for _, ts := range sentiments {
+		if ts.ID == "s1" && (!ts.RelevanceScore.Equal(dec("0.912345")) || !ts.Score.Equal(dec("-0.25")) || !ts.Date.Equal(date(2024, 1, 1))) {
+			t.Errorf("GetTickerSentiments(AAPL) s1 = %+v", ts)
+		}
+	}
+
+	embeddings, err := s.GetTickerEmbeddings(ctx, "AAPL")
+	if err != nil {
+		t.Fatalf("GetTickerEmbeddings() error = %v", err)
+	}
+	if len(embeddings) != 1 || embeddings[0].SentimentId != "s1" || fmt.Sprint(embeddings[0].Vector) != "[0.1 -0.2 0.3]" {
+		t.Errorf("GetTickerEmbeddings(AAPL) = %v", embeddings)
+	}
+
+	// the series of unknown symbols are empty, not errors
+	if history, err := s.GetTickerHistory(ctx, "KO"); err != nil || len(history) != 0 {
+		t.Errorf("GetTickerHistory(KO) = %d, %v, want none", len(history), err)
+	}
+	if sentiments, err := s.GetTickerSentiments(ctx, "MISSING"); err != nil || len(sentiments) != 0 {
+		t.Errorf("GetTickerSentiments(missing) = %d, %v, want none", len(sentiments), err)
+	}
+}
+
+func testDeleteTicker(t *testing.T, s storage.TickerStorageService) {
+
+	ctx := context.Background()
+	if err := s.DeleteTicker(ctx, "KO"); err != nil {
+		t.Fatalf("DeleteTicker() error = %v", err)
+	}
+	if _, err := s.GetTicker(ctx, "KO"); !errors.Is(err, storage.ErrNotFound) {
+		t.Errorf("GetTicker() after delete error = %v, want ErrNotFound", err)
+	}
+	if tks, _ := s.GetTickers(ctx, nil); symbols(tks) != "[AAPL BTC MSFT]" {
+		t.Errorf("GetTickers() after delete = %s, want [AAPL BTC MSFT]", symbols(tks))
+	}
+}
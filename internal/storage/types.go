@@ -1,6 +1,9 @@
 package storage
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"time"
 
 	"github.com/rkapps/fin-tracker-backend-go/internal/domain"
@@ -11,54 +14,130 @@ import (
 // 	FINTRACKER_DB_NAME = "rustic_finance"
 // )
 
+// Errors returned by the storage services — wrapped with the record id, check with errors.Is.
+// Context cancellation and deadlines are returned as is (context.Canceled, context.DeadlineExceeded).
+var (
+	// ErrNotFound is returned when a record looked up by id does not exist.
+	ErrNotFound = errors.New("record not found")
+	// ErrUnauthorized is returned when the record belongs to another user.
+	ErrUnauthorized = errors.New("not authorized")
+	// ErrConflict is returned when a write conflicts with an existing record.
+	ErrConflict = errors.New("conflict")
+	// ErrTransient is returned when the database could not be reached or timed out — the call may be retried.
+	ErrTransient = errors.New("storage unavailable")
+)
+
+// ErrAlreadyExists is returned when a create conflicts with an existing record.
+var ErrAlreadyExists = fmt.Errorf("%w: record already exists", ErrConflict)
+
+// NotFoundError wraps ErrNotFound with the id that was looked up.
+func NotFoundError(id string) error {
+	return fmt.Errorf("%w: %s", ErrNotFound, id)
+}
+
+// UnauthorizedError wraps ErrUnauthorized with the id of the other user's record.
+func UnauthorizedError(id string) error {
+	return fmt.Errorf("%w: %s", ErrUnauthorized, id)
+}
+
 type FinTrackerStorageService interface {
 
 	//Accounts
-	DeleteAccount(uid string, id string) error
-	DeleteAccountSummaries(ids []string) error
-	DeleteActivities(ids []string) error
-	DeleteActivityLots(ids []string) error
-	DeleteImortedActivities(ids []string) error
-	GetAccount(uid string, id string) (*domain.Account, error)
-	GetAccounts(uid string) (domain.Accounts, error)
-	GetAccountSummaries(uid string) ([]*domain.AccountSummary, error)
-	GetAccountCredential(uid string, id string) (*domain.AccountCredential, error)
-	GetAccountSyncState(uid string, id string) (*domain.AccountSyncState, error)
-	GetActivities(uid string) ([]*domain.Activity, error)
-	GetActivitiesForAccount(uid string, acctId string) ([]*domain.Activity, error)
-	GetActivityLots(uid string) ([]*domain.ActivityLot, error)
-	GetActivityLotsForAccount(uid string, acctId string) ([]*domain.ActivityLot, error)
-	GetImortedActivities(uid string, acctId string) ([]*domain.ActivityImport, error)
-
-	SaveAccount(acct *domain.Account) error
-	SaveAccountCredential(acct *domain.AccountCredential) error
-	SaveAccountSyncState(acct *domain.AccountSyncState) error
-	SaveAccountSummaries(asumys []*domain.AccountSummary) error
-	SaveImportedActivities(actvs []*domain.ActivityImport) error
-	SaveActivities(actvs []*domain.Activity) error
-	SaveActivityLots(lots []*domain.ActivityLot) error
+	DeleteAccount(ctx context.Context, uid string, id string) error
+	DeleteAccountCredential(ctx context.Context, uid string, id string) error
+	DeleteAccountSummaries(ctx context.Context, ids []string) error
+	DeleteAccountValues(ctx context.Context, ids []string) error
+	DeleteAccountSyncState(ctx context.Context, uid string, id string) error
+	DeleteActivities(ctx context.Context, ids []string) error
+	DeleteActivityLots(ctx context.Context, ids []string) error
+	DeleteActivityMappingRule(ctx context.Context, uid string, id string) error
+	DeleteImortedActivities(ctx context.Context, ids []string) error
+	GetAccount(ctx context.Context, uid string, id string) (*domain.Account, error)
+	GetAccounts(ctx context.Context, uid string) (domain.Accounts, error)
+	GetAccountSummaries(ctx context.Context, uid string) ([]*domain.AccountSummary, error)
+	// GetAccountSummariesByDate returns the snapshots between the dates (inclusive, zero is open), oldest first.
+	GetAccountSummariesByDate(ctx context.Context, uid string, startDate time.Time, endDate time.Time) ([]*domain.AccountSummary, error)
+	// GetLatestAccountSummaries returns the snapshots of the most recent day.
+	GetLatestAccountSummaries(ctx context.Context, uid string) ([]*domain.AccountSummary, error)
+	// GetAccountValues returns the daily values between the dates (inclusive, zero is open), oldest first.
+	GetAccountValues(ctx context.Context, uid string, startDate time.Time, endDate time.Time) ([]*domain.AccountValue, error)
+	GetAccountCredential(ctx context.Context, uid string, id string) (*domain.AccountCredential, error)
+	GetAccountCredentials(ctx context.Context, uid string) ([]*domain.AccountCredential, error)
+	GetAccountSyncState(ctx context.Context, uid string, id string) (*domain.AccountSyncState, error)
+	GetAccountSyncStates(ctx context.Context, uid string) ([]*domain.AccountSyncState, error)
+	GetActivities(ctx context.Context, uid string) ([]*domain.Activity, error)
+	GetActivitiesForAccount(ctx context.Context, uid string, acctId string) ([]*domain.Activity, error)
+	GetActivityLots(ctx context.Context, uid string) ([]*domain.ActivityLot, error)
+	GetActivityLotsForAccount(ctx context.Context, uid string, acctId string) ([]*domain.ActivityLot, error)
+	QueryActivities(ctx context.Context, uid string, query domain.ActivityQuery) (domain.Paged[*domain.Activity], error)
+	QueryActivityLots(ctx context.Context, uid string, query domain.LotQuery) (domain.Paged[*domain.ActivityLot], error)
+	GetActivityMappingRules(ctx context.Context, uid string) (domain.ActivityMappingRules, error)
+	GetImortedActivities(ctx context.Context, uid string, acctId string) ([]*domain.ActivityImport, error)
+
+	SaveAccount(ctx context.Context, acct *domain.Account) error
+	SaveAccountCredential(ctx context.Context, acct *domain.AccountCredential) error
+	SaveAccountSyncState(ctx context.Context, acct *domain.AccountSyncState) error
+	SaveAccountSummaries(ctx context.Context, asumys []*domain.AccountSummary) error
+	SaveAccountValues(ctx context.Context, values []*domain.AccountValue) error
+	SaveImportedActivities(ctx context.Context, actvs []*domain.ActivityImport) error
+	SaveActivities(ctx context.Context, actvs []*domain.Activity) error
+	SaveActivityLots(ctx context.Context, lots []*domain.ActivityLot) error
+	SaveActivityMappingRule(ctx context.Context, rule *domain.ActivityMappingRule) error
+
+	//Audit
+	// SaveAuditEntry appends the entry — entries are never replaced or deleted.
+	SaveAuditEntry(ctx context.Context, entry *domain.AuditEntry) error
+	// GetAuditEntries returns the user's most recent entries first, optionally for one account.
+	GetAuditEntries(ctx context.Context, uid string, acctId string, limit int64) ([]*domain.AuditEntry, error)
+
+	//Deletion
+	GetDeletionReceipt(ctx context.Context, id string) (*domain.DeletionReceipt, error)
+	// GetDeletionReceiptsByStatus returns the receipts of every user in the status e.g running.
+	GetDeletionReceiptsByStatus(ctx context.Context, status string) ([]*domain.DeletionReceipt, error)
+	SaveDeletionReceipt(ctx context.Context, receipt *domain.DeletionReceipt) error
+
+	//Pipeline
+	GetPipelineRun(ctx context.Context, id string) (*domain.PipelineRun, error)
+	GetPipelineRuns(ctx context.Context, command string, limit int64) ([]*domain.PipelineRun, error)
+	SavePipelineRun(ctx context.Context, run *domain.PipelineRun) error
 
 	//Transaction
-	ImportTransactions(userId string, startDate time.Time, endDate time.Time, transactions []*domain.Transaction) error
-	SearchTransactions(userId string, startDate time.Time, endDate time.Time, searchText string) (domain.Transactions, error)
-	SummaryTransactions(userId string, startDate time.Time, endDate time.Time) ([]domain.TransactionAgg, error)
+	ImportTransactions(ctx context.Context, userId string, startDate time.Time, endDate time.Time, transactions []*domain.Transaction) error
+	SearchTransactions(ctx context.Context, userId string, startDate time.Time, endDate time.Time, searchText string) (domain.Transactions, error)
+	SummaryTransactions(ctx context.Context, userId string, startDate time.Time, endDate time.Time) ([]domain.TransactionAgg, error)
 
 	//User
-	GetUsers() []*domain.User
-	GetUser(id string) (*domain.User, error)
-	SaveUser(user *domain.User) error
+	LockStorage
+	// DeleteUser deletes the user record — deleting a missing user is not an error.
+	// Stores with foreign keys delete the user's other records with it.
+	DeleteUser(ctx context.Context, id string) error
+	GetUsers(ctx context.Context) ([]*domain.User, error)
+	GetUser(ctx context.Context, id string) (*domain.User, error)
+	SaveUser(ctx context.Context, user *domain.User) error
+}
+
+// LockStorage keeps the per-user leases.
+type LockStorage interface {
+	// CreateUserLock returns ErrAlreadyExists when the user is locked.
+	CreateUserLock(ctx context.Context, lock *domain.UserLock) error
+	// GetUserLock returns nil when the user is not locked.
+	GetUserLock(ctx context.Context, uid string) (*domain.UserLock, error)
+	// ReplaceUserLock swaps in lock only while the stored lock still has the owner and
+	// expiry of prev — a compare-and-swap. Returns ErrConflict when it changed or is gone.
+	ReplaceUserLock(ctx context.Context, prev *domain.UserLock, lock *domain.UserLock) error
+	DeleteUserLock(ctx context.Context, uid string) error
 }
 
 type TickerStorageService interface {
 
 	// Ticker
-	DeleteTicker(id string) error
-	GetTicker(id string) (*domain.Ticker, error)
-	GetTickerGroups() (domain.TickerGroups, error)
-	GetTickerEmbeddings(symbol string) ([]*domain.TickerEmbedding, error)
-	GetTickerHistory(symbol string) ([]*domain.TickerHistory, error)
-	GetTickerSentiments(symbol string) ([]*domain.TickerSentiment, error)
-	GetTickers(symbols []string) (domain.Tickers, error)
-	GetTickerPrice(symbol string) (decimal.Decimal, error)
-	SearchTicker(ts domain.TickerSearch) (domain.Tickers, error)
+	DeleteTicker(ctx context.Context, id string) error
+	GetTicker(ctx context.Context, id string) (*domain.Ticker, error)
+	GetTickerGroups(ctx context.Context) (domain.TickerGroups, error)
+	GetTickerEmbeddings(ctx context.Context, symbol string) ([]*domain.TickerEmbedding, error)
+	GetTickerHistory(ctx context.Context, symbol string) ([]*domain.TickerHistory, error)
+	GetTickerSentiments(ctx context.Context, symbol string) ([]*domain.TickerSentiment, error)
+	GetTickers(ctx context.Context, symbols []string) (domain.Tickers, error)
+	GetTickerPrice(ctx context.Context, symbol string) (decimal.Decimal, error)
+	SearchTicker(ctx context.Context, ts domain.TickerSearch) (domain.Tickers, error)
 }